@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"spotify-clone/backup"
+	"spotify-clone/database"
+)
+
+const backupUsage = `usage: spotify-clone backup <command>
+
+commands:
+  export FILE     write every table to a zip archive, "-" for standard output
+  restore FILE    load an archive into an empty database, keeping IDs`
+
+// runBackup implements "spotify-clone backup", which moves the whole database
+// between environments through a portable archive
+func runBackup(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%s", backupUsage)
+	}
+	command, path := args[0], args[1]
+	if command != "export" && command != "restore" {
+		return fmt.Errorf("unknown backup command %q\n\n%s", command, backupUsage)
+	}
+
+	// Restore migrates to the archive's version itself, so neither command
+	// migrates on connecting
+	if err := database.ConnectMySQL(); err != nil {
+		return err
+	}
+	defer database.Close()
+	ctx := context.Background()
+
+	if command == "export" {
+		return exportBackup(ctx, path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	manifest, err := backup.Restore(ctx, database.MySQL, file, info.Size())
+	if err != nil {
+		return err
+	}
+	rows := 0
+	for _, table := range manifest.Tables {
+		rows += table.Rows
+	}
+	fmt.Printf("restored %d rows in %d tables from schema version %d, exported %s\n",
+		rows, len(manifest.Tables), manifest.SchemaVersion, manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	return nil
+}
+
+// exportBackup writes to a temporary file renamed into place at the end, so a
+// failed export leaves no partial archive behind
+func exportBackup(ctx context.Context, path string) error {
+	var out io.Writer = os.Stdout
+	var tmp *os.File
+	if path != "-" {
+		var err error
+		tmp, err = os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		out = tmp
+	}
+
+	manifest, err := backup.Export(ctx, database.MySQL, out)
+	if err != nil {
+		return err
+	}
+	if tmp != nil {
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			return err
+		}
+	}
+
+	rows := 0
+	for _, table := range manifest.Tables {
+		rows += table.Rows
+	}
+	fmt.Fprintf(os.Stderr, "exported %d rows in %d tables at schema version %d\n", rows, len(manifest.Tables), manifest.SchemaVersion)
+	return nil
+}
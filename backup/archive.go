@@ -0,0 +1,310 @@
+package backup
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const manifestName = "manifest.json"
+
+// Manifest describes an archive. It is stored as manifest.json next to one
+// tables/NAME.jsonl file per table.
+type Manifest struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	// SchemaVersion is the migration version of the exported database
+	SchemaVersion int       `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Tables are in an order in which each table follows those it references
+	Tables []Table `json:"tables"`
+}
+
+// Table is an exported table. Each line of its file is a row as a JSON array
+// in the order of Columns.
+type Table struct {
+	Name    string   `json:"name"`
+	Columns []Column `json:"columns"`
+	Rows    int      `json:"rows"`
+	// SHA256 is the hex digest of the table file
+	SHA256 string `json:"sha256"`
+}
+
+// Column is a column of an exported table with its MySQL data type, such as
+// int, varchar or datetime
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func tableFile(name string) string {
+	return "tables/" + name + ".jsonl"
+}
+
+// archiveWriter writes the tables of an archive one after the other, then the
+// manifest
+type archiveWriter struct {
+	zip      *zip.Writer
+	manifest Manifest
+}
+
+func newArchiveWriter(w io.Writer, schemaVersion int) *archiveWriter {
+	return &archiveWriter{
+		zip: zip.NewWriter(w),
+		manifest: Manifest{
+			Format:        Format,
+			Version:       FormatVersion,
+			SchemaVersion: schemaVersion,
+			CreatedAt:     time.Now().UTC(),
+			Tables:        []Table{},
+		},
+	}
+}
+
+// writeTable writes the rows next returns until it returns io.EOF. Rows hold
+// the values scanned from MySQL.
+func (a *archiveWriter) writeTable(name string, columns []Column, next func() ([]interface{}, error)) error {
+	entry, err := a.zip.Create(tableFile(name))
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	encoder := json.NewEncoder(io.MultiWriter(entry, hash))
+
+	table := Table{Name: name, Columns: columns}
+	for {
+		values, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", name, err)
+		}
+		row := make([]interface{}, len(values))
+		for i, value := range values {
+			if row[i], err = encodeValue(columns[i].Type, value); err != nil {
+				return fmt.Errorf("%s.%s: %v", name, columns[i].Name, err)
+			}
+		}
+		if err := encoder.Encode(row); err != nil {
+			return err
+		}
+		table.Rows++
+	}
+
+	table.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	a.manifest.Tables = append(a.manifest.Tables, table)
+	return nil
+}
+
+// close writes the manifest and finishes the zip file
+func (a *archiveWriter) close() (*Manifest, error) {
+	entry, err := a.zip.Create(manifestName)
+	if err != nil {
+		return nil, err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(a.manifest); err != nil {
+		return nil, err
+	}
+	if err := a.zip.Close(); err != nil {
+		return nil, err
+	}
+	return &a.manifest, nil
+}
+
+// archive is an archive opened for restoring
+type archive struct {
+	manifest Manifest
+	files    map[string]*zip.File
+}
+
+// openArchive reads and checks the manifest of an archive
+func openArchive(r io.ReaderAt, size int64) (*archive, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a backup archive: %v", err)
+	}
+	a := &archive{files: map[string]*zip.File{}}
+	for _, file := range reader.File {
+		a.files[file.Name] = file
+	}
+
+	file, ok := a.files[manifestName]
+	if !ok {
+		return nil, fmt.Errorf("not a backup archive: %s is missing", manifestName)
+	}
+	data, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+	if err := json.NewDecoder(data).Decode(&a.manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", manifestName, err)
+	}
+
+	if a.manifest.Format != Format {
+		return nil, fmt.Errorf("not a backup archive: format is %q", a.manifest.Format)
+	}
+	if a.manifest.Version < 1 || a.manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("archive format version %d is not supported (this build reads up to %d)", a.manifest.Version, FormatVersion)
+	}
+	seen := map[string]bool{}
+	for _, table := range a.manifest.Tables {
+		if seen[table.Name] {
+			return nil, fmt.Errorf("table %s appears twice in %s", table.Name, manifestName)
+		}
+		seen[table.Name] = true
+		if _, ok := a.files[tableFile(table.Name)]; !ok {
+			return nil, fmt.Errorf("%s is missing", tableFile(table.Name))
+		}
+	}
+	return a, nil
+}
+
+func (a *archive) table(name string) (Table, bool) {
+	for _, table := range a.manifest.Tables {
+		if table.Name == name {
+			return table, true
+		}
+	}
+	return Table{}, false
+}
+
+// readTable calls fn with each row of a table, decoded for binding, and checks
+// the row count and checksum of the manifest at the end. Since they are only
+// known once every row was read, restores run in a transaction.
+func (a *archive) readTable(table Table, fn func([]interface{}) error) error {
+	file, err := a.files[tableFile(table.Name)].Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	tee := io.TeeReader(file, hash)
+	decoder := json.NewDecoder(tee)
+	decoder.UseNumber()
+
+	rows := 0
+	for {
+		var row []interface{}
+		if err := decoder.Decode(&row); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%s row %d: %v", table.Name, rows+1, err)
+		}
+		rows++
+		if len(row) != len(table.Columns) {
+			return fmt.Errorf("%s row %d has %d values for %d columns", table.Name, rows, len(row), len(table.Columns))
+		}
+		for i, value := range row {
+			if row[i], err = decodeValue(table.Columns[i].Type, value); err != nil {
+				return fmt.Errorf("%s row %d, %s: %v", table.Name, rows, table.Columns[i].Name, err)
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return err
+	}
+
+	if rows != table.Rows {
+		return fmt.Errorf("%s has %d rows, the manifest says %d", table.Name, rows, table.Rows)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != table.SHA256 {
+		return fmt.Errorf("%s does not match its checksum", tableFile(table.Name))
+	}
+	return nil
+}
+
+// isBinary reports whether a MySQL data type holds bytes rather than text
+func isBinary(dataType string) bool {
+	switch strings.ToLower(dataType) {
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob", "bit":
+		return true
+	}
+	return false
+}
+
+func isNumeric(dataType string) bool {
+	switch strings.ToLower(dataType) {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint",
+		"decimal", "numeric", "float", "double", "real":
+		return true
+	}
+	return false
+}
+
+// encodeValue turns a value scanned from MySQL into JSON: numbers as numbers,
+// dates and times as MySQL literals in UTC, binary data in base64 and
+// everything else as strings
+func encodeValue(dataType string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case int64, float64, bool:
+		return v, nil
+	case time.Time:
+		if strings.EqualFold(dataType, "date") {
+			return v.UTC().Format("2006-01-02"), nil
+		}
+		return v.UTC().Format("2006-01-02 15:04:05.999999"), nil
+	case []byte:
+		return encodeBytes(dataType, v)
+	case string:
+		return encodeBytes(dataType, []byte(v))
+	}
+	return nil, fmt.Errorf("unexpected %T value", value)
+}
+
+func encodeBytes(dataType string, data []byte) (interface{}, error) {
+	switch {
+	case isBinary(dataType):
+		return base64.StdEncoding.EncodeToString(data), nil
+	case isNumeric(dataType):
+		number := json.Number(data)
+		if _, err := number.Float64(); err != nil {
+			return nil, fmt.Errorf("invalid number %q", data)
+		}
+		return number, nil
+	}
+	return string(data), nil
+}
+
+// decodeValue turns a JSON value of an archive back into a value to bind
+func decodeValue(dataType string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case json.Number:
+		if !isNumeric(dataType) {
+			return nil, fmt.Errorf("number for a %s column", dataType)
+		}
+		return v.String(), nil
+	case bool:
+		return v, nil
+	case string:
+		if isBinary(dataType) {
+			data, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64: %v", err)
+			}
+			return data, nil
+		}
+		if isNumeric(dataType) {
+			return nil, fmt.Errorf("string for a %s column", dataType)
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("unexpected JSON value %v", value)
+}
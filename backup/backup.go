@@ -0,0 +1,421 @@
+// Package backup exports every table of the MySQL database to a portable
+// archive and restores one into an empty database, keeping IDs and foreign
+// keys. An archive is a zip file holding manifest.json and a JSON Lines file
+// per table, so it needs neither mysqldump nor a particular MySQL version,
+// only a build whose migrations reach the archive's schema version.
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"spotify-clone/migrations"
+	"strings"
+)
+
+const (
+	// Format names the archive format in the manifest
+	Format = "spotify-clone-backup"
+	// FormatVersion is the version of the archive layout Export writes
+	FormatVersion = 1
+)
+
+// insertBatchRows is how many rows a restore inserts per statement
+const insertBatchRows = 200
+
+// derivedTables are maintained by triggers as rows are restored. Their
+// archived rows replace what the triggers wrote once all other tables are in.
+var derivedTables = map[string]bool{"album_stats": true, "track_stats": true}
+
+// skippedTables are not exported: the target records its own migrations
+var skippedTables = map[string]bool{"schema_migrations": true}
+
+// tableSchema is a table of the connected database
+type tableSchema struct {
+	name       string
+	columns    []Column
+	primaryKey []string
+	references []string // tables its foreign keys point to
+}
+
+// queryer is satisfied by *sql.Conn and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Export writes every table to w as an archive. It reads from one
+// transaction, so the archive is a consistent snapshot even while the server
+// is writing.
+func Export(ctx context.Context, db *sql.DB, w io.Writer) (*Manifest, error) {
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return nil, err
+	}
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := utcConn(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tables, err := loadSchema(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := newArchiveWriter(w, version)
+	for _, table := range tables {
+		if err := exportTable(ctx, tx, archive, table); err != nil {
+			return nil, err
+		}
+	}
+	return archive.close()
+}
+
+func exportTable(ctx context.Context, tx *sql.Tx, archive *archiveWriter, table tableSchema) error {
+	names := make([]string, len(table.columns))
+	for i, column := range table.columns {
+		names[i] = quote(column.Name)
+	}
+	query := "SELECT " + strings.Join(names, ", ") + " FROM " + quote(table.name)
+	if len(table.primaryKey) > 0 {
+		keys := make([]string, len(table.primaryKey))
+		for i, key := range table.primaryKey {
+			keys[i] = quote(key)
+		}
+		query += " ORDER BY " + strings.Join(keys, ", ")
+	}
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", table.name, err)
+	}
+	defer rows.Close()
+
+	values := make([]interface{}, len(table.columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	return archive.writeTable(table.name, table.columns, func() ([]interface{}, error) {
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		return values, nil
+	})
+}
+
+// Restore loads an archive into a database without data. It migrates the
+// database to the archive's schema version, inserts every row with its
+// original ID in one transaction, then applies the migrations that came
+// after the archive was made.
+func Restore(ctx context.Context, db *sql.DB, r io.ReaderAt, size int64) (*Manifest, error) {
+	archive, err := openArchive(r, size)
+	if err != nil {
+		return nil, err
+	}
+	manifest := archive.manifest
+	if manifest.SchemaVersion < 1 {
+		return nil, fmt.Errorf("the archive has no schema version")
+	}
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.SchemaVersion > migrator.Latest() {
+		return nil, fmt.Errorf("the archive is at schema version %d, newer than this build's %d", manifest.SchemaVersion, migrator.Latest())
+	}
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version > manifest.SchemaVersion {
+		return nil, fmt.Errorf("the database is at schema version %d, past the archive's %d; restore into a database migrated no further than the archive", version, manifest.SchemaVersion)
+	}
+	if _, err := migrator.Up(ctx, manifest.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	if err := restoreTables(ctx, db, archive); err != nil {
+		return nil, err
+	}
+
+	if _, err := migrator.Up(ctx, 0); err != nil {
+		return nil, fmt.Errorf("the archive was restored, but migrating past it failed: %v", err)
+	}
+	return &manifest, nil
+}
+
+func restoreTables(ctx context.Context, db *sql.DB, archive *archive) error {
+	conn, err := utcConn(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tables, err := loadSchema(ctx, tx)
+	if err != nil {
+		return err
+	}
+	present := map[string]tableSchema{}
+	for _, table := range tables {
+		present[table.name] = table
+	}
+	for _, table := range archive.manifest.Tables {
+		target, ok := present[table.Name]
+		if !ok {
+			return fmt.Errorf("table %s of the archive is not in the database", table.Name)
+		}
+		if err := checkColumns(table, target); err != nil {
+			return err
+		}
+	}
+
+	var notEmpty []string
+	for _, table := range tables {
+		if derivedTables[table.name] {
+			continue
+		}
+		var exists bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM "+quote(table.name)+")").Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			notEmpty = append(notEmpty, table.name)
+		}
+	}
+	if len(notEmpty) > 0 {
+		return fmt.Errorf("the database is not empty: %s have rows", strings.Join(notEmpty, ", "))
+	}
+
+	// Parents before children, so every foreign key is checked as rows go in;
+	// the tables triggers fill are replaced last
+	ordered := append([]tableSchema(nil), tables...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return !derivedTables[ordered[i].name] && derivedTables[ordered[j].name]
+	})
+	for _, target := range ordered {
+		table, ok := archive.table(target.name)
+		if !ok {
+			continue
+		}
+		if derivedTables[table.Name] {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+quote(table.Name)); err != nil {
+				return fmt.Errorf("error clearing %s: %v", table.Name, err)
+			}
+		}
+		if err := restoreTable(ctx, tx, archive, table); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// checkColumns makes sure every archived column exists in the target table.
+// Columns the archive lacks take their defaults.
+func checkColumns(table Table, target tableSchema) error {
+	present := map[string]bool{}
+	for _, column := range target.columns {
+		present[column.Name] = true
+	}
+	for _, column := range table.Columns {
+		if !present[column.Name] {
+			return fmt.Errorf("column %s.%s of the archive is not in the database", table.Name, column.Name)
+		}
+	}
+	return nil
+}
+
+func restoreTable(ctx context.Context, tx *sql.Tx, archive *archive, table Table) error {
+	if len(table.Columns) == 0 {
+		return nil
+	}
+	names := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		names[i] = quote(column.Name)
+	}
+	rowPlaceholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ") + ")"
+	insert := "INSERT INTO " + quote(table.Name) + " (" + strings.Join(names, ", ") + ") VALUES "
+
+	var batch []interface{}
+	rows := 0
+	flush := func() error {
+		if rows == 0 {
+			return nil
+		}
+		query := insert + strings.TrimSuffix(strings.Repeat(rowPlaceholders+", ", rows), ", ")
+		if _, err := tx.ExecContext(ctx, query, batch...); err != nil {
+			return fmt.Errorf("error restoring %s: %v", table.Name, err)
+		}
+		batch, rows = batch[:0], 0
+		return nil
+	}
+
+	err := archive.readTable(table, func(values []interface{}) error {
+		batch = append(batch, values...)
+		rows++
+		if rows == insertBatchRows {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// utcConn returns a connection whose session time zone is UTC, so TIMESTAMP
+// columns read and write the same instants whatever the server's zone
+func utcConn(ctx context.Context, db *sql.DB) (*sql.Conn, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "SET time_zone = '+00:00'"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// loadSchema lists the base tables of the database with their columns, parents
+// first
+func loadSchema(ctx context.Context, q queryer) ([]tableSchema, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT c.TABLE_NAME, c.COLUMN_NAME, c.DATA_TYPE, c.COLUMN_KEY
+		FROM information_schema.COLUMNS c
+		JOIN information_schema.TABLES t
+			ON t.TABLE_SCHEMA = c.TABLE_SCHEMA AND t.TABLE_NAME = c.TABLE_NAME
+		WHERE c.TABLE_SCHEMA = DATABASE()
+			AND t.TABLE_TYPE = 'BASE TABLE'
+			AND c.EXTRA NOT LIKE '%GENERATED%'
+		ORDER BY c.TABLE_NAME, c.ORDINAL_POSITION`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading columns: %v", err)
+	}
+	defer rows.Close()
+
+	byName := map[string]*tableSchema{}
+	var names []string
+	for rows.Next() {
+		var tableName, key string
+		var column Column
+		if err := rows.Scan(&tableName, &column.Name, &column.Type, &key); err != nil {
+			return nil, err
+		}
+		if skippedTables[tableName] {
+			continue
+		}
+		table, ok := byName[tableName]
+		if !ok {
+			table = &tableSchema{name: tableName}
+			byName[tableName] = table
+			names = append(names, tableName)
+		}
+		table.columns = append(table.columns, column)
+		if key == "PRI" {
+			table.primaryKey = append(table.primaryKey, column.Name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	refs, err := q.QueryContext(ctx, `
+		SELECT DISTINCT TABLE_NAME, REFERENCED_TABLE_NAME
+		FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = DATABASE() AND REFERENCED_TABLE_SCHEMA = DATABASE()`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading foreign keys: %v", err)
+	}
+	defer refs.Close()
+	for refs.Next() {
+		var tableName, referenced string
+		if err := refs.Scan(&tableName, &referenced); err != nil {
+			return nil, err
+		}
+		if table, ok := byName[tableName]; ok {
+			table.references = append(table.references, referenced)
+		}
+	}
+	if err := refs.Err(); err != nil {
+		return nil, err
+	}
+
+	tables := make([]tableSchema, 0, len(names))
+	for _, name := range names {
+		tables = append(tables, *byName[name])
+	}
+	return orderTables(tables)
+}
+
+// orderTables sorts tables so each comes after the tables it references,
+// alphabetically among those that are free to go. A table referencing itself
+// is restored in primary key order, which suits rows pointing at older ones.
+func orderTables(tables []tableSchema) ([]tableSchema, error) {
+	remaining := map[string]tableSchema{}
+	for _, table := range tables {
+		remaining[table.name] = table
+	}
+
+	ordered := make([]tableSchema, 0, len(tables))
+	for len(remaining) > 0 {
+		var ready []string
+		for name, table := range remaining {
+			blocked := false
+			for _, ref := range table.references {
+				if _, pending := remaining[ref]; pending && ref != name {
+					blocked = true
+					break
+				}
+			}
+			if !blocked {
+				ready = append(ready, name)
+			}
+		}
+		if len(ready) == 0 {
+			var cycle []string
+			for name := range remaining {
+				cycle = append(cycle, name)
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("foreign keys between %s form a cycle", strings.Join(cycle, ", "))
+		}
+		sort.Strings(ready)
+		for _, name := range ready {
+			ordered = append(ordered, remaining[name])
+			delete(remaining, name)
+		}
+	}
+	return ordered, nil
+}
+
+// quote quotes a MySQL identifier
+func quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
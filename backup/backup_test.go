@@ -0,0 +1,185 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOrderTables(t *testing.T) {
+	tables := []tableSchema{
+		{name: "plays", references: []string{"tracks", "users"}},
+		{name: "tracks", references: []string{"albums", "artists"}},
+		{name: "users"},
+		{name: "albums", references: []string{"artists"}},
+		{name: "artists"},
+		{name: "comments", references: []string{"comments", "users"}},
+	}
+	ordered, err := orderTables(tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, table := range ordered {
+		names = append(names, table.name)
+	}
+	want := []string{"artists", "users", "albums", "comments", "tracks", "plays"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("order = %v, want %v", names, want)
+	}
+
+	_, err = orderTables([]tableSchema{
+		{name: "a", references: []string{"b"}},
+		{name: "b", references: []string{"a"}},
+		{name: "c"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "a, b form a cycle") {
+		t.Errorf("cycle error = %v", err)
+	}
+}
+
+// writeArchive exports rows as scanned from MySQL with parseTime
+func writeArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := newArchiveWriter(&buf, 3)
+
+	columns := []Column{{"id", "int"}, {"name", "varchar"}, {"born", "date"}, {"joined", "timestamp"}, {"score", "decimal"}, {"avatar", "blob"}, {"bio", "text"}}
+	rows := [][]interface{}{
+		{[]byte("1"), []byte("Ana"), time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC), []byte("12.50"), []byte{0, 1, 255}, nil},
+		{int64(2), "Bo \"B\"", nil, time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC), []byte("-3"), nil, []byte("line\nbreak")},
+	}
+	next := 0
+	err := archive.writeTable("users", columns, func() ([]interface{}, error) {
+		if next == len(rows) {
+			return nil, io.EOF
+		}
+		next++
+		return rows[next-1], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.writeTable("empty", []Column{{"id", "int"}}, func() ([]interface{}, error) { return nil, io.EOF }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archive.close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	data := writeArchive(t)
+	archive, err := openArchive(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive.manifest.Format != Format || archive.manifest.Version != FormatVersion || archive.manifest.SchemaVersion != 3 || len(archive.manifest.Tables) != 2 {
+		t.Fatalf("manifest = %+v", archive.manifest)
+	}
+
+	table, ok := archive.table("users")
+	if !ok || table.Rows != 2 {
+		t.Fatalf("users = %+v, %v", table, ok)
+	}
+	var got [][]interface{}
+	err = archive.readTable(table, func(values []interface{}) error {
+		got = append(got, append([]interface{}(nil), values...))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{
+		{"1", "Ana", "1990-05-17", "2024-01-02 03:04:05.6", "12.50", []byte{0, 1, 255}, nil},
+		{"2", `Bo "B"`, nil, "2024-02-03 04:05:06", "-3", nil, "line\nbreak"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %#v, want %#v", got, want)
+	}
+
+	empty, _ := archive.table("empty")
+	if err := archive.readTable(empty, func([]interface{}) error { t.Error("row in an empty table"); return nil }); err != nil {
+		t.Error(err)
+	}
+}
+
+// rewriteArchive copies an archive, passing each file through edit
+func rewriteArchive(t *testing.T, data []byte, edit func(name string, content []byte) []byte) []byte {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, file := range reader.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(r)
+		r.Close()
+		w, _ := writer.Create(file.Name)
+		w.Write(edit(file.Name, content))
+	}
+	writer.Close()
+	return buf.Bytes()
+}
+
+func TestArchiveChecks(t *testing.T) {
+	data := writeArchive(t)
+
+	// A table file edited after export fails its checksum
+	tampered := rewriteArchive(t, data, func(name string, content []byte) []byte {
+		if name == "tables/users.jsonl" {
+			return bytes.Replace(content, []byte("Ana"), []byte("Eve"), 1)
+		}
+		return content
+	})
+	archive, err := openArchive(bytes.NewReader(tampered), int64(len(tampered)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, _ := archive.table("users")
+	if err := archive.readTable(users, func([]interface{}) error { return nil }); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("readTable(tampered) error = %v, want a checksum error", err)
+	}
+
+	// Archives from a newer format are refused
+	newer := rewriteArchive(t, data, func(name string, content []byte) []byte {
+		if name != manifestName {
+			return content
+		}
+		var manifest map[string]interface{}
+		json.Unmarshal(content, &manifest)
+		manifest["version"] = FormatVersion + 1
+		content, _ = json.Marshal(manifest)
+		return content
+	})
+	if _, err := openArchive(bytes.NewReader(newer), int64(len(newer))); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("openArchive(newer) error = %v", err)
+	}
+
+	if _, err := openArchive(strings.NewReader("not a zip"), 9); err == nil {
+		t.Error("openArchive accepted a file that is not a zip")
+	}
+}
+
+func TestDecodeValueTypes(t *testing.T) {
+	if _, err := decodeValue("int", "12"); err == nil {
+		t.Error("decodeValue accepted a string for an int column")
+	}
+	if _, err := decodeValue("varchar", json.Number("12")); err == nil {
+		t.Error("decodeValue accepted a number for a varchar column")
+	}
+	if _, err := decodeValue("blob", "%%%"); err == nil {
+		t.Error("decodeValue accepted invalid base64")
+	}
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"spotify-clone/migrations"
+	"strings"
+	"sync"
+)
+
+// Health is the state of the schema the API relies on, checked at startup
+type Health struct {
+	SchemaVersion int               `json:"schema_version"`
+	Degraded      []DegradedFeature `json:"degraded"`
+}
+
+// DegradedFeature is a feature whose tables or routines are missing or
+// changed, with the endpoints that will fail
+type DegradedFeature struct {
+	Feature   string   `json:"feature"`
+	Endpoints []string `json:"endpoints"`
+	Problems  []string `json:"problems"`
+}
+
+// feature names the database objects an API feature cannot work without, as
+// "kind name" in lower case
+type feature struct {
+	name      string
+	endpoints []string
+	needs     []string
+}
+
+// features lists the endpoints built on triggers and routines. Problems with
+// objects no feature lists degrade "core", which every endpoint relies on.
+var features = []feature{
+	{"track_validation", []string{"POST /api/v1/tracks/add"},
+		[]string{"procedure add_track", "trigger after_track_insert", "table album_stats", "table track_stats"}},
+	{"artist_stats", []string{"GET /api/v1/artists/:id/stats"},
+		[]string{"procedure get_artist_stats", "table track_stats"}},
+	{"album_stats", []string{"GET /api/v1/albums/:id/stats"},
+		[]string{"table album_stats", "trigger after_track_insert", "trigger after_track_delete", "trigger after_track_update"}},
+	{"album_duration", []string{"GET /api/v1/albums/:id/duration"},
+		[]string{"function get_album_duration"}},
+	{"play_counts", []string{"POST /api/v1/tracks/:id/play", "GET /api/v1/recommendations/trending"},
+		[]string{"trigger after_play_insert", "table track_stats", "column track_stats.recommendable_play_count"}},
+	{"account_deletion", []string{"DELETE /api/v1/me", "POST /api/v1/me/restore"},
+		[]string{"table account_deletions"}},
+}
+
+var (
+	healthMu sync.RWMutex
+	health   *Health
+)
+
+// SchemaHealth returns the result of the startup schema check, or nil when the
+// server runs without MySQL
+func SchemaHealth() *Health {
+	healthMu.RLock()
+	defer healthMu.RUnlock()
+	return health
+}
+
+// checkSchema verifies that every table and routine the migrations create is
+// in the database, and records which features are degraded. With
+// SCHEMA_CHECK=strict a degraded feature is an error, so the server refuses to
+// start.
+func checkSchema(migrator *migrations.Migrator) error {
+	ctx := context.Background()
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	drifts, err := migrator.Verify(ctx)
+	if err != nil {
+		return err
+	}
+
+	version := 0
+	for _, status := range statuses {
+		if status.Applied && status.Version > version {
+			version = status.Version
+		}
+	}
+	result := assessHealth(version, drifts)
+
+	healthMu.Lock()
+	health = result
+	healthMu.Unlock()
+
+	if len(result.Degraded) > 0 && os.Getenv("SCHEMA_CHECK") == "strict" {
+		var names []string
+		for _, degraded := range result.Degraded {
+			names = append(names, degraded.Feature)
+		}
+		return fmt.Errorf("degraded features with SCHEMA_CHECK=strict: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// assessHealth maps schema drift onto the features it breaks. Routines whose
+// definition cannot be read are not counted against any feature.
+func assessHealth(version int, drifts []migrations.Drift) *Health {
+	problems := map[string][]string{}
+	for _, drift := range drifts {
+		if drift.Problem == "unreadable" {
+			continue
+		}
+
+		object := strings.ToLower(drift.Kind) + " " + strings.ToLower(drift.Name)
+		claimed := false
+		for _, f := range features {
+			for _, need := range f.needs {
+				if need == object {
+					problems[f.name] = append(problems[f.name], drift.String())
+					claimed = true
+				}
+			}
+		}
+		if !claimed {
+			problems["core"] = append(problems["core"], drift.String())
+		}
+	}
+
+	result := &Health{SchemaVersion: version, Degraded: []DegradedFeature{}}
+	if len(problems["core"]) > 0 {
+		result.Degraded = append(result.Degraded, DegradedFeature{
+			Feature:   "core",
+			Endpoints: []string{"/api/v1/*"},
+			Problems:  problems["core"],
+		})
+	}
+	for _, f := range features {
+		if len(problems[f.name]) > 0 {
+			result.Degraded = append(result.Degraded, DegradedFeature{
+				Feature:   f.name,
+				Endpoints: f.endpoints,
+				Problems:  problems[f.name],
+			})
+		}
+	}
+	for _, degraded := range result.Degraded {
+		sort.Strings(degraded.Problems)
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"reflect"
+	"spotify-clone/migrations"
+	"strings"
+	"testing"
+)
+
+func drift(kind, name, problem string) migrations.Drift {
+	return migrations.Drift{Object: migrations.Object{Kind: kind, Name: name}, Problem: problem}
+}
+
+func TestAssessHealthHealthy(t *testing.T) {
+	health := assessHealth(2, nil)
+	if health.SchemaVersion != 2 || health.Degraded == nil || len(health.Degraded) != 0 {
+		t.Fatalf("health = %+v, want version 2 and an empty degraded list", health)
+	}
+}
+
+func TestAssessHealthMapsDriftToFeatures(t *testing.T) {
+	health := assessHealth(2, []migrations.Drift{
+		drift("PROCEDURE", "add_track", "missing"),
+		drift("TABLE", "track_stats", "missing"),
+		drift("TABLE", "playlists", "missing"),
+		drift("FUNCTION", "get_album_duration", "unreadable"),
+	})
+
+	got := map[string][]string{}
+	for _, degraded := range health.Degraded {
+		got[degraded.Feature] = degraded.Problems
+	}
+	want := map[string][]string{
+		"core":             {"table playlists is missing"},
+		"track_validation": {"procedure add_track is missing", "table track_stats is missing"},
+		"artist_stats":     {"table track_stats is missing"},
+		"play_counts":      {"table track_stats is missing"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("degraded = %v, want %v", got, want)
+	}
+	if health.Degraded[0].Feature != "core" {
+		t.Errorf("first degraded feature = %s, want core", health.Degraded[0].Feature)
+	}
+}
+
+func TestFeaturesNeedKnownObjects(t *testing.T) {
+	all, err := migrations.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects, err := migrations.Objects(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	known := map[string]bool{}
+	for _, object := range objects {
+		known[strings.ToLower(object.Kind)+" "+object.Name] = true
+	}
+	for _, f := range features {
+		for _, need := range f.needs {
+			if !known[need] {
+				t.Errorf("feature %s needs %s, which no migration creates", f.name, need)
+			}
+		}
+	}
+}
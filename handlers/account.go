@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"spotify-clone/media"
+	"spotify-clone/models"
+	"spotify-clone/store"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// defaultDeletionGrace is how long a deleted account can still be restored
+// when ACCOUNT_DELETION_GRACE is not set
+const defaultDeletionGrace = 30 * 24 * time.Hour
+
+// accountDeletionGrace reads ACCOUNT_DELETION_GRACE, a Go duration such as
+// "720h". Zero deletes accounts right away.
+func accountDeletionGrace() time.Duration {
+	value := os.Getenv("ACCOUNT_DELETION_GRACE")
+	if value == "" {
+		return defaultDeletionGrace
+	}
+	grace, err := time.ParseDuration(value)
+	if err != nil || grace < 0 {
+		log.Printf("⚠️  Warning: invalid ACCOUNT_DELETION_GRACE %q, using %s", value, defaultDeletionGrace)
+		return defaultDeletionGrace
+	}
+	return grace
+}
+
+// ExportMyData downloads everything stored about the authenticated user as a
+// zip of JSON files: profile.json, playlists.json, favorites.json,
+// listening_history.json, activity.json, radio.json, player.json and
+// sessions.json
+// GET /api/v1/me/export
+func (h *Handler) ExportMyData(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := h.stores.Users.Get(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	playlists, err := h.exportPlaylists(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export playlists"})
+		return
+	}
+	favorites, err := h.exportFavorites(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export favorites"})
+		return
+	}
+	history, err := h.stores.Plays.History(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export listening history"})
+		return
+	}
+	skips, err := h.stores.Radio.Skips(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export listening history"})
+		return
+	}
+	activities, err := h.stores.Social.Activities(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export activity"})
+		return
+	}
+	stations, err := h.stores.Radio.Stations(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export radio stations"})
+		return
+	}
+	state, err := h.stores.Player.State(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export playback state"})
+		return
+	}
+	queue, err := h.stores.Player.Queue(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export playback state"})
+		return
+	}
+	sessions, err := h.stores.Sessions.Memberships(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export listening sessions"})
+		return
+	}
+
+	privacy, err := h.getPrivacySettings(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export privacy settings"})
+		return
+	}
+
+	profile := gin.H{
+		"exported_at": time.Now().UTC(),
+		"user":        user,
+		"privacy":     privacy,
+	}
+	if deletion, err := h.stores.Accounts.Deletion(user.ID); err == nil {
+		profile["deletion"] = deletion
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", profile},
+		{"playlists.json", gin.H{"playlists": playlists}},
+		{"favorites.json", favorites},
+		{"listening_history.json", gin.H{"plays": history, "skips": skips}},
+		{"activity.json", gin.H{"activities": activities}},
+		{"radio.json", gin.H{"stations": stations}},
+		{"player.json", gin.H{"state": state, "queue": queue}},
+		{"sessions.json", gin.H{"sessions": sessions}},
+	}
+	for _, file := range files {
+		entry, err := archive.Create(file.name)
+		if err == nil {
+			encoder := json.NewEncoder(entry)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(file.content)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build export"})
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build export"})
+		return
+	}
+
+	filename := strings.Trim(unsafeFilenameChars.ReplaceAllString(user.Username, "_"), "_")
+	if filename == "" {
+		filename = "user"
+	}
+	filename += "-data-" + time.Now().UTC().Format("20060102") + ".zip"
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// exportPlaylists returns the playlists a user owns, collaborates on or follows,
+// each with its tracks
+func (h *Handler) exportPlaylists(userID int) ([]models.ExportedPlaylist, error) {
+	playlists, err := h.stores.Playlists.ListForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	exported := []models.ExportedPlaylist{}
+	for _, playlist := range playlists {
+		tracks, err := h.getPlaylistTracks(playlist.ID, playlist.UserID, userID, playlist.IsSmart)
+		if err != nil {
+			return nil, err
+		}
+		role := "collaborator"
+		if playlist.UserID == userID {
+			role = "owner"
+		} else if playlist.IsFollowing {
+			role = "follower"
+		}
+		exported = append(exported, models.ExportedPlaylist{Playlist: playlist, Role: role, Tracks: tracks})
+	}
+	return exported, nil
+}
+
+// exportFavorites returns the favorite genres and artists, the saved tracks
+// and albums, and the followed and following users, each oldest first
+func (h *Handler) exportFavorites(user *models.User) (gin.H, error) {
+	artists, err := h.stores.Users.FavoriteArtists(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	all := store.LibraryFilter{Sort: "recent", Limit: -1}
+	tracks, err := h.stores.Library.Tracks(user.ID, all)
+	if err != nil {
+		return nil, err
+	}
+	savedTracks := make([]models.ExportedSavedItem, len(tracks))
+	for i, track := range tracks {
+		savedTracks[len(tracks)-1-i] = models.ExportedSavedItem{
+			ID: track.ID, Title: track.Title, Artist: track.ArtistName, SavedAt: track.SavedAt,
+		}
+	}
+
+	albums, err := h.stores.Library.Albums(user.ID, all)
+	if err != nil {
+		return nil, err
+	}
+	savedAlbums := make([]models.ExportedSavedItem, len(albums))
+	for i, album := range albums {
+		savedAlbums[len(albums)-1-i] = models.ExportedSavedItem{
+			ID: album.ID, Title: album.Title, Artist: album.ArtistName, SavedAt: album.SavedAt,
+		}
+	}
+
+	following, _, err := h.stores.Social.Following(user.ID, -1, 0)
+	if err != nil {
+		return nil, err
+	}
+	followers, _, err := h.stores.Social.Followers(user.ID, -1, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, users := range [][]models.UserSummary{following, followers} {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+
+	genres := user.FavoriteGenres
+	if genres == nil {
+		genres = []string{}
+	}
+	return gin.H{
+		"favorite_genres":  genres,
+		"favorite_artists": artists,
+		"saved_tracks":     savedTracks,
+		"saved_albums":     savedAlbums,
+		"following":        following,
+		"followers":        followers,
+	}, nil
+}
+
+// DeleteAccount schedules the deletion of the authenticated user's account. The
+// account keeps working during the grace period so it can be restored; after
+// it the purge deletes the account and anonymizes its listening history.
+// DELETE /api/v1/me
+func (h *Handler) DeleteAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get leaves out the password hash, GetByEmail does not
+	user, err := h.stores.Users.Get(userID.(int))
+	if err == nil {
+		user, err = h.stores.Users.GetByEmail(user.Email)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Incorrect password"})
+		return
+	}
+
+	grace := accountDeletionGrace()
+	if grace == 0 {
+		if err := h.purgeAccount(user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+		return
+	}
+
+	// A repeated request keeps the original schedule
+	deletion, err := h.stores.Accounts.ScheduleDeletion(user.ID, grace)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule account deletion"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "Account scheduled for deletion",
+		"deletion": deletion,
+	})
+}
+
+// RestoreAccount cancels a pending deletion of the authenticated user's account
+// POST /api/v1/me/restore
+func (h *Handler) RestoreAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.stores.Accounts.CancelDeletion(userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account is not scheduled for deletion"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account restored"})
+}
+
+// PurgeDeletedAccounts deletes the accounts whose grace period has ended and
+// returns how many it deleted. An account that fails is logged and retried on
+// the next run; it does not hold up the others.
+func (h *Handler) PurgeDeletedAccounts() (int, error) {
+	userIDs, err := h.stores.Accounts.DueDeletions()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range userIDs {
+		if err := h.purgeAccount(id); err != nil {
+			log.Printf("⚠️  Warning: could not purge account %d: %v", id, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// StartAccountPurge runs PurgeDeletedAccounts now and then at every interval
+func (h *Handler) StartAccountPurge(interval time.Duration) {
+	go func() {
+		for {
+			if purged, err := h.PurgeDeletedAccounts(); err != nil {
+				log.Printf("⚠️  Warning: could not purge deleted accounts: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d deleted account(s)", purged)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// purgeAccount deletes a user for good and removes the cover files of their
+// playlists once the rows are gone
+func (h *Handler) purgeAccount(userID int) error {
+	covers, err := h.stores.Accounts.Purge(userID)
+	if err != nil {
+		return err
+	}
+	for _, name := range covers {
+		media.Remove(name)
+	}
+	return nil
+}
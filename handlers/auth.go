@@ -2,8 +2,8 @@ package handlers
 
 import (
 	"net/http"
-	"spotify-clone/database"
 	"spotify-clone/models"
+	"spotify-clone/store"
 	"spotify-clone/utils"
 	"time"
 
@@ -12,18 +12,16 @@ import (
 )
 
 // Register creates a new user account
-func Register(c *gin.Context) {
+func (h *Handler) Register(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Check if user already exists
-	var existingID int
-	err := database.MySQL.QueryRow("SELECT id FROM users WHERE email = ? OR username = ?", req.Email, req.Username).Scan(&existingID)
-	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "User with this email or username already exists"})
+	// Onboarding may pick favorite artists; reject unknown IDs before creating the account
+	if err := h.validateArtistIDs(req.FavoriteArtists); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -34,50 +32,38 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Create user
-	result, err := database.MySQL.Exec(`
-		INSERT INTO users (email, password, username, display_name, theme, language, explicit_content)
-		VALUES (?, ?, ?, ?, 'dark', 'en', TRUE)`,
-		req.Email, string(hashedPassword), req.Username, req.DisplayName)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+	// Create user with their favorite genres and artists
+	user := models.User{
+		Email:           req.Email,
+		Password:        string(hashedPassword),
+		Username:        req.Username,
+		DisplayName:     req.DisplayName,
+		Theme:           "dark",
+		Language:        "en",
+		ExplicitContent: true,
+		FavoriteGenres:  req.Genres,
+		FavoriteArtists: req.FavoriteArtists,
+	}
+	err = h.stores.Users.Create(&user)
+	if err == store.ErrConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "User with this email or username already exists"})
 		return
 	}
-
-	userID, err := result.LastInsertId()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
-	// Add favorite genres
-	if len(req.Genres) > 0 {
-		for _, genre := range req.Genres {
-			database.MySQL.Exec("INSERT INTO user_favorite_genres (user_id, genre) VALUES (?, ?)", userID, genre)
-		}
-	}
-
 	// Generate token
-	token, err := utils.GenerateToken(int(userID), req.Email)
+	token, err := utils.GenerateToken(user.ID, req.Email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// Fetch the created user
-	user := models.User{
-		ID:              int(userID),
-		Email:           req.Email,
-		Username:        req.Username,
-		DisplayName:     req.DisplayName,
-		Theme:           "dark",
-		Language:        "en",
-		ExplicitContent: true,
-		FavoriteGenres:  req.Genres,
-		CreatedAt:       time.Now(),
-		UpdatedAt:       time.Now(),
-	}
+	user.FavoriteArtists = h.getFavoriteArtistIDs(user.ID)
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
 
 	c.JSON(http.StatusCreated, models.AuthResponse{
 		Token: token,
@@ -86,7 +72,7 @@ func Register(c *gin.Context) {
 }
 
 // Login authenticates a user
-func Login(c *gin.Context) {
+func (h *Handler) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -94,37 +80,19 @@ func Login(c *gin.Context) {
 	}
 
 	// Find user
-	var user models.User
-	var hashedPassword string
-	err := database.MySQL.QueryRow(`
-		SELECT id, email, password, username, display_name, profile_picture_url, 
-		       theme, language, explicit_content, created_at, updated_at
-		FROM users WHERE email = ?`, req.Email).Scan(
-		&user.ID, &user.Email, &hashedPassword, &user.Username, &user.DisplayName,
-		&user.ProfilePictureURL, &user.Theme, &user.Language, &user.ExplicitContent,
-		&user.CreatedAt, &user.UpdatedAt)
-
+	user, err := h.stores.Users.GetByEmail(req.Email)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
 	// Verify password
-	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.Password))
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	// Fetch favorite genres
-	rows, _ := database.MySQL.Query("SELECT genre FROM user_favorite_genres WHERE user_id = ?", user.ID)
-	defer rows.Close()
-	for rows.Next() {
-		var genre string
-		rows.Scan(&genre)
-		user.FavoriteGenres = append(user.FavoriteGenres, genre)
-	}
-
 	// Generate token
 	token, err := utils.GenerateToken(user.ID, user.Email)
 	if err != nil {
@@ -134,46 +102,29 @@ func Login(c *gin.Context) {
 
 	c.JSON(http.StatusOK, models.AuthResponse{
 		Token: token,
-		User:  user,
+		User:  *user,
 	})
 }
 
 // GetProfile returns the current user's profile
-func GetProfile(c *gin.Context) {
+func (h *Handler) GetProfile(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	var user models.User
-	err := database.MySQL.QueryRow(`
-		SELECT id, email, username, display_name, profile_picture_url, 
-		       theme, language, explicit_content, created_at, updated_at
-		FROM users WHERE id = ?`, userID).Scan(
-		&user.ID, &user.Email, &user.Username, &user.DisplayName,
-		&user.ProfilePictureURL, &user.Theme, &user.Language, &user.ExplicitContent,
-		&user.CreatedAt, &user.UpdatedAt)
-
+	user, err := h.stores.Users.Get(userID.(int))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	// Fetch favorite genres
-	rows, _ := database.MySQL.Query("SELECT genre FROM user_favorite_genres WHERE user_id = ?", userID)
-	defer rows.Close()
-	for rows.Next() {
-		var genre string
-		rows.Scan(&genre)
-		user.FavoriteGenres = append(user.FavoriteGenres, genre)
-	}
-
 	c.JSON(http.StatusOK, user)
 }
 
 // UpdatePreferences updates user preferences
-func UpdatePreferences(c *gin.Context) {
+func (h *Handler) UpdatePreferences(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
@@ -186,22 +137,23 @@ func UpdatePreferences(c *gin.Context) {
 		return
 	}
 
-	// Update user preferences
-	_, err := database.MySQL.Exec(`
-		UPDATE users 
-		SET theme = ?, language = ?, explicit_content = ?, updated_at = NOW()
-		WHERE id = ?`,
-		prefs.Theme, prefs.Language, prefs.ExplicitContent, userID)
+	if err := h.validateArtistIDs(prefs.FavoriteArtists); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if err != nil {
+	// Update user preferences and favorite genres
+	if err := h.stores.Users.UpdatePreferences(userID.(int), prefs); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
 		return
 	}
 
-	// Update favorite genres
-	database.MySQL.Exec("DELETE FROM user_favorite_genres WHERE user_id = ?", userID)
-	for _, genre := range prefs.PreferredGenres {
-		database.MySQL.Exec("INSERT INTO user_favorite_genres (user_id, genre) VALUES (?, ?)", userID, genre)
+	// Update favorite artists only when the client sent the field
+	if prefs.FavoriteArtists != nil {
+		if err := h.stores.Users.SetFavoriteArtists(userID.(int), prefs.FavoriteArtists); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update favorite artists"})
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Preferences updated successfully"})
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"net/http"
+	"spotify-clone/models"
+	"spotify-clone/store"
+	"spotify-clone/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// inviteTTL is how long a playlist invite link stays valid
+const inviteTTL = 7 * 24 * time.Hour
+
+// CreatePlaylistInvite creates an invite link that lets other users collaborate on a playlist
+func (h *Handler) CreatePlaylistInvite(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+
+	// Only the owner can invite collaborators
+	access, err := h.getPlaylistAccess(playlistID, userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+	if !access.IsOwner(userID.(int)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	token, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invite"})
+		return
+	}
+
+	invite := models.PlaylistInvite{
+		PlaylistID: playlistID,
+		Token:      token,
+		CreatedAt:  time.Now(),
+		ExpiresAt:  time.Now().Add(inviteTTL),
+	}
+
+	if err := h.stores.Invites.Create(invite, userID.(int)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"invite":      invite,
+		"invite_path": "/api/v1/playlists/invites/" + invite.Token + "/accept",
+	})
+}
+
+// GetPlaylistInvites lists the active invite links of a playlist
+func (h *Handler) GetPlaylistInvites(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+
+	access, err := h.getPlaylistAccess(playlistID, userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+	if !access.IsOwner(userID.(int)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	invites, err := h.stores.Invites.Active(playlistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invites": invites})
+}
+
+// RevokePlaylistInvite invalidates an invite link
+func (h *Handler) RevokePlaylistInvite(c *gin.Context) {
+	token := c.Param("token")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+
+	access, err := h.getPlaylistAccess(playlistID, userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+	if !access.IsOwner(userID.(int)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	err = h.stores.Invites.Revoke(playlistID, token)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked successfully"})
+}
+
+// AcceptPlaylistInvite adds the authenticated user as a collaborator using an invite token
+func (h *Handler) AcceptPlaylistInvite(c *gin.Context) {
+	token := c.Param("token")
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	invite, err := h.stores.Invites.Get(token)
+	var access *store.PlaylistAccess
+	if err == nil {
+		access, err = h.stores.Playlists.Access(invite.PlaylistID, userID.(int))
+	}
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invite"})
+		return
+	}
+	if time.Now().After(invite.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "Invite has expired"})
+		return
+	}
+	if access.IsOwner(userID.(int)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You already own this playlist"})
+		return
+	}
+
+	playlistID := invite.PlaylistID
+	if err := h.stores.Playlists.AddCollaborator(playlistID, userID.(int)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join playlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Joined playlist as collaborator",
+		"playlist_id": playlistID,
+	})
+}
+
+// GetPlaylistCollaborators lists the collaborators of a playlist
+func (h *Handler) GetPlaylistCollaborators(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+
+	access, err := h.getPlaylistAccess(playlistID, userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+	if !access.IsPublic && !access.CanEdit(userID.(int)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	collaborators, err := h.stores.Playlists.Collaborators(playlistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collaborators"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"owner_id":      access.OwnerID,
+		"collaborators": collaborators,
+	})
+}
+
+// RemovePlaylistCollaborator revokes a collaborator's access.
+// The owner can remove anyone; collaborators can only remove themselves.
+func (h *Handler) RemovePlaylistCollaborator(c *gin.Context) {
+	collaboratorID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+
+	access, err := h.getPlaylistAccess(playlistID, userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+	if !access.IsOwner(userID.(int)) && collaboratorID != userID.(int) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	err = h.stores.Playlists.RemoveCollaborator(playlistID, collaboratorID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collaborator not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove collaborator"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collaborator removed successfully"})
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"spotify-clone/media"
+	"spotify-clone/store"
+	"spotify-clone/utils"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCoverUploadSize limits user uploaded covers to 4 MB
+const maxCoverUploadSize = 4 << 20
+
+// UploadPlaylistCover stores a user supplied cover that overrides the generated mosaic
+// PUT /api/v1/playlists/:id/cover (multipart field "image")
+func (h *Handler) UploadPlaylistCover(c *gin.Context) {
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	access, err := h.getPlaylistAccess(playlistID, userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+	if !access.IsOwner(userID.(int)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image file is required"})
+		return
+	}
+	if fileHeader.Size > maxCoverUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded image"})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded image"})
+		return
+	}
+
+	// Re-encode so only valid images are stored and every cover has the same size
+	img, err := media.DecodeImage(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image must be a JPEG or PNG"})
+		return
+	}
+	encoded, err := media.EncodeJPEG(media.Square(img, media.CoverSize))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process image"})
+		return
+	}
+
+	suffix, _ := utils.GenerateRandomToken(6)
+	name := fmt.Sprintf("covers/playlist-%d-upload-%s.jpg", playlistID, suffix)
+	if err := media.Save(name, encoded); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+
+	previous, _ := h.stores.Covers.Get(playlistID)
+	if err := h.stores.Covers.SetUploaded(playlistID, name); err != nil {
+		media.Remove(name)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save cover"})
+		return
+	}
+	media.Remove(previous.UploadedFile)
+
+	coverURL := media.URL(name)
+	h.stores.Playlists.SetCoverURL(playlistID, coverURL, true)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Cover uploaded successfully",
+		"cover_url": coverURL,
+	})
+}
+
+// DeletePlaylistCover removes an uploaded cover, falling back to the generated mosaic
+// DELETE /api/v1/playlists/:id/cover
+func (h *Handler) DeletePlaylistCover(c *gin.Context) {
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	access, err := h.getPlaylistAccess(playlistID, userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+	if !access.IsOwner(userID.(int)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	cover, err := h.stores.Covers.Get(playlistID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cover"})
+		return
+	}
+	if cover.UploadedFile == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist has no uploaded cover"})
+		return
+	}
+
+	if err := h.stores.Covers.SetUploaded(playlistID, ""); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove cover"})
+		return
+	}
+	media.Remove(cover.UploadedFile)
+
+	// Regenerate synchronously so the response carries the fallback cover
+	h.refreshPlaylistCover(playlistID)
+
+	coverURL := ""
+	if playlist, err := h.stores.Playlists.Get(playlistID, userID.(int)); err == nil {
+		coverURL = playlist.CoverURL
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Cover removed successfully",
+		"cover_url": coverURL,
+	})
+}
+
+// schedulePlaylistCoverRefresh regenerates a playlist's mosaic in the background
+// after its tracks change
+func (h *Handler) schedulePlaylistCoverRefresh(playlistID int) {
+	go h.refreshPlaylistCover(playlistID)
+}
+
+// refreshPlaylistCover rebuilds the 2x2 mosaic from the first distinct album covers
+// of a playlist and points playlists.cover_url at the uploaded or generated cover
+func (h *Handler) refreshPlaylistCover(playlistID int) {
+	lock, _ := h.coverLocks.LoadOrStore(playlistID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	access, err := h.stores.Playlists.Access(playlistID, 0)
+	if err != nil {
+		return
+	}
+
+	sources := h.playlistCoverSources(playlistID, access.OwnerID, access.IsSmart)
+	hash := sha1.Sum([]byte(strings.Join(sources, "\n")))
+	sourceHash := hex.EncodeToString(hash[:])
+
+	cover, err := h.stores.Covers.Get(playlistID)
+	if err != nil {
+		return
+	}
+
+	// Nothing to do when the same covers produced the existing file
+	if cover.SourceHash == sourceHash && (cover.GeneratedFile == "" || media.Exists(cover.GeneratedFile)) {
+		h.updatePlaylistCoverURL(playlistID, cover.UploadedFile, cover.GeneratedFile)
+		return
+	}
+
+	newFile := ""
+	if len(sources) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		images := []image.Image{}
+		for _, source := range sources {
+			img, err := media.LoadImage(ctx, source)
+			if err != nil {
+				log.Printf("⚠️  Warning: could not load cover %s for playlist %d: %v", source, playlistID, err)
+				continue
+			}
+			images = append(images, img)
+		}
+
+		if len(images) > 0 {
+			data, err := media.EncodeJPEG(media.Mosaic(images, media.CoverSize))
+			if err != nil {
+				log.Printf("⚠️  Warning: could not encode cover for playlist %d: %v", playlistID, err)
+				return
+			}
+			newFile = fmt.Sprintf("covers/playlist-%d-%s.jpg", playlistID, sourceHash[:12])
+			if err := media.Save(newFile, data); err != nil {
+				log.Printf("⚠️  Warning: could not store cover for playlist %d: %v", playlistID, err)
+				return
+			}
+		}
+	}
+
+	if err := h.stores.Covers.SetGenerated(playlistID, newFile, sourceHash); err != nil {
+		log.Printf("⚠️  Warning: could not save cover for playlist %d: %v", playlistID, err)
+		return
+	}
+	if cover.GeneratedFile != "" && cover.GeneratedFile != newFile {
+		media.Remove(cover.GeneratedFile)
+	}
+
+	h.updatePlaylistCoverURL(playlistID, cover.UploadedFile, newFile)
+}
+
+// playlistCoverSources returns up to four cover URLs of the first distinct albums in a playlist,
+// as any reader sees it since the cover is shared
+func (h *Handler) playlistCoverSources(playlistID, ownerID int, isSmart bool) []string {
+	tracks, err := h.getPlaylistTracks(playlistID, ownerID, 0, isSmart)
+	if err != nil {
+		return nil
+	}
+
+	albumIDs := []int{}
+	trackCovers := map[int]string{}
+	for _, track := range tracks {
+		if _, seen := trackCovers[track.AlbumID]; seen {
+			continue
+		}
+		trackCovers[track.AlbumID] = track.CoverURL
+		albumIDs = append(albumIDs, track.AlbumID)
+	}
+	sources := []string{}
+	seen := map[string]bool{}
+	for _, albumID := range albumIDs {
+		cover := ""
+		if album, err := h.stores.Albums.Get(albumID); err == nil {
+			cover = album.CoverURL
+		}
+		if cover == "" {
+			cover = trackCovers[albumID]
+		}
+		if cover == "" || seen[cover] {
+			continue
+		}
+		seen[cover] = true
+		sources = append(sources, cover)
+		if len(sources) == 4 {
+			break
+		}
+	}
+	return sources
+}
+
+// updatePlaylistCoverURL sets cover_url, preferring an uploaded cover over the generated one
+func (h *Handler) updatePlaylistCoverURL(playlistID int, uploaded, generated string) {
+	coverURL := ""
+	switch {
+	case uploaded != "":
+		coverURL = media.URL(uploaded)
+	case generated != "":
+		coverURL = media.URL(generated)
+	}
+	h.stores.Playlists.SetCoverURL(playlistID, coverURL, false)
+}
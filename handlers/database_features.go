@@ -5,14 +5,14 @@ import (
 	"strconv"
 	"time"
 
-	"spotify-clone/database"
+	"spotify-clone/store"
 
 	"github.com/gin-gonic/gin"
 )
 
 // GetArtistStats returns comprehensive statistics for an artist
 // GET /api/artists/:id/stats
-func GetArtistStats(c *gin.Context) {
+func (h *Handler) GetArtistStats(c *gin.Context) {
 	artistIDStr := c.Param("id")
 	artistID, err := strconv.Atoi(artistIDStr)
 	if err != nil {
@@ -20,7 +20,11 @@ func GetArtistStats(c *gin.Context) {
 		return
 	}
 
-	stats, err := database.GetArtistStats(artistID)
+	stats, err := h.stores.Artists.Stats(artistID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Artist not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -34,7 +38,7 @@ func GetArtistStats(c *gin.Context) {
 
 // GetAlbumStats returns statistics for an album (uses trigger-maintained data)
 // GET /api/albums/:id/stats
-func GetAlbumStats(c *gin.Context) {
+func (h *Handler) GetAlbumStats(c *gin.Context) {
 	albumIDStr := c.Param("id")
 	albumID, err := strconv.Atoi(albumIDStr)
 	if err != nil {
@@ -42,7 +46,11 @@ func GetAlbumStats(c *gin.Context) {
 		return
 	}
 
-	stats, err := database.GetAlbumStats(albumID)
+	stats, err := h.stores.Albums.Stats(albumID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Album not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -68,7 +76,7 @@ type AddTrackRequest struct {
 
 // AddTrackWithValidation adds a new track using the stored procedure
 // POST /api/tracks/add
-func AddTrackWithValidation(c *gin.Context) {
+func (h *Handler) AddTrackWithValidation(c *gin.Context) {
 	var req AddTrackRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -83,16 +91,16 @@ func AddTrackWithValidation(c *gin.Context) {
 	}
 
 	// Call the stored procedure
-	trackID, status, err := database.AddTrackWithValidation(
-		req.Title,
-		req.ArtistID,
-		req.AlbumID,
-		req.Duration,
-		req.Genre,
-		req.FileURL,
-		req.CoverURL,
-		releaseDate,
-	)
+	trackID, status, err := h.stores.Tracks.Add(store.NewTrack{
+		Title:       req.Title,
+		ArtistID:    req.ArtistID,
+		AlbumID:     req.AlbumID,
+		Duration:    req.Duration,
+		Genre:       req.Genre,
+		ReleaseDate: releaseDate,
+		FileURL:     req.FileURL,
+		CoverURL:    req.CoverURL,
+	})
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -117,7 +125,7 @@ func AddTrackWithValidation(c *gin.Context) {
 
 // GetAlbumDuration gets the total duration of an album using the SQL function
 // GET /api/albums/:id/duration
-func GetAlbumDuration(c *gin.Context) {
+func (h *Handler) GetAlbumDuration(c *gin.Context) {
 	albumIDStr := c.Param("id")
 	albumID, err := strconv.Atoi(albumIDStr)
 	if err != nil {
@@ -125,7 +133,7 @@ func GetAlbumDuration(c *gin.Context) {
 		return
 	}
 
-	duration, err := database.GetAlbumDuration(albumID)
+	duration, err := h.stores.Albums.Duration(albumID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"spotify-clone/models"
+	"spotify-clone/realtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// socketIdleTimeout closes connections that sent nothing, not even a ping, for this long
+	socketIdleTimeout  = 2 * time.Minute
+	socketWriteTimeout = 10 * time.Second
+	maxSocketMessage   = 64 << 10
+)
+
+var errInvalidCommand = errors.New("Invalid command")
+
+// controlPlayback applies a playback change and broadcasts the new state to all of
+// the user's devices. Changes of one user run one at a time, so devices receive the
+// state broadcasts in the order the changes were made.
+func (h *Handler) controlPlayback(userID int, change func() error) error {
+	var err error
+	h.deviceHub.Exclusive(userID, func() {
+		err = h.applyPlaybackChange(userID, change)
+	})
+	return err
+}
+
+// applyPlaybackChange is controlPlayback for callers already inside deviceHub.Exclusive.
+// Members of a session the user hosts get the new state as well.
+func (h *Handler) applyPlaybackChange(userID int, change func() error) error {
+	if err := change(); err != nil {
+		return err
+	}
+	if state, err := h.getPlaybackView(userID); err == nil {
+		h.deviceHub.Publish(userID, "state", state)
+	}
+	h.broadcastHostedSession(userID)
+	return nil
+}
+
+// GetDevices lists the user's connected devices
+// GET /api/v1/me/player/devices
+func (h *Handler) GetDevices(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"devices": h.deviceHub.Devices(userID.(int))})
+}
+
+// TransferPlayback moves playback to another connected device
+// PUT /api/v1/me/player/transfer
+func (h *Handler) TransferPlayback(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.TransferPlaybackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.controlPlayback(userID.(int), func() error {
+		return h.transferPlayback(userID.(int), "", req.DeviceID, req.Resume)
+	})
+	h.respondPlayer(c, userID.(int), err, "Failed to transfer playback")
+}
+
+// PlayerSocket upgrades to a WebSocket over which a device registers, sees the
+// user's other devices and sends or receives transport commands. The device
+// identifies itself with ?device_id=&device_name=&device_type=. Browsers, which
+// cannot set headers on the handshake, pass the JWT as ?access_token=.
+// GET /api/v1/me/player/socket
+func (h *Handler) PlayerSocket(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	device := realtime.Device{
+		ID:          c.Query("device_id"),
+		Name:        c.DefaultQuery("device_name", "Unknown device"),
+		Type:        c.DefaultQuery("device_type", "computer"),
+		ConnectedAt: time.Now(),
+	}
+	if device.ID == "" || len(device.ID) > 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_id is required (at most 64 characters)"})
+		return
+	}
+
+	// websocket.Server does not enforce an Origin check: the JWT already authenticates
+	// the handshake and native clients send no Origin at all
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		h.servePlayerSocket(ws, userID.(int), device)
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// servePlayerSocket runs one device connection until either side closes it
+func (h *Handler) servePlayerSocket(ws *websocket.Conn, userID int, device realtime.Device) {
+	defer ws.Close()
+	ws.MaxPayloadBytes = maxSocketMessage
+
+	// Register and send the current state while no playback change can run, so the
+	// state the device starts from is never newer than the next broadcast it gets
+	var client *realtime.Client
+	h.deviceHub.Exclusive(userID, func() {
+		client = h.deviceHub.Register(userID, device)
+		if state, err := h.getPlaybackView(userID); err == nil {
+			h.deviceHub.SendTo(userID, device.ID, realtime.Event{Type: "state", Payload: state})
+		}
+	})
+	defer h.deviceHub.Unregister(client)
+
+	// A single writer per connection keeps events in the order the hub queued them
+	go func() {
+		for {
+			select {
+			case event := <-client.Events():
+				ws.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+				if err := websocket.JSON.Send(ws, event); err != nil {
+					ws.Close()
+					return
+				}
+			case <-client.Done():
+				ws.Close()
+				return
+			}
+		}
+	}()
+
+	for {
+		ws.SetReadDeadline(time.Now().Add(socketIdleTimeout))
+		var msg models.PlayerSocketMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				h.replyToDevice(client, msg.ID, "error", gin.H{"error": "Malformed message"})
+				continue
+			}
+			return
+		}
+		h.handleSocketMessage(client, msg)
+	}
+}
+
+// handleSocketMessage answers one message of a device
+func (h *Handler) handleSocketMessage(client *realtime.Client, msg models.PlayerSocketMessage) {
+	switch msg.Type {
+	case "ping":
+		h.replyToDevice(client, msg.ID, "pong", nil)
+	case "command":
+		err := h.controlPlayback(client.UserID, func() error {
+			return h.applyDeviceCommand(client, msg)
+		})
+		if err != nil {
+			h.replyToDevice(client, msg.ID, "error", gin.H{"error": playerErrorMessage(err, "Failed to apply command")})
+			return
+		}
+		h.replyToDevice(client, msg.ID, "ack", nil)
+	default:
+		h.replyToDevice(client, msg.ID, "error", gin.H{"error": "Unknown message type"})
+	}
+}
+
+// applyDeviceCommand changes playback on behalf of a device and forwards the command
+// to the device it targets (the active device unless target_device_id is set)
+func (h *Handler) applyDeviceCommand(client *realtime.Client, msg models.PlayerSocketMessage) error {
+	userID := client.UserID
+
+	if msg.Command == "transfer" {
+		if msg.TargetDeviceID == "" {
+			return errInvalidCommand
+		}
+		return h.transferPlayback(userID, client.Device.ID, msg.TargetDeviceID, msg.Resume)
+	}
+
+	// Check the target before touching playback so a bad target changes nothing
+	target := msg.TargetDeviceID
+	if target == "" {
+		target = h.deviceHub.ActiveDevice(userID)
+	} else if !h.deviceConnected(userID, target) {
+		return realtime.ErrDeviceNotFound
+	}
+
+	var err error
+	switch msg.Command {
+	case "play":
+		req := models.PlayRequest{}
+		if msg.Play != nil {
+			req = *msg.Play
+		}
+		if req.PositionMs < 0 || (req.Offset != nil && *req.Offset < 0) {
+			return errInvalidCommand
+		}
+		err = h.playerPlay(userID, req)
+	case "pause":
+		err = h.playerPause(userID)
+	case "seek":
+		if msg.PositionMs == nil || *msg.PositionMs < 0 {
+			return errInvalidCommand
+		}
+		err = h.playerSeek(userID, *msg.PositionMs)
+	case "next":
+		err = h.playerNext(userID, msg.Ended)
+	case "previous":
+		err = h.playerPrevious(userID)
+	default:
+		return errInvalidCommand
+	}
+	if err != nil {
+		return err
+	}
+
+	if target != "" && target != client.Device.ID {
+		return h.deviceHub.SendTo(userID, target, realtime.Event{Type: "command", Payload: gin.H{
+			"command":        msg.Command,
+			"from_device_id": client.Device.ID,
+		}})
+	}
+	return nil
+}
+
+// transferPlayback makes deviceID the active device and tells it to take over
+func (h *Handler) transferPlayback(userID int, fromDeviceID, deviceID string, resume bool) error {
+	if err := h.deviceHub.SetActiveDevice(userID, deviceID); err != nil {
+		return err
+	}
+	if resume {
+		if err := h.playerPlay(userID, models.PlayRequest{}); err != nil && err != errNothingPlaying {
+			return err
+		}
+	}
+	return h.deviceHub.SendTo(userID, deviceID, realtime.Event{Type: "command", Payload: gin.H{
+		"command":        "transfer",
+		"from_device_id": fromDeviceID,
+	}})
+}
+
+func (h *Handler) deviceConnected(userID int, deviceID string) bool {
+	for _, device := range h.deviceHub.Devices(userID) {
+		if device.ID == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// replyToDevice answers a message of a single device; replies are not sequenced
+func (h *Handler) replyToDevice(client *realtime.Client, inReplyTo, eventType string, payload interface{}) {
+	h.deviceHub.SendTo(client.UserID, client.Device.ID, realtime.Event{
+		Type:      eventType,
+		InReplyTo: inReplyTo,
+		Payload:   payload,
+	})
+}
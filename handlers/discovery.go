@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"spotify-clone/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BrowsePublicPlaylists returns public playlists, optionally filtered by a search term
+func (h *Handler) BrowsePublicPlaylists(c *gin.Context) {
+	page, limit := pageParams(c, 20)
+	search := c.Query("q")
+	sort := c.DefaultQuery("sort", "popular")
+
+	offset := (page - 1) * limit
+
+	if sort != "popular" && sort != "recent" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort. Use popular or recent"})
+		return
+	}
+
+	playlists, err := h.stores.Playlists.BrowsePublic(search, sort, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"playlists": playlists,
+		"page":      page,
+		"limit":     limit,
+	})
+}
+
+// FollowPlaylist adds a public playlist to the user's followed playlists
+func (h *Handler) FollowPlaylist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+
+	access, err := h.getPlaylistAccess(playlistID, userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+	if access.IsOwner(userID.(int)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot follow your own playlist"})
+		return
+	}
+	if !access.IsPublic {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	if err := h.stores.Playlists.Follow(playlistID, userID.(int)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow playlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Playlist followed successfully",
+		"follower_count": h.getPlaylistFollowerCount(playlistID),
+	})
+}
+
+// UnfollowPlaylist removes a playlist from the user's followed playlists
+func (h *Handler) UnfollowPlaylist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+
+	err := h.stores.Playlists.Unfollow(playlistID, userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not following this playlist"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow playlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Playlist unfollowed successfully",
+		"follower_count": h.getPlaylistFollowerCount(playlistID),
+	})
+}
+
+// getPlaylistFollowerCount returns how many users follow a playlist
+func (h *Handler) getPlaylistFollowerCount(playlistID int) int {
+	count, _ := h.stores.Playlists.FollowerCount(playlistID)
+	return count
+}
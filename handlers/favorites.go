@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"spotify-clone/models"
+	"spotify-clone/store"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxFavoriteArtists caps how many favorite artists a user can keep. Artists
+// saved to the library are the same list and count towards it.
+const maxFavoriteArtists = 50
+
+var favoriteArtistLimitMessage = fmt.Sprintf("You can have at most %d favorite artists", maxFavoriteArtists)
+
+// GetFavoriteArtists lists the user's favorite artists with details
+// GET /api/v1/profile/favorite-artists
+func (h *Handler) GetFavoriteArtists(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	artists, err := h.stores.Users.FavoriteArtists(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch favorite artists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"artists": artists})
+}
+
+// AddFavoriteArtist adds an artist to the user's favorites
+// POST /api/v1/profile/favorite-artists
+func (h *Handler) AddFavoriteArtist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.FavoriteArtistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.validateArtistIDs([]int{req.ArtistID}); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Artist not found"})
+		return
+	}
+
+	added, err := h.stores.Users.AddFavoriteArtist(userID.(int), req.ArtistID, maxFavoriteArtists)
+	if err == store.ErrLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": favoriteArtistLimitMessage})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add favorite artist"})
+		return
+	}
+	if added {
+		h.recordActivity(userID.(int), activityFollowedArtist, 0, 0, req.ArtistID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Artist added to favorites",
+		"favorite_artists": h.getFavoriteArtistIDs(userID.(int)),
+	})
+}
+
+// SetFavoriteArtists replaces the user's favorite artists, e.g. at the end of onboarding
+// PUT /api/v1/profile/favorite-artists
+func (h *Handler) SetFavoriteArtists(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.SetFavoriteArtistsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.validateArtistIDs(req.ArtistIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	previous := map[int]bool{}
+	for _, id := range h.getFavoriteArtistIDs(userID.(int)) {
+		previous[id] = true
+	}
+	if err := h.stores.Users.SetFavoriteArtists(userID.(int), req.ArtistIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update favorite artists"})
+		return
+	}
+	for _, id := range req.ArtistIDs {
+		if !previous[id] {
+			previous[id] = true
+			h.recordActivity(userID.(int), activityFollowedArtist, 0, 0, id)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Favorite artists updated successfully",
+		"favorite_artists": h.getFavoriteArtistIDs(userID.(int)),
+	})
+}
+
+// RemoveFavoriteArtist removes an artist from the user's favorites
+// DELETE /api/v1/profile/favorite-artists/:artistId
+func (h *Handler) RemoveFavoriteArtist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	artistID, err := strconv.Atoi(c.Param("artistId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid artist ID"})
+		return
+	}
+
+	err = h.stores.Users.RemoveFavoriteArtist(userID.(int), artistID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Artist is not in your favorites"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove favorite artist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Artist removed from favorites",
+		"favorite_artists": h.getFavoriteArtistIDs(userID.(int)),
+	})
+}
+
+// GetArtistSuggestions returns popular artists to pick from during onboarding,
+// optionally restricted to genres the user chose
+// GET /api/v1/artists/suggestions?genres=Pop,Rock&limit=20
+func (h *Handler) GetArtistSuggestions(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	genres := []string{}
+	for _, genre := range strings.Split(c.Query("genres"), ",") {
+		if genre = strings.TrimSpace(genre); genre != "" {
+			genres = append(genres, genre)
+		}
+	}
+
+	artists, err := h.stores.Artists.Popular(genres, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch artist suggestions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"artists": artists})
+}
+
+// validateArtistIDs checks that every ID refers to an existing artist
+func (h *Handler) validateArtistIDs(ids []int) error {
+	if len(ids) > maxFavoriteArtists {
+		return fmt.Errorf("at most %d favorite artists are allowed", maxFavoriteArtists)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	unique := map[int]bool{}
+	for _, id := range ids {
+		unique[id] = true
+	}
+	distinct := make([]int, 0, len(unique))
+	for id := range unique {
+		distinct = append(distinct, id)
+	}
+
+	existing, err := h.stores.Artists.Existing(distinct)
+	if err != nil {
+		return fmt.Errorf("failed to validate artists")
+	}
+	for _, id := range existing {
+		delete(unique, id)
+	}
+	if len(unique) > 0 {
+		missing := []string{}
+		for _, id := range distinct {
+			if unique[id] {
+				missing = append(missing, strconv.Itoa(id))
+			}
+		}
+		return fmt.Errorf("unknown artist IDs: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// getFavoriteArtistIDs returns the IDs of the user's favorite artists in the order they were added
+func (h *Handler) getFavoriteArtistIDs(userID int) []int {
+	ids, err := h.stores.Users.FavoriteArtistIDs(userID)
+	if err != nil {
+		return []int{}
+	}
+	return ids
+}
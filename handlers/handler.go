@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"spotify-clone/realtime"
+	"spotify-clone/store"
+	"sync"
+)
+
+// Handler serves the API from a set of stores. main wires in the MySQL or
+// in-memory implementation; tests can hand in fakes.
+type Handler struct {
+	stores *store.Stores
+
+	// deviceHub tracks the connected devices of every user on this server instance
+	deviceHub *realtime.Hub
+	// coverLocks serializes cover generation per playlist
+	coverLocks sync.Map
+}
+
+// New returns a Handler serving from stores
+func New(stores *store.Stores) *Handler {
+	return &Handler{
+		stores:    stores,
+		deviceHub: realtime.NewHub(),
+	}
+}
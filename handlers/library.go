@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"net/http"
+	"spotify-clone/models"
+	"spotify-clone/store"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// libraryKind describes a library section
+type libraryKind struct {
+	section string
+	label   string
+}
+
+var libraryKinds = map[string]libraryKind{
+	"tracks":  {section: store.LibraryTracks, label: "Track"},
+	"albums":  {section: store.LibraryAlbums, label: "Album"},
+	"artists": {section: store.LibraryArtists, label: "Artist"},
+}
+
+// libraryItemExists reports whether the catalog holds the item to save
+func (h *Handler) libraryItemExists(section string, id int) (bool, error) {
+	var err error
+	switch section {
+	case store.LibraryTracks:
+		_, err = h.stores.Tracks.Get(id)
+	case store.LibraryAlbums:
+		_, err = h.stores.Albums.Get(id)
+	default:
+		_, err = h.stores.Artists.Get(id)
+	}
+	if err == store.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SaveToLibrary saves a track, album or artist to the user's library
+// PUT /api/v1/library/:kind/:id
+func (h *Handler) SaveToLibrary(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	kind, ok := libraryKinds[c.Param("kind")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library type. Use tracks, albums or artists"})
+		return
+	}
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + strings.ToLower(kind.label) + " ID"})
+		return
+	}
+
+	if found, err := h.libraryItemExists(kind.section, itemID); err != nil || !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": kind.label + " not found"})
+		return
+	}
+
+	if kind.section == store.LibraryArtists {
+		added, err := h.stores.Users.AddFavoriteArtist(userID.(int), itemID, maxFavoriteArtists)
+		if err == store.ErrLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": favoriteArtistLimitMessage})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save to library"})
+			return
+		}
+		if added {
+			h.recordActivity(userID.(int), activityFollowedArtist, 0, 0, itemID)
+		}
+	} else if err := h.stores.Library.Save(userID.(int), kind.section, itemID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save to library"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": kind.label + " saved to library"})
+}
+
+// RemoveFromLibrary removes a track, album or artist from the user's library
+// DELETE /api/v1/library/:kind/:id
+func (h *Handler) RemoveFromLibrary(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	kind, ok := libraryKinds[c.Param("kind")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library type. Use tracks, albums or artists"})
+		return
+	}
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + strings.ToLower(kind.label) + " ID"})
+		return
+	}
+
+	err = h.stores.Library.Remove(userID.(int), kind.section, itemID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": kind.label + " is not in your library"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove from library"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": kind.label + " removed from library"})
+}
+
+// CheckLibrary reports which of the given IDs are saved in the user's library
+// GET /api/v1/library/contains?type=tracks&ids=1,2,3
+func (h *Handler) CheckLibrary(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	kind, ok := libraryKinds[c.DefaultQuery("type", "tracks")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library type. Use tracks, albums or artists"})
+		return
+	}
+
+	ids := []int{}
+	for _, part := range strings.Split(c.Query("ids"), ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 || len(ids) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide between 1 and 100 comma separated ids"})
+		return
+	}
+
+	savedIDs, err := h.stores.Library.Contains(userID.(int), kind.section, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check library"})
+		return
+	}
+
+	saved := map[int]bool{}
+	for _, id := range savedIDs {
+		saved[id] = true
+	}
+
+	result := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		result[strconv.Itoa(id)] = saved[id]
+	}
+	c.JSON(http.StatusOK, gin.H{"saved": result})
+}
+
+// GetLibraryTracks lists saved tracks with sorting and filtering
+// GET /api/v1/library/tracks?sort=recent|title|artist|album|duration&genre=&artist_id=&q=
+func (h *Handler) GetLibraryTracks(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	page, limit := pageParams(c, 50)
+	offset := (page - 1) * limit
+
+	sort := c.DefaultQuery("sort", "recent")
+	switch sort {
+	case "recent", "title", "artist", "album", "duration":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort. Use recent, title, artist, album or duration"})
+		return
+	}
+
+	artistID, _ := strconv.Atoi(c.Query("artist_id"))
+	tracks, err := h.stores.Library.Tracks(userID.(int), store.LibraryFilter{
+		Sort:     sort,
+		Genre:    c.Query("genre"),
+		ArtistID: artistID,
+		Search:   c.Query("q"),
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch library"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tracks": tracks,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+// GetLibraryAlbums lists saved albums
+// GET /api/v1/library/albums?sort=recent|title|artist|release_date&q=
+func (h *Handler) GetLibraryAlbums(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	page, limit := pageParams(c, 50)
+	offset := (page - 1) * limit
+
+	sort := c.DefaultQuery("sort", "recent")
+	switch sort {
+	case "recent", "title", "artist", "release_date":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort. Use recent, title, artist or release_date"})
+		return
+	}
+
+	albums, err := h.stores.Library.Albums(userID.(int), store.LibraryFilter{
+		Sort:   sort,
+		Search: c.Query("q"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch library"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"albums": albums,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+// GetLibraryArtists lists saved artists
+// GET /api/v1/library/artists?sort=recent|name&q=
+func (h *Handler) GetLibraryArtists(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	page, limit := pageParams(c, 50)
+	offset := (page - 1) * limit
+
+	sort := c.DefaultQuery("sort", "recent")
+	if sort != "recent" && sort != "name" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort. Use recent or name"})
+		return
+	}
+
+	artists, err := h.stores.Library.Artists(userID.(int), store.LibraryFilter{
+		Sort:   sort,
+		Search: c.Query("q"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch library"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"artists": artists,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// GetLikedSongs returns the user's saved tracks shaped like GetPlaylistByID
+// GET /api/v1/playlists/liked
+func (h *Handler) GetLikedSongs(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	saved, err := h.stores.Library.Tracks(userID.(int), store.LibraryFilter{Sort: "recent", Limit: -1})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch liked songs"})
+		return
+	}
+
+	owner := userID.(int)
+	playlist := models.Playlist{
+		UserID:   owner,
+		Name:     "Liked Songs",
+		TrackIDs: []int{},
+	}
+	tracks := []models.Track{}
+	items := []models.PlaylistItem{}
+	for _, entry := range saved {
+		track, savedAt := entry.Track, entry.SavedAt
+		items = append(items, models.PlaylistItem{
+			TrackID:  track.ID,
+			Position: len(items),
+			AddedBy:  &owner,
+			AddedAt:  &savedAt,
+		})
+		tracks = append(tracks, track)
+		playlist.TrackIDs = append(playlist.TrackIDs, track.ID)
+		if savedAt.After(playlist.UpdatedAt) {
+			playlist.UpdatedAt = savedAt
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"playlist": playlist,
+		"tracks":   tracks,
+		"items":    items,
+		"virtual":  true,
+	})
+}
+
+// pageParams reads ?page= and ?limit=, replacing a page below 1 with 1 and a
+// limit outside 1..100 with defaultLimit, so that a request never asks a store
+// for an unbounded list
+func pageParams(c *gin.Context, defaultLimit int) (page, limit int) {
+	page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if limit < 1 || limit > 100 {
+		limit = defaultLimit
+	}
+	return page, limit
+}
@@ -0,0 +1,789 @@
+package handlers
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"spotify-clone/models"
+	"spotify-clone/realtime"
+	"spotify-clone/store"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// restartThreshold is how far into a track "previous" restarts it instead of going back
+const restartThreshold = 3 * time.Second
+
+// upNextSize is how many upcoming context tracks are listed after the queue
+const upNextSize = 10
+
+var (
+	errNothingPlaying    = errors.New("Nothing is playing")
+	errContextNotFound   = errors.New("Context not found")
+	errContextForbidden  = errors.New("Access denied")
+	errEmptyContext      = errors.New("Context has no tracks")
+	errTrackNotInContext = errors.New("Track not in context")
+	errTrackNotFound     = errors.New("Track not found")
+	errQueueItemNotFound = errors.New("Queue item not found")
+)
+
+// playerErrorStatus maps player errors to HTTP status codes
+func playerErrorStatus(err error) int {
+	switch err {
+	case errContextNotFound, errTrackNotFound, errQueueItemNotFound, errTrackNotInContext:
+		return http.StatusNotFound
+	case errContextForbidden:
+		return http.StatusForbidden
+	case errNothingPlaying, errEmptyContext, errInvalidCommand:
+		return http.StatusBadRequest
+	case realtime.ErrDeviceNotFound:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// playerErrorMessage returns the message shown to clients for a player error
+func playerErrorMessage(err error, failure string) string {
+	if err == realtime.ErrDeviceNotFound {
+		return "Device not connected"
+	}
+	if playerErrorStatus(err) == http.StatusInternalServerError {
+		return failure
+	}
+	return err.Error()
+}
+
+// respondPlayer writes the refreshed playback state, or the error of a player operation
+func (h *Handler) respondPlayer(c *gin.Context, userID int, err error, failure string) {
+	if err != nil {
+		c.JSON(playerErrorStatus(err), gin.H{"error": playerErrorMessage(err, failure)})
+		return
+	}
+
+	state, err := h.getPlaybackView(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playback state"})
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+// GetPlaybackState returns the user's now-playing state with its queue and upcoming tracks
+// GET /api/v1/me/player
+func (h *Handler) GetPlaybackState(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	h.respondPlayer(c, userID.(int), nil, "")
+}
+
+// Play starts a context or a track, or resumes playback when the body is empty
+// PUT /api/v1/me/player/play
+func (h *Handler) Play(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.PlayRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	err := h.controlPlayback(userID.(int), func() error { return h.playerPlay(userID.(int), req) })
+	h.respondPlayer(c, userID.(int), err, "Failed to start playback")
+}
+
+// Pause pauses playback
+// PUT /api/v1/me/player/pause
+func (h *Handler) Pause(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.controlPlayback(userID.(int), func() error { return h.playerPause(userID.(int)) })
+	h.respondPlayer(c, userID.(int), err, "Failed to pause playback")
+}
+
+// Seek moves the playback position within the current track
+// PUT /api/v1/me/player/seek
+func (h *Handler) Seek(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.SeekRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.controlPlayback(userID.(int), func() error { return h.playerSeek(userID.(int), *req.PositionMs) })
+	h.respondPlayer(c, userID.(int), err, "Failed to seek")
+}
+
+// SkipToNext plays the next queued track, or the next track of the context.
+// Clients pass ?ended=true when a track finished on its own so repeat=track replays it.
+// POST /api/v1/me/player/next
+func (h *Handler) SkipToNext(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.controlPlayback(userID.(int), func() error { return h.playerNext(userID.(int), c.Query("ended") == "true") })
+	h.respondPlayer(c, userID.(int), err, "Failed to skip to next track")
+}
+
+// SkipToPrevious restarts the current track, or goes back one track in the context
+// POST /api/v1/me/player/previous
+func (h *Handler) SkipToPrevious(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.controlPlayback(userID.(int), func() error { return h.playerPrevious(userID.(int)) })
+	h.respondPlayer(c, userID.(int), err, "Failed to skip to previous track")
+}
+
+// SetShuffle turns shuffle on or off
+// PUT /api/v1/me/player/shuffle
+func (h *Handler) SetShuffle(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.ShuffleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.controlPlayback(userID.(int), func() error { return h.playerSetShuffle(userID.(int), *req.State) })
+	h.respondPlayer(c, userID.(int), err, "Failed to change shuffle")
+}
+
+// SetRepeat sets the repeat mode (off, track or context)
+// PUT /api/v1/me/player/repeat
+func (h *Handler) SetRepeat(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.RepeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.controlPlayback(userID.(int), func() error { return h.playerSetRepeat(userID.(int), req.Mode) })
+	h.respondPlayer(c, userID.(int), err, "Failed to change repeat mode")
+}
+
+// GetQueue returns the user's up-next queue
+// GET /api/v1/me/player/queue
+func (h *Handler) GetQueue(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	queue, err := h.getPlaybackQueue(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"queue": queue})
+}
+
+// AddToQueue appends a track to the user's up-next queue
+// POST /api/v1/me/player/queue
+func (h *Handler) AddToQueue(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.AddToQueueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.controlPlayback(userID.(int), func() error { return h.playerAddToQueue(userID.(int), req.TrackID) })
+	if err != nil {
+		h.respondPlayer(c, userID.(int), err, "Failed to add track to queue")
+		return
+	}
+
+	queue, err := h.getPlaybackQueue(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"queue": queue})
+}
+
+// RemoveFromQueue removes an item from the user's up-next queue
+// DELETE /api/v1/me/player/queue/:itemId
+func (h *Handler) RemoveFromQueue(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	itemID, err := strconv.ParseInt(c.Param("itemId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid queue item ID"})
+		return
+	}
+
+	err = h.controlPlayback(userID.(int), func() error { return h.playerRemoveFromQueue(userID.(int), itemID) })
+	if err != nil {
+		h.respondPlayer(c, userID.(int), err, "Failed to remove track from queue")
+		return
+	}
+
+	queue, err := h.getPlaybackQueue(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"queue": queue})
+}
+
+// MoveQueueItem moves a queued track to a new position
+// PUT /api/v1/me/player/queue/:itemId/position
+func (h *Handler) MoveQueueItem(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	itemID, err := strconv.ParseInt(c.Param("itemId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid queue item ID"})
+		return
+	}
+
+	var req models.MoveQueueItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.controlPlayback(userID.(int), func() error { return h.playerMoveQueueItem(userID.(int), itemID, *req.Position) })
+	if err != nil {
+		h.respondPlayer(c, userID.(int), err, "Failed to reorder queue")
+		return
+	}
+
+	queue, err := h.getPlaybackQueue(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"queue": queue})
+}
+
+// playerPlay starts a context, a single track, or resumes the current track
+func (h *Handler) playerPlay(userID int, req models.PlayRequest) error {
+	return h.modifyPlayback(userID, false, func(tx store.PlaybackTx, state *models.PlaybackState) error {
+		switch {
+		case req.ContextType != "":
+			ids, err := h.resolvePlayContext(req.ContextType, req.ContextID, userID)
+			if err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				return errEmptyContext
+			}
+
+			startTrack := 0
+			if req.TrackID != 0 {
+				startTrack = req.TrackID
+			} else if req.Offset != nil {
+				if *req.Offset >= len(ids) {
+					return errTrackNotInContext
+				}
+				startTrack = ids[*req.Offset]
+			}
+
+			if state.Shuffle {
+				state.ShuffleSeed = rand.Int63()
+			}
+			order := h.playbackOrder(ids, state.Shuffle, state.ShuffleSeed)
+			index := 0
+			if startTrack != 0 {
+				if index = indexOf(order, startTrack); index == -1 {
+					return errTrackNotInContext
+				}
+			}
+
+			contextID := req.ContextID
+			state.ContextType = req.ContextType
+			state.ContextID = &contextID
+			state.ContextIndex = index
+			state.TrackID = &order[index]
+
+		case req.TrackID != 0:
+			if !h.trackExists(req.TrackID) {
+				return errTrackNotFound
+			}
+			trackID := req.TrackID
+			state.ContextType = ""
+			state.ContextID = nil
+			state.ContextIndex = 0
+			state.TrackID = &trackID
+
+		default:
+			if state.TrackID == nil {
+				return errNothingPlaying
+			}
+			state.IsPlaying = true
+			return nil
+		}
+
+		state.FromQueue = false
+		state.PositionMs = req.PositionMs
+		state.IsPlaying = true
+		return nil
+	})
+}
+
+// playerPause pauses playback, keeping the position
+func (h *Handler) playerPause(userID int) error {
+	return h.modifyPlayback(userID, false, func(tx store.PlaybackTx, state *models.PlaybackState) error {
+		if state.TrackID == nil {
+			return errNothingPlaying
+		}
+		state.IsPlaying = false
+		return nil
+	})
+}
+
+// playerSeek moves the position within the current track
+func (h *Handler) playerSeek(userID, positionMs int) error {
+	return h.modifyPlayback(userID, false, func(tx store.PlaybackTx, state *models.PlaybackState) error {
+		if state.TrackID == nil {
+			return errNothingPlaying
+		}
+		state.PositionMs = positionMs
+		return nil
+	})
+}
+
+// playerNext advances to the next queued track or the next track of the context.
+// ended reports a track that finished on its own, which repeat=track replays.
+func (h *Handler) playerNext(userID int, ended bool) error {
+	return h.modifyPlayback(userID, true, func(tx store.PlaybackTx, state *models.PlaybackState) error {
+		if ended && state.RepeatMode == "track" && state.TrackID != nil {
+			state.PositionMs = 0
+			return nil
+		}
+
+		// The queue always plays before the rest of the context
+		queuedTrack, ok, err := tx.PopQueue()
+		if err != nil {
+			return err
+		}
+		if ok {
+			state.TrackID = &queuedTrack
+			state.FromQueue = true
+			state.PositionMs = 0
+			state.IsPlaying = true
+			return nil
+		}
+
+		if state.ContextType == "" {
+			if state.TrackID == nil {
+				return errNothingPlaying
+			}
+			// A single track without context simply ends
+			state.PositionMs = 0
+			state.IsPlaying = false
+			return nil
+		}
+
+		order, err := h.contextPlaybackOrder(state, userID)
+		if err != nil {
+			return err
+		}
+		if len(order) == 0 {
+			return errEmptyContext
+		}
+
+		next := state.ContextIndex + 1
+		if next >= len(order) {
+			if state.RepeatMode != "context" {
+				// End of the context: stop on the last track
+				state.PositionMs = 0
+				state.IsPlaying = false
+				return nil
+			}
+			next = 0
+		}
+		state.ContextIndex = next
+		state.TrackID = &order[next]
+		state.FromQueue = false
+		state.PositionMs = 0
+		state.IsPlaying = true
+		return nil
+	})
+}
+
+// playerPrevious restarts the current track when it played for a while, otherwise
+// goes back one track in the context
+func (h *Handler) playerPrevious(userID int) error {
+	return h.modifyPlayback(userID, true, func(tx store.PlaybackTx, state *models.PlaybackState) error {
+		if state.TrackID == nil {
+			return errNothingPlaying
+		}
+		if state.ContextType == "" || time.Duration(state.PositionMs)*time.Millisecond > restartThreshold {
+			state.PositionMs = 0
+			return nil
+		}
+
+		order, err := h.contextPlaybackOrder(state, userID)
+		if err != nil {
+			return err
+		}
+		if len(order) == 0 {
+			return errEmptyContext
+		}
+
+		// After a queued track, "previous" returns to the context track it interrupted
+		previous := state.ContextIndex
+		if !state.FromQueue {
+			previous--
+		}
+		if previous < 0 {
+			previous = 0
+			if state.RepeatMode == "context" {
+				previous = len(order) - 1
+			}
+		}
+		if previous >= len(order) {
+			previous = len(order) - 1
+		}
+		state.ContextIndex = previous
+		state.TrackID = &order[previous]
+		state.FromQueue = false
+		state.PositionMs = 0
+		return nil
+	})
+}
+
+// playerSetShuffle turns shuffle on or off while keeping the current track in place
+func (h *Handler) playerSetShuffle(userID int, on bool) error {
+	return h.modifyPlayback(userID, false, func(tx store.PlaybackTx, state *models.PlaybackState) error {
+		if state.Shuffle == on {
+			return nil
+		}
+		if state.ContextType == "" {
+			state.Shuffle = on
+			return nil
+		}
+
+		ids, err := h.resolvePlayContext(state.ContextType, *state.ContextID, userID)
+		if err != nil {
+			return err
+		}
+		// The context track being played (or interrupted by the queue) stays current
+		current := 0
+		if order := h.playbackOrder(ids, state.Shuffle, state.ShuffleSeed); state.ContextIndex < len(order) {
+			current = order[state.ContextIndex]
+		}
+
+		state.Shuffle = on
+		if on {
+			state.ShuffleSeed = rand.Int63()
+		}
+		order := h.playbackOrder(ids, state.Shuffle, state.ShuffleSeed)
+		if index := indexOf(order, current); index != -1 {
+			state.ContextIndex = index
+		}
+		return nil
+	})
+}
+
+// playerSetRepeat sets the repeat mode
+func (h *Handler) playerSetRepeat(userID int, mode string) error {
+	return h.modifyPlayback(userID, false, func(tx store.PlaybackTx, state *models.PlaybackState) error {
+		state.RepeatMode = mode
+		return nil
+	})
+}
+
+// playerAddToQueue appends a track to the end of the queue
+func (h *Handler) playerAddToQueue(userID, trackID int) error {
+	if !h.trackExists(trackID) {
+		return errTrackNotFound
+	}
+	return h.stores.Player.Enqueue(userID, trackID)
+}
+
+// playerRemoveFromQueue removes a queue item and closes the gap it leaves
+func (h *Handler) playerRemoveFromQueue(userID int, itemID int64) error {
+	err := h.stores.Player.RemoveFromQueue(userID, itemID)
+	if err == store.ErrNotFound {
+		return errQueueItemNotFound
+	}
+	return err
+}
+
+// playerMoveQueueItem moves a queue item to a new position
+func (h *Handler) playerMoveQueueItem(userID int, itemID int64, to int) error {
+	err := h.stores.Player.MoveInQueue(userID, itemID, to)
+	if err == store.ErrNotFound {
+		return errQueueItemNotFound
+	}
+	return err
+}
+
+// modifyPlayback loads the user's playback state under a row lock, settles the
+// progress of a playing track, applies mutate and saves the result. Callers go
+// through controlPlayback so connected devices learn about the change. skipping
+// is set by next and previous: only they can record a skip of the old track.
+func (h *Handler) modifyPlayback(userID int, skipping bool, mutate func(tx store.PlaybackTx, state *models.PlaybackState) error) error {
+	var previous, current models.PlaybackState
+	err := h.stores.Player.Update(userID, func(tx store.PlaybackTx, state *models.PlaybackState) error {
+		h.settleProgress(state)
+		state.PositionMs = state.ProgressMs
+		previous = *state
+		if err := mutate(tx, state); err != nil {
+			return err
+		}
+		state.ProgressMs = state.PositionMs
+		current = *state
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// A different track, or the same track coming from the queue, starts a new listen
+	if previous.TrackID != nil && (current.TrackID == nil || *current.TrackID != *previous.TrackID ||
+		current.FromQueue != previous.FromQueue) {
+		h.onTrackFinished(userID, *previous.TrackID, previous.ProgressMs, skipping)
+	}
+	return nil
+}
+
+// onTrackFinished runs after a user's player moved on from a track. Skipping to
+// the next or previous track before the end counts as a skip; starting other
+// music does not. In a group session the host's player drives
+// everyone, so the play is recorded for every member and the skip votes for the old
+// track are discarded.
+func (h *Handler) onTrackFinished(userID, trackID, playedMs int, skipping bool) {
+	track, err := h.stores.Tracks.Get(trackID)
+	if err != nil {
+		return
+	}
+	completed := playedMs >= track.Duration*1000-completionToleranceMs
+	if skipping && !completed {
+		h.recordSkip(userID, trackID)
+	}
+
+	session, err := h.stores.Sessions.Hosted(userID)
+	if err != nil {
+		return
+	}
+
+	h.stores.Sessions.NextRound(session.ID)
+	h.recordSessionPlays(session.ID, trackID, playedMs, completed)
+}
+
+// loadPlaybackState reads the user's playback state with the progress of a playing track
+func (h *Handler) loadPlaybackState(userID int) (*models.PlaybackState, error) {
+	state, err := h.stores.Player.State(userID)
+	if err != nil {
+		return nil, err
+	}
+	h.settleProgress(state)
+	return state, nil
+}
+
+// settleProgress sets ProgressMs to the position plus the time played since
+// the state was saved, capped at the end of the track
+func (h *Handler) settleProgress(state *models.PlaybackState) {
+	state.ProgressMs = state.PositionMs
+	if state.IsPlaying && state.TrackID != nil {
+		state.ProgressMs += int(time.Since(state.UpdatedAt) / time.Millisecond)
+		if track, err := h.stores.Tracks.Get(*state.TrackID); err == nil && state.ProgressMs > track.Duration*1000 {
+			state.ProgressMs = track.Duration * 1000
+		}
+	}
+}
+
+// getPlaybackView returns the playback state with track details, the queue and the
+// next tracks of the context
+func (h *Handler) getPlaybackView(userID int) (*models.PlaybackState, error) {
+	state, err := h.loadPlaybackState(userID)
+	if err != nil {
+		return nil, err
+	}
+	state.DeviceID = h.deviceHub.ActiveDevice(userID)
+
+	if state.TrackID != nil {
+		if tracks := h.getTrackDetailsByIDs([]int{*state.TrackID}); len(tracks) == 1 {
+			state.Track = &tracks[0]
+		}
+	}
+
+	queue, err := h.getPlaybackQueue(userID)
+	if err != nil {
+		return nil, err
+	}
+	state.Queue = queue
+
+	state.UpNext = []models.Track{}
+	if state.ContextType != "" {
+		// A context that became unavailable simply has nothing up next
+		if order, err := h.contextPlaybackOrder(state, userID); err == nil {
+			upcoming := []int{}
+			for i := state.ContextIndex + 1; i < len(order) && len(upcoming) < upNextSize; i++ {
+				upcoming = append(upcoming, order[i])
+			}
+			if state.RepeatMode == "context" {
+				for i := 0; i <= state.ContextIndex && i < len(order) && len(upcoming) < upNextSize; i++ {
+					upcoming = append(upcoming, order[i])
+				}
+			}
+			state.UpNext = h.getTrackDetailsByIDs(upcoming)
+		}
+	}
+	return state, nil
+}
+
+// getPlaybackQueue returns the user's queue in play order
+func (h *Handler) getPlaybackQueue(userID int) ([]models.QueueItem, error) {
+	queue, err := h.stores.Player.Queue(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	trackIDs := make([]int, len(queue))
+	for i, item := range queue {
+		trackIDs[i] = item.TrackID
+	}
+
+	tracks := map[int]models.Track{}
+	for _, track := range h.getTrackDetailsByIDs(trackIDs) {
+		tracks[track.ID] = track
+	}
+	for i := range queue {
+		if track, ok := tracks[queue[i].TrackID]; ok {
+			queue[i].Track = &track
+		}
+	}
+	return queue, nil
+}
+
+// contextPlaybackOrder returns the track IDs of the state's context in play order and
+// realigns the context index when the context changed since it was started
+func (h *Handler) contextPlaybackOrder(state *models.PlaybackState, userID int) ([]int, error) {
+	ids, err := h.resolvePlayContext(state.ContextType, *state.ContextID, userID)
+	if err != nil {
+		return nil, err
+	}
+	order := h.playbackOrder(ids, state.Shuffle, state.ShuffleSeed)
+
+	if !state.FromQueue && state.TrackID != nil &&
+		(state.ContextIndex >= len(order) || order[state.ContextIndex] != *state.TrackID) {
+		if index := indexOf(order, *state.TrackID); index != -1 {
+			state.ContextIndex = index
+		}
+	}
+	return order, nil
+}
+
+// resolvePlayContext returns the track IDs of an album, playlist or artist in natural order
+func (h *Handler) resolvePlayContext(contextType string, contextID, userID int) ([]int, error) {
+	switch contextType {
+	case "album":
+		if _, err := h.stores.Albums.Get(contextID); err != nil {
+			return nil, contextError(err)
+		}
+		return h.stores.Tracks.AlbumTrackIDs(contextID)
+	case "artist":
+		if _, err := h.stores.Artists.Get(contextID); err != nil {
+			return nil, contextError(err)
+		}
+		return h.stores.Tracks.ArtistTrackIDs(contextID)
+	case "playlist":
+		access, err := h.getPlaylistAccess(contextID, userID)
+		if err != nil {
+			return nil, contextError(err)
+		}
+		if !access.CanRead(userID) {
+			return nil, errContextForbidden
+		}
+		tracks, err := h.getPlaylistTracks(contextID, access.OwnerID, userID, access.IsSmart)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]int, len(tracks))
+		for i, track := range tracks {
+			ids[i] = track.ID
+		}
+		return ids, nil
+	}
+	return nil, errContextNotFound
+}
+
+// contextError maps a missing album, artist or playlist to errContextNotFound
+func contextError(err error) error {
+	if err == store.ErrNotFound {
+		return errContextNotFound
+	}
+	return err
+}
+
+// playbackOrder returns ids in the order they play. With shuffle the order is a
+// smart shuffle derived from seed, so it stays stable for the whole session.
+func (h *Handler) playbackOrder(ids []int, shuffled bool, seed int64) []int {
+	if !shuffled {
+		return ids
+	}
+	return h.smartShuffle(ids, seed)
+}
+
+func indexOf(ids []int, id int) int {
+	for i, v := range ids {
+		if v == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *Handler) trackExists(trackID int) bool {
+	_, err := h.stores.Tracks.Get(trackID)
+	return err == nil
+}
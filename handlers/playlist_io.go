@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"spotify-clone/models"
+	"spotify-clone/playlistio"
+	"spotify-clone/store"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize limits uploaded playlist files to 5 MB
+const maxImportSize = 5 << 20
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ExportPlaylist downloads a readable playlist as M3U8, XSPF or JSON
+// GET /api/v1/playlists/:id/export?format=m3u8|xspf|json
+func (h *Handler) ExportPlaylist(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", playlistio.FormatJSON))
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+
+	if format == "m3u" {
+		format = playlistio.FormatM3U8
+	}
+	if format != playlistio.FormatM3U8 && format != playlistio.FormatXSPF && format != playlistio.FormatJSON {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Use m3u8, xspf or json"})
+		return
+	}
+
+	access, err := h.getPlaylistAccess(playlistID, userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+	if !access.CanRead(userID.(int)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	playlist, err := h.stores.Playlists.Get(playlistID, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+
+	tracks, err := h.getPlaylistTracks(playlistID, access.OwnerID, userID.(int), access.IsSmart)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist tracks"})
+		return
+	}
+
+	file := &playlistio.Playlist{Name: playlist.Name, Description: playlist.Description}
+	for _, track := range tracks {
+		file.Entries = append(file.Entries, playlistio.Entry{
+			TrackID:  track.ID,
+			Title:    track.Title,
+			Artist:   track.ArtistName,
+			Album:    track.AlbumName,
+			Duration: track.Duration,
+			Location: track.FileURL,
+		})
+	}
+
+	data, err := playlistio.Encode(format, file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export playlist"})
+		return
+	}
+
+	filename := strings.Trim(unsafeFilenameChars.ReplaceAllString(playlist.Name, "_"), "_")
+	if filename == "" {
+		filename = "playlist"
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+"."+format+`"`)
+	c.Data(http.StatusOK, playlistio.ContentType(format), data)
+}
+
+// ImportPlaylist creates a new playlist from an uploaded M3U8, XSPF or JSON file.
+// The file can be sent as multipart field "file" or as the raw request body.
+// POST /api/v1/playlists/import?format=&name=&is_public=
+func (h *Handler) ImportPlaylist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var data []byte
+	var filename string
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		if fileHeader.Size > maxImportSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Playlist file is too large"})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		defer file.Close()
+		data, err = io.ReadAll(file)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		filename = fileHeader.Filename
+	} else {
+		data, err = io.ReadAll(io.LimitReader(c.Request.Body, maxImportSize+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		if len(data) > maxImportSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Playlist file is too large"})
+			return
+		}
+	}
+
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		format = playlistio.DetectFormat(filename, data)
+	}
+	if format == "m3u" {
+		format = playlistio.FormatM3U8
+	}
+	if format == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not detect playlist format. Pass format=m3u8, xspf or json"})
+		return
+	}
+
+	file, err := playlistio.Decode(format, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(file.Entries) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Playlist file contains no tracks"})
+		return
+	}
+
+	name := c.DefaultQuery("name", c.PostForm("name"))
+	if name == "" {
+		name = file.Name
+	}
+	if name == "" {
+		name = "Imported playlist"
+	}
+	isPublic := c.DefaultQuery("is_public", c.PostForm("is_public")) == "true"
+
+	// Match every entry against the catalog, skipping duplicates
+	matched := []models.PlaylistImportMatch{}
+	unmatched := []models.PlaylistImportMiss{}
+	seen := map[int]bool{}
+	for i, entry := range file.Entries {
+		match, ok := h.matchImportEntry(entry)
+		if !ok {
+			unmatched = append(unmatched, models.PlaylistImportMiss{
+				Index: i, Title: entry.Title, Artist: entry.Artist, Location: entry.Location,
+				Reason: "No matching track in catalog",
+			})
+			continue
+		}
+		if seen[match.TrackID] {
+			unmatched = append(unmatched, models.PlaylistImportMiss{
+				Index: i, Title: entry.Title, Artist: entry.Artist, Location: entry.Location,
+				Reason: "Duplicate of an earlier entry",
+			})
+			continue
+		}
+		seen[match.TrackID] = true
+		match.Index = i
+		matched = append(matched, match)
+	}
+
+	trackIDs := []int{}
+	for _, match := range matched {
+		trackIDs = append(trackIDs, match.TrackID)
+	}
+
+	playlist := models.Playlist{
+		UserID:      userID.(int),
+		Name:        name,
+		Description: file.Description,
+		IsPublic:    isPublic,
+		TrackIDs:    trackIDs,
+	}
+	if err := h.stores.Playlists.CreateWithTracks(&playlist, trackIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create playlist"})
+		return
+	}
+	h.schedulePlaylistCoverRefresh(playlist.ID)
+	h.recordActivity(userID.(int), activityCreatedPlaylist, playlist.ID, 0, 0)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"playlist":        playlist,
+		"format":          format,
+		"total_entries":   len(file.Entries),
+		"matched_count":   len(matched),
+		"unmatched_count": len(unmatched),
+		"matched":         matched,
+		"unmatched":       unmatched,
+	})
+}
+
+// matchImportEntry finds the catalog track for an imported entry by ID, file URL,
+// or fuzzy title/artist/duration comparison, in that order
+func (h *Handler) matchImportEntry(entry playlistio.Entry) (models.PlaylistImportMatch, bool) {
+	if entry.TrackID > 0 {
+		track, err := h.stores.Tracks.Get(entry.TrackID)
+		// An ID from another environment may point at a different song; only trust it
+		// when the title agrees or the file carries no title at all
+		if err == nil && (entry.Title == "" || playlistio.Similarity(entry.Title, track.Title) >= 0.5) {
+			return models.PlaylistImportMatch{TrackID: entry.TrackID, Method: "id"}, true
+		}
+	}
+
+	if entry.Location != "" {
+		if trackID, err := h.stores.Tracks.IDByFileURL(entry.Location); err == nil {
+			return models.PlaylistImportMatch{TrackID: trackID, Method: "file_url"}, true
+		}
+	}
+
+	if entry.Title == "" {
+		return models.PlaylistImportMatch{}, false
+	}
+
+	best, bestScore := 0, 0.0
+	for _, candidate := range h.fuzzyCandidates(entry) {
+		if score := playlistio.Score(entry, candidate); score > bestScore {
+			best, bestScore = candidate.TrackID, score
+		}
+	}
+	if bestScore < playlistio.MatchThreshold {
+		return models.PlaylistImportMatch{}, false
+	}
+	return models.PlaylistImportMatch{TrackID: best, Method: "fuzzy", Score: bestScore}, true
+}
+
+// fuzzyCandidates narrows the catalog to tracks sharing the most distinctive
+// title word or the artist name before scoring them in Go
+func (h *Handler) fuzzyCandidates(entry playlistio.Entry) []playlistio.Candidate {
+	keyword := ""
+	for _, word := range strings.Fields(playlistio.Normalize(entry.Title)) {
+		if len(word) > len(keyword) {
+			keyword = word
+		}
+	}
+	if keyword == "" {
+		return nil
+	}
+
+	tracks, err := h.stores.Tracks.MatchTitleOrArtist(keyword, entry.Artist, 200)
+	if err != nil {
+		return nil
+	}
+
+	candidates := make([]playlistio.Candidate, len(tracks))
+	for i, track := range tracks {
+		candidates[i] = playlistio.Candidate{
+			TrackID:  track.ID,
+			Title:    track.Title,
+			Artist:   track.ArtistName,
+			Duration: track.Duration,
+		}
+	}
+	return candidates
+}
@@ -1,16 +1,16 @@
 package handlers
 
 import (
-	"database/sql"
 	"net/http"
-	"spotify-clone/database"
 	"spotify-clone/models"
+	"spotify-clone/store"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 // CreatePlaylist creates a new playlist
-func CreatePlaylist(c *gin.Context) {
+func (h *Handler) CreatePlaylist(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
@@ -23,90 +23,79 @@ func CreatePlaylist(c *gin.Context) {
 		return
 	}
 
-	result, err := database.MySQL.Exec(`
-		INSERT INTO playlists (user_id, name, description, is_public)
-		VALUES (?, ?, ?, ?)`,
-		userID, req.Name, req.Description, req.IsPublic)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create playlist"})
-		return
-	}
-
-	playlistID, _ := result.LastInsertId()
-
 	playlist := models.Playlist{
-		ID:          int(playlistID),
 		UserID:      userID.(int),
 		Name:        req.Name,
 		Description: req.Description,
 		IsPublic:    req.IsPublic,
 		TrackIDs:    []int{},
 	}
+	if err := h.stores.Playlists.Create(&playlist); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create playlist"})
+		return
+	}
+	h.recordActivity(userID.(int), activityCreatedPlaylist, playlist.ID, 0, 0)
 
 	c.JSON(http.StatusCreated, playlist)
 }
 
 // GetUserPlaylists returns all playlists for the authenticated user
-func GetUserPlaylists(c *gin.Context) {
+func (h *Handler) GetUserPlaylists(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	rows, err := database.MySQL.Query(`
-		SELECT id, name, description, is_public, cover_url, created_at, updated_at
-		FROM playlists WHERE user_id = ?
-		ORDER BY updated_at DESC`, userID)
-
+	// Owned playlists, playlists the user collaborates on and public playlists they follow
+	playlists, err := h.stores.Playlists.ListForUser(userID.(int))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlists"})
 		return
 	}
-	defer rows.Close()
-
-	playlists := []models.Playlist{}
-	for rows.Next() {
-		var playlist models.Playlist
-		rows.Scan(&playlist.ID, &playlist.Name, &playlist.Description, &playlist.IsPublic,
-			&playlist.CoverURL, &playlist.CreatedAt, &playlist.UpdatedAt)
-		playlist.UserID = userID.(int)
 
-		// Get track count
-		var trackIDs []int
-		trackRows, _ := database.MySQL.Query("SELECT track_id FROM playlist_tracks WHERE playlist_id = ? ORDER BY position", playlist.ID)
-		for trackRows.Next() {
-			var trackID int
-			trackRows.Scan(&trackID)
-			trackIDs = append(trackIDs, trackID)
+	// Smart playlists have no stored tracks; resolve their rules instead
+	for i, playlist := range playlists {
+		if !playlist.IsSmart {
+			continue
+		}
+		listenerID, err := h.smartListener(playlist.UserID, userID.(int))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+			return
+		}
+		if definition, err := h.stores.Playlists.SmartDefinition(playlist.ID); err == nil {
+			tracks, _ := h.stores.Playlists.SmartTracks(definition, listenerID)
+			for _, track := range tracks {
+				playlists[i].TrackIDs = append(playlists[i].TrackIDs, track.ID)
+			}
 		}
-		trackRows.Close()
-		playlist.TrackIDs = trackIDs
-
-		playlists = append(playlists, playlist)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"playlists": playlists})
+	// The virtual "Liked Songs" playlist is served by GetLikedSongs
+	likedCount, _ := h.stores.Users.SavedTrackCount(userID.(int))
+
+	c.JSON(http.StatusOK, gin.H{
+		"playlists":         playlists,
+		"liked_songs_count": likedCount,
+	})
 }
 
 // GetPlaylistByID returns a single playlist with full track details
-func GetPlaylistByID(c *gin.Context) {
-	playlistID := c.Param("id")
+func (h *Handler) GetPlaylistByID(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	var playlist models.Playlist
-	err := database.MySQL.QueryRow(`
-		SELECT id, user_id, name, description, is_public, cover_url, created_at, updated_at
-		FROM playlists WHERE id = ?`, playlistID).Scan(
-		&playlist.ID, &playlist.UserID, &playlist.Name, &playlist.Description,
-		&playlist.IsPublic, &playlist.CoverURL, &playlist.CreatedAt, &playlist.UpdatedAt)
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
 
-	if err == sql.ErrNoRows {
+	playlist, err := h.stores.Playlists.Get(playlistID, userID.(int))
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
 		return
 	}
@@ -115,52 +104,83 @@ func GetPlaylistByID(c *gin.Context) {
 		return
 	}
 
-	// Check if user has access (owner or public playlist)
-	if playlist.UserID != userID.(int) && !playlist.IsPublic {
+	// Check if user has access (owner, collaborator or public playlist)
+	collaborators, err := h.stores.Playlists.Collaborators(playlist.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collaborators"})
+		return
+	}
+	if playlist.UserID != userID.(int) && !playlist.IsPublic && !hasCollaborator(collaborators, userID.(int)) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
 
-	// Fetch track details
-	tracks := []models.Track{}
-	rows, err := database.MySQL.Query(`
-		SELECT t.id, t.title, t.artist_id, a.name as artist_name,
-		       t.album_id, al.title as album_name, t.duration,
-		       t.genre, t.release_date, t.file_url, t.cover_url, t.created_at
-		FROM playlist_tracks pt
-		JOIN tracks t ON pt.track_id = t.id
-		JOIN artists a ON t.artist_id = a.id
-		JOIN albums al ON t.album_id = al.id
-		WHERE pt.playlist_id = ?
-		ORDER BY pt.position`, playlistID)
-
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var track models.Track
-			rows.Scan(&track.ID, &track.Title, &track.ArtistID, &track.ArtistName,
-				&track.AlbumID, &track.AlbumName, &track.Duration, &track.Genre,
-				&track.ReleaseDate, &track.FileURL, &track.CoverURL, &track.CreatedAt)
-			tracks = append(tracks, track)
+	response := gin.H{
+		"collaborative": len(collaborators) > 0,
+		"collaborators": collaborators,
+	}
+
+	// Smart playlists resolve their rules against the catalog on every read
+	if playlist.IsSmart {
+		definition, err := h.stores.Playlists.SmartDefinition(playlist.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load smart playlist rules"})
+			return
+		}
+		listenerID, err := h.smartListener(playlist.UserID, userID.(int))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+			return
+		}
+		tracks, err := h.stores.Playlists.SmartTracks(definition, listenerID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve smart playlist"})
+			return
+		}
+
+		items := []models.PlaylistItem{}
+		for i, track := range tracks {
+			items = append(items, models.PlaylistItem{TrackID: track.ID, Position: i})
 			playlist.TrackIDs = append(playlist.TrackIDs, track.ID)
 		}
+
+		response["playlist"] = playlist
+		response["tracks"] = tracks
+		response["items"] = items
+		response["definition"] = definition
+		c.JSON(http.StatusOK, response)
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"playlist": playlist,
-		"tracks":   tracks,
-	})
+	// Fetch track details along with who added each track
+	tracks, items, err := h.stores.Playlists.Items(playlist.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist tracks"})
+		return
+	}
+	for _, track := range tracks {
+		playlist.TrackIDs = append(playlist.TrackIDs, track.ID)
+	}
+
+	response["playlist"] = playlist
+	response["tracks"] = tracks
+	response["items"] = items
+	c.JSON(http.StatusOK, response)
 }
 
 // AddTrackToPlaylist adds a track to a playlist
-func AddTrackToPlaylist(c *gin.Context) {
-	playlistID := c.Param("id")
+func (h *Handler) AddTrackToPlaylist(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+
 	var req models.AddTrackToPlaylistRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -168,85 +188,90 @@ func AddTrackToPlaylist(c *gin.Context) {
 	}
 
 	// Verify track exists
-	var trackExists bool
-	err := database.MySQL.QueryRow("SELECT EXISTS(SELECT 1 FROM tracks WHERE id = ?)", req.TrackID).Scan(&trackExists)
-	if err != nil || !trackExists {
+	if !h.trackExists(req.TrackID) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
 		return
 	}
 
-	// Check if user owns the playlist
-	var ownerID int
-	err = database.MySQL.QueryRow("SELECT user_id FROM playlists WHERE id = ?", playlistID).Scan(&ownerID)
-	if err == sql.ErrNoRows {
+	// Check if user owns or collaborates on the playlist
+	access, err := h.getPlaylistAccess(playlistID, userID.(int))
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
 		return
 	}
-	if ownerID != userID.(int) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+	if !access.CanEdit(userID.(int)) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
-
-	// Get current max position
-	var maxPosition int
-	database.MySQL.QueryRow("SELECT COALESCE(MAX(position), -1) FROM playlist_tracks WHERE playlist_id = ?", playlistID).Scan(&maxPosition)
+	if access.IsSmart {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tracks of a smart playlist are managed by its rules"})
+		return
+	}
 
 	// Add track to playlist
-	_, err = database.MySQL.Exec(`
-		INSERT INTO playlist_tracks (playlist_id, track_id, position)
-		VALUES (?, ?, ?)
-		ON DUPLICATE KEY UPDATE position = VALUES(position)`,
-		playlistID, req.TrackID, maxPosition+1)
-
-	if err != nil {
+	if err := h.stores.Playlists.AddTrack(playlistID, req.TrackID, userID.(int)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add track to playlist"})
 		return
 	}
-
-	// Update playlist updated_at
-	database.MySQL.Exec("UPDATE playlists SET updated_at = NOW() WHERE id = ?", playlistID)
+	h.schedulePlaylistCoverRefresh(playlistID)
+	h.recordActivity(userID.(int), activityAddedTrack, playlistID, req.TrackID, 0)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Track added to playlist successfully"})
 }
 
 // RemoveTrackFromPlaylist removes a track from a playlist
-func RemoveTrackFromPlaylist(c *gin.Context) {
-	playlistID := c.Param("id")
-	trackID := c.Param("trackId")
+func (h *Handler) RemoveTrackFromPlaylist(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	// Check if user owns the playlist
-	var ownerID int
-	err := database.MySQL.QueryRow("SELECT user_id FROM playlists WHERE id = ?", playlistID).Scan(&ownerID)
-	if err == sql.ErrNoRows {
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+	trackID, err := strconv.Atoi(c.Param("trackId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid track ID"})
+		return
+	}
+
+	// Check if user owns or collaborates on the playlist
+	access, err := h.getPlaylistAccess(playlistID, userID.(int))
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
 		return
 	}
-	if ownerID != userID.(int) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+	if !access.CanEdit(userID.(int)) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
+	if access.IsSmart {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tracks of a smart playlist are managed by its rules"})
+		return
+	}
 
 	// Remove track from playlist
-	_, err = database.MySQL.Exec("DELETE FROM playlist_tracks WHERE playlist_id = ? AND track_id = ?", playlistID, trackID)
-	if err != nil {
+	if err := h.stores.Playlists.RemoveTrack(playlistID, trackID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove track from playlist"})
 		return
 	}
-
-	// Update playlist updated_at
-	database.MySQL.Exec("UPDATE playlists SET updated_at = NOW() WHERE id = ?", playlistID)
+	h.schedulePlaylistCoverRefresh(playlistID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Track removed from playlist successfully"})
 }
 
 // DeletePlaylist deletes a playlist
-func DeletePlaylist(c *gin.Context) {
-	playlistID := c.Param("id")
+func (h *Handler) DeletePlaylist(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
@@ -254,20 +279,12 @@ func DeletePlaylist(c *gin.Context) {
 	}
 
 	// Check if user owns the playlist
-	var ownerID int
-	err := database.MySQL.QueryRow("SELECT user_id FROM playlists WHERE id = ?", playlistID).Scan(&ownerID)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
-		return
-	}
-	if ownerID != userID.(int) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	playlistID, ok := h.requirePlaylistOwner(c, userID.(int))
+	if !ok {
 		return
 	}
 
-	// Delete playlist (CASCADE will delete playlist_tracks)
-	_, err = database.MySQL.Exec("DELETE FROM playlists WHERE id = ?", playlistID)
-	if err != nil {
+	if err := h.stores.Playlists.Delete(playlistID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete playlist"})
 		return
 	}
@@ -276,8 +293,7 @@ func DeletePlaylist(c *gin.Context) {
 }
 
 // UpdatePlaylist updates playlist details
-func UpdatePlaylist(c *gin.Context) {
-	playlistID := c.Param("id")
+func (h *Handler) UpdatePlaylist(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
@@ -291,28 +307,159 @@ func UpdatePlaylist(c *gin.Context) {
 	}
 
 	// Check if user owns the playlist
-	var ownerID int
-	err := database.MySQL.QueryRow("SELECT user_id FROM playlists WHERE id = ?", playlistID).Scan(&ownerID)
-	if err == sql.ErrNoRows {
+	playlistID, ok := h.requirePlaylistOwner(c, userID.(int))
+	if !ok {
+		return
+	}
+
+	if err := h.stores.Playlists.Update(playlistID, req.Name, req.Description, req.IsPublic); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update playlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Playlist updated successfully"})
+}
+
+// MovePlaylistTrack moves a track to a new position within a playlist
+func (h *Handler) MovePlaylistTrack(c *gin.Context) {
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+	trackID, err := strconv.Atoi(c.Param("trackId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid track ID"})
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.MovePlaylistTrackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if user owns or collaborates on the playlist
+	access, err := h.getPlaylistAccess(playlistID, userID.(int))
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
 		return
 	}
-	if ownerID != userID.(int) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+	if !access.CanEdit(userID.(int)) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
+	if access.IsSmart {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tracks of a smart playlist are managed by its rules"})
+		return
+	}
 
-	// Update playlist
-	_, err = database.MySQL.Exec(`
-		UPDATE playlists 
-		SET name = ?, description = ?, is_public = ?, updated_at = NOW()
-		WHERE id = ?`,
-		req.Name, req.Description, req.IsPublic, playlistID)
-
+	order, err := h.stores.Playlists.MoveTrack(playlistID, trackID, *req.Position)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Track not in playlist"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update playlist"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder playlist"})
 		return
 	}
+	h.schedulePlaylistCoverRefresh(playlistID)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Playlist updated successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Track moved successfully", "track_ids": order})
+}
+
+// requirePlaylistOwner parses the playlist ID route parameter and checks that the
+// user owns the playlist. When it returns false the error response has been
+// written already.
+func (h *Handler) requirePlaylistOwner(c *gin.Context, userID int) (int, bool) {
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return 0, false
+	}
+
+	access, err := h.stores.Playlists.Access(playlistID, userID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		return 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return 0, false
+	}
+	if !access.IsOwner(userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return 0, false
+	}
+	return playlistID, true
+}
+
+// playlistIDParam parses the playlist ID route parameter. An ID that is not a
+// number names no playlist, so it gets the 404 of a missing one. When it
+// returns false the error response has been written already.
+func playlistIDParam(c *gin.Context) (int, bool) {
+	playlistID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		return 0, false
+	}
+	return playlistID, true
+}
+
+// getPlaylistAccess loads ownership, visibility and collaborator status for a
+// playlist. Returns store.ErrNotFound when the playlist does not exist.
+func (h *Handler) getPlaylistAccess(playlistID, userID int) (*store.PlaylistAccess, error) {
+	return h.stores.Playlists.Access(playlistID, userID)
+}
+
+func hasCollaborator(collaborators []models.PlaylistCollaborator, userID int) bool {
+	for _, collaborator := range collaborators {
+		if collaborator.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// getPlaylistTracks returns the tracks of a playlist in order as viewerID sees
+// them, resolving smart playlist rules
+func (h *Handler) getPlaylistTracks(playlistID, ownerID, viewerID int, isSmart bool) ([]models.Track, error) {
+	if isSmart {
+		definition, err := h.stores.Playlists.SmartDefinition(playlistID)
+		if err != nil {
+			return nil, err
+		}
+		listenerID, err := h.smartListener(ownerID, viewerID)
+		if err != nil {
+			return nil, err
+		}
+		return h.stores.Playlists.SmartTracks(definition, listenerID)
+	}
+
+	return h.stores.Playlists.Tracks(playlistID)
+}
+
+// smartListener returns whose plays the played_by_me rules of ownerID's smart
+// playlists read when viewerID looks at them: the owner's, or nobody's (0) when
+// the owner hides their listening activity. viewerID 0 is any reader, as for
+// shared covers.
+func (h *Handler) smartListener(ownerID, viewerID int) (int, error) {
+	if viewerID == ownerID {
+		return ownerID, nil
+	}
+	settings, err := h.getPrivacySettings(ownerID)
+	if err != nil {
+		return 0, err
+	}
+	if settings.HideListeningActivity {
+		return 0, nil
+	}
+	return ownerID, nil
 }
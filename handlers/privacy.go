@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"net/http"
+	"spotify-clone/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultPrivateSession is how long a private session lasts when no duration is given
+const defaultPrivateSession = 6 * time.Hour
+
+// GetPrivacySettings returns the authenticated user's privacy settings
+// GET /api/v1/profile/privacy
+func (h *Handler) GetPrivacySettings(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	settings, err := h.getPrivacySettings(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdatePrivacySettings changes the authenticated user's privacy settings
+// PUT /api/v1/profile/privacy
+func (h *Handler) UpdatePrivacySettings(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.UpdatePrivacySettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.getPrivacySettings(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+		return
+	}
+	if req.ShowPlaylists != nil {
+		settings.ShowPlaylists = *req.ShowPlaylists
+	}
+	if req.ShowFollows != nil {
+		settings.ShowFollows = *req.ShowFollows
+	}
+	if req.ShowTopArtists != nil {
+		settings.ShowTopArtists = *req.ShowTopArtists
+	}
+	if req.HideListeningActivity != nil {
+		settings.HideListeningActivity = *req.HideListeningActivity
+	}
+	if req.ExcludeFromRecommendations != nil {
+		settings.ExcludeFromRecommendations = *req.ExcludeFromRecommendations
+	}
+
+	if err := h.stores.Privacy.Update(userID.(int), settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update privacy settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// StartPrivateSession stops recording the user's plays until the session ends
+// POST /api/v1/profile/private-session
+func (h *Handler) StartPrivateSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.StartPrivateSessionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	duration := defaultPrivateSession
+	if req.DurationMinutes > 0 {
+		duration = time.Duration(req.DurationMinutes) * time.Minute
+	}
+	until := time.Now().Add(duration)
+
+	if err := h.stores.Privacy.SetPrivateSession(userID.(int), &until); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start private session"})
+		return
+	}
+
+	settings, err := h.getPrivacySettings(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// EndPrivateSession resumes recording the user's plays
+// DELETE /api/v1/profile/private-session
+func (h *Handler) EndPrivateSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.stores.Privacy.SetPrivateSession(userID.(int), nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end private session"})
+		return
+	}
+
+	settings, err := h.getPrivacySettings(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// getPrivacySettings returns a user's privacy settings; users without a row get the
+// defaults, which show everything and record every play. Callers must not fall
+// back to the defaults on an error, as that would show what the user hid.
+func (h *Handler) getPrivacySettings(userID int) (models.PrivacySettings, error) {
+	return h.stores.Privacy.Get(userID)
+}
+
+// inPrivateSession reports whether plays of the user must currently not be
+// recorded. Callers record nothing when it fails.
+func (h *Handler) inPrivateSession(userID int) (bool, error) {
+	settings, err := h.getPrivacySettings(userID)
+	return settings.PrivateSession, err
+}
+
+// canViewSection reports whether viewerID may see a section of ownerID's profile
+func (h *Handler) canViewSection(viewerID, ownerID int, visible func(models.PrivacySettings) bool) (bool, error) {
+	if viewerID == ownerID {
+		return true, nil
+	}
+	settings, err := h.getPrivacySettings(ownerID)
+	if err != nil {
+		return false, err
+	}
+	return visible(settings), nil
+}
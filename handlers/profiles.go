@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"spotify-clone/models"
+	"spotify-clone/store"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// topArtistsWindowDays is how far back plays count towards a profile's top artists
+const topArtistsWindowDays = 90
+
+// GetPublicProfile returns the public view of a user's profile
+// GET /api/v1/users/:username
+func (h *Handler) GetPublicProfile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := h.stores.Users.GetByUsername(c.Param("username"))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	profile := models.PublicProfile{
+		ID:                user.ID,
+		Username:          user.Username,
+		DisplayName:       user.DisplayName,
+		ProfilePictureURL: user.ProfilePictureURL,
+		CreatedAt:         user.CreatedAt,
+	}
+
+	// Owners always see their full profile
+	privacy, err := h.getPrivacySettings(profile.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+		return
+	}
+	if profile.ID == userID.(int) {
+		privacy = models.PrivacySettings{ShowPlaylists: true, ShowFollows: true, ShowTopArtists: true}
+	}
+	if privacy.HideListeningActivity {
+		privacy.ShowTopArtists = false
+	}
+	profile.HiddenSections = []string{}
+
+	profile.IsFollowing, _ = h.stores.Social.IsFollowing(userID.(int), profile.ID)
+
+	if privacy.ShowFollows {
+		followers, following, _ := h.stores.Social.FollowCounts(profile.ID)
+		profile.FollowerCount = &followers
+		profile.FollowingCount = &following
+	} else {
+		profile.HiddenSections = append(profile.HiddenSections, "follows")
+	}
+
+	if privacy.ShowPlaylists {
+		playlists, err := h.stores.Playlists.PublicByOwner(profile.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlists"})
+			return
+		}
+		profile.Playlists = playlists
+	} else {
+		profile.HiddenSections = append(profile.HiddenSections, "playlists")
+	}
+
+	if privacy.ShowTopArtists {
+		topArtists, err := h.stores.Plays.TopArtists(profile.ID, time.Now().AddDate(0, 0, -topArtistsWindowDays), 10)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch top artists"})
+			return
+		}
+		profile.TopArtists = topArtists
+	} else {
+		profile.HiddenSections = append(profile.HiddenSections, "top_artists")
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"spotify-clone/models"
+	"spotify-clone/store"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultRadioBatch = 20
+	maxRadioBatch     = 50
+	// maxRadioSeeds caps how many tracks of an artist, album, genre or playlist
+	// seed the similarity search
+	maxRadioSeeds = 25
+	// maxArtistTracksPerBatch keeps one artist from filling a batch while other
+	// candidates are left
+	maxArtistTracksPerBatch = 2
+	// radioSkipPenalty is subtracted from a candidate's score for every recent skip
+	// of a track by the same artist
+	radioSkipPenalty = 1.0
+	// radioSkipWindowDays is how long a skip keeps a track off the user's stations
+	radioSkipWindowDays = 30
+)
+
+var (
+	errStationNotFound = errors.New("Station not found")
+	errSeedNotFound    = errors.New("Seed not found")
+	errSeedEmpty       = errors.New("Seed has no tracks")
+)
+
+// CreateRadio starts a station from a track, artist, album, genre or playlist and
+// returns its first batch of tracks
+// POST /api/v1/radio
+func (h *Handler) CreateRadio(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.CreateRadioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.SeedType == "genre" && req.Genre == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "genre is required for a genre seed"})
+		return
+	}
+	if req.SeedType != "genre" && req.SeedID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seed_id is required"})
+		return
+	}
+
+	station := models.RadioStation{SeedType: req.SeedType}
+	if req.SeedType == "genre" {
+		station.SeedGenre = req.Genre
+	} else {
+		station.SeedID = &req.SeedID
+	}
+
+	_, name, err := h.resolveRadioSeed(&station, userID.(int))
+	if err != nil {
+		c.JSON(radioErrorStatus(err), gin.H{"error": radioErrorMessage(err, "Failed to start radio")})
+		return
+	}
+	station.Name = name + " Radio"
+
+	if err := h.stores.Radio.Create(userID.(int), &station); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start radio"})
+		return
+	}
+
+	batch, err := h.nextRadioBatch(station.ID, userID.(int), radioBatchSize(req.Limit))
+	if err != nil {
+		c.JSON(radioErrorStatus(err), gin.H{"error": radioErrorMessage(err, "Failed to start radio")})
+		return
+	}
+	c.JSON(http.StatusCreated, batch)
+}
+
+// GetRadioStation returns one of the user's stations
+// GET /api/v1/radio/:id
+func (h *Handler) GetRadioStation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	stationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Station not found"})
+		return
+	}
+
+	station, err := h.stores.Radio.Get(stationID, userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Station not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch station"})
+		return
+	}
+
+	c.JSON(http.StatusOK, station)
+}
+
+// GetNextRadioBatch extends a station with tracks it has not played yet
+// POST /api/v1/radio/:id/next?limit=20
+func (h *Handler) GetNextRadioBatch(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	stationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid station ID"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	batch, err := h.nextRadioBatch(stationID, userID.(int), radioBatchSize(limit))
+	if err != nil {
+		c.JSON(radioErrorStatus(err), gin.H{"error": radioErrorMessage(err, "Failed to extend station")})
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// SkipRadioTrack records that the user skipped a track the station played, for
+// clients that play a station's batches themselves instead of through the player
+// POST /api/v1/radio/:id/skips
+func (h *Handler) SkipRadioTrack(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.RadioSkipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A station ID that is not a number never served anything
+	stationID, _ := strconv.Atoi(c.Param("id"))
+	served, err := h.stores.Radio.Served(stationID, userID.(int), req.TrackID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record skip"})
+		return
+	}
+	if !served {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Track was not played by this station"})
+		return
+	}
+
+	h.recordSkip(userID.(int), req.TrackID)
+	c.JSON(http.StatusOK, gin.H{"message": "Skip recorded"})
+}
+
+// DeleteRadioStation removes a station and its history
+// DELETE /api/v1/radio/:id
+func (h *Handler) DeleteRadioStation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	stationID, _ := strconv.Atoi(c.Param("id"))
+	err := h.stores.Radio.Delete(stationID, userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Station not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete station"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Station deleted"})
+}
+
+// nextRadioBatch picks the station's next tracks and remembers them so no later
+// batch repeats them. Candidates come, in order of preference, from tracks similar
+// to the seed, tracks similar to what the station played most recently (so the
+// station drifts outward once the seed's neighbourhood is used up), and popular
+// tracks. Tracks the user skipped lately are left out and their artists ranked
+// lower. Only once the whole catalog has been played does the station start over.
+func (h *Handler) nextRadioBatch(stationID, userID, limit int) (*models.RadioBatch, error) {
+	var trackIDs []int
+	station, err := h.stores.Radio.NextBatch(stationID, userID, func(station *models.RadioStation, batch int) ([]int, error) {
+		seeds, _, err := h.resolveRadioSeed(station, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates, err := h.radioCandidates(stationID, userID, seeds, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(candidates) == 0 && batch > 1 {
+			// Everything was played: forget all but the latest batch and start over
+			if err := h.stores.Radio.ForgetBatches(stationID, batch-1); err != nil {
+				return nil, err
+			}
+			if candidates, err = h.radioCandidates(stationID, userID, seeds, limit); err != nil {
+				return nil, err
+			}
+		}
+
+		trackIDs = pickRadioTracks(candidates, limit)
+		return trackIDs, nil
+	})
+	if err == store.ErrNotFound {
+		return nil, errStationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.RadioBatch{Station: *station, Tracks: h.getTrackDetailsByIDs(trackIDs)}, nil
+}
+
+// radioCandidates returns the tracks the station may play next, best first
+func (h *Handler) radioCandidates(stationID, userID int, seeds []int, limit int) ([]store.ScoredTrack, error) {
+	since := time.Now().AddDate(0, 0, -radioSkipWindowDays)
+	filter := store.CandidateFilter{StationID: stationID, SkippedBy: userID, SkippedSince: since}
+	skipsByArtist, err := h.stores.Radio.RecentSkipsByArtist(userID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	// The seed tracks themselves are never played by the station
+	seen := map[int]bool{}
+	for _, id := range seeds {
+		seen[id] = true
+	}
+	candidates := []store.ScoredTrack{}
+	add := func(tracks []store.ScoredTrack) {
+		for i := range tracks {
+			tracks[i].Score -= radioSkipPenalty * float64(skipsByArtist[tracks[i].ArtistID])
+		}
+		sort.SliceStable(tracks, func(i, j int) bool { return tracks[i].Score > tracks[j].Score })
+		for _, track := range tracks {
+			if !seen[track.ID] {
+				seen[track.ID] = true
+				candidates = append(candidates, track)
+			}
+		}
+	}
+
+	similar, err := h.stores.Recommendations.Similar(seeds, filter, limit*4)
+	if err != nil {
+		return nil, err
+	}
+	add(similar)
+
+	if len(candidates) < limit*2 {
+		recent, err := h.stores.Radio.RecentTracks(stationID, maxRadioSeeds)
+		if err != nil {
+			return nil, err
+		}
+		if len(recent) > 0 {
+			similar, err := h.stores.Recommendations.Similar(recent, filter, limit*4)
+			if err != nil {
+				return nil, err
+			}
+			add(similar)
+		}
+	}
+
+	if len(candidates) < limit {
+		popular, err := h.stores.Recommendations.Popular(filter, limit*2)
+		if err != nil {
+			return nil, err
+		}
+		// Popularity only orders candidates among themselves
+		for i := range popular {
+			popular[i].Score = 0
+		}
+		add(popular)
+	}
+	return candidates, nil
+}
+
+// pickRadioTracks takes up to limit candidates in order, at most
+// maxArtistTracksPerBatch per artist unless nothing else is left
+func pickRadioTracks(candidates []store.ScoredTrack, limit int) []int {
+	trackIDs := []int{}
+	perArtist := map[int]int{}
+	overflow := []int{}
+	for _, track := range candidates {
+		if len(trackIDs) == limit {
+			break
+		}
+		if perArtist[track.ArtistID] >= maxArtistTracksPerBatch {
+			overflow = append(overflow, track.ID)
+			continue
+		}
+		perArtist[track.ArtistID]++
+		trackIDs = append(trackIDs, track.ID)
+	}
+	for _, id := range overflow {
+		if len(trackIDs) == limit {
+			break
+		}
+		trackIDs = append(trackIDs, id)
+	}
+	return trackIDs
+}
+
+// resolveRadioSeed returns the tracks a station's seed stands for and the seed's
+// name. Seeds are resolved again for every batch, so a station follows edits to
+// its playlist and stops when the playlist is no longer readable.
+func (h *Handler) resolveRadioSeed(station *models.RadioStation, userID int) ([]int, string, error) {
+	var name string
+	var seeds []int
+	var err error
+
+	switch station.SeedType {
+	case "track":
+		var track *models.Track
+		if track, err = h.stores.Tracks.Get(*station.SeedID); err == nil {
+			name = track.Title
+			seeds = []int{track.ID}
+		}
+	case "artist", "album", "playlist":
+		if name, err = h.radioSeedName(station.SeedType, *station.SeedID); err == nil {
+			seeds, err = h.resolvePlayContext(station.SeedType, *station.SeedID, userID)
+		}
+	case "genre":
+		name = station.SeedGenre
+		seeds, err = h.stores.Recommendations.GenreTop(station.SeedGenre, maxRadioSeeds)
+	}
+
+	switch {
+	case err == store.ErrNotFound || err == errContextNotFound:
+		return nil, "", errSeedNotFound
+	case err != nil:
+		return nil, "", err
+	case len(seeds) == 0:
+		return nil, "", errSeedEmpty
+	}
+	return sampleSeeds(seeds, maxRadioSeeds), name, nil
+}
+
+// sampleSeeds spreads at most n picks evenly over ids, keeping their order
+func sampleSeeds(ids []int, n int) []int {
+	if len(ids) <= n {
+		return ids
+	}
+	sample := make([]int, n)
+	for i := range sample {
+		sample[i] = ids[i*len(ids)/n]
+	}
+	return sample
+}
+
+// radioSeedName returns the name of the artist, album or playlist a station is seeded with
+func (h *Handler) radioSeedName(seedType string, id int) (string, error) {
+	switch seedType {
+	case "artist":
+		artist, err := h.stores.Artists.Get(id)
+		if err != nil {
+			return "", err
+		}
+		return artist.Name, nil
+	case "album":
+		album, err := h.stores.Albums.Get(id)
+		if err != nil {
+			return "", err
+		}
+		return album.Title, nil
+	}
+	playlist, err := h.stores.Playlists.Get(id, 0)
+	if err != nil {
+		return "", err
+	}
+	return playlist.Name, nil
+}
+
+// recordSkip remembers that the user skipped a track, unless they are in a private session
+func (h *Handler) recordSkip(userID, trackID int) {
+	private, err := h.inPrivateSession(userID)
+	if err != nil {
+		log.Printf("Failed to check the private session of user %d, skip not recorded: %v", userID, err)
+		return
+	}
+	if private {
+		return
+	}
+	if err := h.stores.Radio.RecordSkip(userID, trackID); err != nil {
+		log.Printf("Failed to record skip for user %d: %v", userID, err)
+	}
+}
+
+// radioBatchSize applies the default and upper bound to a requested batch size
+func radioBatchSize(limit int) int {
+	if limit <= 0 {
+		return defaultRadioBatch
+	}
+	if limit > maxRadioBatch {
+		return maxRadioBatch
+	}
+	return limit
+}
+
+func radioErrorStatus(err error) int {
+	switch err {
+	case errStationNotFound, errSeedNotFound:
+		return http.StatusNotFound
+	case errContextForbidden:
+		return http.StatusForbidden
+	case errSeedEmpty:
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+func radioErrorMessage(err error, failure string) string {
+	if radioErrorStatus(err) == http.StatusInternalServerError {
+		return failure
+	}
+	return err.Error()
+}
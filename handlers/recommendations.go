@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"spotify-clone/database"
 	"spotify-clone/models"
+	"spotify-clone/store"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -12,7 +13,7 @@ import (
 )
 
 // GetRecommendations returns personalized track recommendations based on user's listening history
-func GetRecommendations(c *gin.Context) {
+func (h *Handler) GetRecommendations(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
@@ -21,6 +22,20 @@ func GetRecommendations(c *gin.Context) {
 
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 
+	// Without the graph database, score the catalog from the user's library
+	if database.Neo4j == nil {
+		trackIDs := h.getLibraryRecommendations(userID.(int), limit)
+		if len(trackIDs) == 0 {
+			trackIDs = h.getPopularTracks(limit)
+		}
+
+		c.JSON(http.StatusOK, models.RecommendationResponse{
+			Tracks: h.getTrackDetailsByIDs(trackIDs),
+			Reason: "Based on your library and listening history",
+		})
+		return
+	}
+
 	ctx := context.Background()
 	session := database.Neo4j.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
 	defer session.Close(ctx)
@@ -61,7 +76,7 @@ func GetRecommendations(c *gin.Context) {
 	`
 
 	params := map[string]interface{}{
-		"userId": userID.(string),
+		"userId": userID.(int),
 		"limit":  limit,
 	}
 
@@ -85,15 +100,18 @@ func GetRecommendations(c *gin.Context) {
 		return
 	}
 
-	// If no recommendations found, get popular tracks
+	// If no recommendations found, fall back to library signals, then popular tracks
 	if len(trackIDs) == 0 {
-		trackIDs = getPopularTracks(limit)
+		trackIDs = h.getLibraryRecommendations(userID.(int), limit)
+	}
+	if len(trackIDs) == 0 {
+		trackIDs = h.getPopularTracks(limit)
 	}
 
 	// Fetch track details from MySQL
 	tracks := []models.Track{}
 	if len(trackIDs) > 0 {
-		tracks = getTrackDetailsByIDs(trackIDs)
+		tracks = h.getTrackDetailsByIDs(trackIDs)
 	}
 
 	c.JSON(http.StatusOK, models.RecommendationResponse{
@@ -103,7 +121,7 @@ func GetRecommendations(c *gin.Context) {
 }
 
 // GetSimilarTracks returns tracks similar to a given track
-func GetSimilarTracks(c *gin.Context) {
+func (h *Handler) GetSimilarTracks(c *gin.Context) {
 	trackID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid track ID"})
@@ -112,6 +130,25 @@ func GetSimilarTracks(c *gin.Context) {
 
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 
+	// Without the graph database, score the same signals in the stores
+	if database.Neo4j == nil {
+		similar, err := h.stores.Recommendations.Similar([]int{trackID}, store.CandidateFilter{}, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get similar tracks"})
+			return
+		}
+		trackIDs := make([]int, len(similar))
+		for i, track := range similar {
+			trackIDs[i] = track.ID
+		}
+
+		c.JSON(http.StatusOK, models.RecommendationResponse{
+			Tracks: h.getTrackDetailsByIDs(trackIDs),
+			Reason: "Tracks similar to what you're listening to",
+		})
+		return
+	}
+
 	ctx := context.Background()
 	session := database.Neo4j.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
 	defer session.Close(ctx)
@@ -178,7 +215,7 @@ func GetSimilarTracks(c *gin.Context) {
 	// Fetch track details from MySQL
 	tracks := []models.Track{}
 	if len(trackIDs) > 0 {
-		tracks = getTrackDetailsByIDs(trackIDs)
+		tracks = h.getTrackDetailsByIDs(trackIDs)
 	}
 
 	c.JSON(http.StatusOK, models.RecommendationResponse{
@@ -188,9 +225,18 @@ func GetSimilarTracks(c *gin.Context) {
 }
 
 // GetTrendingTracks returns currently trending tracks
-func GetTrendingTracks(c *gin.Context) {
+func (h *Handler) GetTrendingTracks(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 
+	// Without the graph database, the most played tracks stand in
+	if database.Neo4j == nil {
+		c.JSON(http.StatusOK, models.RecommendationResponse{
+			Tracks: h.getTrackDetailsByIDs(h.getPopularTracks(limit)),
+			Reason: "Trending this week",
+		})
+		return
+	}
+
 	ctx := context.Background()
 	session := database.Neo4j.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
 	defer session.Close(ctx)
@@ -231,13 +277,13 @@ func GetTrendingTracks(c *gin.Context) {
 
 	// If no trending tracks found, get popular tracks
 	if len(trackIDs) == 0 {
-		trackIDs = getPopularTracks(limit)
+		trackIDs = h.getPopularTracks(limit)
 	}
 
 	// Fetch track details from MySQL
 	tracks := []models.Track{}
 	if len(trackIDs) > 0 {
-		tracks = getTrackDetailsByIDs(trackIDs)
+		tracks = h.getTrackDetailsByIDs(trackIDs)
 	}
 
 	c.JSON(http.StatusOK, models.RecommendationResponse{
@@ -247,11 +293,26 @@ func GetTrendingTracks(c *gin.Context) {
 }
 
 // GetGenreRecommendations returns tracks from a specific genre
-func GetGenreRecommendations(c *gin.Context) {
+func (h *Handler) GetGenreRecommendations(c *gin.Context) {
 	genre := c.Param("genre")
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	userID, exists := c.Get("user_id")
 
+	// Without the graph database, rank the genre's tracks by play count
+	if database.Neo4j == nil {
+		trackIDs, err := h.stores.Recommendations.GenreTop(genre, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get genre recommendations"})
+			return
+		}
+
+		c.JSON(http.StatusOK, models.RecommendationResponse{
+			Tracks: h.getTrackDetailsByIDs(trackIDs),
+			Reason: "Popular tracks in " + genre,
+		})
+		return
+	}
+
 	ctx := context.Background()
 	session := database.Neo4j.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
 	defer session.Close(ctx)
@@ -312,7 +373,7 @@ func GetGenreRecommendations(c *gin.Context) {
 	// Fetch track details from MySQL
 	tracks := []models.Track{}
 	if len(trackIDs) > 0 {
-		tracks = getTrackDetailsByIDs(trackIDs)
+		tracks = h.getTrackDetailsByIDs(trackIDs)
 	}
 
 	c.JSON(http.StatusOK, models.RecommendationResponse{
@@ -321,78 +382,35 @@ func GetGenreRecommendations(c *gin.Context) {
 	})
 }
 
-// getTrackDetailsByIDs fetches track details from MySQL
-func getTrackDetailsByIDs(trackIDs []int) []models.Track {
-	if len(trackIDs) == 0 {
-		return []models.Track{}
-	}
-
-	// Build query with proper number of placeholders
-	query := `
-		SELECT t.id, t.title, t.artist_id, a.name as artist_name, 
-		       t.album_id, al.title as album_name, t.duration, 
-		       t.genre, t.release_date, t.file_url, t.cover_url, t.created_at
-		FROM tracks t
-		JOIN artists a ON t.artist_id = a.id
-		JOIN albums al ON t.album_id = al.id
-		WHERE t.id IN (?`
-
-	args := make([]interface{}, len(trackIDs))
-	args[0] = trackIDs[0]
-	for i := 1; i < len(trackIDs); i++ {
-		query += ",?"
-		args[i] = trackIDs[i]
-	}
-	query += ")"
-
-	rows, err := database.MySQL.Query(query, args...)
+// getTrackDetailsByIDs fetches track details in the order of trackIDs
+func (h *Handler) getTrackDetailsByIDs(trackIDs []int) []models.Track {
+	tracks, err := h.stores.Tracks.GetMany(trackIDs)
 	if err != nil {
 		return []models.Track{}
 	}
-	defer rows.Close()
-
-	// Create a map to maintain order
-	trackMap := make(map[int]models.Track)
-	for rows.Next() {
-		var track models.Track
-		err := rows.Scan(
-			&track.ID, &track.Title, &track.ArtistID, &track.ArtistName,
-			&track.AlbumID, &track.AlbumName, &track.Duration,
-			&track.Genre, &track.ReleaseDate, &track.FileURL,
-			&track.CoverURL, &track.CreatedAt,
-		)
-		if err == nil {
-			trackMap[track.ID] = track
-		}
-	}
-
-	// Return tracks in the order of input IDs
-	tracks := []models.Track{}
-	for _, id := range trackIDs {
-		if track, exists := trackMap[id]; exists {
-			tracks = append(tracks, track)
-		}
-	}
-
 	return tracks
 }
 
-// getPopularTracks returns popular tracks from MySQL as fallback
-func getPopularTracks(limit int) []int {
-	query := "SELECT id FROM tracks ORDER BY created_at DESC LIMIT ?"
-	rows, err := database.MySQL.Query(query, limit)
+// getPopularTracks returns the most played tracks as fallback, newest first
+// among equals. Plays excluded from recommendations are not counted.
+func (h *Handler) getPopularTracks(limit int) []int {
+	popular, err := h.stores.Recommendations.Popular(store.CandidateFilter{}, limit)
 	if err != nil {
 		return []int{}
 	}
-	defer rows.Close()
-
-	trackIDs := []int{}
-	for rows.Next() {
-		var id int
-		if err := rows.Scan(&id); err == nil {
-			trackIDs = append(trackIDs, id)
-		}
+	trackIDs := make([]int, len(popular))
+	for i, track := range popular {
+		trackIDs[i] = track.ID
 	}
+	return trackIDs
+}
 
+// getLibraryRecommendations scores unsaved tracks by how strongly the user's
+// library and recent plays point at their artist and genre
+func (h *Handler) getLibraryRecommendations(userID, limit int) []int {
+	trackIDs, err := h.stores.Recommendations.ForLibrary(userID, limit)
+	if err != nil {
+		return []int{}
+	}
 	return trackIDs
 }
@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"log"
+	"math/big"
+	"net/http"
+	"spotify-clone/models"
+	"spotify-clone/store"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// sessionCodeAlphabet leaves out characters that are easy to confuse when read aloud
+	sessionCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	sessionCodeLength   = 6
+
+	// minSessionPlaySeconds is how long a track must have played in a session to count as a play
+	minSessionPlaySeconds = 30
+	// completionToleranceMs treats a track stopped this close to its end as completed
+	completionToleranceMs = 5000
+)
+
+// StartSession starts a group listening session hosted by the authenticated user.
+// The host's player (state and queue) becomes the session's shared player.
+// POST /api/v1/sessions
+func (h *Handler) StartSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if _, err := h.stores.Sessions.Active(userID.(int)); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Leave your current session first"})
+		return
+	} else if err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	// Codes are random; retry on the rare collision with an existing one
+	var sessionID int
+	for attempt := 0; attempt < 5; attempt++ {
+		code, err := generateSessionCode()
+		if err != nil {
+			break
+		}
+		sessionID, err = h.stores.Sessions.Create(userID.(int), code)
+		if err != store.ErrConflict {
+			break
+		}
+	}
+	if sessionID == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	session, err := h.getSessionView(sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+	c.JSON(http.StatusCreated, session)
+}
+
+// GetCurrentSession returns the session the authenticated user is in
+// GET /api/v1/sessions/current
+func (h *Handler) GetCurrentSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	session, err := h.stores.Sessions.Active(userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not in a listening session"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+
+	h.respondSession(c, session.ID)
+}
+
+// JoinSession adds the authenticated user to a session by its code
+// POST /api/v1/sessions/join
+func (h *Handler) JoinSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.JoinSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := h.stores.Sessions.ByCode(strings.ToUpper(req.Code))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+	sessionID := session.ID
+
+	current, err := h.stores.Sessions.Active(userID.(int))
+	if err == nil && current.ID == sessionID {
+		h.respondSession(c, sessionID)
+		return
+	}
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Leave your current session first"})
+		return
+	}
+	if err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join session"})
+		return
+	}
+
+	if err := h.stores.Sessions.Join(sessionID, userID.(int)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join session"})
+		return
+	}
+
+	h.broadcastSession(sessionID)
+	h.respondSession(c, sessionID)
+}
+
+// GetSession returns a session the authenticated user is a member of
+// GET /api/v1/sessions/:code
+func (h *Handler) GetSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessionID, _, ok := h.requireSessionMember(c, userID.(int))
+	if !ok {
+		return
+	}
+	h.respondSession(c, sessionID)
+}
+
+// AddToSessionQueue lets any member add a track to the shared queue
+// POST /api/v1/sessions/:code/queue
+func (h *Handler) AddToSessionQueue(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.AddToQueueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessionID, hostID, ok := h.requireSessionMember(c, userID.(int))
+	if !ok {
+		return
+	}
+
+	err := h.controlPlayback(hostID, func() error { return h.playerAddToQueue(hostID, req.TrackID) })
+	if err != nil {
+		c.JSON(playerErrorStatus(err), gin.H{"error": playerErrorMessage(err, "Failed to add track to queue")})
+		return
+	}
+	h.respondSession(c, sessionID)
+}
+
+// VoteSkip records the member's vote to skip the current track. The track is
+// skipped once a majority of the members voted.
+// POST /api/v1/sessions/:code/skip
+func (h *Handler) VoteSkip(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessionID, hostID, ok := h.requireSessionMember(c, userID.(int))
+	if !ok {
+		return
+	}
+
+	// Votes are counted under the host's playback lock so two last votes cannot both skip
+	skipped := false
+	var voteErr error
+	h.deviceHub.Exclusive(hostID, func() {
+		if voteErr = h.stores.Sessions.Vote(sessionID, userID.(int)); voteErr != nil {
+			return
+		}
+		skipped, voteErr = h.skipOnMajority(sessionID, hostID)
+	})
+	if voteErr != nil {
+		c.JSON(playerErrorStatus(voteErr), gin.H{"error": playerErrorMessage(voteErr, "Failed to vote")})
+		return
+	}
+	// A skip has told the members already
+	if !skipped {
+		h.broadcastSession(sessionID)
+	}
+
+	session, err := h.getSessionView(sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"skipped": skipped, "session": session})
+}
+
+// LeaveSession removes the authenticated user from a session. When the host
+// leaves, the session ends for everyone.
+// POST /api/v1/sessions/:code/leave
+func (h *Handler) LeaveSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessionID, hostID, ok := h.requireSessionMember(c, userID.(int))
+	if !ok {
+		return
+	}
+
+	if hostID == userID.(int) {
+		if err := h.endSession(sessionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end session"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Session ended"})
+		return
+	}
+
+	// Leaving drops the member's vote but also shrinks the majority, so the
+	// votes of those who stay may now be enough to skip
+	skipped := false
+	var leaveErr error
+	h.deviceHub.Exclusive(hostID, func() {
+		if leaveErr = h.stores.Sessions.Leave(sessionID, userID.(int)); leaveErr != nil {
+			return
+		}
+		var err error
+		if skipped, err = h.skipOnMajority(sessionID, hostID); err != nil {
+			log.Printf("Failed to skip in session %d after user %d left: %v", sessionID, userID, err)
+		}
+	})
+	if leaveErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave session"})
+		return
+	}
+
+	if !skipped {
+		h.broadcastSession(sessionID)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Left session"})
+}
+
+// EndSession ends a session; only the host can do this
+// DELETE /api/v1/sessions/:code
+func (h *Handler) EndSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessionID, hostID, ok := h.requireSessionMember(c, userID.(int))
+	if !ok {
+		return
+	}
+	if hostID != userID.(int) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the host can end the session"})
+		return
+	}
+
+	if err := h.endSession(sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session ended"})
+}
+
+// recordSessionPlays adds a play of the track for every member of the session,
+// except members in a private session
+func (h *Handler) recordSessionPlays(sessionID, trackID, playedMs int, completed bool) {
+	if playedMs/1000 < minSessionPlaySeconds && !completed {
+		return
+	}
+
+	for _, member := range h.getSessionMemberIDs(sessionID) {
+		private, err := h.inPrivateSession(member)
+		if err != nil {
+			log.Printf("Failed to check the private session of user %d, play not recorded: %v", member, err)
+			continue
+		}
+		if private {
+			continue
+		}
+		if err := h.stores.Plays.Record(member, trackID, playedMs/1000, completed); err != nil {
+			log.Printf("Failed to record session play for user %d: %v", member, err)
+		}
+	}
+}
+
+// broadcastHostedSession tells the members of the session a user hosts about a
+// change of the shared player
+func (h *Handler) broadcastHostedSession(hostID int) {
+	if session, err := h.stores.Sessions.Hosted(hostID); err == nil {
+		h.broadcastSession(session.ID)
+	}
+}
+
+// broadcastSession sends the session view to the devices of every member
+func (h *Handler) broadcastSession(sessionID int) {
+	session, err := h.getSessionView(sessionID)
+	if err != nil {
+		return
+	}
+	for _, member := range session.Members {
+		h.deviceHub.Publish(member.ID, "session", session)
+	}
+}
+
+// endSession closes a session and notifies its members
+func (h *Handler) endSession(sessionID int) error {
+	members := h.getSessionMemberIDs(sessionID)
+
+	if err := h.stores.Sessions.End(sessionID); err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		h.deviceHub.Publish(member, "session_ended", gin.H{"session_id": sessionID})
+	}
+	return nil
+}
+
+// respondSession writes the session view
+func (h *Handler) respondSession(c *gin.Context, sessionID int) {
+	session, err := h.getSessionView(sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// requireSessionMember resolves :code to an active session and checks the user is
+// in it, writing the error response otherwise
+func (h *Handler) requireSessionMember(c *gin.Context, userID int) (sessionID, hostID int, ok bool) {
+	session, err := h.stores.Sessions.ByCode(strings.ToUpper(c.Param("code")))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return 0, 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return 0, 0, false
+	}
+
+	if member, _ := h.stores.Sessions.IsMember(session.ID, userID); !member {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not in this session"})
+		return 0, 0, false
+	}
+	return session.ID, session.Host.ID, true
+}
+
+// getSessionMemberIDs returns the IDs of the users currently in a session
+func (h *Handler) getSessionMemberIDs(sessionID int) []int {
+	ids := []int{}
+	members, err := h.stores.Sessions.Members(sessionID)
+	if err != nil {
+		return ids
+	}
+	for _, member := range members {
+		ids = append(ids, member.ID)
+	}
+	return ids
+}
+
+// skipOnMajority skips the host's current track once a majority of the members
+// voted for it. The caller holds the host's playback lock.
+func (h *Handler) skipOnMajority(sessionID, hostID int) (bool, error) {
+	votes, needed := h.getSkipVotes(sessionID)
+	if votes < needed {
+		return false, nil
+	}
+	if err := h.applyPlaybackChange(hostID, func() error { return h.playerNext(hostID, false) }); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getSkipVotes returns the votes to skip the current track and how many are needed,
+// which is a majority of the members
+func (h *Handler) getSkipVotes(sessionID int) (votes, needed int) {
+	votes, _ = h.stores.Sessions.SkipVotes(sessionID)
+	needed = len(h.getSessionMemberIDs(sessionID))/2 + 1
+	return votes, needed
+}
+
+// getSessionView builds the shared view of a session: members and the host's player
+func (h *Handler) getSessionView(sessionID int) (*models.ListeningSession, error) {
+	session, err := h.stores.Sessions.Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Members, err = h.stores.Sessions.Members(sessionID); err != nil {
+		return nil, err
+	}
+
+	state, err := h.getPlaybackView(session.Host.ID)
+	if err != nil {
+		return nil, err
+	}
+	session.State = state
+	session.SkipVotes, session.SkipVotesNeeded = h.getSkipVotes(sessionID)
+	return session, nil
+}
+
+// generateSessionCode returns a random join code
+func generateSessionCode() (string, error) {
+	code := make([]byte, sessionCodeLength)
+	max := big.NewInt(int64(len(sessionCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = sessionCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"math/rand"
+	"net/http"
+	"spotify-clone/shuffle"
+	"spotify-clone/store"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxShuffleSeed keeps generated seeds within the integers JavaScript clients can
+// represent exactly, so they can send them back unchanged
+const maxShuffleSeed = 1 << 53
+
+// ShufflePlaylist returns a playlist's tracks in smart shuffle order
+// GET /api/v1/playlists/:id/shuffle?seed=
+func (h *Handler) ShufflePlaylist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+
+	ids, err := h.resolvePlayContext("playlist", playlistID, userID.(int))
+	if err != nil {
+		c.JSON(playerErrorStatus(err), gin.H{"error": playerErrorMessage(err, "Failed to shuffle playlist")})
+		return
+	}
+	h.respondShuffled(c, ids)
+}
+
+// ShuffleAlbum returns an album's tracks in smart shuffle order. Only compilations
+// have more than one artist to spread.
+// GET /api/v1/albums/:id/shuffle?seed=
+func (h *Handler) ShuffleAlbum(c *gin.Context) {
+	albumID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid album ID"})
+		return
+	}
+
+	ids, err := h.resolvePlayContext("album", albumID, 0)
+	if err != nil {
+		c.JSON(playerErrorStatus(err), gin.H{"error": playerErrorMessage(err, "Failed to shuffle album")})
+		return
+	}
+	h.respondShuffled(c, ids)
+}
+
+// ShuffleLibrary returns the user's saved tracks in smart shuffle order
+// GET /api/v1/library/tracks/shuffle?seed=
+func (h *Handler) ShuffleLibrary(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	saved, err := h.stores.Library.Tracks(userID.(int), store.LibraryFilter{Limit: -1})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to shuffle library"})
+		return
+	}
+
+	ids := make([]int, len(saved))
+	for i, track := range saved {
+		ids[i] = track.ID
+	}
+	h.respondShuffled(c, ids)
+}
+
+// respondShuffled writes the tracks in smart shuffle order together with the seed
+// that produced it. Passing the seed back as ?seed= gives the same order again.
+func (h *Handler) respondShuffled(c *gin.Context, ids []int) {
+	seed := rand.Int63n(maxShuffleSeed)
+	if value := c.Query("seed"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid seed"})
+			return
+		}
+		seed = parsed
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"seed":   seed,
+		"tracks": h.getTrackDetailsByIDs(h.smartShuffle(ids, seed)),
+	})
+}
+
+// smartShuffle orders track IDs with shuffle.Spread using each track's artist and album
+func (h *Handler) smartShuffle(ids []int, seed int64) []int {
+	if len(ids) == 0 {
+		return ids
+	}
+
+	type key struct{ artistID, albumID int }
+	keys := map[int]key{}
+	if tracks, err := h.stores.Tracks.GetMany(ids); err == nil {
+		for _, track := range tracks {
+			keys[track.ID] = key{artistID: track.ArtistID, albumID: track.AlbumID}
+		}
+	}
+
+	// Tracks whose artist could not be looked up are shuffled as one group
+	items := make([]shuffle.Item, len(ids))
+	for i, id := range ids {
+		items[i] = shuffle.Item{ID: id, ArtistID: keys[id].artistID, AlbumID: keys[id].albumID}
+	}
+
+	order := make([]int, len(ids))
+	for i, item := range shuffle.Spread(items, seed) {
+		order[i] = item.ID
+	}
+	return order
+}
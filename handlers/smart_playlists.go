@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"spotify-clone/models"
+	"spotify-clone/store"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSmartPlaylistLimit = 50
+	maxSmartPlaylistLimit     = 500
+)
+
+// CreateSmartPlaylist creates a playlist whose tracks are computed from rules
+func (h *Handler) CreateSmartPlaylist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.CreateSmartPlaylistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := normalizeSmartPlaylistDefinition(&req.Definition); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	playlist := models.Playlist{
+		UserID:      userID.(int),
+		Name:        req.Name,
+		Description: req.Description,
+		IsPublic:    req.IsPublic,
+		TrackIDs:    []int{},
+	}
+	if err := h.stores.Playlists.CreateSmart(&playlist, req.Definition); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create playlist"})
+		return
+	}
+	h.schedulePlaylistCoverRefresh(playlist.ID)
+	h.recordActivity(userID.(int), activityCreatedPlaylist, playlist.ID, 0, 0)
+
+	tracks, err := h.stores.Playlists.SmartTracks(&req.Definition, userID.(int))
+	if err == nil {
+		for _, track := range tracks {
+			playlist.TrackIDs = append(playlist.TrackIDs, track.ID)
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"playlist":   playlist,
+		"definition": req.Definition,
+	})
+}
+
+// UpdateSmartPlaylistRules replaces the rules of a smart playlist
+func (h *Handler) UpdateSmartPlaylistRules(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	playlistID, ok := playlistIDParam(c)
+	if !ok {
+		return
+	}
+
+	var definition models.SmartPlaylistDefinition
+	if err := c.ShouldBindJSON(&definition); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	access, err := h.getPlaylistAccess(playlistID, userID.(int))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlist"})
+		return
+	}
+	if !access.IsOwner(userID.(int)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	if !access.IsSmart {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Playlist is not a smart playlist"})
+		return
+	}
+
+	if err := normalizeSmartPlaylistDefinition(&definition); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.stores.Playlists.SetSmartDefinition(playlistID, definition); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update smart playlist rules"})
+		return
+	}
+	h.schedulePlaylistCoverRefresh(playlistID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Smart playlist rules updated successfully",
+		"definition": definition,
+	})
+}
+
+// normalizeSmartPlaylistDefinition validates rules about to be saved, fills in
+// defaults and draws the seed that fixes a random order until the next save
+func normalizeSmartPlaylistDefinition(def *models.SmartPlaylistDefinition) error {
+	switch def.Match {
+	case "":
+		def.Match = "all"
+	case "all", "any":
+	default:
+		return fmt.Errorf("invalid match %q, use all or any", def.Match)
+	}
+
+	switch def.OrderBy {
+	case "":
+		def.OrderBy = "release_date"
+	case "release_date", "play_count", "title", "recently_added", "random":
+	default:
+		return fmt.Errorf("invalid order_by %q", def.OrderBy)
+	}
+
+	if def.Limit <= 0 {
+		def.Limit = defaultSmartPlaylistLimit
+	}
+	if def.Limit > maxSmartPlaylistLimit {
+		def.Limit = maxSmartPlaylistLimit
+	}
+
+	if len(def.Rules) == 0 {
+		return fmt.Errorf("at least one rule is required")
+	}
+
+	for i, rule := range def.Rules {
+		if err := validateSmartRule(rule); err != nil {
+			return fmt.Errorf("rule %d: %v", i+1, err)
+		}
+	}
+	def.Seed = rand.Int63n(maxShuffleSeed)
+	return nil
+}
+
+// validateSmartRule checks that a rule names a known field and operator and
+// carries the value they need
+func validateSmartRule(rule models.SmartPlaylistRule) error {
+	operators := map[string][]string{
+		"genre":        {"in", "not_in"},
+		"artist":       {"in", "not_in"},
+		"release_date": {"after", "before"},
+		"play_count":   {"gt", "lt"},
+		"played_by_me": {"within_days", "not_within_days"},
+	}
+
+	switch rule.Field {
+	case "genre":
+		if len(rule.Values) == 0 {
+			return fmt.Errorf("genre rule requires values")
+		}
+	case "artist":
+		if len(rule.IDs) == 0 {
+			return fmt.Errorf("artist rule requires ids")
+		}
+	case "release_date":
+		if _, err := time.Parse("2006-01-02", rule.Date); err != nil {
+			return fmt.Errorf("release_date rule requires date in YYYY-MM-DD format")
+		}
+	case "play_count":
+		if rule.Number < 0 {
+			return fmt.Errorf("play_count rule requires a non-negative number")
+		}
+	case "played_by_me":
+		if rule.Number <= 0 {
+			return fmt.Errorf("played_by_me rule requires a positive number of days")
+		}
+	default:
+		return fmt.Errorf("unknown field %q", rule.Field)
+	}
+
+	for _, operator := range operators[rule.Field] {
+		if rule.Operator == operator {
+			return nil
+		}
+	}
+	return fmt.Errorf("operator %q is not supported for field %q", rule.Operator, rule.Field)
+}
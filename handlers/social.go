@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"spotify-clone/models"
+	"spotify-clone/store"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Activity types recorded for the social feed
+const (
+	activityCreatedPlaylist = "created_playlist"
+	activityAddedTrack      = "added_track"
+	activityFollowedArtist  = "followed_artist"
+)
+
+// feedWindow bounds how far back the activity feed looks. The fan-out-on-read
+// query reads each followee's latest entries from the (user_id, id) index, so
+// its cost grows with the number of followees, not with their history.
+const feedWindow = 30 * 24 * time.Hour
+
+// FollowUser makes the authenticated user follow another user
+func (h *Handler) FollowUser(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	followeeID, err := h.getUserIDByUsername(c.Param("username"))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	if followeeID == userID.(int) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot follow yourself"})
+		return
+	}
+
+	if err := h.stores.Social.Follow(userID.(int), followeeID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User followed successfully"})
+}
+
+// UnfollowUser removes a user from the authenticated user's followees
+func (h *Handler) UnfollowUser(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	followeeID, err := h.getUserIDByUsername(c.Param("username"))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	err = h.stores.Social.Unfollow(userID.(int), followeeID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not following this user"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unfollowed successfully"})
+}
+
+// GetUserFollowers returns the users following the given user
+func (h *Handler) GetUserFollowers(c *gin.Context) {
+	h.listUserFollows(c, "followers")
+}
+
+// GetUserFollowing returns the users the given user follows
+func (h *Handler) GetUserFollowing(c *gin.Context) {
+	h.listUserFollows(c, "following")
+}
+
+// listUserFollows serves both directions of the follow graph
+func (h *Handler) listUserFollows(c *gin.Context, direction string) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+	offset := (page - 1) * limit
+
+	targetID, err := h.getUserIDByUsername(c.Param("username"))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	visible, err := h.canViewSection(userID.(int), targetID, func(p models.PrivacySettings) bool { return p.ShowFollows })
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+		return
+	}
+	if !visible {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This user's follows are private"})
+		return
+	}
+
+	list := h.stores.Social.Followers
+	if direction == "following" {
+		list = h.stores.Social.Following
+	}
+	users, total, err := list(targetID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch " + direction})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		direction: users,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// GetUserPublicPlaylists returns the public playlists owned by the given user
+func (h *Handler) GetUserPublicPlaylists(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	targetID, err := h.getUserIDByUsername(c.Param("username"))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	visible, err := h.canViewSection(userID.(int), targetID, func(p models.PrivacySettings) bool { return p.ShowPlaylists })
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+		return
+	}
+	if !visible {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This user's playlists are private"})
+		return
+	}
+
+	playlists, err := h.stores.Playlists.PublicByOwner(targetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch playlists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"playlists": playlists})
+}
+
+// GetActivityFeed returns recent public activity of the users the authenticated user follows.
+// Pagination is keyset based: pass the returned next_cursor as ?before= to get older entries.
+func (h *Handler) GetActivityFeed(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	cursor := int64(math.MaxInt64)
+	if before := c.Query("before"); before != "" {
+		var err error
+		if cursor, err = strconv.ParseInt(before, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
+
+	activities, err := h.stores.Social.Feed(userID.(int), cursor, time.Now().Add(-feedWindow), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activity feed"})
+		return
+	}
+
+	response := gin.H{"activities": activities}
+	if len(activities) == limit {
+		response["next_cursor"] = activities[len(activities)-1].ID
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// recordActivity appends an entry to the user's activity log. Zero IDs are left out.
+// Failures are logged only; the feed is best effort and must not break the originating request.
+func (h *Handler) recordActivity(userID int, activityType string, playlistID, trackID, artistID int) {
+	err := h.stores.Social.RecordActivity(store.NewActivity{
+		UserID:     userID,
+		Type:       activityType,
+		PlaylistID: playlistID,
+		TrackID:    trackID,
+		ArtistID:   artistID,
+	})
+	if err != nil {
+		log.Printf("Failed to record %s activity for user %d: %v", activityType, userID, err)
+	}
+}
+
+// getUserIDByUsername resolves a username to a user ID
+func (h *Handler) getUserIDByUsername(username string) (int, error) {
+	user, err := h.stores.Users.GetByUsername(username)
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
@@ -1,69 +1,33 @@
 package handlers
 
 import (
-	"database/sql"
 	"net/http"
-	"spotify-clone/database"
 	"spotify-clone/models"
+	"spotify-clone/store"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
+// searchLimit is how many results Search returns per kind
+const searchLimit = 10
+
 // GetTracks returns a list of tracks with pagination
-func GetTracks(c *gin.Context) {
+func (h *Handler) GetTracks(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	genre := c.Query("genre")
-	search := c.Query("search")
-
 	offset := (page - 1) * limit
 
-	query := `
-		SELECT t.id, t.title, t.artist_id, a.name as artist_name, 
-		       t.album_id, al.title as album_name, t.duration, 
-		       t.genre, t.release_date, t.file_url, t.cover_url, t.created_at
-		FROM tracks t
-		JOIN artists a ON t.artist_id = a.id
-		JOIN albums al ON t.album_id = al.id
-		WHERE 1=1
-	`
-
-	args := []interface{}{}
-	if genre != "" {
-		query += " AND t.genre = ?"
-		args = append(args, genre)
-	}
-	if search != "" {
-		query += " AND (t.title LIKE ? OR a.name LIKE ?)"
-		searchParam := "%" + search + "%"
-		args = append(args, searchParam, searchParam)
-	}
-
-	query += " ORDER BY t.created_at DESC LIMIT ? OFFSET ?"
-	args = append(args, limit, offset)
-
-	rows, err := database.MySQL.Query(query, args...)
+	tracks, err := h.stores.Tracks.List(store.TrackFilter{
+		Genre:  c.Query("genre"),
+		Search: c.Query("search"),
+		Limit:  limit,
+		Offset: offset,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tracks"})
 		return
 	}
-	defer rows.Close()
-
-	tracks := []models.Track{}
-	for rows.Next() {
-		var track models.Track
-		err := rows.Scan(
-			&track.ID, &track.Title, &track.ArtistID, &track.ArtistName,
-			&track.AlbumID, &track.AlbumName, &track.Duration,
-			&track.Genre, &track.ReleaseDate, &track.FileURL,
-			&track.CoverURL, &track.CreatedAt,
-		)
-		if err != nil {
-			continue
-		}
-		tracks = append(tracks, track)
-	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"tracks": tracks,
@@ -73,28 +37,15 @@ func GetTracks(c *gin.Context) {
 }
 
 // GetTrackByID returns a single track by ID
-func GetTrackByID(c *gin.Context) {
-	id := c.Param("id")
-
-	query := `
-		SELECT t.id, t.title, t.artist_id, a.name as artist_name, 
-		       t.album_id, al.title as album_name, t.duration, 
-		       t.genre, t.release_date, t.file_url, t.cover_url, t.created_at
-		FROM tracks t
-		JOIN artists a ON t.artist_id = a.id
-		JOIN albums al ON t.album_id = al.id
-		WHERE t.id = ?
-	`
-
-	var track models.Track
-	err := database.MySQL.QueryRow(query, id).Scan(
-		&track.ID, &track.Title, &track.ArtistID, &track.ArtistName,
-		&track.AlbumID, &track.AlbumName, &track.Duration,
-		&track.Genre, &track.ReleaseDate, &track.FileURL,
-		&track.CoverURL, &track.CreatedAt,
-	)
+func (h *Handler) GetTrackByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid track ID"})
+		return
+	}
 
-	if err == sql.ErrNoRows {
+	track, err := h.stores.Tracks.Get(id)
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
 		return
 	}
@@ -107,28 +58,16 @@ func GetTrackByID(c *gin.Context) {
 }
 
 // GetArtists returns a list of artists
-func GetArtists(c *gin.Context) {
+func (h *Handler) GetArtists(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset := (page - 1) * limit
 
-	query := "SELECT id, name, bio, image_url, created_at FROM artists ORDER BY name LIMIT ? OFFSET ?"
-	rows, err := database.MySQL.Query(query, limit, offset)
+	artists, err := h.stores.Artists.List(limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch artists"})
 		return
 	}
-	defer rows.Close()
-
-	artists := []models.Artist{}
-	for rows.Next() {
-		var artist models.Artist
-		err := rows.Scan(&artist.ID, &artist.Name, &artist.Bio, &artist.ImageURL, &artist.CreatedAt)
-		if err != nil {
-			continue
-		}
-		artists = append(artists, artist)
-	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"artists": artists,
@@ -138,16 +77,15 @@ func GetArtists(c *gin.Context) {
 }
 
 // GetArtistByID returns a single artist with their tracks
-func GetArtistByID(c *gin.Context) {
-	id := c.Param("id")
-
-	// Get artist info
-	var artist models.Artist
-	err := database.MySQL.QueryRow(
-		"SELECT id, name, bio, image_url, created_at FROM artists WHERE id = ?", id,
-	).Scan(&artist.ID, &artist.Name, &artist.Bio, &artist.ImageURL, &artist.CreatedAt)
+func (h *Handler) GetArtistByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid artist ID"})
+		return
+	}
 
-	if err == sql.ErrNoRows {
+	artist, err := h.stores.Artists.Get(id)
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Artist not found"})
 		return
 	}
@@ -156,39 +94,11 @@ func GetArtistByID(c *gin.Context) {
 		return
 	}
 
-	// Get artist's tracks
-	query := `
-		SELECT t.id, t.title, t.artist_id, a.name as artist_name, 
-		       t.album_id, al.title as album_name, t.duration, 
-		       t.genre, t.release_date, t.file_url, t.cover_url, t.created_at
-		FROM tracks t
-		JOIN artists a ON t.artist_id = a.id
-		JOIN albums al ON t.album_id = al.id
-		WHERE t.artist_id = ?
-		ORDER BY t.release_date DESC
-	`
-
-	rows, err := database.MySQL.Query(query, id)
+	tracks, err := h.stores.Tracks.ByArtist(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch artist tracks"})
 		return
 	}
-	defer rows.Close()
-
-	tracks := []models.Track{}
-	for rows.Next() {
-		var track models.Track
-		err := rows.Scan(
-			&track.ID, &track.Title, &track.ArtistID, &track.ArtistName,
-			&track.AlbumID, &track.AlbumName, &track.Duration,
-			&track.Genre, &track.ReleaseDate, &track.FileURL,
-			&track.CoverURL, &track.CreatedAt,
-		)
-		if err != nil {
-			continue
-		}
-		tracks = append(tracks, track)
-	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"artist": artist,
@@ -197,39 +107,16 @@ func GetArtistByID(c *gin.Context) {
 }
 
 // GetAlbums returns a list of albums
-func GetAlbums(c *gin.Context) {
+func (h *Handler) GetAlbums(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset := (page - 1) * limit
 
-	query := `
-		SELECT al.id, al.title, al.artist_id, a.name as artist_name, 
-		       al.release_date, al.cover_url, al.created_at
-		FROM albums al
-		JOIN artists a ON al.artist_id = a.id
-		ORDER BY al.release_date DESC
-		LIMIT ? OFFSET ?
-	`
-
-	rows, err := database.MySQL.Query(query, limit, offset)
+	albums, err := h.stores.Albums.List(limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch albums"})
 		return
 	}
-	defer rows.Close()
-
-	albums := []models.Album{}
-	for rows.Next() {
-		var album models.Album
-		err := rows.Scan(
-			&album.ID, &album.Title, &album.ArtistID, &album.ArtistName,
-			&album.ReleaseDate, &album.CoverURL, &album.CreatedAt,
-		)
-		if err != nil {
-			continue
-		}
-		albums = append(albums, album)
-	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"albums": albums,
@@ -239,94 +126,41 @@ func GetAlbums(c *gin.Context) {
 }
 
 // Search performs a global search across tracks, artists, and albums
-func Search(c *gin.Context) {
+func (h *Handler) Search(c *gin.Context) {
 	query := c.Query("q")
 	if query == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
 		return
 	}
 
-	searchParam := "%" + query + "%"
-
-	// Search tracks
-	tracksQuery := `
-		SELECT t.id, t.title, t.artist_id, a.name as artist_name, 
-		       t.album_id, al.title as album_name, t.duration, 
-		       t.genre, t.release_date, t.file_url, t.cover_url, t.created_at
-		FROM tracks t
-		JOIN artists a ON t.artist_id = a.id
-		JOIN albums al ON t.album_id = al.id
-		WHERE t.title LIKE ? OR a.name LIKE ?
-		LIMIT 10
-	`
-
-	tracks := []models.Track{}
-	rows, err := database.MySQL.Query(tracksQuery, searchParam, searchParam)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var track models.Track
-			err := rows.Scan(
-				&track.ID, &track.Title, &track.ArtistID, &track.ArtistName,
-				&track.AlbumID, &track.AlbumName, &track.Duration,
-				&track.Genre, &track.ReleaseDate, &track.FileURL,
-				&track.CoverURL, &track.CreatedAt,
-			)
-			if err == nil {
-				tracks = append(tracks, track)
-			}
-		}
+	// A failing section is left empty rather than failing the whole search
+	tracks, err := h.stores.Tracks.Search(query, searchLimit)
+	if err != nil {
+		tracks = []models.Track{}
 	}
-
-	// Search artists
-	artistsQuery := "SELECT id, name, bio, image_url, created_at FROM artists WHERE name LIKE ? LIMIT 10"
-	artists := []models.Artist{}
-	rows, err = database.MySQL.Query(artistsQuery, searchParam)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var artist models.Artist
-			err := rows.Scan(&artist.ID, &artist.Name, &artist.Bio, &artist.ImageURL, &artist.CreatedAt)
-			if err == nil {
-				artists = append(artists, artist)
-			}
-		}
+	artists, err := h.stores.Artists.Search(query, searchLimit)
+	if err != nil {
+		artists = []models.Artist{}
 	}
-
-	// Search albums
-	albumsQuery := `
-		SELECT al.id, al.title, al.artist_id, a.name as artist_name, 
-		       al.release_date, al.cover_url, al.created_at
-		FROM albums al
-		JOIN artists a ON al.artist_id = a.id
-		WHERE al.title LIKE ? OR a.name LIKE ?
-		LIMIT 10
-	`
-	albums := []models.Album{}
-	rows, err = database.MySQL.Query(albumsQuery, searchParam, searchParam)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var album models.Album
-			err := rows.Scan(
-				&album.ID, &album.Title, &album.ArtistID, &album.ArtistName,
-				&album.ReleaseDate, &album.CoverURL, &album.CreatedAt,
-			)
-			if err == nil {
-				albums = append(albums, album)
-			}
-		}
+	albums, err := h.stores.Albums.Search(query, searchLimit)
+	if err != nil {
+		albums = []models.Album{}
+	}
+	playlists, err := h.stores.Playlists.SearchPublic(query, searchLimit)
+	if err != nil {
+		playlists = []models.Playlist{}
 	}
 
 	c.JSON(http.StatusOK, models.SearchResponse{
-		Tracks:  tracks,
-		Artists: artists,
-		Albums:  albums,
+		Tracks:    tracks,
+		Artists:   artists,
+		Albums:    albums,
+		Playlists: playlists,
 	})
 }
 
 // RecordPlay records a track play in user's listening history
-func RecordPlay(c *gin.Context) {
+func (h *Handler) RecordPlay(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
@@ -339,13 +173,8 @@ func RecordPlay(c *gin.Context) {
 		return
 	}
 
-	// Get track info from MySQL
-	var duration int
-	err = database.MySQL.QueryRow(
-		"SELECT duration FROM tracks WHERE id = ?", trackID,
-	).Scan(&duration)
-
-	if err == sql.ErrNoRows {
+	track, err := h.stores.Tracks.Get(trackID)
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
 		return
 	}
@@ -354,15 +183,21 @@ func RecordPlay(c *gin.Context) {
 		return
 	}
 
-	// Insert play record in MySQL
-	_, err = database.MySQL.Exec(
-		"INSERT INTO plays (user_id, track_id, played_at, duration_played, completed) VALUES (?, ?, NOW(), ?, TRUE)",
-		userID, trackID, duration,
-	)
+	// Private sessions leave no trace in history or stats
+	private, err := h.inPrivateSession(userID.(int))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record play in MySQL"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privacy settings"})
+		return
+	}
+	if private {
+		c.JSON(http.StatusOK, gin.H{"message": "Private session active, play not recorded", "recorded": false})
+		return
+	}
+
+	if err := h.stores.Plays.Record(userID.(int), trackID, track.Duration, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record play"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Play recorded successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Play recorded successfully", "recorded": true})
 }
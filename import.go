@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"spotify-clone/database"
+	"spotify-clone/importer"
+	"spotify-clone/store"
+	"strings"
+)
+
+const importUsage = `usage: spotify-clone import [-format csv|jsonl] [-batch N] FILE
+
+Loads artists, albums and tracks. FILE "-" reads standard input. The format
+defaults to the file extension: .csv, or .jsonl and .ndjson for JSON Lines.`
+
+// runImport implements "spotify-clone import", which loads catalog data into
+// MySQL without starting the server
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, importUsage) }
+	format := flags.String("format", "", "csv or jsonl")
+	batch := flags.Int("batch", importer.DefaultBatchSize, "rows looked up and written together")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%s", importUsage)
+	}
+	path := flags.Arg(0)
+
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			*format = "csv"
+		case ".jsonl", ".ndjson":
+			*format = "jsonl"
+		default:
+			return fmt.Errorf("cannot tell the format of %s, pass -format csv or -format jsonl", path)
+		}
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	if err := database.InitMySQL(); err != nil {
+		return fmt.Errorf("failed to connect to MySQL: %v", err)
+	}
+	defer database.Close()
+
+	im := importer.New(store.NewMySQL(database.MySQL))
+	im.BatchSize = *batch
+	result, err := im.Import(input, *format)
+	if result == nil {
+		return err
+	}
+
+	for _, rowErr := range result.Errors {
+		fmt.Fprintln(os.Stderr, rowErr)
+	}
+	fmt.Printf("%d rows: %d artists, %d albums and %d tracks created, %d duplicate tracks skipped, %d rows failed\n",
+		result.Rows, result.ArtistsCreated, result.AlbumsCreated, result.TracksCreated, result.Duplicates, len(result.Errors))
+	if err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%d of %d rows were not imported", len(result.Errors), result.Rows)
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+// Package importer loads artists, albums and tracks into the catalog from CSV
+// or JSON Lines files. Each row names an artist, optionally one of its albums
+// and optionally a track on that album; artists and albums are found by name
+// or created, and tracks already in the catalog are skipped.
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"spotify-clone/models"
+	"spotify-clone/store"
+	"strings"
+	"time"
+)
+
+// DefaultBatchSize is how many rows are looked up and written together
+const DefaultBatchSize = 500
+
+// RowError is a row that was not imported
+type RowError struct {
+	Line    int
+	Message string
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Result counts what an import did
+type Result struct {
+	Rows           int
+	ArtistsCreated int
+	AlbumsCreated  int
+	TracksCreated  int
+	// Duplicates are tracks already in the catalog or earlier in the file
+	Duplicates int
+	Errors     []RowError
+}
+
+type albumKey struct {
+	artistID int
+	title    string // lower-cased
+}
+
+type trackKey struct {
+	albumID int
+	title   string // lower-cased
+}
+
+// Importer writes rows through the stores, remembering the artists, albums and
+// tracks it has seen so each is looked up once
+type Importer struct {
+	stores    *store.Stores
+	BatchSize int
+
+	artists    map[string]int // by lower-cased name
+	albums     map[albumKey]int
+	albumDates map[int]time.Time
+	tracks     map[trackKey]bool
+}
+
+// New returns an Importer writing to stores
+func New(stores *store.Stores) *Importer {
+	return &Importer{
+		stores:     stores,
+		BatchSize:  DefaultBatchSize,
+		artists:    map[string]int{},
+		albums:     map[albumKey]int{},
+		albumDates: map[int]time.Time{},
+		tracks:     map[trackKey]bool{},
+	}
+}
+
+// Import reads rows from r in format, "csv" or "jsonl". Bad rows are reported
+// in the result and skipped. The error is for input that cannot be read: a
+// bad header, or a read failure part way, in which case the rows before it
+// are still imported and counted in the result.
+func (im *Importer) Import(r io.Reader, format string) (*Result, error) {
+	rows, err := newRowReader(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	batchSize := im.BatchSize
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+
+	result := &Result{}
+	batch := make([]*record, 0, batchSize)
+	for {
+		fields, line, err := rows.next()
+		if err == io.EOF {
+			break
+		}
+		var readErr *readError
+		if errors.As(err, &readErr) {
+			im.importBatch(batch, result)
+			return result, err
+		}
+		result.Rows++
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{line, err.Error()})
+			continue
+		}
+
+		rec, err := parseRecord(fields)
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{line, err.Error()})
+			continue
+		}
+		rec.line = line
+		batch = append(batch, rec)
+
+		if len(batch) == batchSize {
+			im.importBatch(batch, result)
+			batch = batch[:0]
+		}
+	}
+	im.importBatch(batch, result)
+	return result, nil
+}
+
+// importBatch resolves the artists, then the albums, then the tracks of a
+// batch, so each level is looked up with one query per parent
+func (im *Importer) importBatch(batch []*record, result *Result) {
+	if len(batch) == 0 {
+		return
+	}
+	fail := func(rec *record, format string, args ...interface{}) {
+		rec.failed = true
+		result.Errors = append(result.Errors, RowError{rec.line, fmt.Sprintf(format, args...)})
+	}
+
+	im.resolveArtists(batch, result, fail)
+	im.resolveAlbums(batch, result, fail)
+
+	// Tracks already in the catalog, per album
+	titles := map[int][]string{}
+	for _, rec := range batch {
+		if rec.failed || rec.track == nil {
+			continue
+		}
+		key := trackKey{rec.albumID, strings.ToLower(rec.track.Title)}
+		if !im.tracks[key] {
+			titles[rec.albumID] = append(titles[rec.albumID], rec.track.Title)
+		}
+	}
+	for albumID, list := range titles {
+		existing, err := im.stores.Tracks.IDsByTitle(albumID, list)
+		if err != nil {
+			for _, rec := range batch {
+				if !rec.failed && rec.track != nil && rec.albumID == albumID {
+					fail(rec, "error looking up tracks: %v", err)
+				}
+			}
+			continue
+		}
+		for title := range existing {
+			im.tracks[trackKey{albumID, title}] = true
+		}
+	}
+
+	for _, rec := range batch {
+		if rec.failed || rec.track == nil {
+			continue
+		}
+		key := trackKey{rec.albumID, strings.ToLower(rec.track.Title)}
+		if im.tracks[key] {
+			result.Duplicates++
+			continue
+		}
+
+		track := *rec.track
+		track.ArtistID, track.AlbumID = rec.artistID, rec.albumID
+		if track.ReleaseDate.IsZero() {
+			// Tracks without a date take their album's
+			date, err := im.albumDate(rec.albumID)
+			if err != nil {
+				fail(rec, "error looking up album: %v", err)
+				continue
+			}
+			track.ReleaseDate = date
+		}
+		// The same checks as the add_track procedure, which MySQL runs
+		_, status, err := im.stores.Tracks.Add(track)
+		if err != nil {
+			fail(rec, "error adding track: %v", err)
+			continue
+		}
+		if strings.HasPrefix(status, "ERROR") {
+			fail(rec, "%s", strings.TrimSpace(strings.TrimPrefix(status, "ERROR:")))
+			continue
+		}
+		im.tracks[key] = true
+		result.TracksCreated++
+	}
+}
+
+// resolveArtists sets the artist ID of every record, creating the artists the
+// catalog lacks from the first row naming them
+func (im *Importer) resolveArtists(batch []*record, result *Result, fail func(*record, string, ...interface{})) {
+	var unknown []string
+	for _, rec := range batch {
+		if _, ok := im.artists[strings.ToLower(rec.artist.Name)]; !ok {
+			unknown = append(unknown, rec.artist.Name)
+		}
+	}
+	if len(unknown) > 0 {
+		found, err := im.stores.Artists.IDsByName(unknown)
+		if err != nil {
+			for _, rec := range batch {
+				fail(rec, "error looking up artists: %v", err)
+			}
+			return
+		}
+		for name, id := range found {
+			im.artists[name] = id
+		}
+	}
+
+	failed := map[string]string{}
+	for _, rec := range batch {
+		key := strings.ToLower(rec.artist.Name)
+		if message, ok := failed[key]; ok {
+			fail(rec, "%s", message)
+			continue
+		}
+		id, ok := im.artists[key]
+		if !ok {
+			artist := rec.artist
+			if err := im.stores.Artists.Create(&artist); err != nil {
+				failed[key] = fmt.Sprintf("error creating artist %q: %v", artist.Name, err)
+				fail(rec, "%s", failed[key])
+				continue
+			}
+			id = artist.ID
+			im.artists[key] = id
+			result.ArtistsCreated++
+		}
+		rec.artistID = id
+	}
+}
+
+// resolveAlbums sets the album ID of every record naming an album, creating
+// the albums the catalog lacks
+func (im *Importer) resolveAlbums(batch []*record, result *Result, fail func(*record, string, ...interface{})) {
+	titles := map[int][]string{}
+	for _, rec := range batch {
+		if rec.failed || rec.album == nil {
+			continue
+		}
+		if _, ok := im.albums[albumKey{rec.artistID, strings.ToLower(rec.album.Title)}]; !ok {
+			titles[rec.artistID] = append(titles[rec.artistID], rec.album.Title)
+		}
+	}
+	for artistID, list := range titles {
+		found, err := im.stores.Albums.IDsByTitle(artistID, list)
+		if err != nil {
+			for _, rec := range batch {
+				if !rec.failed && rec.album != nil && rec.artistID == artistID {
+					fail(rec, "error looking up albums: %v", err)
+				}
+			}
+			continue
+		}
+		for title, id := range found {
+			im.albums[albumKey{artistID, title}] = id
+		}
+	}
+
+	failed := map[albumKey]string{}
+	for _, rec := range batch {
+		if rec.failed || rec.album == nil {
+			continue
+		}
+		key := albumKey{rec.artistID, strings.ToLower(rec.album.Title)}
+		if message, ok := failed[key]; ok {
+			fail(rec, "%s", message)
+			continue
+		}
+		id, ok := im.albums[key]
+		if !ok {
+			album := *rec.album
+			album.ArtistID = rec.artistID
+			if album.ReleaseDate.IsZero() {
+				// Not remembered as failed: a later row may carry the date
+				fail(rec, "album_release_date or release_date is required for a new album")
+				continue
+			}
+			if err := im.stores.Albums.Create(&album); err != nil {
+				failed[key] = fmt.Sprintf("error creating album %q: %v", album.Title, err)
+				fail(rec, "%s", failed[key])
+				continue
+			}
+			id = album.ID
+			im.albums[key] = id
+			im.albumDates[id] = album.ReleaseDate
+			result.AlbumsCreated++
+		}
+		rec.albumID = id
+	}
+}
+
+func (im *Importer) albumDate(id int) (time.Time, error) {
+	if date, ok := im.albumDates[id]; ok {
+		return date, nil
+	}
+	album, err := im.stores.Albums.Get(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	im.albumDates[id] = album.ReleaseDate
+	return album.ReleaseDate, nil
+}
+
+// record is a parsed row
+type record struct {
+	line   int
+	artist models.Artist
+	album  *models.Album   // nil when the row names no album
+	track  *store.NewTrack // nil when the row has no title
+
+	artistID int
+	albumID  int
+	failed   bool
+}
@@ -0,0 +1,218 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"spotify-clone/store"
+)
+
+func newDemoImporter(t *testing.T) (*Importer, *store.Stores) {
+	t.Helper()
+	memory := store.NewMemory()
+	if err := store.SeedDemo(memory); err != nil {
+		t.Fatal(err)
+	}
+	stores := memory.Stores()
+	return New(stores), stores
+}
+
+func errorLines(result *Result) map[int]string {
+	lines := map[int]string{}
+	for _, rowErr := range result.Errors {
+		lines[rowErr.Line] = rowErr.Message
+	}
+	return lines
+}
+
+func TestImportCSV(t *testing.T) {
+	im, stores := newDemoImporter(t)
+	im.BatchSize = 2
+
+	csv := `artist,album,album_release_date,title,duration,genre,file_url
+Radiohead,OK Computer,1997-05-21,Airbag,4:44,Alternative,https://audio.example.com/airbag.mp3
+Radiohead,OK Computer,,Paranoid Android,383,Alternative,
+radiohead,ok computer,,AIRBAG,284,Alternative,
+the weeknd,after hours,,blinding lights,200,Pop,
+Radiohead,Kid A,,Idioteque,5:09,Electronic,
+Radiohead,OK Computer,,Lucky,,Alternative,
+Radiohead,,,Let Down,299,Alternative,
+Bjork,,,,,,
+"Bjork",Homogenic,1997-09-22,,,,
+`
+	result, err := im.Import(strings.NewReader(csv), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Rows != 9 || result.ArtistsCreated != 2 || result.AlbumsCreated != 2 || result.TracksCreated != 2 || result.Duplicates != 2 {
+		t.Fatalf("result = %+v, want 9 rows, 2 artists, 2 albums, 2 tracks, 2 duplicates", result)
+	}
+	want := map[int]string{
+		6: "album_release_date or release_date is required for a new album",
+		7: `duration is required for a track`,
+		8: "album is required for a track",
+	}
+	got := errorLines(result)
+	if len(got) != len(want) {
+		t.Fatalf("errors = %v, want %v", got, want)
+	}
+	for line, message := range want {
+		if got[line] != message {
+			t.Errorf("line %d error = %q, want %q", line, got[line], message)
+		}
+	}
+
+	tracks, err := stores.Tracks.Search("Airbag", 10)
+	if err != nil || len(tracks) != 1 {
+		t.Fatalf("Search(Airbag) = %v, %v, want one track", tracks, err)
+	}
+	airbag := tracks[0]
+	if airbag.Duration != 284 || airbag.AlbumName != "OK Computer" || airbag.ReleaseDate.Format("2006-01-02") != "1997-05-21" {
+		t.Errorf("Airbag = %+v, want 284s on OK Computer released 1997-05-21", airbag)
+	}
+
+	// The add_track checks bump album_stats as the trigger does
+	stats, err := stores.Albums.Stats(airbag.AlbumID)
+	if err != nil || stats.TrackCount != 2 || stats.TotalDuration != 284+383 {
+		t.Errorf("album stats = %+v, %v, want 2 tracks of %ds", stats, err, 284+383)
+	}
+
+	// Importing the same file again only finds duplicates
+	again, err := New(stores).Import(strings.NewReader(csv), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ArtistsCreated != 0 || again.AlbumsCreated != 0 || again.TracksCreated != 0 || again.Duplicates != 4 {
+		t.Errorf("second import = %+v, want 4 duplicates and nothing created", again)
+	}
+}
+
+func TestImportCSVHeader(t *testing.T) {
+	im, _ := newDemoImporter(t)
+	for _, header := range []string{"", "album,title\n", "artist,colour\n", "artist,artist\n"} {
+		if _, err := im.Import(strings.NewReader(header), "csv"); err == nil {
+			t.Errorf("Import accepted header %q", header)
+		}
+	}
+}
+
+func TestImportMalformedCSV(t *testing.T) {
+	im, _ := newDemoImporter(t)
+	csv := `artist,album,release_date,title,duration
+Dr"ake,Scorpion,2018-06-29,Nonstop,238
+Portishead,"Dummy,1994-08-22,Roads,302
+`
+	// A bare quote in the first field used to panic in csv.Reader.FieldPos
+	result, err := im.Import(strings.NewReader(csv), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := errorLines(result)
+	if result.TracksCreated != 0 || !strings.Contains(got[2], "bare") || !strings.Contains(got[3], "quote") {
+		t.Fatalf("result = %+v, want a bare quote error on line 2 and a quote error on line 3", result)
+	}
+
+	csv = `artist,album,release_date,title,duration
+Massive Attack,Mezzanine,1998-04-20,Teardrop,330,extra
+Massive Attack,Mezzanine,1998-04-20,Angel,379
+`
+	result, err = im.Import(strings.NewReader(csv), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 2 || result.TracksCreated != 1 || !strings.Contains(errorLines(result)[2], "number of fields") {
+		t.Fatalf("result = %+v, want line 2 rejected for its field count and Angel imported", result)
+	}
+}
+
+func TestImportStopsOnReadFailure(t *testing.T) {
+	for _, format := range []string{"csv", "jsonl"} {
+		im, _ := newDemoImporter(t)
+		head := "artist,album,release_date,title,duration\nMassive Attack,Mezzanine,1998-04-20,Teardrop,330\n"
+		if format == "jsonl" {
+			head = `{"artist": "Massive Attack", "album": "Mezzanine", "release_date": "1998-04-20", "title": "Teardrop", "duration": 330}` + "\n"
+		}
+		// The reader fails on every call after the first rows; the import must
+		// end rather than retry it as a bad row forever
+		input := io.MultiReader(strings.NewReader(head), iotest.ErrReader(errors.New("disk failure")))
+
+		result, err := im.Import(input, format)
+		if err == nil || !strings.Contains(err.Error(), "disk failure") {
+			t.Fatalf("%s: err = %v, want the read failure", format, err)
+		}
+		if result == nil || result.TracksCreated != 1 {
+			t.Fatalf("%s: result = %+v, want the track before the failure imported", format, result)
+		}
+	}
+}
+
+func TestImportJSONL(t *testing.T) {
+	im, _ := newDemoImporter(t)
+	jsonl := `{"artist": "Massive Attack", "album": "Mezzanine", "release_date": "1998-04-20", "title": "Teardrop", "duration": 330}
+
+{"artist": "Massive Attack", "album": "Mezzanine", "title": "Angel", "duration": "6:19", "release_date": "1998-04-20"}
+{"artist": "Massive Attack", "mood": "dark"}
+{"artist": "Massive Attack", "album": "Mezzanine", "title": "Inertia Creeps", "duration": 356.5, "release_date": "1998-04-20"}
+{"artist": "Massive Attack",
+{"artist": "Massive Attack", "album": "Mezzanine", "title": "Dissolved Girl", "duration": 367, "release_date": "20/04/1998"}
+`
+	result, err := im.Import(strings.NewReader(jsonl), "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 6 || result.TracksCreated != 2 {
+		t.Fatalf("result = %+v, want 6 rows and 2 tracks", result)
+	}
+
+	got := errorLines(result)
+	for line, prefix := range map[int]string{
+		4: `unknown field "mood"`,
+		5: `duration "356.5" must be`,
+		6: "invalid json",
+		7: "release_date must be a date",
+	} {
+		if !strings.HasPrefix(got[line], prefix) {
+			t.Errorf("line %d error = %q, want %q...", line, got[line], prefix)
+		}
+	}
+}
+
+// rejectingTracks answers Add as the add_track procedure does for a bad row
+type rejectingTracks struct {
+	store.TrackStore
+}
+
+func (rejectingTracks) Add(store.NewTrack) (int, string, error) {
+	return 0, "ERROR: Album does not belong to the specified artist", nil
+}
+
+func TestImportReportsAddTrackStatus(t *testing.T) {
+	_, stores := newDemoImporter(t)
+	stores.Tracks = rejectingTracks{stores.Tracks}
+
+	result, err := New(stores).Import(strings.NewReader("artist,album,release_date,title,duration\nDrake,Scorpion,2018-06-29,Nonstop,238\n"), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Error() != "line 2: Album does not belong to the specified artist" {
+		t.Fatalf("errors = %v, want the add_track status", result.Errors)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	valid := map[string]int{"200": 200, "3:20": 200, "0:05": 5, "61:00": 3660}
+	for value, want := range valid {
+		if got, err := parseDuration(value); err != nil || got != want {
+			t.Errorf("parseDuration(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "0", "-5", "3:5", "3:60", "0:75", "abc", "1:2:3", "3.5"} {
+		if _, err := parseDuration(value); err == nil {
+			t.Errorf("parseDuration(%q) succeeded", value)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"spotify-clone/models"
+	"spotify-clone/store"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// columns are the fields of a row, the CSV header names and the JSON keys,
+// with the lengths of their database columns (0 for none). Only artist is
+// always required.
+var columns = map[string]int{
+	"artist":             255,
+	"artist_bio":         65535,
+	"artist_image_url":   500,
+	"album":              255,
+	"album_release_date": 0,
+	"album_cover_url":    500,
+	"title":              255,
+	"duration":           0,
+	"genre":              100,
+	"release_date":       0,
+	"file_url":           500,
+	"cover_url":          500,
+}
+
+// rowReader yields the fields of each row with its line number, and io.EOF
+// after the last one. A *readError means the input cannot be read any further;
+// other errors concern that row only.
+type rowReader interface {
+	next() (map[string]string, int, error)
+}
+
+// readError is a failure to read the input rather than a malformed row
+type readError struct {
+	after int // the last line read
+	err   error
+}
+
+func (e *readError) Error() string {
+	return fmt.Sprintf("error reading the input after line %d: %v", e.after, e.err)
+}
+
+func (e *readError) Unwrap() error {
+	return e.err
+}
+
+func newRowReader(r io.Reader, format string) (rowReader, error) {
+	switch format {
+	case "csv":
+		return newCSVReader(r)
+	case "jsonl":
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		return &jsonlReader{scanner: scanner}, nil
+	}
+	return nil, fmt.Errorf("unsupported import format %q, want csv or jsonl", format)
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+	line   int // of the last record read
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty csv file")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading csv header: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("unknown csv column %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate csv column %q", name)
+		}
+		seen[name] = true
+		header[i] = name
+	}
+	if !seen["artist"] {
+		return nil, errors.New("csv header has no artist column")
+	}
+	return &csvReader{reader: reader, header: header}, nil
+}
+
+func (r *csvReader) next() (map[string]string, int, error) {
+	values, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	if err != nil {
+		// The reader moves on to the next record after a parse error; any
+		// other error comes from the underlying reader
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			r.line = parseErr.Line
+			return nil, parseErr.StartLine, parseErr.Err
+		}
+		return nil, r.line + 1, &readError{r.line, err}
+	}
+	// FieldPos is only valid after a record was read without error
+	line, _ := r.reader.FieldPos(0)
+	r.line = line
+
+	fields := make(map[string]string, len(values))
+	for i, value := range values {
+		fields[r.header[i]] = value
+	}
+	return fields, line, nil
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+	done    bool
+}
+
+func (r *jsonlReader) next() (map[string]string, int, error) {
+	for r.scanner.Scan() {
+		r.line++
+		text := strings.TrimSpace(r.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &object); err != nil {
+			return nil, r.line, fmt.Errorf("invalid json: %v", err)
+		}
+		fields := make(map[string]string, len(object))
+		for key, value := range object {
+			if _, ok := columns[key]; !ok {
+				return nil, r.line, fmt.Errorf("unknown field %q", key)
+			}
+			switch v := value.(type) {
+			case nil:
+			case string:
+				fields[key] = v
+			case float64:
+				fields[key] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				return nil, r.line, fmt.Errorf("field %q must be a string or a number", key)
+			}
+		}
+		return fields, r.line, nil
+	}
+	if err := r.scanner.Err(); err != nil && !r.done {
+		r.done = true
+		if err != bufio.ErrTooLong {
+			return nil, r.line + 1, &readError{r.line, err}
+		}
+		// A line too long to scan ends the file; report it against that line
+		return nil, r.line + 1, err
+	}
+	return nil, 0, io.EOF
+}
+
+// parseRecord checks a row's fields and converts them. Fields are trimmed and
+// empty ones count as missing.
+func parseRecord(fields map[string]string) (*record, error) {
+	for name, value := range fields {
+		value = strings.TrimSpace(value)
+		fields[name] = value
+		if limit := columns[name]; limit > 0 && utf8.RuneCountInString(value) > limit {
+			return nil, fmt.Errorf("%s is longer than %d characters", name, limit)
+		}
+	}
+
+	rec := &record{artist: models.Artist{
+		Name:     fields["artist"],
+		Bio:      fields["artist_bio"],
+		ImageURL: fields["artist_image_url"],
+	}}
+	if rec.artist.Name == "" {
+		return nil, errors.New("artist is required")
+	}
+
+	albumRelease, err := parseDate(fields, "album_release_date")
+	if err != nil {
+		return nil, err
+	}
+	release, err := parseDate(fields, "release_date")
+	if err != nil {
+		return nil, err
+	}
+	if albumRelease.IsZero() {
+		albumRelease = release
+	}
+	if release.IsZero() {
+		release = albumRelease
+	}
+
+	if fields["album"] != "" {
+		rec.album = &models.Album{
+			Title:       fields["album"],
+			ReleaseDate: albumRelease,
+			CoverURL:    fields["album_cover_url"],
+		}
+	}
+
+	if fields["title"] == "" {
+		return rec, nil
+	}
+	if rec.album == nil {
+		return nil, errors.New("album is required for a track")
+	}
+	duration, err := parseDuration(fields["duration"])
+	if err != nil {
+		return nil, err
+	}
+	rec.track = &store.NewTrack{
+		Title:       fields["title"],
+		Duration:    duration,
+		Genre:       fields["genre"],
+		ReleaseDate: release,
+		FileURL:     fields["file_url"],
+		CoverURL:    fields["cover_url"],
+	}
+	return rec, nil
+}
+
+func parseDate(fields map[string]string, name string) (time.Time, error) {
+	if fields[name] == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse("2006-01-02", fields[name])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a date like 2024-01-31", name)
+	}
+	return date, nil
+}
+
+// parseDuration accepts seconds or m:ss
+func parseDuration(value string) (int, error) {
+	if value == "" {
+		return 0, errors.New("duration is required for a track")
+	}
+	invalid := fmt.Errorf("duration %q must be a positive number of seconds or m:ss", value)
+
+	minutes, seconds, clock := "0", value, false
+	if i := strings.IndexByte(value, ':'); i >= 0 {
+		minutes, seconds, clock = value[:i], value[i+1:], true
+		if len(seconds) != 2 {
+			return 0, invalid
+		}
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 {
+		return 0, invalid
+	}
+	s, err := strconv.Atoi(seconds)
+	if err != nil || s < 0 || (clock && s > 59) {
+		return 0, invalid
+	}
+	duration := m*60 + s
+	if duration <= 0 {
+		return 0, invalid
+	}
+	return duration, nil
+}
@@ -5,7 +5,11 @@ import (
 	"os"
 	"spotify-clone/database"
 	"spotify-clone/handlers"
+	"spotify-clone/media"
 	"spotify-clone/middleware"
+	"spotify-clone/scanner"
+	"spotify-clone/store"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -17,15 +21,67 @@ func main() {
 		log.Println("Warning: .env file not found, using system environment variables")
 	}
 
-	// Initialize MySQL database
-	if err := database.InitMySQL(); err != nil {
-		log.Fatalf("Failed to connect to MySQL: %v", err)
+	// Subcommands manage the schema and the catalog instead of serving
+	if len(os.Args) > 1 {
+		commands := map[string]func([]string) error{
+			"migrate": runMigrate,
+			"import":  runImport,
+			"scan":    runScan,
+			"backup":  runBackup,
+		}
+		if run, ok := commands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 	}
 
-	defer database.Close()
+	// STORE=memory runs the API on an in-memory demo catalog without MySQL
+	var stores *store.Stores
+	if os.Getenv("STORE") == "memory" {
+		memory := store.NewMemory()
+		if err := store.SeedDemo(memory); err != nil {
+			log.Fatalf("Failed to seed the in-memory store: %v", err)
+		}
+		stores = memory.Stores()
+		log.Println("Using the in-memory store")
+	} else {
+		// Initialize MySQL database
+		if err := database.InitMySQL(); err != nil {
+			log.Fatalf("Failed to connect to MySQL: %v", err)
+		}
+
+		defer database.Close()
+
+		stores = store.NewMySQL(database.MySQL)
+	}
+	h := handlers.New(stores)
+	// Delete accounts whose grace period has ended
+	h.StartAccountPurge(time.Hour)
 
 	// Setup Gin router
-	router := gin.Default()
+	router := setupRouter(h)
+
+	// Start server
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	log.Printf("🚀 Server starting on port %s", port)
+	log.Printf("📚 API Documentation: http://localhost:%s/api/v1", port)
+
+	if err := router.Run(":" + port); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
+
+// setupRouter builds the router with all routes served by h
+func setupRouter(h *handlers.Handler) *gin.Engine {
+	router := gin.New()
+	// The socket token is taken out of the URL before the request is logged
+	router.Use(middleware.QueryToken(), gin.Logger(), gin.Recovery())
 
 	// CORS middleware
 	router.Use(func(c *gin.Context) {
@@ -42,12 +98,29 @@ func main() {
 		c.Next()
 	})
 
+	// Generated and uploaded media (playlist covers)
+	router.Static(media.URLPrefix, media.Dir())
+
+	// Audio files imported by "spotify-clone scan"
+	if dir := os.Getenv("MUSIC_DIR"); dir != "" {
+		router.Static(scanner.URLPrefix, dir)
+	}
+
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
+		response := gin.H{
 			"status": "ok",
 			"mysql":  database.MySQL != nil,
-		})
+		}
+		// Features whose tables or routines were found missing at startup
+		if health := database.SchemaHealth(); health != nil {
+			response["schema_version"] = health.SchemaVersion
+			response["degraded"] = health.Degraded
+			if len(health.Degraded) > 0 {
+				response["status"] = "degraded"
+			}
+		}
+		c.JSON(200, response)
 	})
 
 	// API v1 routes
@@ -56,43 +129,48 @@ func main() {
 		// Public routes (no authentication required)
 		auth := v1.Group("/auth")
 		{
-			auth.POST("/register", handlers.Register)
-			auth.POST("/login", handlers.Login)
+			auth.POST("/register", h.Register)
+			auth.POST("/login", h.Login)
 		}
 
 		// Tracks routes (public read access)
 		tracks := v1.Group("/tracks")
 		{
-			tracks.GET("", handlers.GetTracks)
-			tracks.GET("/:id", handlers.GetTrackByID)
-			tracks.GET("/:id/similar", handlers.GetSimilarTracks)
-			tracks.POST("/add", handlers.AddTrackWithValidation) // Uses stored procedure with validation
+			tracks.GET("", h.GetTracks)
+			tracks.GET("/:id", h.GetTrackByID)
+			tracks.GET("/:id/similar", h.GetSimilarTracks)
+			tracks.POST("/add", h.AddTrackWithValidation) // Uses stored procedure with validation
 		}
 
 		// Artists routes (public read access)
 		artists := v1.Group("/artists")
 		{
-			artists.GET("", handlers.GetArtists)
-			artists.GET("/:id", handlers.GetArtistByID)
-			artists.GET("/:id/stats", handlers.GetArtistStats) // Uses stored procedure
+			artists.GET("", h.GetArtists)
+			artists.GET("/suggestions", h.GetArtistSuggestions) // Onboarding picks
+			artists.GET("/:id", h.GetArtistByID)
+			artists.GET("/:id/stats", h.GetArtistStats) // Uses stored procedure
 		}
 
 		// Albums routes (public read access)
 		albums := v1.Group("/albums")
 		{
-			albums.GET("", handlers.GetAlbums)
-			albums.GET("/:id/stats", handlers.GetAlbumStats)       // Uses trigger-maintained data
-			albums.GET("/:id/duration", handlers.GetAlbumDuration) // Uses SQL function
+			albums.GET("", h.GetAlbums)
+			albums.GET("/:id/stats", h.GetAlbumStats)       // Uses trigger-maintained data
+			albums.GET("/:id/duration", h.GetAlbumDuration) // Uses SQL function
+			albums.GET("/:id/shuffle", h.ShuffleAlbum)
 		}
 
 		// Search (public access)
-		v1.GET("/search", handlers.Search)
+		v1.GET("/search", h.Search)
+
+		// Public playlist discovery (public access)
+		v1.GET("/playlists/public", h.BrowsePublicPlaylists)
 
 		// Trending and genre recommendations (public access)
 		recommendations := v1.Group("/recommendations")
 		{
-			recommendations.GET("/trending", handlers.GetTrendingTracks)
-			recommendations.GET("/genre/:genre", handlers.GetGenreRecommendations)
+			recommendations.GET("/trending", h.GetTrendingTracks)
+			recommendations.GET("/genre/:genre", h.GetGenreRecommendations)
 		}
 
 		// Protected routes (authentication required)
@@ -102,40 +180,130 @@ func main() {
 			// User profile
 			profile := protected.Group("/profile")
 			{
-				profile.GET("", handlers.GetProfile)
-				profile.PUT("/preferences", handlers.UpdatePreferences)
+				profile.GET("", h.GetProfile)
+				profile.PUT("/preferences", h.UpdatePreferences)
+				profile.GET("/favorite-artists", h.GetFavoriteArtists)
+				profile.POST("/favorite-artists", h.AddFavoriteArtist)
+				profile.PUT("/favorite-artists", h.SetFavoriteArtists)
+				profile.DELETE("/favorite-artists/:artistId", h.RemoveFavoriteArtist)
+				profile.GET("/privacy", h.GetPrivacySettings)
+				profile.PUT("/privacy", h.UpdatePrivacySettings)
+				profile.POST("/private-session", h.StartPrivateSession)
+				profile.DELETE("/private-session", h.EndPrivateSession)
 			}
 
+			// Personal data export and account deletion
+			protected.GET("/me/export", h.ExportMyData)
+			protected.DELETE("/me", h.DeleteAccount)
+			protected.POST("/me/restore", h.RestoreAccount)
+
 			// User playlists
 			playlists := protected.Group("/playlists")
 			{
-				playlists.POST("", handlers.CreatePlaylist)
-				playlists.GET("", handlers.GetUserPlaylists)
-				playlists.GET("/:id", handlers.GetPlaylistByID)
-				playlists.PUT("/:id", handlers.UpdatePlaylist)
-				playlists.DELETE("/:id", handlers.DeletePlaylist)
-				playlists.POST("/:id/tracks", handlers.AddTrackToPlaylist)
-				playlists.DELETE("/:id/tracks/:trackId", handlers.RemoveTrackFromPlaylist)
+				playlists.POST("", h.CreatePlaylist)
+				playlists.POST("/smart", h.CreateSmartPlaylist)
+				playlists.POST("/import", h.ImportPlaylist)
+				playlists.GET("", h.GetUserPlaylists)
+				playlists.GET("/liked", h.GetLikedSongs)
+				playlists.GET("/:id", h.GetPlaylistByID)
+				playlists.PUT("/:id", h.UpdatePlaylist)
+				playlists.DELETE("/:id", h.DeletePlaylist)
+				playlists.PUT("/:id/rules", h.UpdateSmartPlaylistRules)
+				playlists.GET("/:id/export", h.ExportPlaylist)
+				playlists.GET("/:id/shuffle", h.ShufflePlaylist)
+				playlists.PUT("/:id/cover", h.UploadPlaylistCover)
+				playlists.DELETE("/:id/cover", h.DeletePlaylistCover)
+				playlists.POST("/:id/tracks", h.AddTrackToPlaylist)
+				playlists.DELETE("/:id/tracks/:trackId", h.RemoveTrackFromPlaylist)
+				playlists.PUT("/:id/tracks/:trackId/position", h.MovePlaylistTrack)
+
+				// Collaboration
+				playlists.GET("/:id/collaborators", h.GetPlaylistCollaborators)
+				playlists.DELETE("/:id/collaborators/:userId", h.RemovePlaylistCollaborator)
+				playlists.POST("/:id/invites", h.CreatePlaylistInvite)
+				playlists.GET("/:id/invites", h.GetPlaylistInvites)
+				playlists.DELETE("/:id/invites/:token", h.RevokePlaylistInvite)
+				playlists.POST("/invites/:token/accept", h.AcceptPlaylistInvite)
+
+				// Following
+				playlists.POST("/:id/follow", h.FollowPlaylist)
+				playlists.DELETE("/:id/follow", h.UnfollowPlaylist)
 			}
 
-			// Recording plays
-			protected.POST("/tracks/:id/play", handlers.RecordPlay)
+			// Playback state and queue, shared by all of the user's clients
+			player := protected.Group("/me/player")
+			{
+				player.GET("", h.GetPlaybackState)
+				player.PUT("/play", h.Play)
+				player.PUT("/pause", h.Pause)
+				player.PUT("/seek", h.Seek)
+				player.POST("/next", h.SkipToNext)
+				player.POST("/previous", h.SkipToPrevious)
+				player.PUT("/shuffle", h.SetShuffle)
+				player.PUT("/repeat", h.SetRepeat)
+				player.GET("/queue", h.GetQueue)
+				player.POST("/queue", h.AddToQueue)
+				player.DELETE("/queue/:itemId", h.RemoveFromQueue)
+				player.PUT("/queue/:itemId/position", h.MoveQueueItem)
+				player.GET("/devices", h.GetDevices)
+				player.PUT("/transfer", h.TransferPlayback)
+				player.GET("/socket", h.PlayerSocket)
+			}
 
-			// Personalized recommendations (disabled for now)
-			// protected.GET("/recommendations", handlers.GetRecommendations)
-		}
-	}
+			// Group listening sessions around the host's player
+			sessions := protected.Group("/sessions")
+			{
+				sessions.POST("", h.StartSession)
+				sessions.GET("/current", h.GetCurrentSession)
+				sessions.POST("/join", h.JoinSession)
+				sessions.GET("/:code", h.GetSession)
+				sessions.DELETE("/:code", h.EndSession)
+				sessions.POST("/:code/queue", h.AddToSessionQueue)
+				sessions.POST("/:code/skip", h.VoteSkip)
+				sessions.POST("/:code/leave", h.LeaveSession)
+			}
 
-	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+			// Radio stations generated from a seed
+			radio := protected.Group("/radio")
+			{
+				radio.POST("", h.CreateRadio)
+				radio.GET("/:id", h.GetRadioStation)
+				radio.DELETE("/:id", h.DeleteRadioStation)
+				radio.POST("/:id/next", h.GetNextRadioBatch)
+				radio.POST("/:id/skips", h.SkipRadioTrack)
+			}
 
-	log.Printf("🚀 Server starting on port %s", port)
-	log.Printf("📚 API Documentation: http://localhost:%s/api/v1", port)
+			// Recording plays
+			protected.POST("/tracks/:id/play", h.RecordPlay)
 
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+			// Saved library (tracks, albums, artists)
+			library := protected.Group("/library")
+			{
+				library.GET("/tracks", h.GetLibraryTracks)
+				library.GET("/tracks/shuffle", h.ShuffleLibrary)
+				library.GET("/albums", h.GetLibraryAlbums)
+				library.GET("/artists", h.GetLibraryArtists)
+				library.GET("/contains", h.CheckLibrary)
+				library.PUT("/:kind/:id", h.SaveToLibrary)
+				library.DELETE("/:kind/:id", h.RemoveFromLibrary)
+			}
+
+			// Public profiles, social graph and activity feed
+			users := protected.Group("/users")
+			{
+				users.GET("/:username", h.GetPublicProfile)
+				users.GET("/:username/playlists", h.GetUserPublicPlaylists)
+				users.GET("/:username/followers", h.GetUserFollowers)
+				users.GET("/:username/following", h.GetUserFollowing)
+				users.POST("/:username/follow", h.FollowUser)
+				users.DELETE("/:username/follow", h.UnfollowUser)
+			}
+			protected.GET("/feed", h.GetActivityFeed)
+
+			// Personalized recommendations (library signals in MySQL when Neo4j is not configured)
+			protected.GET("/recommendations", h.GetRecommendations)
+		}
 	}
+
+	return router
 }
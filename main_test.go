@@ -0,0 +1,1031 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"spotify-clone/handlers"
+	"spotify-clone/models"
+	"spotify-clone/store"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The end-to-end tests drive the real router over HTTP against the in-memory
+// store seeded with store.SeedDemo. Demo catalog IDs: artists 1-4 (The Weeknd,
+// Taylor Swift, Drake, Daft Punk), album N belongs to artist N, tracks
+// 3N-2..3N are on album N.
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	if os.Getenv("JWT_SECRET") == "" {
+		os.Setenv("JWT_SECRET", "test-secret")
+	}
+	os.Exit(m.Run())
+}
+
+type testServer struct {
+	t       *testing.T
+	router  *gin.Engine
+	handler *handlers.Handler
+	memory  *store.Memory
+}
+
+// response is a decoded JSON response
+type response struct {
+	Status int
+	Body   map[string]interface{}
+}
+
+func newTestServer(t *testing.T) *testServer {
+	return newTestServerWith(t, nil)
+}
+
+// newTestServerWith lets change swap stores before the handler is built
+func newTestServerWith(t *testing.T, change func(stores *store.Stores)) *testServer {
+	t.Helper()
+	memory := store.NewMemory()
+	if err := store.SeedDemo(memory); err != nil {
+		t.Fatalf("seeding demo catalog: %v", err)
+	}
+	stores := memory.Stores()
+	if change != nil {
+		change(stores)
+	}
+	h := handlers.New(stores)
+	return &testServer{t: t, router: setupRouter(h), handler: h, memory: memory}
+}
+
+// do sends body as JSON, authenticated with token unless it is empty
+func (s *testServer) do(method, path, token string, body interface{}) response {
+	s.t.Helper()
+	rec := s.send(method, path, token, body)
+
+	res := response{Status: rec.Code, Body: map[string]interface{}{}}
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &res.Body); err != nil {
+			s.t.Fatalf("%s %s: decoding %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return res
+}
+
+// send is do without decoding the response
+func (s *testServer) send(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			s.t.Fatalf("encoding body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, "/api/v1"+path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect fails the test unless the response has the given status
+func (s *testServer) expect(res response, status int, what string) response {
+	s.t.Helper()
+	if res.Status != status {
+		s.t.Fatalf("%s: got status %d, want %d (body %v)", what, res.Status, status, res.Body)
+	}
+	return res
+}
+
+// register creates a user named username and returns their token and ID
+func (s *testServer) register(username string) (string, int) {
+	s.t.Helper()
+	res := s.expect(s.do("POST", "/auth/register", "", gin.H{
+		"email":        username + "@example.com",
+		"password":     "secret123",
+		"username":     username,
+		"display_name": username,
+	}), http.StatusCreated, "register "+username)
+	user := res.Body["user"].(map[string]interface{})
+	return res.Body["token"].(string), int(user["id"].(float64))
+}
+
+// createPlaylist creates a playlist owned by token's user and returns its ID
+func (s *testServer) createPlaylist(token, name string, public bool) int {
+	s.t.Helper()
+	res := s.expect(s.do("POST", "/playlists", token, gin.H{"name": name, "is_public": public}),
+		http.StatusCreated, "create playlist")
+	return int(res.Body["id"].(float64))
+}
+
+// createSmartPlaylist creates a smart playlist owned by token's user and returns its ID
+func (s *testServer) createSmartPlaylist(token string, public bool, definition gin.H) int {
+	s.t.Helper()
+	res := s.expect(s.do("POST", "/playlists/smart", token, gin.H{
+		"name": "Smart", "is_public": public, "definition": definition,
+	}), http.StatusCreated, "create smart playlist")
+	return int(res.Body["playlist"].(map[string]interface{})["id"].(float64))
+}
+
+// trackIDs returns the track_ids of a playlist as seen by token's user
+func (s *testServer) trackIDs(token string, playlistID int) []int {
+	s.t.Helper()
+	res := s.expect(s.do("GET", fmt.Sprintf("/playlists/%d", playlistID), token, nil), http.StatusOK, "get playlist")
+	ids := []int{}
+	for _, id := range res.Body["playlist"].(map[string]interface{})["track_ids"].([]interface{}) {
+		ids = append(ids, int(id.(float64)))
+	}
+	return ids
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	s := newTestServer(t)
+
+	res := s.expect(s.do("POST", "/auth/register", "", gin.H{
+		"email":            "alice@example.com",
+		"password":         "secret123",
+		"username":         "alice",
+		"display_name":     "Alice",
+		"genres":           []string{"Pop"},
+		"favorite_artists": []int{4, 1},
+	}), http.StatusCreated, "register")
+	if res.Body["token"] == "" {
+		t.Fatal("register returned no token")
+	}
+	user := res.Body["user"].(map[string]interface{})
+	if user["username"] != "alice" || fmt.Sprint(user["favorite_artists"]) != "[4 1]" {
+		t.Fatalf("unexpected user %v", user)
+	}
+	if _, ok := user["password"]; ok {
+		t.Fatal("register exposed the password")
+	}
+
+	s.expect(s.do("POST", "/auth/register", "", gin.H{
+		"email": "alice@example.com", "password": "secret123", "username": "alice2", "display_name": "A",
+	}), http.StatusConflict, "register with a taken email")
+	s.expect(s.do("POST", "/auth/register", "", gin.H{
+		"email": "other@example.com", "password": "secret123", "username": "alice", "display_name": "A",
+	}), http.StatusConflict, "register with a taken username")
+	s.expect(s.do("POST", "/auth/register", "", gin.H{
+		"email": "short@example.com", "password": "123", "username": "short", "display_name": "S",
+	}), http.StatusBadRequest, "register with a short password")
+
+	res = s.expect(s.do("POST", "/auth/login", "", gin.H{"email": "alice@example.com", "password": "secret123"}),
+		http.StatusOK, "login")
+	token := res.Body["token"].(string)
+	s.expect(s.do("POST", "/auth/login", "", gin.H{"email": "alice@example.com", "password": "wrong-password"}),
+		http.StatusUnauthorized, "login with a wrong password")
+	s.expect(s.do("POST", "/auth/login", "", gin.H{"email": "nobody@example.com", "password": "secret123"}),
+		http.StatusUnauthorized, "login with an unknown email")
+
+	res = s.expect(s.do("GET", "/profile", token, nil), http.StatusOK, "get profile")
+	if res.Body["email"] != "alice@example.com" {
+		t.Fatalf("unexpected profile %v", res.Body)
+	}
+}
+
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("alice")
+
+	s.expect(s.do("GET", "/profile", "", nil), http.StatusUnauthorized, "no token")
+	s.expect(s.do("GET", "/profile", "not-a-jwt", nil), http.StatusUnauthorized, "invalid token")
+	s.expect(s.do("POST", "/playlists", "", gin.H{"name": "Mix"}), http.StatusUnauthorized, "create playlist without token")
+	s.expect(s.do("POST", "/tracks/1/play", "", nil), http.StatusUnauthorized, "record play without token")
+
+	req := httptest.NewRequest("GET", "/api/v1/profile", nil)
+	req.Header.Set("Authorization", "Token "+token)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("non-Bearer scheme: got status %d, want 401", rec.Code)
+	}
+}
+
+func TestQueryTokenIsNotLogged(t *testing.T) {
+	var log bytes.Buffer
+	defer func(w io.Writer) { gin.DefaultWriter = w }(gin.DefaultWriter)
+	gin.DefaultWriter = &log
+	s := newTestServer(t)
+	token, _ := s.register("alice")
+
+	get := func(upgrade bool) int {
+		req := httptest.NewRequest("GET", "/api/v1/profile?access_token="+token+"&device_id=phone", nil)
+		if upgrade {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+		}
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if status := get(true); status != http.StatusOK {
+		t.Fatalf("WebSocket handshake with a query token: got status %d, want 200", status)
+	}
+	if status := get(false); status != http.StatusUnauthorized {
+		t.Fatalf("plain request with a query token: got status %d, want 401", status)
+	}
+
+	if strings.Contains(log.String(), token) || !strings.Contains(log.String(), "/api/v1/profile?device_id=phone") {
+		t.Fatalf("request log should show the path without the token:\n%s", log.String())
+	}
+}
+
+func TestUpdatePreferences(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("alice")
+
+	s.expect(s.do("PUT", "/profile/preferences", token, gin.H{
+		"theme": "light", "language": "de", "preferred_genres": []string{"Jazz"}, "favorite_artists": []int{2, 99},
+	}), http.StatusBadRequest, "preferences with an unknown artist")
+
+	s.expect(s.do("PUT", "/profile/preferences", token, gin.H{
+		"theme": "light", "language": "de", "preferred_genres": []string{"Jazz"}, "favorite_artists": []int{2, 3},
+	}), http.StatusOK, "update preferences")
+
+	res := s.expect(s.do("GET", "/profile", token, nil), http.StatusOK, "get profile")
+	if res.Body["theme"] != "light" || res.Body["language"] != "de" ||
+		fmt.Sprint(res.Body["favorite_genres"]) != "[Jazz]" || fmt.Sprint(res.Body["favorite_artists"]) != "[2 3]" {
+		t.Fatalf("preferences not applied: %v", res.Body)
+	}
+}
+
+func TestPlaylistCRUD(t *testing.T) {
+	s := newTestServer(t)
+	token, userID := s.register("alice")
+
+	id := s.createPlaylist(token, "Road trip", false)
+
+	res := s.expect(s.do("GET", "/playlists", token, nil), http.StatusOK, "list playlists")
+	playlists := res.Body["playlists"].([]interface{})
+	if len(playlists) != 1 || playlists[0].(map[string]interface{})["name"] != "Road trip" {
+		t.Fatalf("unexpected playlists %v", playlists)
+	}
+
+	for _, trackID := range []int{1, 4, 7} {
+		s.expect(s.do("POST", fmt.Sprintf("/playlists/%d/tracks", id), token, gin.H{"track_id": trackID}),
+			http.StatusOK, "add track")
+	}
+	s.expect(s.do("POST", fmt.Sprintf("/playlists/%d/tracks", id), token, gin.H{"track_id": 999}),
+		http.StatusNotFound, "add unknown track")
+	if got := s.trackIDs(token, id); !equalIDs(got, []int{1, 4, 7}) {
+		t.Fatalf("track order %v, want [1 4 7]", got)
+	}
+
+	// Adding a track again moves it to the end
+	s.expect(s.do("POST", fmt.Sprintf("/playlists/%d/tracks", id), token, gin.H{"track_id": 1}), http.StatusOK, "re-add track")
+	if got := s.trackIDs(token, id); !equalIDs(got, []int{4, 7, 1}) {
+		t.Fatalf("track order %v, want [4 7 1]", got)
+	}
+
+	res = s.expect(s.do("PUT", fmt.Sprintf("/playlists/%d/tracks/1/position", id), token, gin.H{"position": 0}),
+		http.StatusOK, "move track")
+	if fmt.Sprint(res.Body["track_ids"]) != "[1 4 7]" {
+		t.Fatalf("move returned %v, want [1 4 7]", res.Body["track_ids"])
+	}
+	s.expect(s.do("PUT", fmt.Sprintf("/playlists/%d/tracks/2/position", id), token, gin.H{"position": 0}),
+		http.StatusNotFound, "move a track not in the playlist")
+
+	s.expect(s.do("DELETE", fmt.Sprintf("/playlists/%d/tracks/4", id), token, nil), http.StatusOK, "remove track")
+	res = s.expect(s.do("GET", fmt.Sprintf("/playlists/%d", id), token, nil), http.StatusOK, "get playlist")
+	items := res.Body["items"].([]interface{})
+	if len(items) != 2 || int(items[0].(map[string]interface{})["added_by"].(float64)) != userID {
+		t.Fatalf("unexpected items %v", items)
+	}
+
+	s.expect(s.do("PUT", fmt.Sprintf("/playlists/%d", id), token, gin.H{"name": "Renamed", "is_public": true}),
+		http.StatusOK, "update playlist")
+	res = s.expect(s.do("GET", fmt.Sprintf("/playlists/%d", id), token, nil), http.StatusOK, "get playlist")
+	playlist := res.Body["playlist"].(map[string]interface{})
+	if playlist["name"] != "Renamed" || playlist["is_public"] != true {
+		t.Fatalf("update not applied: %v", playlist)
+	}
+
+	s.expect(s.do("DELETE", fmt.Sprintf("/playlists/%d", id), token, nil), http.StatusOK, "delete playlist")
+	s.expect(s.do("GET", fmt.Sprintf("/playlists/%d", id), token, nil), http.StatusNotFound, "get deleted playlist")
+	s.expect(s.do("GET", "/playlists/abc", token, nil), http.StatusNotFound, "get playlist with a malformed ID")
+}
+
+func TestPlaylistAuthorization(t *testing.T) {
+	s := newTestServer(t)
+	alice, _ := s.register("alice")
+	bob, bobID := s.register("bob")
+
+	private := s.createPlaylist(alice, "Private", false)
+	public := s.createPlaylist(alice, "Public", true)
+	path := func(id int, suffix string) string { return fmt.Sprintf("/playlists/%d%s", id, suffix) }
+
+	s.expect(s.do("GET", path(private, ""), bob, nil), http.StatusForbidden, "read another user's private playlist")
+	s.expect(s.do("GET", path(public, ""), bob, nil), http.StatusOK, "read another user's public playlist")
+	s.expect(s.do("GET", path(9999, ""), bob, nil), http.StatusNotFound, "read a missing playlist")
+	for _, route := range []struct{ method, suffix string }{
+		{"GET", ""}, {"DELETE", "/cover"}, {"GET", "/shuffle"}, {"GET", "/export"},
+		{"GET", "/collaborators"}, {"POST", "/invites"}, {"POST", "/follow"}, {"DELETE", "/follow"},
+	} {
+		s.expect(s.do(route.method, "/playlists/abc"+route.suffix, bob, nil), http.StatusNotFound, route.method+" a playlist ID that is not a number")
+	}
+
+	for _, id := range []int{private, public} {
+		s.expect(s.do("POST", path(id, "/tracks"), bob, gin.H{"track_id": 1}), http.StatusForbidden, "add track as a stranger")
+		s.expect(s.do("PUT", path(id, ""), bob, gin.H{"name": "Mine now"}), http.StatusForbidden, "update as a stranger")
+		s.expect(s.do("DELETE", path(id, ""), bob, nil), http.StatusForbidden, "delete as a stranger")
+	}
+
+	// A collaborator edits tracks but does not own the playlist
+	if err := s.memory.AddCollaborator(private, bobID); err != nil {
+		t.Fatal(err)
+	}
+	s.expect(s.do("GET", path(private, ""), bob, nil), http.StatusOK, "read as a collaborator")
+	s.expect(s.do("POST", path(private, "/tracks"), bob, gin.H{"track_id": 1}), http.StatusOK, "add track as a collaborator")
+	s.expect(s.do("POST", path(private, "/tracks"), alice, gin.H{"track_id": 2}), http.StatusOK, "add track as the owner")
+	s.expect(s.do("PUT", path(private, "/tracks/2/position"), bob, gin.H{"position": 0}), http.StatusOK, "move as a collaborator")
+	s.expect(s.do("DELETE", path(private, "/tracks/1"), bob, nil), http.StatusOK, "remove track as a collaborator")
+	s.expect(s.do("PUT", path(private, ""), bob, gin.H{"name": "Mine now"}), http.StatusForbidden, "update as a collaborator")
+	s.expect(s.do("DELETE", path(private, ""), bob, nil), http.StatusForbidden, "delete as a collaborator")
+	if got := s.trackIDs(alice, private); !equalIDs(got, []int{2}) {
+		t.Fatalf("track order %v, want [2]", got)
+	}
+}
+
+func TestRecordPlayUpdatesStats(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("alice")
+
+	for i := 0; i < 2; i++ {
+		res := s.expect(s.do("POST", "/tracks/1/play", token, nil), http.StatusOK, "record play")
+		if res.Body["recorded"] != true {
+			t.Fatalf("play not recorded: %v", res.Body)
+		}
+	}
+	s.expect(s.do("POST", "/tracks/2/play", token, nil), http.StatusOK, "record play")
+	s.expect(s.do("POST", "/tracks/999/play", token, nil), http.StatusNotFound, "play an unknown track")
+	s.expect(s.do("POST", "/tracks/abc/play", token, nil), http.StatusBadRequest, "play a malformed track ID")
+
+	if got := s.memory.PlayCount(1); got != 2 {
+		t.Fatalf("play count of track 1 is %d, want 2", got)
+	}
+
+	res := s.expect(s.do("GET", "/artists/1/stats", "", nil), http.StatusOK, "artist stats")
+	stats := res.Body["data"].(map[string]interface{})
+	if stats["total_tracks"] != 3.0 || stats["total_albums"] != 1.0 || stats["total_duration_seconds"] != 652.0 ||
+		stats["genres"] != "Pop, R&B" || stats["avg_plays_per_track"] != 1.0 {
+		t.Fatalf("unexpected artist stats %v", stats)
+	}
+	s.expect(s.do("GET", "/artists/99/stats", "", nil), http.StatusNotFound, "stats of a missing artist")
+}
+
+func TestAddTrackValidation(t *testing.T) {
+	s := newTestServer(t)
+
+	track := func(artistID, albumID int, releaseDate string) gin.H {
+		return gin.H{
+			"title": "Bonus Track", "artist_id": artistID, "album_id": albumID, "duration": 180,
+			"genre": "Pop", "release_date": releaseDate, "file_url": "https://audio.example.com/bonus.mp3",
+		}
+	}
+	rejected := []struct {
+		body    gin.H
+		message string
+	}{
+		{track(99, 1, "2020-03-20"), "ERROR: Artist does not exist"},
+		{track(1, 99, "2020-03-20"), "ERROR: Album does not exist"},
+		{track(1, 2, "2020-03-20"), "ERROR: Album does not belong to the specified artist"},
+	}
+	for _, tc := range rejected {
+		res := s.expect(s.do("POST", "/tracks/add", "", tc.body), http.StatusBadRequest, tc.message)
+		if res.Body["message"] != tc.message {
+			t.Fatalf("got message %v, want %q", res.Body["message"], tc.message)
+		}
+	}
+	s.expect(s.do("POST", "/tracks/add", "", track(1, 1, "20.03.2020")), http.StatusBadRequest, "malformed release date")
+
+	res := s.expect(s.do("POST", "/tracks/add", "", track(1, 1, "2020-03-20")), http.StatusCreated, "add track")
+	trackID := int(res.Body["track_id"].(float64))
+	s.expect(s.do("GET", fmt.Sprintf("/tracks/%d", trackID), "", nil), http.StatusOK, "get added track")
+
+	// after_track_insert keeps album_stats in step
+	res = s.expect(s.do("GET", "/albums/1/stats", "", nil), http.StatusOK, "album stats")
+	stats := res.Body["data"].(map[string]interface{})
+	if stats["track_count"] != 4.0 || stats["total_duration_seconds"] != 832.0 {
+		t.Fatalf("unexpected album stats %v", stats)
+	}
+	res = s.expect(s.do("GET", "/albums/1/duration", "", nil), http.StatusOK, "album duration")
+	if res.Body["duration_seconds"] != 832.0 || res.Body["duration_formatted"] != "13:52" {
+		t.Fatalf("unexpected album duration %v", res.Body)
+	}
+
+	// after_track_delete takes it back out
+	if err := s.memory.DeleteTrack(trackID); err != nil {
+		t.Fatal(err)
+	}
+	res = s.expect(s.do("GET", "/albums/1/stats", "", nil), http.StatusOK, "album stats")
+	if stats := res.Body["data"].(map[string]interface{}); stats["track_count"] != 3.0 {
+		t.Fatalf("unexpected album stats after delete %v", stats)
+	}
+}
+
+func TestCatalogAndSearch(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("alice")
+	s.createPlaylist(token, "Daft Punk essentials", true)
+	s.createPlaylist(token, "Daft Punk secrets", false)
+
+	res := s.expect(s.do("GET", "/tracks?genre=electronic&limit=2", "", nil), http.StatusOK, "list tracks")
+	if tracks := res.Body["tracks"].([]interface{}); len(tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(tracks))
+	}
+	res = s.expect(s.do("GET", "/artists/4", "", nil), http.StatusOK, "get artist")
+	if tracks := res.Body["tracks"].([]interface{}); len(tracks) != 3 {
+		t.Fatalf("got %d artist tracks, want 3", len(tracks))
+	}
+	s.expect(s.do("GET", "/tracks/999", "", nil), http.StatusNotFound, "get a missing track")
+
+	s.expect(s.do("GET", "/search", "", nil), http.StatusBadRequest, "search without a query")
+	res = s.expect(s.do("GET", "/search?q=daft", "", nil), http.StatusOK, "search")
+	counts := map[string]int{}
+	for _, kind := range []string{"tracks", "artists", "albums", "playlists"} {
+		counts[kind] = len(res.Body[kind].([]interface{}))
+	}
+	if counts["tracks"] != 3 || counts["artists"] != 1 || counts["albums"] != 1 || counts["playlists"] != 1 {
+		t.Fatalf("unexpected search results %v", counts)
+	}
+
+	res = s.expect(s.do("GET", "/playlists/public?limit=-1&page=-2", "", nil), http.StatusOK, "browse out of range")
+	if res.Body["limit"] != 20.0 || res.Body["page"] != 1.0 || len(res.Body["playlists"].([]interface{})) != 1 {
+		t.Fatalf("unexpected browse page %v", res.Body)
+	}
+}
+
+func TestLibraryAndActivityFeed(t *testing.T) {
+	s := newTestServer(t)
+	alice, _ := s.register("alice")
+	bob, _ := s.register("bob")
+
+	s.expect(s.do("PUT", "/library/tracks/1", alice, nil), http.StatusOK, "save a track")
+	s.expect(s.do("PUT", "/library/tracks/999", alice, nil), http.StatusNotFound, "save a missing track")
+	res := s.expect(s.do("GET", "/library/tracks", alice, nil), http.StatusOK, "list saved tracks")
+	if tracks := res.Body["tracks"].([]interface{}); len(tracks) != 1 {
+		t.Fatalf("got %d saved tracks, want 1", len(tracks))
+	}
+	res = s.expect(s.do("GET", "/library/tracks?limit=-1&page=0", alice, nil), http.StatusOK, "list saved tracks out of range")
+	if res.Body["limit"] != 50.0 || res.Body["page"] != 1.0 {
+		t.Fatalf("got page %v limit %v, want page 1 limit 50", res.Body["page"], res.Body["limit"])
+	}
+
+	s.expect(s.do("POST", "/users/alice/follow", bob, nil), http.StatusOK, "follow alice")
+	s.createPlaylist(alice, "Shared", true)
+	s.createPlaylist(alice, "Hidden", false)
+	res = s.expect(s.do("GET", "/feed", bob, nil), http.StatusOK, "activity feed")
+	activities := res.Body["activities"].([]interface{})
+	if len(activities) != 1 || activities[0].(map[string]interface{})["playlist_name"] != "Shared" {
+		t.Fatalf("unexpected feed %v", activities)
+	}
+}
+
+func TestPlayerQueueAndTrending(t *testing.T) {
+	s := newTestServer(t)
+	token, _ := s.register("alice")
+
+	res := s.expect(s.do("POST", "/me/player/queue", token, gin.H{"track_id": 2}), http.StatusCreated, "queue a track")
+	if queue := res.Body["queue"].([]interface{}); len(queue) != 1 {
+		t.Fatalf("got %d queued tracks, want 1", len(queue))
+	}
+	s.expect(s.do("POST", "/tracks/5/play", token, nil), http.StatusOK, "record a play")
+
+	res = s.expect(s.do("GET", "/recommendations/trending?limit=1", "", nil), http.StatusOK, "trending")
+	tracks := res.Body["tracks"].([]interface{})
+	if len(tracks) != 1 || tracks[0].(map[string]interface{})["id"] != 5.0 {
+		t.Fatalf("unexpected trending tracks %v", tracks)
+	}
+}
+
+func TestAccountDeletionGracePeriodAndRestore(t *testing.T) {
+	t.Setenv("ACCOUNT_DELETION_GRACE", "1h")
+	s := newTestServer(t)
+	token, _ := s.register("alice")
+
+	s.expect(s.do("DELETE", "/me", token, gin.H{"password": "wrong-password"}), http.StatusForbidden, "delete with a wrong password")
+	res := s.expect(s.do("DELETE", "/me", token, gin.H{"password": "secret123"}), http.StatusAccepted, "delete account")
+	deletion := res.Body["deletion"].(map[string]interface{})
+	res = s.expect(s.do("DELETE", "/me", token, gin.H{"password": "secret123"}), http.StatusAccepted, "delete account again")
+	if again := res.Body["deletion"].(map[string]interface{}); again["delete_after"] != deletion["delete_after"] {
+		t.Fatalf("a repeated deletion moved the schedule from %v to %v", deletion["delete_after"], again["delete_after"])
+	}
+
+	// The account keeps working and is not purged during the grace period
+	s.expect(s.do("GET", "/library/tracks", token, nil), http.StatusOK, "library during the grace period")
+	if purged, err := s.handler.PurgeDeletedAccounts(); err != nil || purged != 0 {
+		t.Fatalf("purged %d accounts (err %v) during the grace period", purged, err)
+	}
+
+	s.expect(s.do("POST", "/me/restore", token, nil), http.StatusOK, "restore account")
+	s.expect(s.do("POST", "/me/restore", token, nil), http.StatusNotFound, "restore an account that is not deleted")
+}
+
+func TestPurgeAnonymizesPlays(t *testing.T) {
+	t.Setenv("ACCOUNT_DELETION_GRACE", "1ms")
+	s := newTestServer(t)
+	alice, _ := s.register("alice")
+	bob, _ := s.register("bob")
+
+	s.expect(s.do("POST", "/tracks/5/play", alice, nil), http.StatusOK, "record play")
+	s.expect(s.do("POST", "/tracks/5/play", alice, nil), http.StatusOK, "record play")
+	playlistID := s.createPlaylist(alice, "Mine", true)
+	s.expect(s.do("POST", "/users/alice/follow", bob, nil), http.StatusOK, "follow alice")
+
+	s.expect(s.do("DELETE", "/me", alice, gin.H{"password": "secret123"}), http.StatusAccepted, "delete account")
+	time.Sleep(5 * time.Millisecond)
+	if purged, err := s.handler.PurgeDeletedAccounts(); err != nil || purged != 1 {
+		t.Fatalf("purged %d accounts (err %v), want 1", purged, err)
+	}
+
+	// The plays stay without their user
+	if count := s.memory.PlayCount(5); count != 2 {
+		t.Fatalf("play count %d after the purge, want 2", count)
+	}
+	res := s.expect(s.do("GET", "/recommendations/trending?limit=1", "", nil), http.StatusOK, "trending")
+	if tracks := res.Body["tracks"].([]interface{}); len(tracks) != 1 || tracks[0].(map[string]interface{})["id"] != 5.0 {
+		t.Fatalf("unexpected trending tracks after the purge %v", tracks)
+	}
+
+	s.expect(s.do("POST", "/auth/login", "", gin.H{"email": "alice@example.com", "password": "secret123"}),
+		http.StatusUnauthorized, "login to a purged account")
+	s.expect(s.do("GET", fmt.Sprintf("/playlists/%d", playlistID), bob, nil), http.StatusNotFound, "playlist of a purged account")
+	res = s.expect(s.do("GET", "/users/bob/following", bob, nil), http.StatusOK, "following")
+	if res.Body["total"] != 0.0 {
+		t.Fatalf("bob still follows %v", res.Body["following"])
+	}
+}
+
+// failingAccounts fails to purge one user
+type failingAccounts struct {
+	store.AccountStore
+	userID int
+}
+
+func (a *failingAccounts) Purge(userID int) ([]string, error) {
+	if userID == a.userID {
+		return nil, errors.New("purge failed")
+	}
+	return a.AccountStore.Purge(userID)
+}
+
+func TestPurgeContinuesPastFailures(t *testing.T) {
+	t.Setenv("ACCOUNT_DELETION_GRACE", "1ms")
+	accounts := &failingAccounts{}
+	s := newTestServerWith(t, func(stores *store.Stores) {
+		accounts.AccountStore = stores.Accounts
+		stores.Accounts = accounts
+	})
+	alice, aliceID := s.register("alice")
+	bob, _ := s.register("bob")
+	accounts.userID = aliceID
+
+	s.expect(s.do("DELETE", "/me", alice, gin.H{"password": "secret123"}), http.StatusAccepted, "delete alice")
+	s.expect(s.do("DELETE", "/me", bob, gin.H{"password": "secret123"}), http.StatusAccepted, "delete bob")
+	time.Sleep(5 * time.Millisecond)
+	if purged, err := s.handler.PurgeDeletedAccounts(); err != nil || purged != 1 {
+		t.Fatalf("purged %d accounts (err %v), want 1", purged, err)
+	}
+
+	s.expect(s.do("POST", "/auth/login", "", gin.H{"email": "alice@example.com", "password": "secret123"}),
+		http.StatusOK, "login to the account that failed to purge")
+	s.expect(s.do("POST", "/auth/login", "", gin.H{"email": "bob@example.com", "password": "secret123"}),
+		http.StatusUnauthorized, "login to a purged account")
+}
+
+func TestExportMyData(t *testing.T) {
+	s := newTestServer(t)
+	alice, _ := s.register("alice")
+	bob, _ := s.register("bob")
+
+	s.expect(s.do("POST", "/users/alice/follow", bob, nil), http.StatusOK, "follow alice")
+	s.createPlaylist(alice, "Mine", false)
+	s.expect(s.do("POST", "/tracks/1/play", alice, nil), http.StatusOK, "record play")
+	res := s.expect(s.do("POST", "/radio", alice, gin.H{"seed_type": "track", "seed_id": 1}), http.StatusCreated, "start radio")
+	stationID := int(res.Body["station"].(map[string]interface{})["id"].(float64))
+	skipped := res.Body["tracks"].([]interface{})[0].(map[string]interface{})["id"]
+	s.expect(s.do("POST", fmt.Sprintf("/radio/%d/skips", stationID), alice, gin.H{"track_id": skipped}), http.StatusOK, "skip a radio track")
+	s.expect(s.do("POST", "/me/player/queue", alice, gin.H{"track_id": 2}), http.StatusCreated, "queue a track")
+	s.expect(s.do("POST", "/sessions", alice, nil), http.StatusCreated, "start a session")
+
+	rec := s.send("GET", "/me/export", alice, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export: got status %d (body %s)", rec.Code, rec.Body.String())
+	}
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]map[string]interface{}{}
+	for _, file := range archive.File {
+		content, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := map[string]interface{}{}
+		if err := json.NewDecoder(content).Decode(&decoded); err != nil {
+			t.Fatalf("decoding %s: %v", file.Name, err)
+		}
+		content.Close()
+		files[file.Name] = decoded
+	}
+
+	count := func(file, key string) int {
+		t.Helper()
+		items, ok := files[file][key].([]interface{})
+		if !ok {
+			t.Fatalf("%s has no %s: %v", file, key, files[file])
+		}
+		return len(items)
+	}
+	for _, entry := range []struct {
+		file, key string
+		want      int
+	}{
+		{"playlists.json", "playlists", 1},
+		{"favorites.json", "followers", 1},
+		{"favorites.json", "following", 0},
+		{"listening_history.json", "plays", 1},
+		{"listening_history.json", "skips", 1},
+		{"activity.json", "activities", 1},
+		{"radio.json", "stations", 1},
+		{"player.json", "queue", 1},
+		{"sessions.json", "sessions", 1},
+	} {
+		if got := count(entry.file, entry.key); got != entry.want {
+			t.Errorf("%s has %d %s, want %d", entry.file, got, entry.key, entry.want)
+		}
+	}
+	station := files["radio.json"]["stations"].([]interface{})[0].(map[string]interface{})
+	if tracks := station["tracks"].([]interface{}); len(tracks) == 0 {
+		t.Errorf("exported station has no served tracks")
+	}
+}
+
+// failingPrivacy cannot read anyone's privacy settings
+type failingPrivacy struct {
+	store.PrivacyStore
+}
+
+func (failingPrivacy) Get(userID int) (models.PrivacySettings, error) {
+	return models.PrivacySettings{}, errors.New("privacy settings unavailable")
+}
+
+func TestPrivacyFailsClosed(t *testing.T) {
+	s := newTestServerWith(t, func(stores *store.Stores) {
+		stores.Privacy = failingPrivacy{stores.Privacy}
+	})
+	alice, _ := s.register("alice")
+	bob, _ := s.register("bob")
+
+	s.expect(s.do("POST", "/tracks/1/play", alice, nil), http.StatusInternalServerError, "record play without privacy settings")
+	if count := s.memory.PlayCount(1); count != 0 {
+		t.Fatalf("play count %d, want the play left unrecorded", count)
+	}
+	s.expect(s.do("GET", "/users/alice", bob, nil), http.StatusInternalServerError, "profile without privacy settings")
+	s.expect(s.do("GET", "/users/alice/followers", bob, nil), http.StatusInternalServerError, "followers without privacy settings")
+}
+
+func TestPlayedByMeHonorsHiddenListening(t *testing.T) {
+	s := newTestServer(t)
+	alice, _ := s.register("alice")
+	bob, _ := s.register("bob")
+
+	s.expect(s.do("POST", "/tracks/1/play", alice, nil), http.StatusOK, "record a play")
+	playlistID := s.createSmartPlaylist(alice, true, gin.H{
+		"rules": []gin.H{{"field": "played_by_me", "operator": "within_days", "number": 7}},
+	})
+
+	if ids := s.trackIDs(bob, playlistID); !equalIDs(ids, []int{1}) {
+		t.Fatalf("bob sees %v, want alice's play", ids)
+	}
+	s.expect(s.do("PUT", "/profile/privacy", alice, gin.H{"hide_listening_activity": true}), http.StatusOK, "hide listening")
+	if ids := s.trackIDs(bob, playlistID); len(ids) != 0 {
+		t.Fatalf("bob sees %v, want alice's hidden plays left out", ids)
+	}
+	if ids := s.trackIDs(alice, playlistID); !equalIDs(ids, []int{1}) {
+		t.Fatalf("alice sees %v, want her own play", ids)
+	}
+}
+
+func TestFollowListsClampPaging(t *testing.T) {
+	s := newTestServer(t)
+	alice, _ := s.register("alice")
+	bob, _ := s.register("bob")
+	s.expect(s.do("POST", "/users/alice/follow", bob, nil), http.StatusOK, "follow alice")
+
+	for _, query := range []string{"?limit=-1", "?limit=0&page=0", "?limit=1000&page=-3"} {
+		res := s.expect(s.do("GET", "/users/alice/followers"+query, alice, nil), http.StatusOK, "followers"+query)
+		if res.Body["limit"] != 50.0 || res.Body["page"] != 1.0 {
+			t.Fatalf("followers%s: got page %v limit %v, want page 1 limit 50", query, res.Body["page"], res.Body["limit"])
+		}
+		if followers := res.Body["followers"].([]interface{}); len(followers) != 1 {
+			t.Fatalf("followers%s: got %d followers, want 1", query, len(followers))
+		}
+	}
+}
+
+func TestSmartPlaylistRules(t *testing.T) {
+	s := newTestServer(t)
+	alice, _ := s.register("alice")
+	bob, _ := s.register("bob")
+	s.expect(s.do("POST", "/tracks/4/play", alice, nil), http.StatusOK, "alice plays a track")
+	s.expect(s.do("POST", "/tracks/5/play", bob, nil), http.StatusOK, "bob plays a track")
+
+	cases := []struct {
+		name       string
+		definition gin.H
+		want       []int
+	}{
+		{"genre", gin.H{"rules": []gin.H{{"field": "genre", "operator": "in", "values": []string{"electronic"}}}}, []int{10, 11, 12}},
+		{"artist", gin.H{"rules": []gin.H{{"field": "artist", "operator": "in", "ids": []int{1}}}}, []int{1, 2, 3}},
+		{"release date", gin.H{"rules": []gin.H{{"field": "release_date", "operator": "after", "date": "2020-01-01"}}}, []int{7, 8, 9, 1, 2, 3}},
+		{"play count", gin.H{"rules": []gin.H{{"field": "play_count", "operator": "gt", "number": 0}}}, []int{4, 5}},
+		{"played by me", gin.H{"rules": []gin.H{{"field": "played_by_me", "operator": "within_days", "number": 7}}}, []int{4}},
+		{"match all", gin.H{"rules": []gin.H{
+			{"field": "genre", "operator": "in", "values": []string{"pop"}},
+			{"field": "release_date", "operator": "before", "date": "2018-01-01"},
+		}}, []int{4, 5, 6}},
+		{"match any by title with a limit", gin.H{"match": "any", "order_by": "title", "limit": 4, "rules": []gin.H{
+			{"field": "genre", "operator": "in", "values": []string{"electronic"}},
+			{"field": "artist", "operator": "in", "ids": []int{1}},
+		}}, []int{1, 10, 3, 11}},
+	}
+	for _, tc := range cases {
+		playlistID := s.createSmartPlaylist(alice, false, tc.definition)
+		if ids := s.trackIDs(alice, playlistID); !equalIDs(ids, tc.want) {
+			t.Errorf("%s: got tracks %v, want %v", tc.name, ids, tc.want)
+		}
+	}
+
+	for name, definition := range map[string]gin.H{
+		"unknown field":    {"rules": []gin.H{{"field": "mood", "operator": "in", "values": []string{"happy"}}}},
+		"missing values":   {"rules": []gin.H{{"field": "genre", "operator": "in"}}},
+		"unknown operator": {"rules": []gin.H{{"field": "play_count", "operator": "between", "number": 3}}},
+		"unknown order":    {"order_by": "loudness", "rules": []gin.H{{"field": "artist", "operator": "in", "ids": []int{1}}}},
+	} {
+		s.expect(s.do("POST", "/playlists/smart", alice, gin.H{"name": "Bad", "definition": definition}),
+			http.StatusBadRequest, "create smart playlist with "+name)
+	}
+
+	playlistID := s.createSmartPlaylist(alice, false, gin.H{"rules": []gin.H{{"field": "artist", "operator": "in", "ids": []int{1}}}})
+	s.expect(s.do("PUT", fmt.Sprintf("/playlists/%d/rules", playlistID), alice, gin.H{
+		"rules": []gin.H{{"field": "artist", "operator": "in", "ids": []int{2}}},
+	}), http.StatusOK, "update rules")
+	if ids := s.trackIDs(alice, playlistID); !equalIDs(ids, []int{4, 5, 6}) {
+		t.Fatalf("got tracks %v after the rules update, want 4, 5 and 6", ids)
+	}
+	s.expect(s.do("PUT", fmt.Sprintf("/playlists/%d/rules", playlistID), bob, gin.H{
+		"rules": []gin.H{{"field": "artist", "operator": "in", "ids": []int{3}}},
+	}), http.StatusForbidden, "update rules of another user's playlist")
+}
+
+func TestSmartPlaylistRandomOrderIsStable(t *testing.T) {
+	s := newTestServer(t)
+	alice, _ := s.register("alice")
+
+	playlistID := s.createSmartPlaylist(alice, false, gin.H{
+		"order_by": "random",
+		"rules":    []gin.H{{"field": "genre", "operator": "not_in", "values": []string{"electronic"}}},
+	})
+	first := s.trackIDs(alice, playlistID)
+	if len(first) != 9 {
+		t.Fatalf("got %d tracks, want the 9 that are not electronic", len(first))
+	}
+	for i := 0; i < 5; i++ {
+		if ids := s.trackIDs(alice, playlistID); !equalIDs(ids, first) {
+			t.Fatalf("order changed between reads: %v then %v", first, ids)
+		}
+	}
+}
+
+func TestListeningSession(t *testing.T) {
+	s := newTestServer(t)
+	alice, _ := s.register("alice")
+	bob, _ := s.register("bob")
+	carol, _ := s.register("carol")
+	dave, _ := s.register("dave")
+
+	res := s.expect(s.do("POST", "/sessions", alice, nil), http.StatusCreated, "start a session")
+	code := res.Body["code"].(string)
+	path := func(suffix string) string { return "/sessions/" + code + suffix }
+
+	s.expect(s.do("POST", "/sessions/join", bob, gin.H{"code": "NOPE42"}), http.StatusNotFound, "join with a wrong code")
+	s.expect(s.do("GET", path(""), bob, nil), http.StatusForbidden, "read a session before joining")
+	for _, token := range []string{bob, carol, dave} {
+		s.expect(s.do("POST", "/sessions/join", token, gin.H{"code": strings.ToLower(code)}), http.StatusOK, "join")
+	}
+	res = s.expect(s.do("POST", "/sessions/join", bob, gin.H{"code": code}), http.StatusOK, "join twice")
+	if members := res.Body["members"].([]interface{}); len(members) != 4 {
+		t.Fatalf("got %d members, want 4", len(members))
+	}
+	s.expect(s.do("POST", "/sessions", bob, nil), http.StatusConflict, "start a session while in one")
+
+	// Any member queues on the host's player
+	s.expect(s.do("PUT", "/me/player/play", alice, gin.H{"context_type": "album", "context_id": 1, "position_ms": 60000}),
+		http.StatusOK, "host plays an album")
+	s.expect(s.do("POST", path("/queue"), bob, gin.H{"track_id": 7}), http.StatusOK, "queue as a member")
+	res = s.expect(s.do("GET", "/me/player/queue", alice, nil), http.StatusOK, "host's queue")
+	if queue := res.Body["queue"].([]interface{}); len(queue) != 1 || queue[0].(map[string]interface{})["track_id"] != 7.0 {
+		t.Fatalf("unexpected host queue %v", queue)
+	}
+
+	// Three of four members make a majority; carol listens privately
+	s.expect(s.do("POST", "/profile/private-session", carol, nil), http.StatusOK, "carol goes private")
+	for i, token := range []string{bob, carol, dave} {
+		res = s.expect(s.do("POST", path("/skip"), token, nil), http.StatusOK, "vote to skip")
+		if skipped := res.Body["skipped"] == true; skipped != (i == 2) {
+			t.Fatalf("vote %d: skipped %v", i+1, skipped)
+		}
+	}
+	state := res.Body["session"].(map[string]interface{})["state"].(map[string]interface{})
+	if state["track_id"] != 7.0 {
+		t.Fatalf("playing %v after the skip, want the queued track 7", state["track_id"])
+	}
+	if count := s.memory.PlayCount(1); count != 3 {
+		t.Fatalf("track 1 has %d plays, want one for each member but carol", count)
+	}
+
+	// Two votes fall short of three until a member who did not vote leaves
+	s.expect(s.do("POST", path("/skip"), bob, nil), http.StatusOK, "vote to skip")
+	s.expect(s.do("POST", path("/skip"), carol, nil), http.StatusOK, "vote to skip")
+	s.expect(s.do("POST", path("/leave"), dave, nil), http.StatusOK, "leave")
+	res = s.expect(s.do("GET", path(""), alice, nil), http.StatusOK, "read the session")
+	state = res.Body["state"].(map[string]interface{})
+	if state["track_id"] == 7.0 || res.Body["skip_votes"] != 0.0 || res.Body["skip_votes_needed"] != 2.0 {
+		t.Fatalf("votes %v of %v playing %v, want the remaining majority to have skipped track 7",
+			res.Body["skip_votes"], res.Body["skip_votes_needed"], state["track_id"])
+	}
+	s.expect(s.do("GET", path(""), dave, nil), http.StatusForbidden, "read a session after leaving")
+
+	s.expect(s.do("POST", path("/leave"), alice, nil), http.StatusOK, "host leaves")
+	s.expect(s.do("GET", "/sessions/current", bob, nil), http.StatusNotFound, "session after the host left")
+}
+
+func TestPlaylistCollaboration(t *testing.T) {
+	s := newTestServer(t)
+	alice, aliceID := s.register("alice")
+	bob, bobID := s.register("bob")
+	carol, carolID := s.register("carol")
+	playlistID := s.createPlaylist(alice, "Road trip", false)
+	path := func(suffix string) string { return fmt.Sprintf("/playlists/%d%s", playlistID, suffix) }
+	invite := func(token string) string {
+		res := s.expect(s.do("POST", path("/invites"), token, nil), http.StatusCreated, "create an invite")
+		return res.Body["invite"].(map[string]interface{})["token"].(string)
+	}
+	accept := func(token, inviteToken string) response {
+		return s.do("POST", "/playlists/invites/"+inviteToken+"/accept", token, nil)
+	}
+
+	s.expect(s.do("POST", path("/tracks"), alice, gin.H{"track_id": 1}), http.StatusOK, "add track as the owner")
+	s.expect(s.do("POST", path("/invites"), bob, nil), http.StatusForbidden, "invite as a stranger")
+	s.expect(accept(bob, "not-a-token"), http.StatusNotFound, "accept an unknown invite")
+	first := invite(alice)
+	s.expect(accept(alice, first), http.StatusBadRequest, "accept an invite to your own playlist")
+	s.expect(accept(bob, first), http.StatusOK, "accept an invite")
+
+	// A collaborator edits the tracks but not the playlist or who can edit it
+	s.expect(s.do("POST", path("/tracks"), bob, gin.H{"track_id": 2}), http.StatusOK, "add track as a collaborator")
+	s.expect(s.do("PUT", path("/tracks/2/position"), bob, gin.H{"position": 0}), http.StatusOK, "reorder as a collaborator")
+	if ids := s.trackIDs(alice, playlistID); !equalIDs(ids, []int{2, 1}) {
+		t.Fatalf("track order %v, want [2 1]", ids)
+	}
+	s.expect(s.do("POST", path("/invites"), bob, nil), http.StatusForbidden, "invite as a collaborator")
+	s.expect(s.do("GET", path("/invites"), bob, nil), http.StatusForbidden, "list invites as a collaborator")
+	s.expect(s.do("DELETE", path("/invites/"+first), bob, nil), http.StatusForbidden, "revoke as a collaborator")
+	s.expect(s.do("DELETE", path(fmt.Sprintf("/collaborators/%d", aliceID)), bob, nil), http.StatusForbidden, "remove the owner as a collaborator")
+	s.expect(s.do("PUT", path(""), bob, gin.H{"name": "Bob's trip"}), http.StatusForbidden, "rename as a collaborator")
+	s.expect(s.do("DELETE", path(""), bob, nil), http.StatusForbidden, "delete as a collaborator")
+
+	// A revoked invite lets nobody in
+	second := invite(alice)
+	s.expect(s.do("DELETE", path("/invites/"+second), alice, nil), http.StatusOK, "revoke an invite")
+	s.expect(s.do("DELETE", path("/invites/"+second), alice, nil), http.StatusNotFound, "revoke an invite twice")
+	s.expect(accept(carol, second), http.StatusNotFound, "accept a revoked invite")
+	s.expect(s.do("GET", path(""), carol, nil), http.StatusForbidden, "read after a revoked invite")
+
+	// Removed collaborators lose write access; collaborators may leave on their own
+	s.expect(s.do("DELETE", path(fmt.Sprintf("/collaborators/%d", bobID)), alice, nil), http.StatusOK, "remove a collaborator")
+	s.expect(s.do("POST", path("/tracks"), bob, gin.H{"track_id": 3}), http.StatusForbidden, "add track after removal")
+	s.expect(s.do("PUT", path("/tracks/1/position"), bob, gin.H{"position": 0}), http.StatusForbidden, "reorder after removal")
+	s.expect(s.do("GET", path(""), bob, nil), http.StatusForbidden, "read after removal")
+	s.expect(accept(carol, first), http.StatusOK, "accept the first invite")
+	s.expect(s.do("DELETE", path(fmt.Sprintf("/collaborators/%d", carolID)), carol, nil), http.StatusOK, "leave as a collaborator")
+	s.expect(s.do("POST", path("/tracks"), carol, gin.H{"track_id": 3}), http.StatusForbidden, "add track after leaving")
+	if ids := s.trackIDs(alice, playlistID); !equalIDs(ids, []int{2, 1}) {
+		t.Fatalf("track order %v, want [2 1]", ids)
+	}
+}
+
+// radioTracks returns the IDs of a radio batch's tracks
+func radioTracks(res response) []int {
+	ids := []int{}
+	for _, track := range res.Body["tracks"].([]interface{}) {
+		ids = append(ids, int(track.(map[string]interface{})["id"].(float64)))
+	}
+	return ids
+}
+
+func TestRadioBatchesDoNotRepeat(t *testing.T) {
+	s := newTestServer(t)
+	alice, _ := s.register("alice")
+
+	res := s.expect(s.do("POST", "/radio", alice, gin.H{"seed_type": "track", "seed_id": 1, "limit": 4}), http.StatusCreated, "start a station")
+	stationID := int(res.Body["station"].(map[string]interface{})["id"].(float64))
+	next := fmt.Sprintf("/radio/%d/next?limit=4", stationID)
+
+	// The 11 tracks besides the seed fill three batches without a repeat
+	served := map[int]bool{}
+	batch := radioTracks(res)
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			batch = radioTracks(s.expect(s.do("POST", next, alice, nil), http.StatusOK, "next batch"))
+		}
+		for _, id := range batch {
+			if id == 1 || served[id] {
+				t.Fatalf("batch %d repeats track %d", i+1, id)
+			}
+			served[id] = true
+		}
+	}
+	if len(served) != 11 {
+		t.Fatalf("served %d tracks, want the whole catalog but the seed", len(served))
+	}
+
+	// Then the station starts over, except for the batch it just played
+	again := radioTracks(s.expect(s.do("POST", next, alice, nil), http.StatusOK, "batch after the catalog ran out"))
+	if len(again) == 0 {
+		t.Fatal("the station stopped once the catalog was used up")
+	}
+	for _, id := range again {
+		for _, last := range batch {
+			if id == last || id == 1 {
+				t.Fatalf("track %d came back right after it played", id)
+			}
+		}
+	}
+}
+
+func TestRadioLeavesOutSkips(t *testing.T) {
+	s := newTestServer(t)
+	alice, _ := s.register("alice")
+	bob, _ := s.register("bob")
+
+	// Bob skips the two Drake tracks a first station serves, one of them twice
+	res := s.expect(s.do("POST", "/radio", bob, gin.H{"seed_type": "track", "seed_id": 10, "limit": 4}), http.StatusCreated, "start a station")
+	stationID := int(res.Body["station"].(map[string]interface{})["id"].(float64))
+	for _, id := range []int{8, 9, 8} {
+		s.expect(s.do("POST", fmt.Sprintf("/radio/%d/skips", stationID), bob, gin.H{"track_id": id}), http.StatusOK, "skip a served track")
+	}
+	s.expect(s.do("POST", fmt.Sprintf("/radio/%d/skips", stationID), bob, gin.H{"track_id": 1}), http.StatusNotFound, "skip a track the station did not serve")
+
+	playlistID := s.createPlaylist(bob, "Seeds", true)
+	for _, id := range []int{1, 8} {
+		s.expect(s.do("POST", fmt.Sprintf("/playlists/%d/tracks", playlistID), bob, gin.H{"track_id": id}), http.StatusOK, "add a seed track")
+	}
+	seed := gin.H{"seed_type": "playlist", "seed_id": playlistID, "limit": 2}
+
+	// Without skips Drake's remaining track ranks with the best matches
+	res = s.expect(s.do("POST", "/radio", alice, seed), http.StatusCreated, "start alice's station")
+	if ids := radioTracks(res); !equalIDs(ids, []int{2, 7}) {
+		t.Fatalf("alice's first batch %v, want [2 7]", ids)
+	}
+
+	// Bob's skips rank Drake lower and keep the skipped track out of every batch
+	res = s.expect(s.do("POST", "/radio", bob, seed), http.StatusCreated, "start bob's station")
+	if ids := radioTracks(res); !equalIDs(ids, []int{2, 3}) {
+		t.Fatalf("bob's first batch %v, want [2 3]", ids)
+	}
+	next := fmt.Sprintf("/radio/%d/next?limit=2", int(res.Body["station"].(map[string]interface{})["id"].(float64)))
+	for i := 0; i < 6; i++ {
+		for _, id := range radioTracks(s.expect(s.do("POST", next, bob, nil), http.StatusOK, "next batch")) {
+			if id == 9 {
+				t.Fatalf("batch %d has the skipped track 9", i+2)
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// CoverSize is the edge length in pixels of generated and uploaded covers
+const CoverSize = 640
+
+// maxImageBytes bounds how much is read when loading a source image
+const maxImageBytes = 10 << 20
+
+// maxImagePixels bounds the width times height of decoded images. A small
+// compressed file can declare a huge canvas, and decoding allocates all of it.
+const maxImagePixels = 4096 * 4096
+
+// errBlockedAddress is returned when an image URL resolves to an address on
+// the server's own networks
+var errBlockedAddress = errors.New("image url points to a private or local address")
+
+// nonPublicNetworks are the ranges net.IP does not classify that still do not
+// reach the public internet: "this network" and carrier-grade NAT
+var nonPublicNetworks = []*net.IPNet{
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+}
+
+// publicAddress reports whether ip may be fetched from. Image URLs come from
+// catalog data anyone can submit, so fetching must not reach loopback,
+// private, link-local (including cloud metadata) or other internal addresses.
+var publicAddress = func(ip net.IP) bool {
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// httpClient checks the address of every connection it opens, after name
+// resolution and on redirects, so a hostname cannot point it inward. It does
+// not use a proxy, which would hide the address it connects to.
+var httpClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !publicAddress(ip) {
+					return errBlockedAddress
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	},
+}
+
+// LoadImage decodes an image from an http(s) URL on a public address or from
+// a URL served out of local storage
+func LoadImage(ctx context.Context, url string) (image.Image, error) {
+	if name, ok := strings.CutPrefix(url, URLPrefix+"/"); ok {
+		path, err := localPath(name)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return DecodeImage(data)
+	}
+
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("unsupported image url %q", url)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: status %d", url, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes))
+	if err != nil {
+		return nil, err
+	}
+	return DecodeImage(data)
+}
+
+// DecodeImage decodes JPEG or PNG data, refusing images larger than
+// maxImagePixels before allocating them
+func DecodeImage(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported or corrupt image: %v", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > maxImagePixels/config.Height {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported or corrupt image: %v", err)
+	}
+	return img, nil
+}
+
+// EncodeJPEG encodes an image as a JPEG
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Mosaic tiles up to four images into a square cover. Four images form a 2x2
+// grid; with fewer, the first image fills the whole cover.
+func Mosaic(images []image.Image, size int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{color.Black}, image.Point{}, draw.Src)
+
+	if len(images) < 4 {
+		if len(images) > 0 {
+			draw.Draw(dst, dst.Bounds(), Square(images[0], size), image.Point{}, draw.Src)
+		}
+		return dst
+	}
+
+	half := size / 2
+	for i, img := range images[:4] {
+		x, y := (i%2)*half, (i/2)*half
+		tile := Square(img, half)
+		draw.Draw(dst, image.Rect(x, y, x+half, y+half), tile, image.Point{}, draw.Src)
+	}
+	return dst
+}
+
+// Square center-crops an image to a square and scales it to size x size
+// by averaging the source pixels that fall into each destination pixel
+func Square(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	edge := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-edge)/2
+	y0 := b.Min.Y + (b.Dy()-edge)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	if edge == 0 {
+		return dst
+	}
+
+	for dy := 0; dy < size; dy++ {
+		sy0 := y0 + dy*edge/size
+		sy1 := max(y0+(dy+1)*edge/size, sy0+1)
+		for dx := 0; dx < size; dx++ {
+			sx0 := x0 + dx*edge/size
+			sx1 := max(x0+(dx+1)*edge/size, sx0+1)
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(dx, dy, color.RGBA{
+				R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(bl / n >> 8), A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
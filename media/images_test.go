@@ -0,0 +1,115 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeImageRejectsHugeCanvas(t *testing.T) {
+	if _, err := DecodeImage(encodePNG(t, 8, 8)); err != nil {
+		t.Fatalf("small image: %v", err)
+	}
+
+	// Declare 50000x50000 pixels in the IHDR chunk of a tiny PNG
+	data := encodePNG(t, 1, 1)
+	ihdr := data[8+8 : 8+8+13]
+	binary.BigEndian.PutUint32(ihdr[0:], 50000)
+	binary.BigEndian.PutUint32(ihdr[4:], 50000)
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+
+	_, err := DecodeImage(data)
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("DecodeImage(50000x50000) = %v, want too large", err)
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	for address, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"0.1.2.3":          false,
+		"::1":              false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"::ffff:127.0.0.1": false,
+		"224.0.0.1":        false,
+	} {
+		if got := publicAddress(net.ParseIP(address)); got != want {
+			t.Errorf("publicAddress(%s) = %v, want %v", address, got, want)
+		}
+	}
+}
+
+func TestLoadImageBlocksLocalAddresses(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer server.Close()
+
+	_, err := LoadImage(context.Background(), server.URL+"/cover.png")
+	if !errors.Is(err, errBlockedAddress) {
+		t.Fatalf("LoadImage(%s) = %v, want the address blocked", server.URL, err)
+	}
+	if requested {
+		t.Fatal("the request reached the local server")
+	}
+}
+
+func TestStoredNamesStayInDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("STORAGE_DIR", filepath.Join(dir, "storage"))
+	if err := os.WriteFile(filepath.Join(dir, "secret.png"), encodePNG(t, 1, 1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadImage(context.Background(), URLPrefix+"/../secret.png"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("LoadImage outside the storage directory = %v, want ErrInvalidName", err)
+	}
+	for _, name := range []string{"../secret.png", "covers/../../secret.png", "/etc/passwd", ""} {
+		if err := Save(name, []byte("x")); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("Save(%q) = %v, want ErrInvalidName", name, err)
+		}
+	}
+	if err := Remove("../secret.png"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Remove outside the storage directory = %v, want ErrInvalidName", err)
+	}
+	if Exists("../secret.png") {
+		t.Error("Exists reports a file outside the storage directory")
+	}
+
+	if err := Save("covers/a.jpg", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if !Exists("covers/a.jpg") {
+		t.Error("Exists(covers/a.jpg) = false after Save")
+	}
+}
@@ -0,0 +1,83 @@
+// Package media stores generated and uploaded files (such as playlist covers)
+// on the local filesystem and builds the URLs they are served from.
+package media
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrInvalidName is returned for names that are empty, absolute or leave the
+// storage directory
+var ErrInvalidName = errors.New("invalid media file name")
+
+// URLPrefix is the path under which the storage directory is served
+const URLPrefix = "/media"
+
+// Dir returns the local storage directory, configured with STORAGE_DIR
+func Dir() string {
+	if dir := os.Getenv("STORAGE_DIR"); dir != "" {
+		return dir
+	}
+	return "storage"
+}
+
+// localPath returns the path of a stored file. Names are slash-separated and
+// relative to the storage directory, which they cannot leave.
+func localPath(name string) (string, error) {
+	local := filepath.FromSlash(name)
+	if !filepath.IsLocal(local) {
+		return "", ErrInvalidName
+	}
+	return filepath.Join(Dir(), local), nil
+}
+
+// Save writes data to a file relative to the storage directory
+func Save(name string, data []byte) error {
+	path, err := localPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial image
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Remove deletes a stored file, ignoring files that are already gone
+func Remove(name string) error {
+	if name == "" {
+		return nil
+	}
+	path, err := localPath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Exists reports whether a stored file is present
+func Exists(name string) bool {
+	path, err := localPath(name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// URL returns the public URL of a stored file
+func URL(name string) string {
+	return URLPrefix + "/" + name
+}
@@ -41,6 +41,29 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// QueryToken takes an ?access_token= parameter out of the request URL so the
+// token never reaches the request log. It must run before the logger. Browsers
+// cannot set headers on a WebSocket handshake, so for those the token becomes
+// the Authorization header.
+func QueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		if !query.Has("access_token") {
+			c.Next()
+			return
+		}
+
+		token := query.Get("access_token")
+		query.Del("access_token")
+		c.Request.URL.RawQuery = query.Encode()
+		c.Request.RequestURI = c.Request.URL.RequestURI()
+		if token != "" && c.GetHeader("Authorization") == "" && strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		c.Next()
+	}
+}
+
 // GetUserID retrieves the user ID from context
 func GetUserID(c *gin.Context) (int, bool) {
 	userID, exists := c.Get("user_id")
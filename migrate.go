@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"spotify-clone/database"
+	"spotify-clone/migrations"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = `usage: spotify-clone migrate <command>
+
+commands:
+  up [-to VERSION]    apply pending migrations, up to VERSION if given
+  down [-steps N]     roll back the last N applied migrations (default 1)
+  status              list migrations and whether they are applied
+  force VERSION       record the schema as at VERSION after fixing a failed migration`
+
+// runMigrate implements "spotify-clone migrate", which changes the schema
+// without starting the server
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+
+	if err := database.ConnectMySQL(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	migrator, err := migrations.New(database.MySQL)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "up":
+		flags := flag.NewFlagSet("migrate up", flag.ContinueOnError)
+		to := flags.Int("to", 0, "version to migrate up to")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		applied, err := migrator.Up(ctx, *to)
+		for _, migration := range applied {
+			fmt.Printf("applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return err
+
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := flags.Int("steps", 1, "number of migrations to roll back")
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if *steps < 1 {
+			return fmt.Errorf("-steps must be at least 1")
+		}
+		reverted, err := migrator.Down(ctx, *steps)
+		for _, migration := range reverted {
+			fmt.Printf("rolled back %d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("no applied migrations")
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state, appliedAt = "applied", status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Dirty {
+				state = "dirty"
+			}
+			if status.Applied && status.Up == "" {
+				state += " (unknown to this build)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+		}
+		return w.Flush()
+
+	case "force":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: spotify-clone migrate force VERSION")
+		}
+		version, err := strconv.Atoi(args[0])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			return err
+		}
+		fmt.Printf("schema recorded at version %d\n", version)
+		return nil
+	}
+
+	return fmt.Errorf("unknown migrate command %q\n\n%s", command, migrateUsage)
+}
@@ -0,0 +1,384 @@
+package migrations
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func versions(migrations []Migration) []int {
+	out := []int{}
+	for _, migration := range migrations {
+		out = append(out, migration.Version)
+	}
+	return out
+}
+
+func TestLoadOrdersByVersion(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"0002_add_genres.up.sql":   file("CREATE TABLE genres (id INT);"),
+		"0002_add_genres.down.sql": file("DROP TABLE genres;"),
+		"0001_baseline.up.sql":     file("CREATE TABLE artists (id INT);"),
+		"0001_baseline.down.sql":   file("DROP TABLE artists;"),
+		"README.md":                file("not a migration"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(migrations); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Fatalf("versions = %v, want [1 2]", got)
+	}
+	if migrations[1].Name != "add_genres" || migrations[1].Down != "DROP TABLE genres;" {
+		t.Fatalf("migration 2 = %+v", migrations[1])
+	}
+}
+
+func TestLoadRejectsBadSets(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"missing down": {
+			"0001_baseline.up.sql": file("CREATE TABLE a (id INT);"),
+		},
+		"empty up": {
+			"0001_baseline.up.sql":   file("  \n"),
+			"0001_baseline.down.sql": file("DROP TABLE a;"),
+		},
+		"duplicate version": {
+			"0001_baseline.up.sql":   file("CREATE TABLE a (id INT);"),
+			"0001_baseline.down.sql": file("DROP TABLE a;"),
+			"1_baseline.up.sql":      file("CREATE TABLE b (id INT);"),
+		},
+		"mismatched names": {
+			"0001_baseline.up.sql": file("CREATE TABLE a (id INT);"),
+			"0001_other.down.sql":  file("DROP TABLE a;"),
+		},
+		"bad file name": {
+			"baseline.sql": file("CREATE TABLE a (id INT);"),
+		},
+		"version zero": {
+			"0000_baseline.up.sql":   file("CREATE TABLE a (id INT);"),
+			"0000_baseline.down.sql": file("DROP TABLE a;"),
+		},
+	}
+	for name, fsys := range cases {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: Load succeeded", name)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 {
+		t.Fatalf("versions = %v, want to start at 1", versions(migrations))
+	}
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("versions = %v, want no gaps", versions(migrations))
+			break
+		}
+	}
+
+	// Every table the baseline creates is dropped by its down script
+	baseline := migrations[0]
+	for _, statement := range splitStatements(baseline.Up) {
+		if !strings.HasPrefix(statement, "CREATE TABLE IF NOT EXISTS ") {
+			continue
+		}
+		table := strings.Fields(statement)[5]
+		if !strings.Contains(baseline.Down, "DROP TABLE IF EXISTS "+table+";") {
+			t.Errorf("baseline down does not drop %s", table)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- leading comment
+CREATE TABLE a (id INT); # trailing comment
+INSERT INTO a VALUES ('semi;colon', "it\"s;", ` + "`odd;name`" + `);
+/* block; comment */ SELECT 1 - -1;
+SELECT '--not a comment'
+`
+	want := []string{
+		"CREATE TABLE a (id INT)",
+		"INSERT INTO a VALUES ('semi;colon', \"it\\\"s;\", `odd;name`)",
+		"SELECT 1 - -1",
+		"SELECT '--not a comment'",
+	}
+	if got := splitStatements(script); !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitStatements =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestPlanUp(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}, {Version: 3}, {Version: 4}}
+	applied := map[int]appliedRow{1: {}, 3: {}}
+
+	if got := versions(planUp(migrations, applied, 0)); !reflect.DeepEqual(got, []int{2, 4}) {
+		t.Errorf("planUp(all) = %v, want [2 4]", got)
+	}
+	if got := versions(planUp(migrations, applied, 2)); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("planUp(2) = %v, want [2]", got)
+	}
+}
+
+func TestPlanDown(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}, {Version: 3}}
+	applied := map[int]appliedRow{1: {}, 2: {}, 3: {}}
+
+	plan, err := planDown(migrations, applied, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(plan); !reflect.DeepEqual(got, []int{3, 2}) {
+		t.Errorf("planDown(2) = %v, want [3 2]", got)
+	}
+	plan, _ = planDown(migrations, applied, 10)
+	if got := versions(plan); !reflect.DeepEqual(got, []int{3, 2, 1}) {
+		t.Errorf("planDown(10) = %v, want [3 2 1]", got)
+	}
+
+	// A version applied by a newer build cannot be rolled back by this one
+	applied[4] = appliedRow{name: "future"}
+	if _, err := planDown(migrations, applied, 1); err == nil {
+		t.Error("planDown rolled back a migration missing from this build")
+	}
+}
+
+func TestCheckDirty(t *testing.T) {
+	if err := checkDirty(map[int]appliedRow{1: {}}); err != nil {
+		t.Fatalf("checkDirty(clean) = %v", err)
+	}
+	err := checkDirty(map[int]appliedRow{1: {}, 2: {dirty: true}, 3: {dirty: true}})
+	var dirty *DirtyError
+	if !errors.As(err, &dirty) || dirty.Version != 2 {
+		t.Fatalf("checkDirty = %v, want DirtyError for version 2", err)
+	}
+}
+
+func TestStatusOf(t *testing.T) {
+	migrations := []Migration{{Version: 1, Name: "baseline", Up: "x"}, {Version: 2, Name: "genres", Up: "y"}}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	applied := map[int]appliedRow{1: {name: "baseline", appliedAt: at}, 5: {name: "future", appliedAt: at}}
+
+	statuses := statusOf(migrations, applied)
+	if len(statuses) != 3 {
+		t.Fatalf("len(statuses) = %d, want 3", len(statuses))
+	}
+	if !statuses[0].Applied || !statuses[0].AppliedAt.Equal(at) {
+		t.Errorf("status 1 = %+v, want applied at %v", statuses[0], at)
+	}
+	if statuses[1].Applied {
+		t.Errorf("status 2 = %+v, want pending", statuses[1])
+	}
+	if statuses[2].Version != 5 || statuses[2].Name != "future" || statuses[2].Up != "" {
+		t.Errorf("status 3 = %+v, want the unknown applied migration", statuses[2])
+	}
+}
+
+func TestSplitStatementsDelimiter(t *testing.T) {
+	script := `DELIMITER $$
+DROP TRIGGER IF EXISTS t$$
+CREATE TRIGGER t AFTER INSERT ON a
+FOR EACH ROW
+BEGIN
+    INSERT INTO b VALUES (NEW.id); -- keep going
+    UPDATE c SET n = n + 1;
+END$$
+  delimiter ;
+SELECT 1;
+`
+	want := []string{
+		"DROP TRIGGER IF EXISTS t",
+		"CREATE TRIGGER t AFTER INSERT ON a\nFOR EACH ROW\nBEGIN\n    INSERT INTO b VALUES (NEW.id); \n    UPDATE c SET n = n + 1;\nEND",
+		"SELECT 1",
+	}
+	if got := splitStatements(script); !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitStatements =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestEmbeddedObjects(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects, err := Objects(migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	tables := 0
+	for _, object := range objects {
+		if object.Kind == "TABLE" {
+			tables++
+		}
+		if object.Kind == "TABLE" || object.Kind == "COLUMN" {
+			continue
+		}
+		names = append(names, objectKey(object.Kind, object.Name))
+	}
+	if tables < 20 {
+		t.Errorf("got %d tables, want every table of the baseline", tables)
+	}
+	want := []string{
+		"FUNCTION get_album_duration",
+		"PROCEDURE add_track",
+		"PROCEDURE get_artist_stats",
+		"TRIGGER after_play_insert",
+		"TRIGGER after_track_delete",
+		"TRIGGER after_track_insert",
+		"TRIGGER after_track_update",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("routines = %v, want %v", names, want)
+	}
+}
+
+func TestObjectsChecksumBodies(t *testing.T) {
+	migrations := []Migration{{Version: 1, Name: "routines", Up: `
+CREATE TABLE IF NOT EXISTS a (id INT);
+DELIMITER $$
+CREATE TRIGGER t AFTER INSERT ON a FOR EACH ROW UPDATE c SET n = n + 1$$
+CREATE FUNCTION f(x INT) RETURNS INT DETERMINISTIC
+BEGIN
+    -- double it
+    RETURN x * 2;
+END$$
+CREATE PROCEDURE gone() BEGIN SELECT 1; END$$
+DROP PROCEDURE IF EXISTS gone$$
+`}}
+	objects, err := Objects(migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 4 {
+		t.Fatalf("objects = %+v, want a, a.id, f and t", objects)
+	}
+
+	// information_schema holds the body alone, formatted however it was sent
+	deployed := map[string]string{
+		"TABLE a":     "",
+		"COLUMN a.id": "",
+		"FUNCTION f":  checksum("BEGIN RETURN x * 2; END"),
+		"TRIGGER t":   checksum("UPDATE c\n  SET n = n + 1"),
+	}
+	if drifts := compareObjects(objects, deployed); len(drifts) != 0 {
+		t.Fatalf("drifts = %v, want none", drifts)
+	}
+
+	deployed["FUNCTION f"] = checksum("BEGIN RETURN x * 3; END")
+	deployed["TRIGGER t"] = ""
+	drifts := compareObjects(objects, deployed)
+	if len(drifts) != 2 || drifts[0].Problem != "changed" || drifts[1].Problem != "unreadable" {
+		t.Fatalf("drifts = %v, want f changed and t unreadable", drifts)
+	}
+
+	// A missing table is reported once, not with each of its columns
+	delete(deployed, "TABLE a")
+	delete(deployed, "COLUMN a.id")
+	delete(deployed, "TRIGGER t")
+	drifts = compareObjects(objects, deployed)
+	if len(drifts) != 3 || drifts[1].String() != "table a is missing" || drifts[2].String() != "trigger t is missing" {
+		t.Fatalf("drifts = %v, want a and t missing", drifts)
+	}
+}
+
+func TestReplayColumns(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "baseline", Up: "CREATE TABLE IF NOT EXISTS a (id INT PRIMARY KEY, `name` VARCHAR(10) DEFAULT 'x,y', size DECIMAL(10, 2), INDEX idx_name (name), FOREIGN KEY (id) REFERENCES b(id));"},
+		{Version: 2, Name: "changes", Up: `
+ALTER TABLE a
+    ADD COLUMN owner INT NULL AFTER id,
+    ADD CONSTRAINT fk_owner FOREIGN KEY (owner) REFERENCES users(id),
+    ADD INDEX idx_owner (owner),
+    DROP size,
+    CHANGE COLUMN name title VARCHAR(20);
+ALTER TABLE a RENAME COLUMN title TO label, MODIFY owner BIGINT;`},
+	}
+	objects, err := Objects(migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var columns []string
+	for _, object := range objects {
+		if object.Kind == "COLUMN" {
+			columns = append(columns, object.Name)
+		}
+	}
+	if want := []string{"a.id", "a.label", "a.owner"}; !reflect.DeepEqual(columns, want) {
+		t.Fatalf("columns = %v, want %v", columns, want)
+	}
+
+	for _, up := range []string{
+		"ALTER TABLE a ADD COLUMN id INT;",
+		"ALTER TABLE a DROP COLUMN missing;",
+		"ALTER TABLE missing ADD COLUMN id INT;",
+		"CREATE TABLE a (id INT);",
+	} {
+		bad := append(migrations[:1:1], Migration{Version: 2, Name: "bad", Up: up})
+		if _, err := Objects(bad); err == nil {
+			t.Errorf("Objects accepted %q", up)
+		}
+	}
+}
+
+// TestLegacyDatabaseMigrates replays the migrations on the schema the server
+// created before migrations existed. Its tables are kept by CREATE TABLE IF NOT
+// EXISTS, so it must end up with the tables and columns of a new database only
+// through later migrations.
+func TestLegacyDatabaseMigrates(t *testing.T) {
+	legacySchema, err := os.ReadFile("testdata/legacy_schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := Objects([]Migration{{Version: 1, Name: "legacy", Up: string(legacySchema)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated, err := replay(legacy, migrations)
+	if err != nil {
+		t.Fatalf("migrating the legacy schema: %v", err)
+	}
+	fresh, err := Objects(migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if drifts := compareObjects(fresh, deployedKeys(migrated)); len(drifts) != 0 {
+		t.Errorf("a migrated legacy database lacks %v", drifts)
+	}
+	if drifts := compareObjects(migrated, deployedKeys(fresh)); len(drifts) != 0 {
+		t.Errorf("a new database lacks %v", drifts)
+	}
+	for _, object := range migrated {
+		if object.Kind == "COLUMN" && object.Name == "playlist_tracks.added_by" {
+			return
+		}
+	}
+	t.Error("playlist_tracks.added_by is missing after migrating the legacy schema")
+}
+
+func deployedKeys(objects []Object) map[string]string {
+	deployed := map[string]string{}
+	for _, object := range objects {
+		deployed[objectKey(object.Kind, object.Name)] = object.Checksum
+	}
+	return deployed
+}
@@ -0,0 +1,357 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// lockName is the MySQL named lock held while migrating, so instances starting
+// together apply each migration once
+const lockName = "spotify_clone.schema_migrations"
+
+// DefaultLockTimeout is how long Migrator waits for another instance to finish
+const DefaultLockTimeout = 60 * time.Second
+
+// ErrLocked is returned when the lock is not granted within the lock timeout
+var ErrLocked = errors.New("timed out waiting for another instance to finish migrating")
+
+// DirtyError is returned when a migration failed halfway. MySQL cannot roll
+// back DDL, so the schema has to be repaired by hand and the version set with
+// Force before migrating again.
+type DirtyError struct {
+	Version int
+}
+
+func (e *DirtyError) Error() string {
+	return fmt.Sprintf("migration %d failed halfway; repair the schema and run \"migrate force\" with the version it is at", e.Version)
+}
+
+// Status is a migration and whether it has been applied. Applied migrations
+// missing from this build have no Up or Down script.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+	Dirty     bool
+}
+
+type appliedRow struct {
+	name      string
+	dirty     bool
+	appliedAt time.Time
+}
+
+// Migrator applies and rolls back migrations on a database
+type Migrator struct {
+	db          *sql.DB
+	migrations  []Migration
+	LockTimeout time.Duration
+}
+
+// New returns a Migrator for the embedded migrations
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, LockTimeout: DefaultLockTimeout}, nil
+}
+
+// Latest returns the highest known version
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies pending migrations in order, up to and including target, or all
+// of them when target is 0. It returns the migrations it applied.
+func (m *Migrator) Up(ctx context.Context, target int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn, applied map[int]appliedRow) error {
+		if err := checkDirty(applied); err != nil {
+			return err
+		}
+		for _, migration := range planUp(m.migrations, applied, target) {
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the last steps applied migrations, newest first. It returns
+// the migrations it rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn, applied map[int]appliedRow) error {
+		if err := checkDirty(applied); err != nil {
+			return err
+		}
+		plan, err := planDown(m.migrations, applied, steps)
+		if err != nil {
+			return err
+		}
+		for _, migration := range plan {
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Force records the schema as being exactly at version, clearing a failed
+// migration, without running any SQL. Version 0 records nothing as applied.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	return m.withLock(ctx, func(conn *sql.Conn, _ map[int]appliedRow) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			if _, err := tx.ExecContext(ctx,
+				"INSERT INTO schema_migrations (version, name) VALUES (?, ?)", migration.Version, migration.Name); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+}
+
+// Status lists the known migrations and any applied ones this build lacks,
+// by version
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(_ *sql.Conn, applied map[int]appliedRow) error {
+		statuses = statusOf(m.migrations, applied)
+		return nil
+	})
+	return statuses, err
+}
+
+// Version returns the highest applied version, or 0 when nothing is applied.
+// A migration left dirty is a DirtyError.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	version := 0
+	err := m.withLock(ctx, func(_ *sql.Conn, applied map[int]appliedRow) error {
+		if err := checkDirty(applied); err != nil {
+			return err
+		}
+		for v := range applied {
+			if v > version {
+				version = v
+			}
+		}
+		return nil
+	})
+	return version, err
+}
+
+// withLock runs fn on one connection holding the migration lock, with the
+// applied migrations read after the lock was granted
+func (m *Migrator) withLock(ctx context.Context, fn func(*sql.Conn, map[int]appliedRow) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Named locks belong to the connection, so they go away with it should the
+	// process die while migrating
+	var granted sql.NullInt64
+	timeout := int(m.LockTimeout / time.Second)
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, timeout).Scan(&granted); err != nil {
+		return fmt.Errorf("error acquiring migration lock: %v", err)
+	}
+	if !granted.Valid || granted.Int64 != 1 {
+		return ErrLocked
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			dirty BOOLEAN NOT NULL DEFAULT FALSE,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations: %v", err)
+	}
+
+	applied, err := readApplied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, applied)
+}
+
+func readApplied(ctx context.Context, conn *sql.Conn) (map[int]appliedRow, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, dirty, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedRow{}
+	for rows.Next() {
+		var version int
+		var row appliedRow
+		if err := rows.Scan(&version, &row.name, &row.dirty, &row.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = row
+	}
+	return applied, rows.Err()
+}
+
+// apply runs a migration's up script. The row is written dirty first and
+// cleared at the end, so a failure part way leaves a trace.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	if _, err := conn.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name, dirty) VALUES (?, ?, TRUE)", migration.Version, migration.Name); err != nil {
+		return fmt.Errorf("error recording migration %d: %v", migration.Version, err)
+	}
+	if err := execScript(ctx, conn, migration.Up); err != nil {
+		return fmt.Errorf("migration %d_%s: %v", migration.Version, migration.Name, err)
+	}
+	_, err := conn.ExecContext(ctx,
+		"UPDATE schema_migrations SET dirty = FALSE, applied_at = CURRENT_TIMESTAMP WHERE version = ?", migration.Version)
+	return err
+}
+
+// revert runs a migration's down script and forgets the migration
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	if _, err := conn.ExecContext(ctx,
+		"UPDATE schema_migrations SET dirty = TRUE WHERE version = ?", migration.Version); err != nil {
+		return err
+	}
+	if err := execScript(ctx, conn, migration.Down); err != nil {
+		return fmt.Errorf("rolling back migration %d_%s: %v", migration.Version, migration.Name, err)
+	}
+	_, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+	return err
+}
+
+func execScript(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, statement := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// checkDirty returns a DirtyError for the oldest migration left dirty
+func checkDirty(applied map[int]appliedRow) error {
+	dirty := 0
+	for version, row := range applied {
+		if row.dirty && (dirty == 0 || version < dirty) {
+			dirty = version
+		}
+	}
+	if dirty != 0 {
+		return &DirtyError{Version: dirty}
+	}
+	return nil
+}
+
+// planUp returns the migrations not yet applied, up to target or all of them
+// when target is 0
+func planUp(migrations []Migration, applied map[int]appliedRow, target int) []Migration {
+	var plan []Migration
+	for _, migration := range migrations {
+		if target != 0 && migration.Version > target {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			plan = append(plan, migration)
+		}
+	}
+	return plan
+}
+
+// planDown returns the last steps applied migrations, newest first. It fails
+// when one of them is missing from this build, since its down script is too.
+func planDown(migrations []Migration, applied map[int]appliedRow, steps int) ([]Migration, error) {
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	if steps < len(versions) {
+		versions = versions[:steps]
+	}
+
+	known := map[int]Migration{}
+	for _, migration := range migrations {
+		known[migration.Version] = migration
+	}
+	plan := make([]Migration, 0, len(versions))
+	for _, version := range versions {
+		migration, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d (%s) is applied but not part of this build", version, applied[version].name)
+		}
+		plan = append(plan, migration)
+	}
+	return plan, nil
+}
+
+func statusOf(migrations []Migration, applied map[int]appliedRow) []Status {
+	statuses := make([]Status, 0, len(migrations))
+	seen := map[int]bool{}
+	for _, migration := range migrations {
+		status := Status{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.appliedAt
+			status.Applied, status.AppliedAt, status.Dirty = true, &appliedAt, row.dirty
+		}
+		statuses = append(statuses, status)
+		seen[migration.Version] = true
+	}
+	for version, row := range applied {
+		if !seen[version] {
+			appliedAt := row.appliedAt
+			statuses = append(statuses, Status{
+				Migration: Migration{Version: version, Name: row.name},
+				Applied:   true,
+				AppliedAt: &appliedAt,
+				Dirty:     row.dirty,
+			})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses
+}
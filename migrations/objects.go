@@ -0,0 +1,365 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Object is a table, column, trigger, function or procedure the migrations
+// leave behind. Tables and columns are only checked for existence and have no
+// checksum; a column is named table.column.
+type Object struct {
+	Kind     string // TABLE, COLUMN, TRIGGER, FUNCTION or PROCEDURE
+	Name     string
+	Checksum string // of the normalized body
+}
+
+// Drift is an object that is missing from the database or whose deployed
+// definition is not the one the migrations create
+type Drift struct {
+	Object
+	Problem string // "missing", "changed" or "unreadable"
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s %s is %s", strings.ToLower(d.Kind), d.Name, d.Problem)
+}
+
+var (
+	createTable   = regexp.MustCompile("(?is)^CREATE\\s+TABLE\\s+(IF\\s+NOT\\s+EXISTS\\s+)?`?(\\w+)`?")
+	dropTable     = regexp.MustCompile("(?is)^DROP\\s+TABLE\\s+(?:IF\\s+EXISTS\\s+)?`?(\\w+)`?")
+	alterTable    = regexp.MustCompile("(?is)^ALTER\\s+TABLE\\s+`?(\\w+)`?\\s+(.*)$")
+	createRoutine = regexp.MustCompile("(?is)^CREATE\\s+(?:DEFINER\\s*=\\s*\\S+\\s+)?(TRIGGER|FUNCTION|PROCEDURE)\\s+`?(\\w+)`?")
+	dropRoutine   = regexp.MustCompile("(?is)^DROP\\s+(TRIGGER|FUNCTION|PROCEDURE)\\s+(?:IF\\s+EXISTS\\s+)?`?(\\w+)`?")
+	// A trigger body follows FOR EACH ROW, a routine body is its BEGIN ... END
+	triggerBody = regexp.MustCompile(`(?is)\bFOR\s+EACH\s+ROW\s+(?:(?:FOLLOWS|PRECEDES)\s+\S+\s+)?`)
+	routineBody = regexp.MustCompile(`(?i)\bBEGIN\b`)
+)
+
+// notColumns are the words that start a table definition item or an ALTER
+// TABLE clause about something other than a column
+var notColumns = map[string]bool{
+	"PRIMARY": true, "KEY": true, "INDEX": true, "UNIQUE": true, "FOREIGN": true,
+	"CONSTRAINT": true, "CHECK": true, "FULLTEXT": true, "SPATIAL": true,
+}
+
+// Objects replays the CREATE, ALTER and DROP statements of migrations in order
+// on an empty database and returns the objects that remain, routines with the
+// checksums of their bodies
+func Objects(migrations []Migration) ([]Object, error) {
+	return replay(nil, migrations)
+}
+
+// replay is Objects on a database that already holds existing. As in MySQL,
+// CREATE TABLE IF NOT EXISTS leaves an existing table and its columns alone,
+// and adding a column that exists or dropping one that does not is an error.
+func replay(existing []Object, migrations []Migration) ([]Object, error) {
+	objects := map[string]Object{}
+	for _, object := range existing {
+		objects[objectKey(object.Kind, object.Name)] = object
+	}
+
+	for _, migration := range migrations {
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("migration %d_%s: %s", migration.Version, migration.Name, fmt.Sprintf(format, args...))
+		}
+		for _, statement := range splitStatements(migration.Up) {
+			if match := createTable.FindStringSubmatch(statement); match != nil {
+				table := match[2]
+				if _, ok := objects[objectKey("TABLE", table)]; ok {
+					if match[1] != "" {
+						continue
+					}
+					return nil, fail("table %s already exists", table)
+				}
+				objects[objectKey("TABLE", table)] = Object{Kind: "TABLE", Name: table}
+				for _, column := range tableColumns(statement) {
+					addColumn(objects, table, column)
+				}
+				continue
+			}
+			if match := dropTable.FindStringSubmatch(statement); match != nil {
+				delete(objects, objectKey("TABLE", match[1]))
+				for key, object := range objects {
+					if object.Kind == "COLUMN" && strings.EqualFold(columnTable(object.Name), match[1]) {
+						delete(objects, key)
+					}
+				}
+				continue
+			}
+			if match := alterTable.FindStringSubmatch(statement); match != nil {
+				if _, ok := objects[objectKey("TABLE", match[1])]; !ok {
+					return nil, fail("table %s does not exist", match[1])
+				}
+				if err := alterColumns(objects, match[1], match[2]); err != nil {
+					return nil, fail("%v", err)
+				}
+				continue
+			}
+			if match := dropRoutine.FindStringSubmatch(statement); match != nil {
+				delete(objects, objectKey(match[1], match[2]))
+				continue
+			}
+			match := createRoutine.FindStringSubmatch(statement)
+			if match == nil {
+				continue
+			}
+
+			kind := strings.ToUpper(match[1])
+			pattern := routineBody
+			if kind == "TRIGGER" {
+				pattern = triggerBody
+			}
+			loc := pattern.FindStringIndex(statement)
+			if loc == nil {
+				return nil, fail("cannot find the body of %s %s", strings.ToLower(kind), match[2])
+			}
+			start := loc[1]
+			if kind != "TRIGGER" {
+				start = loc[0]
+			}
+			objects[objectKey(kind, match[2])] = Object{Kind: kind, Name: match[2], Checksum: checksum(statement[start:])}
+		}
+	}
+
+	list := make([]Object, 0, len(objects))
+	for _, object := range objects {
+		list = append(list, object)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return objectKey(list[i].Kind, list[i].Name) < objectKey(list[j].Kind, list[j].Name)
+	})
+	return list, nil
+}
+
+// tableColumns returns the column names of a CREATE TABLE statement
+func tableColumns(statement string) []string {
+	start, end := strings.Index(statement, "("), strings.LastIndex(statement, ")")
+	if start < 0 || end < start {
+		return nil
+	}
+	var columns []string
+	for _, item := range splitList(statement[start+1 : end]) {
+		fields := strings.Fields(item)
+		if len(fields) > 0 && !notColumns[strings.ToUpper(fields[0])] {
+			columns = append(columns, strings.Trim(fields[0], "`"))
+		}
+	}
+	return columns
+}
+
+// alterColumns applies the ADD, DROP, CHANGE and RENAME COLUMN clauses of an
+// ALTER TABLE statement. Other clauses leave the columns as they are.
+func alterColumns(objects map[string]Object, table, clauses string) error {
+	for _, clause := range splitList(clauses) {
+		fields := strings.Fields(clause)
+		if len(fields) < 2 {
+			continue
+		}
+		action, target := strings.ToUpper(fields[0]), fields[1:]
+		if strings.EqualFold(target[0], "COLUMN") {
+			target = target[1:]
+		} else if notColumns[strings.ToUpper(target[0])] {
+			continue
+		}
+		if len(target) == 0 {
+			continue
+		}
+
+		name := strings.Trim(target[0], "`")
+		switch action {
+		case "ADD":
+			if !addColumn(objects, table, name) {
+				return fmt.Errorf("column %s.%s already exists", table, name)
+			}
+		case "DROP":
+			if !dropColumn(objects, table, name) {
+				return fmt.Errorf("column %s.%s does not exist", table, name)
+			}
+		case "CHANGE", "RENAME":
+			newName := ""
+			if action == "CHANGE" && len(target) > 1 {
+				newName = target[1]
+			} else if action == "RENAME" && len(target) > 2 && strings.EqualFold(target[1], "TO") {
+				newName = target[2]
+			}
+			if newName == "" {
+				continue
+			}
+			if !dropColumn(objects, table, name) {
+				return fmt.Errorf("column %s.%s does not exist", table, name)
+			}
+			addColumn(objects, table, strings.Trim(newName, "`"))
+		}
+	}
+	return nil
+}
+
+// addColumn records a column and reports whether it was new
+func addColumn(objects map[string]Object, table, column string) bool {
+	key := objectKey("COLUMN", table+"."+column)
+	if _, ok := objects[key]; ok {
+		return false
+	}
+	objects[key] = Object{Kind: "COLUMN", Name: table + "." + column}
+	return true
+}
+
+// dropColumn removes a column and reports whether it existed
+func dropColumn(objects map[string]Object, table, column string) bool {
+	key := objectKey("COLUMN", table+"."+column)
+	if _, ok := objects[key]; !ok {
+		return false
+	}
+	delete(objects, key)
+	return true
+}
+
+// columnTable returns the table of a column named table.column
+func columnTable(name string) string {
+	table, _, _ := strings.Cut(name, ".")
+	return table
+}
+
+// splitList splits s at the commas outside of parentheses and quotes
+func splitList(s string) []string {
+	var items []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if item := strings.TrimSpace(s[start:]); item != "" {
+		items = append(items, item)
+	}
+	return items
+}
+
+// Verify compares the tables, columns and routines in the current database
+// with the ones the applied migrations create. It returns nothing when they all match.
+func (m *Migrator) Verify(ctx context.Context) ([]Drift, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(_ *sql.Conn, rows map[int]appliedRow) error {
+		for _, migration := range m.migrations {
+			if _, ok := rows[migration.Version]; ok {
+				applied = append(applied, migration)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	expected, err := Objects(applied)
+	if err != nil {
+		return nil, err
+	}
+	deployed, err := deployedObjects(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	return compareObjects(expected, deployed), nil
+}
+
+// deployedObjects returns the objects in the current database by objectKey,
+// with the checksums of routine bodies. Tables, columns and bodies hidden from
+// this user map to "".
+func deployedObjects(ctx context.Context, db *sql.DB) (map[string]string, error) {
+	deployed := map[string]string{}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT 'TABLE', TABLE_NAME, NULL
+		FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE'
+		UNION ALL
+		SELECT 'COLUMN', CONCAT(c.TABLE_NAME, '.', c.COLUMN_NAME), NULL
+		FROM information_schema.COLUMNS c
+		JOIN information_schema.TABLES t
+		  ON t.TABLE_SCHEMA = c.TABLE_SCHEMA AND t.TABLE_NAME = c.TABLE_NAME
+		WHERE c.TABLE_SCHEMA = DATABASE() AND t.TABLE_TYPE = 'BASE TABLE'
+		UNION ALL
+		SELECT 'TRIGGER', TRIGGER_NAME, ACTION_STATEMENT
+		FROM information_schema.TRIGGERS
+		WHERE TRIGGER_SCHEMA = DATABASE()
+		UNION ALL
+		SELECT ROUTINE_TYPE, ROUTINE_NAME, ROUTINE_DEFINITION
+		FROM information_schema.ROUTINES
+		WHERE ROUTINE_SCHEMA = DATABASE()`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading deployed objects: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind, name string
+		var body sql.NullString
+		if err := rows.Scan(&kind, &name, &body); err != nil {
+			return nil, err
+		}
+		deployed[objectKey(kind, name)] = ""
+		if body.Valid {
+			deployed[objectKey(kind, name)] = checksum(body.String)
+		}
+	}
+	return deployed, rows.Err()
+}
+
+func compareObjects(expected []Object, deployed map[string]string) []Drift {
+	var drifts []Drift
+	for _, object := range expected {
+		actual, ok := deployed[objectKey(object.Kind, object.Name)]
+		if !ok && object.Kind == "COLUMN" {
+			// The columns of a missing table are reported as the table
+			if _, ok := deployed[objectKey("TABLE", columnTable(object.Name))]; !ok {
+				continue
+			}
+		}
+		switch {
+		case !ok:
+			drifts = append(drifts, Drift{object, "missing"})
+		case object.Checksum == "":
+			// Tables and columns only need to exist
+		case actual == "":
+			drifts = append(drifts, Drift{object, "unreadable"})
+		case actual != object.Checksum:
+			drifts = append(drifts, Drift{object, "changed"})
+		}
+	}
+	return drifts
+}
+
+func objectKey(kind, name string) string {
+	return strings.ToUpper(kind) + " " + strings.ToLower(name)
+}
+
+// checksum hashes a body with comments dropped and whitespace collapsed, so
+// formatting does not count as a change
+func checksum(body string) string {
+	normalized := strings.Join(strings.Fields(strings.Join(splitStatements(body), ";")), " ")
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
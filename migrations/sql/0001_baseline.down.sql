@@ -0,0 +1,14 @@
+-- Drops every table of the baseline, referencing tables first.
+
+DROP TABLE IF EXISTS track_stats;
+DROP TABLE IF EXISTS album_stats;
+DROP TABLE IF EXISTS plays;
+DROP TABLE IF EXISTS playlist_tracks;
+DROP TABLE IF EXISTS playlists;
+DROP TABLE IF EXISTS user_favorite_artists;
+DROP TABLE IF EXISTS user_favorite_genres;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS genres;
+DROP TABLE IF EXISTS tracks;
+DROP TABLE IF EXISTS albums;
+DROP TABLE IF EXISTS artists;
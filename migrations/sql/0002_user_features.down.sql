@@ -0,0 +1,24 @@
+-- Drops what 0002_user_features.up.sql creates, referencing tables first.
+
+DROP TABLE IF EXISTS radio_station_tracks;
+DROP TABLE IF EXISTS radio_stations;
+DROP TABLE IF EXISTS track_skips;
+DROP TABLE IF EXISTS listening_session_skip_votes;
+DROP TABLE IF EXISTS listening_session_members;
+DROP TABLE IF EXISTS listening_sessions;
+DROP TABLE IF EXISTS playback_queue;
+DROP TABLE IF EXISTS playback_states;
+DROP TABLE IF EXISTS user_activities;
+DROP TABLE IF EXISTS playlist_invites;
+DROP TABLE IF EXISTS playlist_followers;
+DROP TABLE IF EXISTS playlist_covers;
+DROP TABLE IF EXISTS smart_playlists;
+DROP TABLE IF EXISTS playlist_collaborators;
+DROP TABLE IF EXISTS user_privacy_settings;
+DROP TABLE IF EXISTS user_follows;
+DROP TABLE IF EXISTS user_saved_albums;
+DROP TABLE IF EXISTS user_saved_tracks;
+
+ALTER TABLE playlist_tracks
+    DROP FOREIGN KEY fk_playlist_tracks_added_by,
+    DROP COLUMN added_by;
@@ -0,0 +1,211 @@
+-- Playlist collaboration, public and smart playlists, covers, the library,
+-- follows and activity, privacy settings, playback, listening sessions and
+-- radio. Databases from before migrations get the new column of
+-- playlist_tracks here as well as the new tables.
+
+ALTER TABLE playlist_tracks
+    ADD COLUMN added_by INT NULL AFTER position,
+    ADD CONSTRAINT fk_playlist_tracks_added_by FOREIGN KEY (added_by) REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS user_saved_tracks (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    track_id INT NOT NULL,
+    saved_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_user_track (user_id, track_id),
+    INDEX idx_user_saved (user_id, saved_at)
+);
+
+CREATE TABLE IF NOT EXISTS user_saved_albums (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    album_id INT NOT NULL,
+    saved_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (album_id) REFERENCES albums(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_user_album (user_id, album_id),
+    INDEX idx_user_saved (user_id, saved_at)
+);
+
+CREATE TABLE IF NOT EXISTS user_follows (
+    follower_id INT NOT NULL,
+    followee_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id),
+    FOREIGN KEY (follower_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (followee_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_followee (followee_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_privacy_settings (
+    user_id INT PRIMARY KEY,
+    show_playlists BOOLEAN DEFAULT TRUE,
+    show_follows BOOLEAN DEFAULT TRUE,
+    show_top_artists BOOLEAN DEFAULT TRUE,
+    hide_listening_activity BOOLEAN DEFAULT FALSE,
+    exclude_from_recommendations BOOLEAN DEFAULT FALSE,
+    private_session_until TIMESTAMP NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS playlist_collaborators (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    playlist_id INT NOT NULL,
+    user_id INT NOT NULL,
+    added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (playlist_id) REFERENCES playlists(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_playlist_collaborator (playlist_id, user_id),
+    INDEX idx_user (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS smart_playlists (
+    playlist_id INT PRIMARY KEY,
+    definition JSON NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (playlist_id) REFERENCES playlists(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS playlist_covers (
+    playlist_id INT PRIMARY KEY,
+    generated_file VARCHAR(255),
+    source_hash CHAR(40),
+    uploaded_file VARCHAR(255),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (playlist_id) REFERENCES playlists(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS playlist_followers (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    playlist_id INT NOT NULL,
+    user_id INT NOT NULL,
+    followed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (playlist_id) REFERENCES playlists(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE KEY unique_playlist_follower (playlist_id, user_id),
+    INDEX idx_user (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS playlist_invites (
+    token VARCHAR(64) PRIMARY KEY,
+    playlist_id INT NOT NULL,
+    created_by INT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (playlist_id) REFERENCES playlists(id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_playlist (playlist_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_activities (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    playlist_id INT NULL,
+    track_id INT NULL,
+    artist_id INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (playlist_id) REFERENCES playlists(id) ON DELETE CASCADE,
+    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE,
+    FOREIGN KEY (artist_id) REFERENCES artists(id) ON DELETE CASCADE,
+    INDEX idx_user_recent (user_id, id)
+);
+
+CREATE TABLE IF NOT EXISTS playback_states (
+    user_id INT PRIMARY KEY,
+    context_type VARCHAR(16) NULL,
+    context_id INT NULL,
+    context_index INT DEFAULT 0,
+    track_id INT NULL,
+    from_queue BOOLEAN DEFAULT FALSE,
+    position_ms INT DEFAULT 0,
+    is_playing BOOLEAN DEFAULT FALSE,
+    shuffle BOOLEAN DEFAULT FALSE,
+    shuffle_seed BIGINT DEFAULT 0,
+    repeat_mode VARCHAR(8) DEFAULT 'off',
+    updated_at TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS playback_queue (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    track_id INT NOT NULL,
+    position INT NOT NULL,
+    added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE,
+    INDEX idx_user_position (user_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS listening_sessions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    host_id INT NOT NULL,
+    code CHAR(6) NOT NULL UNIQUE,
+    skip_round INT DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ended_at TIMESTAMP NULL,
+    FOREIGN KEY (host_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_host_active (host_id, ended_at)
+);
+
+CREATE TABLE IF NOT EXISTS listening_session_members (
+    session_id INT NOT NULL,
+    user_id INT NOT NULL,
+    joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    left_at TIMESTAMP NULL,
+    PRIMARY KEY (session_id, user_id),
+    FOREIGN KEY (session_id) REFERENCES listening_sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user_active (user_id, left_at)
+);
+
+CREATE TABLE IF NOT EXISTS listening_session_skip_votes (
+    session_id INT NOT NULL,
+    skip_round INT NOT NULL,
+    user_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, skip_round, user_id),
+    FOREIGN KEY (session_id) REFERENCES listening_sessions(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS track_skips (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    track_id INT NOT NULL,
+    skipped_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE,
+    INDEX idx_user_recent (user_id, skipped_at)
+);
+
+CREATE TABLE IF NOT EXISTS radio_stations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    seed_type VARCHAR(16) NOT NULL,
+    seed_id INT NULL,
+    seed_genre VARCHAR(100) NULL,
+    name VARCHAR(255) NOT NULL,
+    tracks_served INT DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_user (user_id)
+);
+
+CREATE TABLE IF NOT EXISTS radio_station_tracks (
+    station_id INT NOT NULL,
+    track_id INT NOT NULL,
+    batch INT NOT NULL,
+    served_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (station_id, track_id),
+    FOREIGN KEY (station_id) REFERENCES radio_stations(id) ON DELETE CASCADE,
+    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE,
+    INDEX idx_station_batch (station_id, batch)
+);
@@ -0,0 +1,8 @@
+-- Drops the triggers, functions and procedures of 0003_routines.up.sql.
+
+DROP PROCEDURE IF EXISTS get_artist_stats;
+DROP PROCEDURE IF EXISTS add_track;
+DROP FUNCTION IF EXISTS get_album_duration;
+DROP TRIGGER IF EXISTS after_play_insert;
+DROP TRIGGER IF EXISTS after_track_delete;
+DROP TRIGGER IF EXISTS after_track_insert;
@@ -1,24 +1,6 @@
--- Spotify Clone - Triggers, Procedures, and Functions
--- This file contains advanced MySQL database objects for the music catalog
-
--- UTILITY TABLES
-
--- Create a table to track album statistics (needed for triggers)
-CREATE TABLE IF NOT EXISTS album_stats (
-    album_id INT PRIMARY KEY,
-    track_count INT DEFAULT 0,
-    total_duration INT DEFAULT 0,
-    last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-    FOREIGN KEY (album_id) REFERENCES albums(id) ON DELETE CASCADE
-);
-
--- Create a table to track play counts (for trigger demo)
-CREATE TABLE IF NOT EXISTS track_stats (
-    track_id INT PRIMARY KEY,
-    play_count INT DEFAULT 0,
-    last_played TIMESTAMP NULL,
-    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE CASCADE
-);
+-- Triggers, functions and procedures of the music catalog. Their bodies are
+-- checksummed from this file and compared with the deployed definitions at
+-- startup; change them with a new migration, never by editing this one.
 
 -- TRIGGER 1: Update Album Stats When Track is Added
 DELIMITER $$
@@ -62,6 +44,19 @@ END$$
 
 DELIMITER ;
 
+-- TRIGGER 3: Count Plays in track_stats
+DELIMITER $$
+
+DROP TRIGGER IF EXISTS after_play_insert$$
+CREATE TRIGGER after_play_insert
+AFTER INSERT ON plays
+FOR EACH ROW
+UPDATE track_stats
+SET play_count = play_count + 1,
+    last_played = NEW.played_at
+WHERE track_id = NEW.track_id$$
+
+DELIMITER ;
 
 -- FUNCTION 1: Calculate Album Total Duration
 
@@ -192,40 +187,3 @@ INSERT INTO track_stats (track_id, play_count)
 SELECT id, 0
 FROM tracks
 ON DUPLICATE KEY UPDATE play_count = play_count;
-
--- ======================
--- USAGE EXAMPLES
--- ======================
-
--- Example 1: Add a new track using the stored procedure
--- CALL add_track(
---     'New Song Title',
---     1,
---     1,
---     240,
---     'Pop',
---     '2025-01-01',
---     'https://audio.example.com/new-song.mp3',
---     'https://i.scdn.co/image/cover.jpg',
---     @new_track_id,
---     @status
--- );
--- SELECT @new_track_id AS track_id, @status AS status;
-
--- Example 2: Get statistics for an artist
--- CALL get_artist_stats(1);
-
--- Example 3: Use the function to get album duration
--- SELECT id, title, get_album_duration(id) AS total_duration_seconds
--- FROM albums;
-
--- Example 4: View album statistics
--- SELECT
---     a.id,
---     a.title,
---     ast.track_count,
---     ast.total_duration,
---     ROUND(ast.total_duration / 60.0, 2) AS duration_minutes
--- FROM albums a
--- LEFT JOIN album_stats ast ON a.id = ast.album_id;
-
@@ -0,0 +1,4 @@
+-- Drops what 0004_scanned_files.up.sql creates.
+
+DROP TRIGGER IF EXISTS after_track_update;
+DROP TABLE IF EXISTS scanned_files;
@@ -0,0 +1,40 @@
+-- Audio files imported by the "scan" command, so rescans can skip unchanged
+-- files and notice deleted ones, and a trigger keeping album_stats right when
+-- a rescanned track changes album or duration.
+
+CREATE TABLE IF NOT EXISTS scanned_files (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    path VARCHAR(700) NOT NULL,
+    size BIGINT NOT NULL,
+    mod_time BIGINT NOT NULL,
+    hash CHAR(64) NOT NULL,
+    track_id INT NULL,
+    scanned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY unique_path (path),
+    FOREIGN KEY (track_id) REFERENCES tracks(id) ON DELETE SET NULL,
+    INDEX idx_hash (hash)
+);
+
+-- TRIGGER 4: Move Album Stats When a Track Changes
+DELIMITER $$
+
+DROP TRIGGER IF EXISTS after_track_update$$
+CREATE TRIGGER after_track_update
+AFTER UPDATE ON tracks
+FOR EACH ROW
+BEGIN
+    IF OLD.album_id != NEW.album_id OR OLD.duration != NEW.duration THEN
+        UPDATE album_stats
+        SET track_count = track_count - 1,
+            total_duration = total_duration - OLD.duration
+        WHERE album_id = OLD.album_id;
+
+        INSERT INTO album_stats (album_id, track_count, total_duration)
+        VALUES (NEW.album_id, 1, NEW.duration)
+        ON DUPLICATE KEY UPDATE
+            track_count = track_count + 1,
+            total_duration = total_duration + NEW.duration;
+    END IF;
+END$$
+
+DELIMITER ;
@@ -0,0 +1,3 @@
+-- Drops what 0005_account_deletions.up.sql creates.
+
+DROP TABLE IF EXISTS account_deletions;
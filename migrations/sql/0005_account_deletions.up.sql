@@ -0,0 +1,10 @@
+-- Accounts whose owners asked for deletion. The server purges an account once
+-- delete_after has passed; until then the owner can cancel.
+
+CREATE TABLE IF NOT EXISTS account_deletions (
+    user_id INT PRIMARY KEY,
+    requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    delete_after TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_delete_after (delete_after)
+);
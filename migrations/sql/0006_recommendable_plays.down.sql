@@ -0,0 +1,17 @@
+-- Restores the after_play_insert trigger of 0003_routines and drops the
+-- column 0006_recommendable_plays.up.sql adds.
+
+DELIMITER $$
+
+DROP TRIGGER IF EXISTS after_play_insert$$
+CREATE TRIGGER after_play_insert
+AFTER INSERT ON plays
+FOR EACH ROW
+UPDATE track_stats
+SET play_count = play_count + 1,
+    last_played = NEW.played_at
+WHERE track_id = NEW.track_id$$
+
+DELIMITER ;
+
+ALTER TABLE track_stats DROP COLUMN recommendable_play_count;
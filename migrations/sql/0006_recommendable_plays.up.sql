@@ -0,0 +1,30 @@
+-- A second play count in track_stats that leaves out the plays of users who
+-- excluded their listening from recommendations. Popularity used to recommend
+-- tracks and artists reads it; play_count stays the public catalog count.
+
+ALTER TABLE track_stats ADD COLUMN recommendable_play_count INT DEFAULT 0 AFTER play_count;
+
+UPDATE track_stats ts
+SET recommendable_play_count = (
+    SELECT COUNT(*) FROM plays p
+    WHERE p.track_id = ts.track_id
+      AND NOT EXISTS (
+          SELECT 1 FROM user_privacy_settings ps
+          WHERE ps.user_id = p.user_id AND ps.exclude_from_recommendations = TRUE));
+
+-- after_play_insert from 0003_routines, counting recommendable plays as well
+DELIMITER $$
+
+DROP TRIGGER IF EXISTS after_play_insert$$
+CREATE TRIGGER after_play_insert
+AFTER INSERT ON plays
+FOR EACH ROW
+UPDATE track_stats
+SET play_count = play_count + 1,
+    recommendable_play_count = recommendable_play_count + NOT EXISTS (
+        SELECT 1 FROM user_privacy_settings ps
+        WHERE ps.user_id = NEW.user_id AND ps.exclude_from_recommendations = TRUE),
+    last_played = NEW.played_at
+WHERE track_id = NEW.track_id$$
+
+DELIMITER ;
@@ -39,6 +39,18 @@ type Album struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// SavedTrack is a track in a user's library
+type SavedTrack struct {
+	Track
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// SavedAlbum is an album in a user's library
+type SavedAlbum struct {
+	Album
+	SavedAt time.Time `json:"saved_at"`
+}
+
 type Genre struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
@@ -62,11 +74,153 @@ type User struct {
 	UpdatedAt         time.Time `json:"updated_at"`
 }
 
+// UserSummary is the public, minimal view of a user used in lists
+type UserSummary struct {
+	ID                int        `json:"id"`
+	Username          string     `json:"username"`
+	DisplayName       string     `json:"display_name"`
+	ProfilePictureURL string     `json:"profile_picture_url"`
+	FollowedAt        *time.Time `json:"followed_at,omitempty"`
+}
+
+// Activity is an entry in the social feed
+type Activity struct {
+	ID           int64       `json:"id"`
+	Type         string      `json:"type"` // created_playlist, added_track, followed_artist
+	User         UserSummary `json:"user"`
+	PlaylistID   *int        `json:"playlist_id,omitempty"`
+	PlaylistName string      `json:"playlist_name,omitempty"`
+	TrackID      *int        `json:"track_id,omitempty"`
+	TrackTitle   string      `json:"track_title,omitempty"`
+	ArtistID     *int        `json:"artist_id,omitempty"`
+	ArtistName   string      `json:"artist_name,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+// PrivacySettings controls what others can see of a user and how their plays are used
+type PrivacySettings struct {
+	ShowPlaylists              bool       `json:"show_playlists"`
+	ShowFollows                bool       `json:"show_follows"`
+	ShowTopArtists             bool       `json:"show_top_artists"`
+	HideListeningActivity      bool       `json:"hide_listening_activity"`
+	ExcludeFromRecommendations bool       `json:"exclude_from_recommendations"`
+	PrivateSession             bool       `json:"private_session"`
+	PrivateSessionUntil        *time.Time `json:"private_session_until,omitempty"`
+}
+
+// UpdatePrivacySettingsRequest changes only the settings that are present
+type UpdatePrivacySettingsRequest struct {
+	ShowPlaylists              *bool `json:"show_playlists"`
+	ShowFollows                *bool `json:"show_follows"`
+	ShowTopArtists             *bool `json:"show_top_artists"`
+	HideListeningActivity      *bool `json:"hide_listening_activity"`
+	ExcludeFromRecommendations *bool `json:"exclude_from_recommendations"`
+}
+
+// StartPrivateSessionRequest starts a private session; the duration defaults to 6 hours
+type StartPrivateSessionRequest struct {
+	DurationMinutes int `json:"duration_minutes" binding:"omitempty,min=1,max=1440"`
+}
+
+// DeleteAccountRequest confirms an account deletion with the user's password
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// AccountDeletion is a pending account deletion; the account is purged after DeleteAfter
+type AccountDeletion struct {
+	RequestedAt time.Time `json:"requested_at"`
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+// ExportedPlaylist is a playlist in a personal data export with its tracks.
+// Role is owner, collaborator or follower.
+type ExportedPlaylist struct {
+	Playlist
+	Role   string  `json:"role"`
+	Tracks []Track `json:"tracks"`
+}
+
+// ExportedSavedItem is a track or album in the library of a personal data export
+type ExportedSavedItem struct {
+	ID      int       `json:"id"`
+	Title   string    `json:"title"`
+	Artist  string    `json:"artist"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// ExportedPlay is an entry of the listening history in a personal data export
+type ExportedPlay struct {
+	PlayedAt       time.Time `json:"played_at"`
+	TrackID        int       `json:"track_id"`
+	Title          string    `json:"title"`
+	Artist         string    `json:"artist"`
+	Album          string    `json:"album"`
+	DurationPlayed int       `json:"duration_played"`
+	Completed      bool      `json:"completed"`
+}
+
+// ExportedSkip is a track the user skipped, in a personal data export
+type ExportedSkip struct {
+	SkippedAt time.Time `json:"skipped_at"`
+	TrackID   int       `json:"track_id"`
+	Title     string    `json:"title"`
+	Artist    string    `json:"artist"`
+}
+
+// ExportedRadioStation is a radio station in a personal data export with the
+// tracks it served, oldest first
+type ExportedRadioStation struct {
+	RadioStation
+	Tracks []ExportedRadioTrack `json:"tracks"`
+}
+
+// ExportedRadioTrack is a track a radio station served
+type ExportedRadioTrack struct {
+	TrackID  int       `json:"track_id"`
+	Batch    int       `json:"batch"`
+	ServedAt time.Time `json:"served_at"`
+}
+
+// ExportedSessionMembership is a listening session the user hosted or joined,
+// in a personal data export
+type ExportedSessionMembership struct {
+	SessionID int         `json:"session_id"`
+	Code      string      `json:"code"`
+	Host      UserSummary `json:"host"`
+	JoinedAt  time.Time   `json:"joined_at"`
+	LeftAt    *time.Time  `json:"left_at,omitempty"`
+	EndedAt   *time.Time  `json:"ended_at,omitempty"`
+}
+
+// TopArtist is an artist ranked by how often a user played them
+type TopArtist struct {
+	Artist
+	PlayCount int `json:"play_count"`
+}
+
+// PublicProfile is what other users see at GET /users/:username.
+// Sections hidden by the owner's privacy settings are omitted.
+type PublicProfile struct {
+	ID                int         `json:"id"`
+	Username          string      `json:"username"`
+	DisplayName       string      `json:"display_name"`
+	ProfilePictureURL string      `json:"profile_picture_url"`
+	FollowerCount     *int        `json:"follower_count,omitempty"`
+	FollowingCount    *int        `json:"following_count,omitempty"`
+	IsFollowing       bool        `json:"is_following"`
+	Playlists         []Playlist  `json:"playlists,omitempty"`
+	TopArtists        []TopArtist `json:"top_artists,omitempty"`
+	HiddenSections    []string    `json:"hidden_sections"` // playlists, follows, top_artists
+	CreatedAt         time.Time   `json:"created_at"`
+}
+
 type UserPreferences struct {
 	Theme           string   `json:"theme"` // light, dark
 	Language        string   `json:"language"`
 	ExplicitContent bool     `json:"explicit_content"`
 	PreferredGenres []string `json:"preferred_genres"`
+	FavoriteArtists []int    `json:"favorite_artists"` // omitted leaves favorites unchanged
 }
 
 type ListeningHistory struct {
@@ -76,26 +230,136 @@ type ListeningHistory struct {
 	Completed      bool      `json:"completed"`
 }
 
+// PlaybackState is a user's now-playing state, shared by all of their clients
+type PlaybackState struct {
+	ContextType  string      `json:"context_type,omitempty"` // album, playlist, artist; empty for a single track
+	ContextID    *int        `json:"context_id,omitempty"`
+	ContextIndex int         `json:"context_index"`
+	DeviceID     string      `json:"device_id,omitempty"` // active device, when one is connected
+	TrackID      *int        `json:"track_id"`
+	Track        *Track      `json:"track,omitempty"`
+	FromQueue    bool        `json:"from_queue"`
+	PositionMs   int         `json:"position_ms"` // at updated_at
+	ProgressMs   int         `json:"progress_ms"` // position_ms plus the time played since updated_at
+	IsPlaying    bool        `json:"is_playing"`
+	Shuffle      bool        `json:"shuffle"`
+	ShuffleSeed  int64       `json:"-"`
+	RepeatMode   string      `json:"repeat_mode"` // off, track, context
+	UpdatedAt    time.Time   `json:"updated_at"`
+	Queue        []QueueItem `json:"queue,omitempty"`
+	UpNext       []Track     `json:"up_next,omitempty"` // upcoming context tracks after the queue
+}
+
+// ListeningSession is a group session; everyone hears the host's playback
+type ListeningSession struct {
+	ID              int            `json:"id"`
+	Code            string         `json:"code"`
+	Host            UserSummary    `json:"host"`
+	Members         []UserSummary  `json:"members"` // includes the host
+	State           *PlaybackState `json:"state,omitempty"`
+	SkipVotes       int            `json:"skip_votes"`
+	SkipVotesNeeded int            `json:"skip_votes_needed"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+// QueueItem is a track the user queued to play next
+type QueueItem struct {
+	ID       int64     `json:"id"`
+	TrackID  int       `json:"track_id"`
+	Position int       `json:"position"`
+	Track    *Track    `json:"track,omitempty"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
 type Playlist struct {
-	ID          int       `json:"id"`
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	OwnerName     string    `json:"owner_name,omitempty"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	TrackIDs      []int     `json:"track_ids"`
+	IsPublic      bool      `json:"is_public"`
+	CoverURL      string    `json:"cover_url"`
+	IsSmart       bool      `json:"is_smart"`
+	FollowerCount int       `json:"follower_count"`
+	IsFollowing   bool      `json:"is_following"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type PlaylistItem struct {
+	TrackID     int        `json:"track_id"`
+	Position    int        `json:"position"`
+	AddedBy     *int       `json:"added_by"`
+	AddedByName string     `json:"added_by_name,omitempty"`
+	AddedAt     *time.Time `json:"added_at,omitempty"` // nil for tracks resolved by a smart playlist
+}
+
+type PlaylistCollaborator struct {
 	UserID      int       `json:"user_id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	TrackIDs    []int     `json:"track_ids"`
-	IsPublic    bool      `json:"is_public"`
-	CoverURL    string    `json:"cover_url"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	AddedAt     time.Time `json:"added_at"`
+}
+
+type PlaylistInvite struct {
+	Token      string    `json:"token"`
+	PlaylistID int       `json:"playlist_id"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SmartPlaylistRule is a single condition a track must satisfy.
+//
+//	genre         in | not_in                 values
+//	artist        in | not_in                 ids
+//	release_date  after | before              date (YYYY-MM-DD)
+//	play_count    gt | lt                     number
+//	played_by_me  within_days | not_within_days  number (days)
+type SmartPlaylistRule struct {
+	Field    string   `json:"field" binding:"required"`
+	Operator string   `json:"operator" binding:"required"`
+	Values   []string `json:"values,omitempty"`
+	IDs      []int    `json:"ids,omitempty"`
+	Date     string   `json:"date,omitempty"`
+	Number   int      `json:"number,omitempty"`
+}
+
+// SmartPlaylistDefinition holds the stored rules of a smart playlist
+type SmartPlaylistDefinition struct {
+	Match   string              `json:"match"` // all (default) or any
+	Rules   []SmartPlaylistRule `json:"rules" binding:"required,min=1,dive"`
+	OrderBy string              `json:"order_by"` // release_date (default), play_count, title, recently_added, random
+	Limit   int                 `json:"limit"`
+	Seed    int64               `json:"seed,omitempty"` // orders random playlists; drawn again whenever the rules are saved
+}
+
+// PlaylistImportMatch reports how an imported entry was matched to a catalog track
+type PlaylistImportMatch struct {
+	Index   int     `json:"index"`
+	TrackID int     `json:"track_id"`
+	Method  string  `json:"method"` // id, file_url or fuzzy
+	Score   float64 `json:"score,omitempty"`
+}
+
+// PlaylistImportMiss describes an imported entry that was not added to the playlist
+type PlaylistImportMiss struct {
+	Index    int    `json:"index"`
+	Title    string `json:"title,omitempty"`
+	Artist   string `json:"artist,omitempty"`
+	Location string `json:"location,omitempty"`
+	Reason   string `json:"reason"`
 }
 
 // Request/Response Models
 
 type RegisterRequest struct {
-	Email       string   `json:"email" binding:"required,email"`
-	Password    string   `json:"password" binding:"required,min=6"`
-	Username    string   `json:"username" binding:"required,min=3"`
-	DisplayName string   `json:"display_name" binding:"required"`
-	Genres      []string `json:"genres"`
+	Email           string   `json:"email" binding:"required,email"`
+	Password        string   `json:"password" binding:"required,min=6"`
+	Username        string   `json:"username" binding:"required,min=3"`
+	DisplayName     string   `json:"display_name" binding:"required"`
+	Genres          []string `json:"genres"`
+	FavoriteArtists []int    `json:"favorite_artists"`
 }
 
 type LoginRequest struct {
@@ -108,6 +372,14 @@ type AuthResponse struct {
 	User  User   `json:"user"`
 }
 
+type FavoriteArtistRequest struct {
+	ArtistID int `json:"artist_id" binding:"required"`
+}
+
+type SetFavoriteArtistsRequest struct {
+	ArtistIDs []int `json:"artist_ids"`
+}
+
 type CreatePlaylistRequest struct {
 	Name        string `json:"name" binding:"required"`
 	Description string `json:"description"`
@@ -118,10 +390,73 @@ type AddTrackToPlaylistRequest struct {
 	TrackID int `json:"track_id" binding:"required"`
 }
 
+type CreateSmartPlaylistRequest struct {
+	Name        string                  `json:"name" binding:"required"`
+	Description string                  `json:"description"`
+	IsPublic    bool                    `json:"is_public"`
+	Definition  SmartPlaylistDefinition `json:"definition" binding:"required"`
+}
+
+type MovePlaylistTrackRequest struct {
+	Position *int `json:"position" binding:"required,min=0"`
+}
+
+// PlayRequest starts a context or a single track. An empty body resumes playback.
+// Within a context, track_id or offset (index in the context's natural order) picks the first track.
+type PlayRequest struct {
+	ContextType string `json:"context_type" binding:"omitempty,oneof=album playlist artist"`
+	ContextID   int    `json:"context_id"`
+	TrackID     int    `json:"track_id"`
+	Offset      *int   `json:"offset" binding:"omitempty,min=0"`
+	PositionMs  int    `json:"position_ms" binding:"min=0"`
+}
+
+type SeekRequest struct {
+	PositionMs *int `json:"position_ms" binding:"required,min=0"`
+}
+
+type ShuffleRequest struct {
+	State *bool `json:"state" binding:"required"`
+}
+
+type RepeatRequest struct {
+	Mode string `json:"mode" binding:"required,oneof=off track context"`
+}
+
+type AddToQueueRequest struct {
+	TrackID int `json:"track_id" binding:"required"`
+}
+
+type MoveQueueItemRequest struct {
+	Position *int `json:"position" binding:"required,min=0"`
+}
+
+type TransferPlaybackRequest struct {
+	DeviceID string `json:"device_id" binding:"required"`
+	Resume   bool   `json:"resume"` // start playing on the new device
+}
+
+// PlayerSocketMessage is a message a device sends over the player WebSocket
+type PlayerSocketMessage struct {
+	Type           string       `json:"type"` // command, ping
+	ID             string       `json:"id"`   // echoed as in_reply_to
+	Command        string       `json:"command"`
+	TargetDeviceID string       `json:"target_device_id"`
+	Play           *PlayRequest `json:"play"`
+	PositionMs     *int         `json:"position_ms"`
+	Ended          bool         `json:"ended"`
+	Resume         bool         `json:"resume"` // transfer: start playing on the target
+}
+
+type JoinSessionRequest struct {
+	Code string `json:"code" binding:"required,len=6"`
+}
+
 type SearchResponse struct {
-	Tracks  []Track  `json:"tracks"`
-	Artists []Artist `json:"artists"`
-	Albums  []Album  `json:"albums"`
+	Tracks    []Track    `json:"tracks"`
+	Artists   []Artist   `json:"artists"`
+	Albums    []Album    `json:"albums"`
+	Playlists []Playlist `json:"playlists"`
 }
 
 type RecommendationRequest struct {
@@ -132,3 +467,55 @@ type RecommendationResponse struct {
 	Tracks []Track `json:"tracks"`
 	Reason string  `json:"reason"`
 }
+
+// RadioStation is an endless station generated from a seed
+type RadioStation struct {
+	ID           int       `json:"id"`
+	SeedType     string    `json:"seed_type"` // track, artist, album, genre, playlist
+	SeedID       *int      `json:"seed_id,omitempty"`
+	SeedGenre    string    `json:"seed_genre,omitempty"`
+	Name         string    `json:"name"`
+	TracksServed int       `json:"tracks_served"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type CreateRadioRequest struct {
+	SeedType string `json:"seed_type" binding:"required,oneof=track artist album genre playlist"`
+	SeedID   int    `json:"seed_id"`
+	Genre    string `json:"genre"`
+	Limit    int    `json:"limit"`
+}
+
+type RadioBatch struct {
+	Station RadioStation `json:"station"`
+	Tracks  []Track      `json:"tracks"`
+}
+
+type RadioSkipRequest struct {
+	TrackID int `json:"track_id" binding:"required"`
+}
+
+// ArtistStats are the catalog and play statistics of an artist
+type ArtistStats struct {
+	ArtistID             int       `json:"artist_id"`
+	ArtistName           string    `json:"artist_name"`
+	TotalAlbums          int       `json:"total_albums"`
+	TotalTracks          int       `json:"total_tracks"`
+	TotalDurationSeconds int       `json:"total_duration_seconds"`
+	TotalDurationMinutes float64   `json:"total_duration_minutes"`
+	UniqueGenres         int       `json:"unique_genres"`
+	Genres               string    `json:"genres"`
+	FirstRelease         time.Time `json:"first_release"`
+	LatestRelease        time.Time `json:"latest_release"`
+	AvgPlaysPerTrack     float64   `json:"avg_plays_per_track"`
+}
+
+// AlbumStats are the track counters of an album
+type AlbumStats struct {
+	AlbumID         int     `json:"album_id"`
+	Title           string  `json:"title"`
+	TrackCount      int     `json:"track_count"`
+	TotalDuration   int     `json:"total_duration_seconds"`
+	DurationMinutes float64 `json:"duration_minutes"`
+}
@@ -0,0 +1,73 @@
+package playlistio
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonFormatName and jsonFormatVersion identify files written by this package
+const (
+	jsonFormatName    = "spotify-clone-playlist"
+	jsonFormatVersion = 1
+)
+
+type jsonPlaylist struct {
+	Format      string      `json:"format"`
+	Version     int         `json:"version"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Tracks      []jsonTrack `json:"tracks"`
+}
+
+type jsonTrack struct {
+	ID       int    `json:"id,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Artist   string `json:"artist,omitempty"`
+	Album    string `json:"album,omitempty"`
+	Duration int    `json:"duration,omitempty"`
+	FileURL  string `json:"file_url,omitempty"`
+}
+
+func encodeJSON(p *Playlist) ([]byte, error) {
+	doc := jsonPlaylist{
+		Format:      jsonFormatName,
+		Version:     jsonFormatVersion,
+		Name:        p.Name,
+		Description: p.Description,
+		Tracks:      []jsonTrack{},
+	}
+	for _, e := range p.Entries {
+		doc.Tracks = append(doc.Tracks, jsonTrack{
+			ID:       e.TrackID,
+			Title:    e.Title,
+			Artist:   e.Artist,
+			Album:    e.Album,
+			Duration: e.Duration,
+			FileURL:  e.Location,
+		})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func decodeJSON(data []byte) (*Playlist, error) {
+	var doc jsonPlaylist
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing json playlist: %v", err)
+	}
+	if doc.Version > jsonFormatVersion {
+		return nil, fmt.Errorf("unsupported json playlist version %d", doc.Version)
+	}
+
+	p := &Playlist{Name: doc.Name, Description: doc.Description}
+	for _, t := range doc.Tracks {
+		p.Entries = append(p.Entries, Entry{
+			TrackID:  t.ID,
+			Title:    t.Title,
+			Artist:   t.Artist,
+			Album:    t.Album,
+			Duration: t.Duration,
+			Location: t.FileURL,
+		})
+	}
+	return p, nil
+}
@@ -0,0 +1,94 @@
+package playlistio
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// encodeM3U writes an extended M3U playlist. The catalog track ID is kept in a
+// non-standard #EXTTRACKID directive so re-imports match exactly.
+func encodeM3U(p *Playlist) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n")
+	if p.Name != "" {
+		fmt.Fprintf(&buf, "#PLAYLIST:%s\n", oneLine(p.Name))
+	}
+
+	for _, e := range p.Entries {
+		label := oneLine(e.Title)
+		if e.Artist != "" {
+			label = oneLine(e.Artist) + " - " + label
+		}
+		fmt.Fprintf(&buf, "#EXTINF:%d,%s\n", e.Duration, label)
+		if e.Album != "" {
+			fmt.Fprintf(&buf, "#EXTALB:%s\n", oneLine(e.Album))
+		}
+		if e.TrackID != 0 {
+			fmt.Fprintf(&buf, "#EXTTRACKID:%d\n", e.TrackID)
+		}
+		buf.WriteString(oneLine(e.Location) + "\n")
+	}
+	return buf.Bytes()
+}
+
+func decodeM3U(data []byte) (*Playlist, error) {
+	p := &Playlist{}
+	var current Entry
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		switch {
+		case line == "" || line == "#EXTM3U":
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			p.Name = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+		case strings.HasPrefix(line, "#EXTINF:"):
+			// An entry exported without a location has no line of its own
+			if current != (Entry{}) {
+				p.Entries = append(p.Entries, current)
+				current = Entry{}
+			}
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			durationPart, label, _ := strings.Cut(info, ",")
+			// Attributes such as tvg-id="..." may follow the duration
+			if fields := strings.Fields(durationPart); len(fields) > 0 {
+				if d, err := strconv.ParseFloat(fields[0], 64); err == nil && d > 0 {
+					current.Duration = int(d + 0.5)
+				}
+			}
+			if artist, title, ok := strings.Cut(label, " - "); ok {
+				current.Artist = strings.TrimSpace(artist)
+				current.Title = strings.TrimSpace(title)
+			} else {
+				current.Title = strings.TrimSpace(label)
+			}
+		case strings.HasPrefix(line, "#EXTALB:"):
+			current.Album = strings.TrimSpace(strings.TrimPrefix(line, "#EXTALB:"))
+		case strings.HasPrefix(line, "#EXTTRACKID:"):
+			current.TrackID, _ = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "#EXTTRACKID:")))
+		case strings.HasPrefix(line, "#"):
+			// Unknown directive or comment
+		default:
+			current.Location = line
+			p.Entries = append(p.Entries, current)
+			current = Entry{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading m3u: %v", err)
+	}
+
+	// A trailing #EXTINF without a location still describes a track
+	if current != (Entry{}) {
+		p.Entries = append(p.Entries, current)
+	}
+	return p, nil
+}
+
+func oneLine(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
@@ -0,0 +1,121 @@
+package playlistio
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MatchThreshold is the minimum Score for a fuzzy match to be accepted
+const MatchThreshold = 0.75
+
+// Candidate is a catalog track considered for a fuzzy match
+type Candidate struct {
+	TrackID  int
+	Title    string
+	Artist   string
+	Duration int
+}
+
+// Score rates how well a catalog candidate matches an entry, from 0 to 1.
+// Title similarity dominates; artist and duration refine it when present.
+func Score(e Entry, c Candidate) float64 {
+	title := Similarity(e.Title, c.Title)
+	if title == 0 {
+		return 0
+	}
+
+	score, weight := title*0.6, 0.6
+	if e.Artist != "" {
+		score += Similarity(e.Artist, c.Artist) * 0.3
+		weight += 0.3
+	}
+	if e.Duration > 0 && c.Duration > 0 {
+		diff := e.Duration - c.Duration
+		if diff < 0 {
+			diff = -diff
+		}
+		closeness := 0.0
+		switch {
+		case diff <= 2:
+			closeness = 1
+		case diff <= 10:
+			closeness = 1 - float64(diff-2)/8
+		}
+		score += closeness * 0.1
+		weight += 0.1
+	}
+	return score / weight
+}
+
+// Similarity compares two strings after normalization using Levenshtein distance
+func Similarity(a, b string) float64 {
+	a, b = Normalize(a), Normalize(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// Normalize lowercases a title, drops bracketed suffixes such as "(Remastered)"
+// and "feat." credits, and collapses punctuation to single spaces
+func Normalize(s string) string {
+	s = strings.ToLower(s)
+	for _, marker := range []string{" feat.", " ft.", " featuring "} {
+		if i := strings.Index(s, marker); i > 0 {
+			s = s[:i]
+		}
+	}
+
+	var b strings.Builder
+	depth := 0
+	space := false
+	for _, r := range s {
+		switch {
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			if depth > 0 {
+				depth--
+			}
+		case depth > 0:
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
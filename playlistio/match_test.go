@@ -0,0 +1,84 @@
+package playlistio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Teardrop", "teardrop"},
+		{"Hey Jude (Remastered 2015)", "hey jude"},
+		{"Song [Live] (Radio Edit)", "song"},
+		{"Nice For What feat. Drake", "nice for what"},
+		{"Stay ft. Justin Bieber", "stay"},
+		{"Lean On featuring MØ", "lean on"},
+		{"  Don't   Stop!! ", "don t stop"},
+		{"Björk - Jóga", "björk jóga"},
+		{"Unbalanced ) paren (open", "unbalanced paren"},
+		{"(Intro)", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"Teardrop", "teardrop", 1},
+		{"Hey Jude", "Hey Jude - Remastered", 8.0 / 19},
+		{"Hey Jude", "Hey Jude (Remastered)", 1},
+		{"kitten", "sitting", 1 - 3.0/7},
+		{"abc", "xyz", 0},
+		{"", "anything", 0},
+		{"(Live)", "(Live)", 0},
+	}
+	for _, tt := range tests {
+		if got := Similarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := Similarity(tt.b, tt.a); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	teardrop := Candidate{TrackID: 1, Title: "Teardrop", Artist: "Massive Attack", Duration: 330}
+	tests := []struct {
+		name   string
+		entry  Entry
+		c      Candidate
+		accept bool
+	}{
+		{"exact", Entry{Title: "Teardrop", Artist: "Massive Attack", Duration: 330}, teardrop, true},
+		{"title only", Entry{Title: "teardrop"}, teardrop, true},
+		{"remaster suffix and close duration", Entry{Title: "Teardrop (2019 Remaster)", Artist: "Massive Attack", Duration: 332}, teardrop, true},
+		{"typo", Entry{Title: "Teardorp", Artist: "Massive Atack"}, teardrop, true},
+		{"other artist", Entry{Title: "Teardrop", Artist: "Newton Faulkner", Duration: 270}, teardrop, false},
+		{"other title", Entry{Title: "Angel", Artist: "Massive Attack", Duration: 330}, teardrop, false},
+		{"no title", Entry{Artist: "Massive Attack", Duration: 330}, teardrop, false},
+		{"candidate without duration", Entry{Title: "Teardrop", Artist: "Massive Attack", Duration: 330}, Candidate{Title: "Teardrop", Artist: "Massive Attack"}, true},
+	}
+	for _, tt := range tests {
+		score := Score(tt.entry, tt.c)
+		if score < 0 || score > 1 {
+			t.Errorf("%s: score %v out of range", tt.name, score)
+		}
+		if got := score >= MatchThreshold; got != tt.accept {
+			t.Errorf("%s: score %v, accepted = %v, want %v", tt.name, score, got, tt.accept)
+		}
+	}
+
+	if exact, near := Score(Entry{Title: "Teardrop", Duration: 330}, teardrop), Score(Entry{Title: "Teardrop", Duration: 340}, teardrop); near >= exact {
+		t.Errorf("a 10 second difference scores %v, not below the exact duration's %v", near, exact)
+	}
+}
@@ -0,0 +1,96 @@
+// Package playlistio reads and writes playlists in portable file formats
+// (M3U8, XSPF and JSON). It knows nothing about the database; callers map
+// entries to catalog tracks themselves.
+package playlistio
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Supported formats
+const (
+	FormatM3U8 = "m3u8"
+	FormatXSPF = "xspf"
+	FormatJSON = "json"
+)
+
+// Playlist is the format independent representation of a playlist file
+type Playlist struct {
+	Name        string
+	Description string
+	Entries     []Entry
+}
+
+// Entry is a single track reference inside a playlist file.
+// Any field may be empty; importers match on whatever is present.
+type Entry struct {
+	TrackID  int
+	Title    string
+	Artist   string
+	Album    string
+	Duration int // seconds
+	Location string
+}
+
+// ContentType returns the MIME type used when serving a format
+func ContentType(format string) string {
+	switch format {
+	case FormatM3U8:
+		return "audio/x-mpegurl; charset=utf-8"
+	case FormatXSPF:
+		return "application/xspf+xml; charset=utf-8"
+	default:
+		return "application/json; charset=utf-8"
+	}
+}
+
+// Encode serializes a playlist in the given format
+func Encode(format string, p *Playlist) ([]byte, error) {
+	switch format {
+	case FormatM3U8:
+		return encodeM3U(p), nil
+	case FormatXSPF:
+		return encodeXSPF(p)
+	case FormatJSON:
+		return encodeJSON(p)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// Decode parses a playlist file in the given format
+func Decode(format string, data []byte) (*Playlist, error) {
+	switch format {
+	case FormatM3U8:
+		return decodeM3U(data)
+	case FormatXSPF:
+		return decodeXSPF(data)
+	case FormatJSON:
+		return decodeJSON(data)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// DetectFormat guesses the format from a file name, falling back to the content
+func DetectFormat(filename string, data []byte) string {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".m3u", ".m3u8":
+		return FormatM3U8
+	case ".xspf":
+		return FormatXSPF
+	case ".json":
+		return FormatJSON
+	}
+
+	trimmed := strings.TrimSpace(string(data))
+	switch {
+	case strings.HasPrefix(trimmed, "#EXTM3U"):
+		return FormatM3U8
+	case strings.HasPrefix(trimmed, "<"):
+		return FormatXSPF
+	case strings.HasPrefix(trimmed, "{"):
+		return FormatJSON
+	}
+	return ""
+}
@@ -0,0 +1,202 @@
+package playlistio
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func samplePlaylist() *Playlist {
+	return &Playlist{
+		Name:        "Road Trip",
+		Description: "Songs for the drive",
+		Entries: []Entry{
+			{TrackID: 12, Title: "Teardrop", Artist: "Massive Attack", Album: "Mezzanine", Duration: 330, Location: "/media/tracks/teardrop.mp3"},
+			{Title: "Roads", Artist: "Portishead", Duration: 302, Location: "https://example.com/roads.mp3"},
+			{TrackID: 7, Title: "Glory Box"},
+			{Title: "Ünïcödé & <Markup>", Artist: "Café \"Quotes\"", Album: "Line\nBreak"},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatM3U8, FormatXSPF, FormatJSON} {
+		want := samplePlaylist()
+		data, err := Encode(format, want)
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", format, err)
+		}
+		if got := DetectFormat("", data); got != format {
+			t.Errorf("%s: DetectFormat of the encoded file = %q", format, got)
+		}
+
+		got, err := Decode(format, data)
+		if err != nil {
+			t.Fatalf("%s: Decode: %v\n%s", format, err, data)
+		}
+		if format == FormatM3U8 {
+			// M3U has no description and keeps every field on one line
+			want.Description = ""
+			want.Entries[3].Album = "Line Break"
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: round trip = %+v, want %+v\n%s", format, got, want, data)
+		}
+	}
+}
+
+func TestRoundTripEmpty(t *testing.T) {
+	for _, format := range []string{FormatM3U8, FormatXSPF, FormatJSON} {
+		data, err := Encode(format, &Playlist{Name: "Empty"})
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", format, err)
+		}
+		got, err := Decode(format, data)
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", format, err)
+		}
+		if got.Name != "Empty" || len(got.Entries) != 0 {
+			t.Errorf("%s: round trip = %+v, want an empty playlist named Empty", format, got)
+		}
+	}
+}
+
+func TestDecodeM3U(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Entry
+	}{
+		{
+			name:  "plain file list",
+			input: "a.mp3\r\nb.mp3\r\n",
+			want:  []Entry{{Location: "a.mp3"}, {Location: "b.mp3"}},
+		},
+		{
+			name:  "byte order mark and blank lines",
+			input: "\ufeff#EXTM3U\n\n#EXTINF:215.6,Air - La Femme d'Argent\n\nair.mp3\n",
+			want:  []Entry{{Title: "La Femme d'Argent", Artist: "Air", Duration: 216, Location: "air.mp3"}},
+		},
+		{
+			name:  "attributes after the duration",
+			input: "#EXTM3U\n#EXTINF:120 tvg-id=\"x\" group-title=\"y\",Moby - Porcelain\nhttp://example.com/p.mp3\n",
+			want:  []Entry{{Title: "Porcelain", Artist: "Moby", Duration: 120, Location: "http://example.com/p.mp3"}},
+		},
+		{
+			name:  "unknown and negative durations",
+			input: "#EXTINF:-1,Live Stream\nstream\n#EXTINF:abc,Broken\nbroken.mp3\n",
+			want:  []Entry{{Title: "Live Stream", Location: "stream"}, {Title: "Broken", Location: "broken.mp3"}},
+		},
+		{
+			name:  "entries without a location",
+			input: "#EXTINF:100,First\n#EXTTRACKID:3\n#EXTINF:200,Second\n",
+			want:  []Entry{{Title: "First", Duration: 100, TrackID: 3}, {Title: "Second", Duration: 200}},
+		},
+		{
+			name:  "bad track id and unknown directives",
+			input: "#EXTINF:10,Song\n#EXTTRACKID:twelve\n#EXTGRP:Rock\n# a comment\nsong.mp3\n",
+			want:  []Entry{{Title: "Song", Duration: 10, Location: "song.mp3"}},
+		},
+		{
+			name:  "missing comma",
+			input: "#EXTINF:42\nsong.mp3\n",
+			want:  []Entry{{Duration: 42, Location: "song.mp3"}},
+		},
+		{
+			name:  "empty",
+			input: "",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := Decode(FormatM3U8, []byte(tt.input))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got.Entries, tt.want) {
+			t.Errorf("%s: entries = %+v, want %+v", tt.name, got.Entries, tt.want)
+		}
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		want   string
+	}{
+		{"m3u line too long", FormatM3U8, "#EXTM3U\n" + strings.Repeat("a", 2*1024*1024), "error reading m3u"},
+		{"xspf not xml", FormatXSPF, "not xml at all", "error parsing xspf"},
+		{"xspf truncated", FormatXSPF, `<playlist version="1"><trackList><track><title>A`, "error parsing xspf"},
+		{"xspf wrong root", FormatXSPF, `<rss><channel/></rss>`, "error parsing xspf"},
+		{"xspf bad duration", FormatXSPF, `<playlist><trackList><track><duration>long</duration></track></trackList></playlist>`, "error parsing xspf"},
+		{"json truncated", FormatJSON, `{"name": "A", "tracks": [`, "error parsing json playlist"},
+		{"json wrong type", FormatJSON, `{"name": "A", "tracks": "none"}`, "error parsing json playlist"},
+		{"json newer version", FormatJSON, `{"format": "spotify-clone-playlist", "version": 2, "tracks": []}`, "unsupported json playlist version 2"},
+		{"unknown format", "pls", "[playlist]", `unsupported format "pls"`},
+	}
+
+	for _, tt := range tests {
+		_, err := Decode(tt.format, []byte(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeXSPFIdentifiers(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/">
+  <title> Mix </title>
+  <trackList>
+    <track>
+      <location> a.mp3 </location>
+      <location>b.mp3</location>
+      <identifier>urn:isrc:GBAAA9800001</identifier>
+      <identifier>spotify-clone:track:42</identifier>
+      <title>Angel</title>
+      <duration>379400</duration>
+    </track>
+    <track>
+      <identifier>spotify-clone:track:abc</identifier>
+      <title>Unknown ID</title>
+    </track>
+  </trackList>
+</playlist>`
+
+	got, err := Decode(FormatXSPF, []byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Playlist{Name: "Mix", Entries: []Entry{
+		{TrackID: 42, Title: "Angel", Duration: 379, Location: "a.mp3"},
+		{Title: "Unknown ID"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode = %+v, want %+v", got, want)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		data     string
+		want     string
+	}{
+		{"mix.M3U", "", FormatM3U8},
+		{"mix.m3u8", "{}", FormatM3U8},
+		{"mix.xspf", "", FormatXSPF},
+		{"mix.json", "", FormatJSON},
+		{"upload", "  #EXTM3U\n", FormatM3U8},
+		{"upload.txt", "<?xml version=\"1.0\"?>", FormatXSPF},
+		{"", "\n{\"tracks\": []}", FormatJSON},
+		{"mix.pls", "[playlist]", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectFormat(tt.filename, []byte(tt.data)); got != tt.want {
+			t.Errorf("DetectFormat(%q, %q) = %q, want %q", tt.filename, tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package playlistio
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	xspfNamespace = "http://xspf.org/ns/0/"
+	// trackIdentifierPrefix marks catalog IDs inside <identifier> elements
+	trackIdentifierPrefix = "spotify-clone:track:"
+)
+
+type xspfPlaylist struct {
+	XMLName    xml.Name    `xml:"playlist"`
+	Version    string      `xml:"version,attr"`
+	Namespace  string      `xml:"xmlns,attr"`
+	Title      string      `xml:"title,omitempty"`
+	Annotation string      `xml:"annotation,omitempty"`
+	Tracks     []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location   []string `xml:"location,omitempty"`
+	Identifier []string `xml:"identifier,omitempty"`
+	Title      string   `xml:"title,omitempty"`
+	Creator    string   `xml:"creator,omitempty"`
+	Album      string   `xml:"album,omitempty"`
+	Duration   int      `xml:"duration,omitempty"` // milliseconds
+}
+
+func encodeXSPF(p *Playlist) ([]byte, error) {
+	doc := xspfPlaylist{
+		Version:    "1",
+		Namespace:  xspfNamespace,
+		Title:      p.Name,
+		Annotation: p.Description,
+	}
+
+	for _, e := range p.Entries {
+		track := xspfTrack{
+			Title:    e.Title,
+			Creator:  e.Artist,
+			Album:    e.Album,
+			Duration: e.Duration * 1000,
+		}
+		if e.Location != "" {
+			track.Location = []string{e.Location}
+		}
+		if e.TrackID != 0 {
+			track.Identifier = []string{trackIdentifierPrefix + strconv.Itoa(e.TrackID)}
+		}
+		doc.Tracks = append(doc.Tracks, track)
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+func decodeXSPF(data []byte) (*Playlist, error) {
+	var doc xspfPlaylist
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing xspf: %v", err)
+	}
+
+	p := &Playlist{Name: strings.TrimSpace(doc.Title), Description: strings.TrimSpace(doc.Annotation)}
+	for _, track := range doc.Tracks {
+		entry := Entry{
+			Title:    strings.TrimSpace(track.Title),
+			Artist:   strings.TrimSpace(track.Creator),
+			Album:    strings.TrimSpace(track.Album),
+			Duration: (track.Duration + 500) / 1000,
+		}
+		if len(track.Location) > 0 {
+			entry.Location = strings.TrimSpace(track.Location[0])
+		}
+		for _, id := range track.Identifier {
+			if rest, ok := strings.CutPrefix(strings.TrimSpace(id), trackIdentifierPrefix); ok {
+				entry.TrackID, _ = strconv.Atoi(rest)
+				break
+			}
+		}
+		p.Entries = append(p.Entries, entry)
+	}
+	return p, nil
+}
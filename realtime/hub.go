@@ -0,0 +1,283 @@
+// Package realtime keeps track of the devices each user has connected and
+// fans events out to them. It is transport agnostic: callers read a client's
+// events and write them to whatever connection the device uses.
+package realtime
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// sendBuffer is how many undelivered events a device may have before it is
+// considered too slow and disconnected
+const sendBuffer = 64
+
+// ErrDeviceNotFound is returned when a device is not connected
+var ErrDeviceNotFound = errors.New("device not connected")
+
+// Device describes a connected client of a user
+type Device struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"` // computer, smartphone, speaker, ...
+	IsActive    bool      `json:"is_active"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// Event is a message sent to devices. Broadcast events carry a per-user sequence
+// number that grows by one for every broadcast, so every device of a user sees
+// them in the same order and can detect a gap. Events addressed to a single
+// device have no sequence number.
+type Event struct {
+	Seq       uint64      `json:"seq,omitempty"`
+	Type      string      `json:"type"`
+	InReplyTo string      `json:"in_reply_to,omitempty"`
+	Payload   interface{} `json:"payload,omitempty"`
+}
+
+// Client is one connected device
+type Client struct {
+	UserID int
+	Device Device
+
+	send      chan Event
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Events returns the channel of events to deliver to the device, in order
+func (c *Client) Events() <-chan Event {
+	return c.send
+}
+
+// Done is closed when the client was disconnected by the hub
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// enqueue queues an event without blocking. A device that cannot keep up is
+// disconnected instead of silently missing events.
+func (c *Client) enqueue(event Event) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- event:
+		return true
+	default:
+		c.close()
+		return false
+	}
+}
+
+func (c *Client) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// userDevices holds the connected devices of one user
+type userDevices struct {
+	// control serializes operations that change playback so that their
+	// broadcasts go out in the order the changes were made
+	control sync.Mutex
+
+	// refs counts the hub calls using this entry; it is guarded by Hub.mu
+	refs int
+
+	mu           sync.Mutex
+	seq          uint64
+	clients      map[string]*Client
+	activeDevice string
+}
+
+// Hub routes events to the connected devices of each user. A user has an entry
+// only while they have a connected device or a call is using it.
+type Hub struct {
+	mu    sync.Mutex
+	users map[int]*userDevices
+}
+
+// NewHub creates an empty hub
+func NewHub() *Hub {
+	return &Hub{users: map[int]*userDevices{}}
+}
+
+// user returns the entry of a user, creating it if needed. Every call must be
+// paired with a release once the caller is done with the entry.
+func (h *Hub) user(userID int) *userDevices {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	u, ok := h.users[userID]
+	if !ok {
+		u = &userDevices{clients: map[string]*Client{}}
+		h.users[userID] = u
+	}
+	u.refs++
+	return u
+}
+
+// release drops the entry of a user who has no connected devices left and
+// that no other call is using. It must not be called with u.mu held.
+func (h *Hub) release(userID int, u *userDevices) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	u.refs--
+	if u.refs > 0 {
+		return
+	}
+	u.mu.Lock()
+	idle := len(u.clients) == 0
+	u.mu.Unlock()
+	if idle {
+		delete(h.users, userID)
+	}
+}
+
+// Exclusive runs fn while no other Exclusive call for the same user runs.
+// Playback changes go through it so the state broadcasts they publish are in
+// the same order as the changes themselves.
+func (h *Hub) Exclusive(userID int, fn func()) {
+	u := h.user(userID)
+	defer h.release(userID, u)
+	u.control.Lock()
+	defer u.control.Unlock()
+	fn()
+}
+
+// Register connects a device. A device reconnecting with the same ID replaces
+// its previous connection. The new client first receives a "hello" event with
+// the device list and the current sequence number; every other device then gets
+// a "devices" broadcast.
+func (h *Hub) Register(userID int, device Device) *Client {
+	u := h.user(userID)
+	defer h.release(userID, u)
+	client := &Client{
+		UserID: userID,
+		Device: device,
+		send:   make(chan Event, sendBuffer),
+		done:   make(chan struct{}),
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if previous, ok := u.clients[device.ID]; ok {
+		previous.close()
+	}
+	u.clients[device.ID] = client
+	if u.activeDevice == "" {
+		u.activeDevice = device.ID
+	}
+
+	client.enqueue(Event{Type: "hello", Payload: map[string]interface{}{
+		"device_id": device.ID,
+		"devices":   u.devices(),
+		"seq":       u.seq,
+	}})
+	u.broadcast("devices", u.devices())
+	return client
+}
+
+// Unregister disconnects a device and tells the remaining devices
+func (h *Hub) Unregister(client *Client) {
+	u := h.user(client.UserID)
+	defer h.release(client.UserID, u)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	client.close()
+	if current, ok := u.clients[client.Device.ID]; !ok || current != client {
+		return
+	}
+	delete(u.clients, client.Device.ID)
+	if u.activeDevice == client.Device.ID {
+		u.activeDevice = ""
+	}
+	u.broadcast("devices", u.devices())
+}
+
+// Devices lists the connected devices of a user
+func (h *Hub) Devices(userID int) []Device {
+	u := h.user(userID)
+	defer h.release(userID, u)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.devices()
+}
+
+// ActiveDevice returns the ID of the device playback happens on, if any
+func (h *Hub) ActiveDevice(userID int) string {
+	u := h.user(userID)
+	defer h.release(userID, u)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.activeDevice
+}
+
+// SetActiveDevice moves playback to another connected device
+func (h *Hub) SetActiveDevice(userID int, deviceID string) error {
+	u := h.user(userID)
+	defer h.release(userID, u)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.clients[deviceID]; !ok {
+		return ErrDeviceNotFound
+	}
+	if u.activeDevice != deviceID {
+		u.activeDevice = deviceID
+		u.broadcast("devices", u.devices())
+	}
+	return nil
+}
+
+// Publish sends a sequenced event to every connected device of the user
+func (h *Hub) Publish(userID int, eventType string, payload interface{}) {
+	u := h.user(userID)
+	defer h.release(userID, u)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.broadcast(eventType, payload)
+}
+
+// SendTo sends an event to a single device of the user
+func (h *Hub) SendTo(userID int, deviceID string, event Event) error {
+	u := h.user(userID)
+	defer h.release(userID, u)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	client, ok := u.clients[deviceID]
+	if !ok {
+		return ErrDeviceNotFound
+	}
+	event.Seq = 0
+	client.enqueue(event)
+	return nil
+}
+
+// broadcast must be called with u.mu held, which is what makes the sequence
+// numbers and the enqueue order agree on every device
+func (u *userDevices) broadcast(eventType string, payload interface{}) {
+	u.seq++
+	event := Event{Seq: u.seq, Type: eventType, Payload: payload}
+	for id, client := range u.clients {
+		if !client.enqueue(event) {
+			delete(u.clients, id)
+		}
+	}
+	if _, ok := u.clients[u.activeDevice]; !ok {
+		u.activeDevice = ""
+	}
+}
+
+func (u *userDevices) devices() []Device {
+	devices := make([]Device, 0, len(u.clients))
+	for _, client := range u.clients {
+		device := client.Device
+		device.IsActive = device.ID == u.activeDevice
+		devices = append(devices, device)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ConnectedAt.Before(devices[j].ConnectedAt) })
+	return devices
+}
@@ -0,0 +1,156 @@
+package realtime
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestHubForgetsDisconnectedUsers(t *testing.T) {
+	h := NewHub()
+	phone := h.Register(1, Device{ID: "phone"})
+	laptop := h.Register(1, Device{ID: "laptop"})
+	h.Publish(2, "state", nil)
+	h.Exclusive(3, func() { h.Publish(3, "state", nil) })
+	if len(h.users) != 1 {
+		t.Fatalf("hub has %d users, want only the connected one", len(h.users))
+	}
+
+	// A replaced connection unregistering late must not drop the new one
+	replaced := phone
+	phone = h.Register(1, Device{ID: "phone"})
+	h.Unregister(replaced)
+	if got := len(h.Devices(1)); got != 2 {
+		t.Fatalf("user has %d devices after a reconnect, want 2", got)
+	}
+
+	h.Unregister(phone)
+	h.Unregister(laptop)
+	if len(h.users) != 0 {
+		t.Fatalf("hub still has %d users after every device disconnected", len(h.users))
+	}
+	if got := h.ActiveDevice(1); got != "" {
+		t.Fatalf("active device of a disconnected user = %q", got)
+	}
+	if len(h.users) != 0 {
+		t.Fatal("looking up a disconnected user left an entry behind")
+	}
+}
+
+// drain returns the events queued for a client without waiting
+func drain(c *Client) []Event {
+	events := []Event{}
+	for {
+		select {
+		case event := <-c.Events():
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// broadcasts returns the sequenced events after the hello, checking that the
+// numbers continue from the hello's without a gap
+func broadcasts(t *testing.T, name string, events []Event) []Event {
+	t.Helper()
+	if len(events) == 0 || events[0].Type != "hello" || events[0].Seq != 0 {
+		t.Fatalf("%s: events start with %v, want an unsequenced hello", name, events)
+	}
+	seq := events[0].Payload.(map[string]interface{})["seq"].(uint64)
+	for _, event := range events[1:] {
+		seq++
+		if event.Seq != seq {
+			t.Fatalf("%s: got seq %d, want %d", name, event.Seq, seq)
+		}
+	}
+	return events[1:]
+}
+
+func TestHubSequencesEventsPerUser(t *testing.T) {
+	h := NewHub()
+	phone := h.Register(1, Device{ID: "phone"})
+	laptop := h.Register(1, Device{ID: "laptop"})
+	tablet := h.Register(2, Device{ID: "tablet"})
+
+	var wg sync.WaitGroup
+	for writer := 0; writer < 4; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				h.Publish(1, "state", writer*10+i)
+				h.Publish(2, "state", writer*10+i)
+			}
+		}(writer)
+	}
+	wg.Wait()
+
+	phoneEvents := broadcasts(t, "phone", drain(phone))
+	laptopEvents := broadcasts(t, "laptop", drain(laptop))
+	tabletEvents := broadcasts(t, "tablet", drain(tablet))
+	if len(phoneEvents) != 42 || len(tabletEvents) != 41 {
+		t.Fatalf("phone got %d broadcasts, tablet %d, want 42 and 41", len(phoneEvents), len(tabletEvents))
+	}
+	// Every device of a user sees the same events in the same order
+	if !reflect.DeepEqual(phoneEvents[1:], laptopEvents) {
+		t.Fatalf("phone and laptop disagree:\n%v\n%v", phoneEvents[1:], laptopEvents)
+	}
+}
+
+func TestHubRepliesAreNotSequenced(t *testing.T) {
+	h := NewHub()
+	phone := h.Register(1, Device{ID: "phone"})
+	laptop := h.Register(1, Device{ID: "laptop"})
+	drain(phone)
+	drain(laptop)
+
+	if err := h.SendTo(1, "phone", Event{Seq: 99, Type: "error", InReplyTo: "cmd-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SendTo(1, "watch", Event{Type: "error"}); err != ErrDeviceNotFound {
+		t.Fatalf("sending to a missing device: got %v, want ErrDeviceNotFound", err)
+	}
+	h.Publish(1, "state", nil)
+
+	events := drain(phone)
+	if len(events) != 2 || events[0].Seq != 0 || events[0].InReplyTo != "cmd-1" {
+		t.Fatalf("phone got %v, want the reply without a seq first", events)
+	}
+	// The reply used no sequence number, so the broadcast follows the last one
+	if events[1].Seq != 3 {
+		t.Fatalf("broadcast after the reply has seq %d, want 3", events[1].Seq)
+	}
+	if events := drain(laptop); len(events) != 1 || events[0].Type != "state" {
+		t.Fatalf("laptop got %v, want only the broadcast", events)
+	}
+}
+
+func TestHubDisconnectsSlowClients(t *testing.T) {
+	h := NewHub()
+	slow := h.Register(1, Device{ID: "phone"})
+	fast := h.Register(1, Device{ID: "laptop"})
+
+	for i := 0; i < sendBuffer; i++ {
+		drain(fast)
+		h.Publish(1, "state", i)
+	}
+	select {
+	case <-slow.Done():
+	default:
+		t.Fatal("a client with a full send buffer is still connected")
+	}
+	select {
+	case <-fast.Done():
+		t.Fatal("a client that keeps up was disconnected")
+	default:
+	}
+
+	devices := h.Devices(1)
+	if len(devices) != 1 || devices[0].ID != "laptop" {
+		t.Fatalf("devices %v, want only the laptop", devices)
+	}
+	if got := h.ActiveDevice(1); got != "" {
+		t.Fatalf("active device %q, want none once the active phone was dropped", got)
+	}
+}
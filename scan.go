@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"spotify-clone/database"
+	"spotify-clone/scanner"
+	"spotify-clone/store"
+)
+
+const scanUsage = `usage: spotify-clone scan [-delete-missing] [DIR]
+
+Imports the MP3 and FLAC files under DIR, which defaults to MUSIC_DIR, with
+their tags and cover art. Files unchanged since the last scan are skipped and
+files that are gone are reported; -delete-missing deletes their tracks. Run the
+server with the same MUSIC_DIR to serve the files at their file_url.`
+
+// runScan implements "spotify-clone scan", which imports a local music
+// directory into MySQL without starting the server
+func runScan(args []string) error {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, scanUsage) }
+	deleteMissing := flags.Bool("delete-missing", false, "delete the tracks of files that are gone")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	dir := os.Getenv("MUSIC_DIR")
+	switch flags.NArg() {
+	case 0:
+		if dir == "" {
+			return fmt.Errorf("%s", scanUsage)
+		}
+	case 1:
+		dir = flags.Arg(0)
+	default:
+		return fmt.Errorf("%s", scanUsage)
+	}
+
+	if err := database.InitMySQL(); err != nil {
+		return fmt.Errorf("failed to connect to MySQL: %v", err)
+	}
+	defer database.Close()
+
+	s := scanner.New(store.NewMySQL(database.MySQL), dir)
+	s.DeleteMissing = *deleteMissing
+	result, err := s.Scan()
+	if err != nil {
+		return err
+	}
+
+	for _, warning := range result.Warnings {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+	for _, fileErr := range result.Errors {
+		fmt.Fprintln(os.Stderr, fileErr)
+	}
+	for _, path := range result.Missing {
+		fmt.Fprintln(os.Stderr, "missing:", path)
+	}
+	fmt.Printf("%d files: %d added, %d updated, %d unchanged, %d moved, %d failed; %d artists and %d albums created\n",
+		result.Files, result.Added, result.Updated, result.Unchanged, result.Moved, len(result.Errors),
+		result.ArtistsCreated, result.AlbumsCreated)
+	if len(result.Missing) > 0 {
+		fmt.Printf("%d files missing, %d tracks deleted\n", len(result.Missing), result.Removed)
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%d of %d files were not imported", len(result.Errors), result.Files)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FLAC metadata block types
+const (
+	flacStreamInfo    = 0
+	flacVorbisComment = 4
+	flacPicture       = 6
+)
+
+// readFLAC reads the STREAMINFO, VORBIS_COMMENT and PICTURE metadata blocks
+func readFLAC(r io.ReaderAt, size int64) (*Tags, error) {
+	magic, err := readAt(r, 0, 4)
+	if err != nil || string(magic) != "fLaC" {
+		return nil, fmt.Errorf("not a FLAC file")
+	}
+
+	tags := &Tags{}
+	pictureType := -1
+	offset := int64(4)
+	for {
+		header, err := readAt(r, offset, 4)
+		if err != nil {
+			return nil, err
+		}
+		last := header[0]&0x80 != 0
+		kind := header[0] & 0x7f
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		offset += 4
+		if offset+length > size {
+			return nil, fmt.Errorf("truncated metadata block")
+		}
+
+		switch kind {
+		case flacStreamInfo, flacVorbisComment, flacPicture:
+			if length > maxPictureBytes {
+				break
+			}
+			block, err := readAt(r, offset, int(length))
+			if err != nil {
+				return nil, err
+			}
+			switch kind {
+			case flacStreamInfo:
+				tags.Duration = flacDuration(block)
+			case flacVorbisComment:
+				parseVorbisComment(tags, block)
+			case flacPicture:
+				if kindOf, image := flacPictureBlock(block); image != nil && (pictureType == -1 || kindOf == 3 && pictureType != 3) {
+					tags.Picture, pictureType = image, kindOf
+				}
+			}
+		}
+
+		offset += length
+		if last {
+			return tags, nil
+		}
+	}
+}
+
+// flacDuration reads the sample rate and total samples of STREAMINFO
+func flacDuration(block []byte) int {
+	if len(block) < 18 {
+		return 0
+	}
+	sampleRate := int64(block[10])<<12 | int64(block[11])<<4 | int64(block[12])>>4
+	total := int64(block[13]&0x0f)<<32 | int64(binary.BigEndian.Uint32(block[14:18]))
+	if sampleRate == 0 {
+		return 0
+	}
+	return int((total + sampleRate/2) / sampleRate)
+}
+
+// parseVorbisComment reads the little-endian, length-prefixed KEY=value
+// comments, keeping the first value of each field
+func parseVorbisComment(tags *Tags, block []byte) {
+	next := func() (string, bool) {
+		if len(block) < 4 {
+			return "", false
+		}
+		n := int(binary.LittleEndian.Uint32(block))
+		if n < 0 || n > len(block)-4 {
+			return "", false
+		}
+		value := string(block[4 : 4+n])
+		block = block[4+n:]
+		return value, true
+	}
+
+	if _, ok := next(); !ok { // vendor
+		return
+	}
+	if len(block) < 4 {
+		return
+	}
+	count := int(binary.LittleEndian.Uint32(block))
+	block = block[4:]
+
+	fill := func(dest *string, value string) {
+		if *dest == "" {
+			*dest = value
+		}
+	}
+	for i := 0; i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			return
+		}
+		key, value, ok := strings.Cut(comment, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			fill(&tags.Title, value)
+		case "ARTIST":
+			fill(&tags.Artist, value)
+		case "ALBUM":
+			fill(&tags.Album, value)
+		case "ALBUMARTIST", "ALBUM ARTIST":
+			fill(&tags.AlbumArtist, value)
+		case "GENRE":
+			fill(&tags.Genre, value)
+		case "TRACKNUMBER":
+			if tags.TrackNumber == 0 {
+				tags.TrackNumber = parseTrackNumber(value)
+			}
+		case "DATE", "YEAR":
+			if tags.ReleaseDate.IsZero() {
+				tags.ReleaseDate = parseDate(value)
+			}
+		}
+	}
+}
+
+// flacPictureBlock returns the picture type and image of a PICTURE block
+func flacPictureBlock(block []byte) (int, []byte) {
+	field := func(at int) (int, bool) {
+		if at+4 > len(block) {
+			return 0, false
+		}
+		return int(binary.BigEndian.Uint32(block[at:])), true
+	}
+
+	kind, ok := field(0)
+	if !ok {
+		return 0, nil
+	}
+	mimeLength, ok := field(4)
+	if !ok || mimeLength > len(block) {
+		return kind, nil
+	}
+	at := 8 + mimeLength
+	descriptionLength, ok := field(at)
+	if !ok || descriptionLength > len(block) {
+		return kind, nil
+	}
+	// Width, height, depth and colour count precede the data length
+	at += 4 + descriptionLength + 16
+	dataLength, ok := field(at)
+	if !ok || dataLength == 0 || dataLength > len(block)-at-4 {
+		return kind, nil
+	}
+	return kind, block[at+4 : at+4+dataLength]
+}
@@ -0,0 +1,500 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// frameSearchBytes is how far past the ID3v2 tag the first MPEG frame is
+// looked for
+const frameSearchBytes = 64 << 10
+
+// readMP3 reads ID3v2 tags, falling back to ID3v1 for the fields they lack,
+// and works out the duration from the tags or the MPEG frames
+func readMP3(r io.ReaderAt, size int64) (*Tags, error) {
+	tags := &Tags{}
+	found := false
+
+	audioStart := int64(0)
+	if header, err := readAt(r, 0, 10); err == nil && string(header[:3]) == "ID3" {
+		tagSize := int64(syncsafe(header[6:10]))
+		audioStart = 10 + tagSize
+		if header[3] == 4 && header[5]&0x10 != 0 {
+			audioStart += 10 // footer
+		}
+		if audioStart > size {
+			return nil, fmt.Errorf("truncated ID3v2 tag")
+		}
+		body, err := readAt(r, 10, int(tagSize))
+		if err != nil {
+			return nil, err
+		}
+		parseID3v2(tags, header[3], header[5], body)
+		found = true
+	}
+
+	audioEnd := size
+	if size >= audioStart+128 {
+		if trailer, err := readAt(r, size-128, 128); err == nil && string(trailer[:3]) == "TAG" {
+			parseID3v1(tags, trailer)
+			audioEnd -= 128
+			found = true
+		}
+	}
+
+	if tags.Duration == 0 {
+		n := int64(frameSearchBytes)
+		if audioEnd-audioStart < n {
+			n = audioEnd - audioStart
+		}
+		if n > 0 {
+			if data, err := readAt(r, audioStart, int(n)); err == nil {
+				tags.Duration = mpegDuration(data, audioEnd-audioStart)
+			}
+		}
+	}
+
+	if !found && tags.Duration == 0 {
+		return nil, errNoTags
+	}
+	return tags, nil
+}
+
+// syncsafe decodes the 7 bits per byte integers of ID3v2
+func syncsafe(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n<<7 | int(c&0x7f)
+	}
+	return n
+}
+
+// resync undoes ID3v2 unsynchronisation, which inserts a zero after every 0xFF
+func resync(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte{0xff, 0x00}, []byte{0xff})
+}
+
+// parseID3v2 reads the frames of an ID3v2.2, 2.3 or 2.4 tag body
+func parseID3v2(tags *Tags, version, flags byte, body []byte) {
+	if version < 2 || version > 4 {
+		return
+	}
+	if flags&0x80 != 0 && version < 4 {
+		body = resync(body)
+	}
+	if flags&0x40 != 0 && version > 2 && len(body) >= 4 {
+		// Extended header: its size excludes itself in 2.3 and includes
+		// itself, syncsafe, in 2.4
+		skip := int(binary.BigEndian.Uint32(body)) + 4
+		if version == 4 {
+			skip = syncsafe(body[:4])
+		}
+		if skip > len(body) {
+			return
+		}
+		body = body[skip:]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	var year, dayMonth string
+	var picture []byte
+	pictureType := -1
+	for len(body) >= headerSize && body[0] != 0 {
+		id := string(body[:idSize])
+		var frameSize int
+		var formatFlags byte
+		switch version {
+		case 2:
+			frameSize = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(body[4:8]))
+			formatFlags = body[9]
+		case 4:
+			frameSize = syncsafe(body[4:8])
+			formatFlags = body[9]
+		}
+		if frameSize > len(body)-headerSize {
+			break
+		}
+		data := body[headerSize : headerSize+frameSize]
+		body = body[headerSize+frameSize:]
+
+		data, ok := frameData(version, flags, formatFlags, data)
+		if !ok {
+			continue
+		}
+
+		switch id {
+		case "TIT2", "TT2":
+			tags.Title = id3Text(data)
+		case "TPE1", "TP1":
+			tags.Artist = id3Text(data)
+		case "TALB", "TAL":
+			tags.Album = id3Text(data)
+		case "TPE2", "TP2":
+			tags.AlbumArtist = id3Text(data)
+		case "TCON", "TCO":
+			tags.Genre = id3Genre(id3Text(data))
+		case "TRCK", "TRK":
+			tags.TrackNumber = parseTrackNumber(id3Text(data))
+		case "TDRC", "TDRL":
+			if tags.ReleaseDate.IsZero() {
+				tags.ReleaseDate = parseDate(id3Text(data))
+			}
+		case "TYER", "TYE":
+			year = id3Text(data)
+		case "TDAT", "TDA":
+			dayMonth = id3Text(data)
+		case "TLEN", "TLE":
+			if ms, err := strconv.Atoi(id3Text(data)); err == nil && ms > 0 {
+				tags.Duration = (ms + 500) / 1000
+			}
+		case "APIC", "PIC":
+			kind, image := id3Picture(data, id == "PIC")
+			// Keep the front cover (type 3) over any other picture
+			if image != nil && (pictureType == -1 || kind == 3 && pictureType != 3) {
+				picture, pictureType = image, kind
+			}
+		}
+	}
+
+	if tags.ReleaseDate.IsZero() && year != "" {
+		tags.ReleaseDate = parseDate(year)
+		// TDAT holds the day and month of 2.3 tags as DDMM
+		if date, err := time.Parse("2006 0201", year+" "+dayMonth); err == nil {
+			tags.ReleaseDate = date
+		}
+	}
+	tags.Picture = picture
+}
+
+// frameData strips what the frame flags add before the content, reporting
+// false for compressed and encrypted frames, which are skipped
+func frameData(version, tagFlags, formatFlags byte, data []byte) ([]byte, bool) {
+	switch version {
+	case 3:
+		if formatFlags&0xc0 != 0 {
+			return nil, false
+		}
+		if formatFlags&0x20 != 0 && len(data) > 0 {
+			data = data[1:] // group
+		}
+	case 4:
+		if formatFlags&0x0c != 0 {
+			return nil, false
+		}
+		if formatFlags&0x40 != 0 && len(data) > 0 {
+			data = data[1:] // group
+		}
+		if formatFlags&0x01 != 0 && len(data) >= 4 {
+			data = data[4:] // data length indicator
+		}
+		if formatFlags&0x02 != 0 || tagFlags&0x80 != 0 {
+			data = resync(data)
+		}
+	}
+	return data, true
+}
+
+// id3Text decodes a text frame, keeping the first of several values
+func id3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	text, _ := decodeID3String(data[0], data[1:])
+	return strings.TrimSpace(text)
+}
+
+// decodeID3String decodes a string terminated by the null of its encoding or
+// the end of data, and returns what follows the terminator
+func decodeID3String(encoding byte, data []byte) (string, []byte) {
+	if encoding == 1 || encoding == 2 {
+		end := len(data) &^ 1
+		rest := []byte(nil)
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				end, rest = i, data[i+2:]
+				break
+			}
+		}
+		text := data[:end]
+
+		bigEndian := encoding == 2
+		if encoding == 1 && len(text) >= 2 {
+			switch {
+			case text[0] == 0xfe && text[1] == 0xff:
+				bigEndian, text = true, text[2:]
+			case text[0] == 0xff && text[1] == 0xfe:
+				text = text[2:]
+			}
+		}
+		units := make([]uint16, len(text)/2)
+		for i := range units {
+			if bigEndian {
+				units[i] = binary.BigEndian.Uint16(text[2*i:])
+			} else {
+				units[i] = binary.LittleEndian.Uint16(text[2*i:])
+			}
+		}
+		return string(utf16.Decode(units)), rest
+	}
+
+	end, rest := len(data), []byte(nil)
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		end, rest = i, data[i+1:]
+	}
+	if encoding == 3 {
+		return string(data[:end]), rest
+	}
+	return latin1(data[:end]), rest
+}
+
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// id3Picture returns the picture type and image of an APIC frame, or of a PIC
+// frame of ID3v2.2, which has a three letter format instead of a MIME type
+func id3Picture(data []byte, v22 bool) (int, []byte) {
+	if len(data) < 2 {
+		return 0, nil
+	}
+	encoding := data[0]
+	rest := data[1:]
+	if v22 {
+		if len(rest) < 3 {
+			return 0, nil
+		}
+		rest = rest[3:]
+	} else {
+		i := bytes.IndexByte(rest, 0)
+		if i < 0 {
+			return 0, nil
+		}
+		rest = rest[i+1:]
+	}
+	if len(rest) < 1 {
+		return 0, nil
+	}
+	kind := int(rest[0])
+	_, image := decodeID3String(encoding, rest[1:])
+	if len(image) == 0 || len(image) > maxPictureBytes {
+		return kind, nil
+	}
+	return kind, image
+}
+
+// genreReference matches the "(17)" references of ID3v2.3 genres
+var genreReference = regexp.MustCompile(`^\((\d+|RX|CR)\)(.*)$`)
+
+// id3Genre resolves ID3v1 genre numbers, written "17", "(17)" or "(17)Rock"
+func id3Genre(value string) string {
+	if match := genreReference.FindStringSubmatch(value); match != nil {
+		if refinement := strings.TrimSpace(match[2]); refinement != "" {
+			return refinement
+		}
+		value = match[1]
+	}
+	switch value {
+	case "RX":
+		return "Remix"
+	case "CR":
+		return "Cover"
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		if n >= 0 && n < len(id3v1Genres) {
+			return id3v1Genres[n]
+		}
+		return ""
+	}
+	return value
+}
+
+// parseID3v1 fills the fields the ID3v2 tag left empty from a 128 byte ID3v1
+// trailer
+func parseID3v1(tags *Tags, trailer []byte) {
+	field := func(from, to int) string {
+		value := trailer[from:to]
+		if i := bytes.IndexByte(value, 0); i >= 0 {
+			value = value[:i]
+		}
+		return strings.TrimSpace(latin1(value))
+	}
+	fill := func(dest *string, value string) {
+		if *dest == "" {
+			*dest = value
+		}
+	}
+	fill(&tags.Title, field(3, 33))
+	fill(&tags.Artist, field(33, 63))
+	fill(&tags.Album, field(63, 93))
+	if tags.ReleaseDate.IsZero() {
+		tags.ReleaseDate = parseDate(field(93, 97))
+	}
+	// ID3v1.1 keeps the track number in the last byte of the comment
+	if tags.TrackNumber == 0 && trailer[125] == 0 {
+		tags.TrackNumber = int(trailer[126])
+	}
+	if tags.Genre == "" && int(trailer[127]) < len(id3v1Genres) {
+		tags.Genre = id3v1Genres[trailer[127]]
+	}
+}
+
+// mpegFrame is a decoded MPEG audio frame header
+type mpegFrame struct {
+	version    int // 1, 2, or 25 for MPEG 2.5
+	layer      int
+	bitrate    int // bits per second
+	sampleRate int
+	padding    int
+	mono       bool
+}
+
+var mpegBitrates = map[[2]int][]int{
+	{1, 1}: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+	{1, 2}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+	{1, 3}: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{2, 1}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+	{2, 2}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	{2, 3}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// parseMPEGFrame decodes a frame header, reporting false for anything that is
+// not one
+func parseMPEGFrame(b []byte) (mpegFrame, bool) {
+	if len(b) < 4 || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return mpegFrame{}, false
+	}
+	var frame mpegFrame
+	switch (b[1] >> 3) & 3 {
+	case 0:
+		frame.version = 25
+	case 2:
+		frame.version = 2
+	case 3:
+		frame.version = 1
+	default:
+		return frame, false
+	}
+	frame.layer = 4 - int((b[1]>>1)&3)
+	bitrateIndex := int(b[2] >> 4)
+	rateIndex := int((b[2] >> 2) & 3)
+	if frame.layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return frame, false
+	}
+
+	table := frame.version
+	if table == 25 {
+		table = 2
+	}
+	frame.bitrate = mpegBitrates[[2]int{table, frame.layer}][bitrateIndex] * 1000
+	frame.sampleRate = []int{44100, 48000, 32000}[rateIndex]
+	switch frame.version {
+	case 2:
+		frame.sampleRate /= 2
+	case 25:
+		frame.sampleRate /= 4
+	}
+	frame.padding = int((b[2] >> 1) & 1)
+	frame.mono = b[3]>>6 == 3
+	return frame, true
+}
+
+func (f mpegFrame) samples() int {
+	switch {
+	case f.layer == 1:
+		return 384
+	case f.layer == 3 && f.version != 1:
+		return 576
+	}
+	return 1152
+}
+
+func (f mpegFrame) length() int {
+	if f.layer == 1 {
+		return (12*f.bitrate/f.sampleRate + f.padding) * 4
+	}
+	return f.samples()/8*f.bitrate/f.sampleRate + f.padding
+}
+
+// mpegDuration finds the first frame in data, which starts the audio, and
+// takes the frame count from a Xing, Info or VBRI header, or else assumes a
+// constant bitrate over audioSize bytes
+func mpegDuration(data []byte, audioSize int64) int {
+	for i := 0; i+4 <= len(data); i++ {
+		frame, ok := parseMPEGFrame(data[i:])
+		if !ok {
+			continue
+		}
+		// A second frame where the first ends rules out stray sync bytes
+		next := i + frame.length()
+		if next+4 <= len(data) {
+			if _, ok := parseMPEGFrame(data[next:]); !ok {
+				continue
+			}
+		}
+
+		if frames := vbrFrames(data[i:], frame); frames > 0 {
+			return int((int64(frames)*int64(frame.samples()) + int64(frame.sampleRate)/2) / int64(frame.sampleRate))
+		}
+		return int((audioSize - int64(i)) * 8 / int64(frame.bitrate))
+	}
+	return 0
+}
+
+// vbrFrames reads the frame count of the Xing or Info header, or the VBRI
+// header, in the first frame
+func vbrFrames(data []byte, frame mpegFrame) int {
+	sideInfo := 32
+	switch {
+	case frame.version == 1 && frame.mono, frame.version != 1 && !frame.mono:
+		sideInfo = 17
+	case frame.version != 1 && frame.mono:
+		sideInfo = 9
+	}
+	if at := 4 + sideInfo; len(data) >= at+12 {
+		if id := string(data[at : at+4]); id == "Xing" || id == "Info" {
+			if binary.BigEndian.Uint32(data[at+4:])&1 != 0 {
+				return int(binary.BigEndian.Uint32(data[at+8:]))
+			}
+			return 0
+		}
+	}
+	if at := 4 + 32; len(data) >= at+18 && string(data[at:at+4]) == "VBRI" {
+		return int(binary.BigEndian.Uint32(data[at+14:]))
+	}
+	return 0
+}
+
+// id3v1Genres are the genres numbered by ID3v1
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge",
+	"Hip-Hop", "Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B",
+	"Rap", "Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska",
+	"Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient",
+	"Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance", "Classical",
+	"Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative",
+	"Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic", "Darkwave",
+	"Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap",
+	"Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave",
+	"Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi", "Tribal",
+	"Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll",
+	"Hard Rock",
+}
@@ -0,0 +1,571 @@
+// Package scanner imports a folder of MP3 and FLAC files into the catalog. It
+// reads the tags and cover art of each file, finds or creates its artist and
+// album, and adds or updates its track with a file_url under URLPrefix. Files
+// are remembered in scanned_files, so a rescan only reads files whose size,
+// modification time and content changed, follows files that moved and reports
+// files that are gone.
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"spotify-clone/media"
+	"spotify-clone/models"
+	"spotify-clone/store"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// URLPrefix is the path under which the music directory is served
+const URLPrefix = "/music"
+
+// maxPathLength is the size of scanned_files.path
+const maxPathLength = 700
+
+// coverFiles are the folder images used for albums without embedded art
+var coverFiles = []string{"cover", "folder", "front"}
+
+// trackNumberPrefix matches the "01 - " file names often start with
+var trackNumberPrefix = regexp.MustCompile(`^\d+\s*[-._]?\s*`)
+
+// FileError is a file that was not imported, or whose cover art was not
+type FileError struct {
+	Path    string
+	Message string
+}
+
+func (e FileError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Result counts what a scan did
+type Result struct {
+	Files int
+	// Added, Updated and Unchanged count files by what happened to their
+	// tracks; Moved counts files found under a new path
+	Added          int
+	Updated        int
+	Unchanged      int
+	Moved          int
+	ArtistsCreated int
+	AlbumsCreated  int
+	// Missing are the files scanned before that are gone, by path
+	Missing []string
+	// Removed counts the tracks deleted for missing files
+	Removed  int
+	Errors   []FileError
+	Warnings []FileError
+}
+
+type albumKey struct {
+	artistID int
+	title    string // lower-cased
+}
+
+// Scanner imports the audio files under a directory through the stores
+type Scanner struct {
+	stores *store.Stores
+	root   string
+	// DeleteMissing deletes the tracks of missing files instead of only
+	// reporting them
+	DeleteMissing bool
+	// SaveCover stores the cover art of an album and returns its URL. New sets
+	// it to store a square JPEG in the media directory.
+	SaveCover func(albumID int, data []byte) (string, error)
+
+	artists   map[string]int // by lower-cased name
+	albums    map[albumKey]*models.Album
+	folderArt map[string][]byte // by directory
+	linked    map[int]bool      // tracks belonging to a scanned file
+}
+
+// New returns a Scanner importing the files under root
+func New(stores *store.Stores, root string) *Scanner {
+	return &Scanner{
+		stores:    stores,
+		root:      root,
+		SaveCover: saveCover,
+		artists:   map[string]int{},
+		albums:    map[albumKey]*models.Album{},
+		folderArt: map[string][]byte{},
+		linked:    map[int]bool{},
+	}
+}
+
+// localFile is an audio file found under the root
+type localFile struct {
+	path    string // relative to the root, with forward slashes
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+// Scan imports new and changed files and looks for missing ones. Files that
+// fail are reported in the result; the error is for a root or a file list
+// that cannot be read at all.
+func (s *Scanner) Scan() (*Result, error) {
+	info, err := os.Stat(s.root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", s.root)
+	}
+
+	scanned, err := s.stores.Files.List()
+	if err != nil {
+		return nil, fmt.Errorf("error reading scanned files: %v", err)
+	}
+	known := make(map[string]store.ScannedFile, len(scanned))
+	for _, file := range scanned {
+		known[file.Path] = file
+		if file.TrackID != 0 {
+			s.linked[file.TrackID] = true
+		}
+	}
+
+	result := &Result{}
+	found, err := s.walk(result)
+	if err != nil {
+		return nil, err
+	}
+	result.Files = len(found)
+
+	// Files are only hashed when their size or modification time changed, and
+	// only read when their content did
+	present := map[string]bool{}
+	var changed []localFile
+	for _, file := range found {
+		present[file.path] = true
+		previous, ok := known[file.path]
+		if ok && previous.Size == file.size && previous.ModTime.Equal(file.modTime) {
+			result.Unchanged++
+			continue
+		}
+
+		hash, err := hashFile(s.abs(file.path))
+		if err != nil {
+			result.Errors = append(result.Errors, FileError{file.path, err.Error()})
+			continue
+		}
+		file.hash = hash
+		if ok && previous.Hash == hash {
+			previous.Size, previous.ModTime = file.size, file.modTime
+			if err := s.stores.Files.Save(previous); err != nil {
+				result.Errors = append(result.Errors, FileError{file.path, err.Error()})
+				continue
+			}
+			result.Unchanged++
+			continue
+		}
+		changed = append(changed, file)
+	}
+
+	// A new file with the content of a missing one is that file moved
+	gone := map[string][]store.ScannedFile{}
+	for _, file := range scanned {
+		if !present[file.Path] {
+			gone[file.Hash] = append(gone[file.Hash], file)
+		}
+	}
+
+	for _, file := range changed {
+		previous, ok := known[file.path]
+		if candidates := gone[file.hash]; !ok && len(candidates) > 0 {
+			gone[file.hash] = candidates[1:]
+			if err := s.move(candidates[0], file); err != nil {
+				result.Errors = append(result.Errors, FileError{file.path, err.Error()})
+				continue
+			}
+			result.Moved++
+			continue
+		}
+		if err := s.importFile(file, previous.TrackID, result); err != nil {
+			result.Errors = append(result.Errors, FileError{file.path, err.Error()})
+		}
+	}
+
+	for _, files := range gone {
+		for _, file := range files {
+			result.Missing = append(result.Missing, file.Path)
+			if !s.DeleteMissing {
+				continue
+			}
+			if file.TrackID != 0 {
+				if err := s.stores.Tracks.Delete(file.TrackID); err != nil && err != store.ErrNotFound {
+					result.Errors = append(result.Errors, FileError{file.Path, fmt.Sprintf("error deleting track: %v", err)})
+					continue
+				}
+				result.Removed++
+			}
+			if err := s.stores.Files.Delete(file.Path); err != nil {
+				result.Errors = append(result.Errors, FileError{file.Path, err.Error()})
+			}
+		}
+	}
+	sort.Strings(result.Missing)
+	return result, nil
+}
+
+// walk lists the audio files under the root, skipping hidden directories
+func (s *Scanner) walk(result *Result) ([]localFile, error) {
+	var files []localFile
+	err := filepath.WalkDir(s.root, func(name string, entry fs.DirEntry, err error) error {
+		rel, _ := filepath.Rel(s.root, name)
+		rel = filepath.ToSlash(rel)
+		if err != nil {
+			if rel == "." {
+				return err
+			}
+			result.Errors = append(result.Errors, FileError{rel, err.Error()})
+			return nil
+		}
+		if entry.IsDir() {
+			if rel != "." && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || !supported(name) {
+			return nil
+		}
+		if utf8.RuneCountInString(rel) > maxPathLength {
+			result.Errors = append(result.Errors, FileError{rel, fmt.Sprintf("path is longer than %d characters", maxPathLength)})
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			result.Errors = append(result.Errors, FileError{rel, err.Error()})
+			return nil
+		}
+		files = append(files, localFile{path: rel, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+func (s *Scanner) abs(rel string) string {
+	return filepath.Join(s.root, filepath.FromSlash(rel))
+}
+
+// move points the track of a missing file at the file that replaced it. The
+// track of a file whose track was deleted stays deleted.
+func (s *Scanner) move(old store.ScannedFile, file localFile) error {
+	trackID := old.TrackID
+	if trackID != 0 {
+		track, err := s.stores.Tracks.Get(trackID)
+		switch {
+		case err == store.ErrNotFound:
+			trackID = 0
+		case err != nil:
+			return err
+		default:
+			fileURL, err := fileURL(file.path)
+			if err != nil {
+				return err
+			}
+			err = s.stores.Tracks.Update(trackID, store.NewTrack{
+				Title:       track.Title,
+				ArtistID:    track.ArtistID,
+				AlbumID:     track.AlbumID,
+				Duration:    track.Duration,
+				Genre:       track.Genre,
+				ReleaseDate: track.ReleaseDate,
+				FileURL:     fileURL,
+				CoverURL:    track.CoverURL,
+			})
+			if err != nil {
+				return fmt.Errorf("error updating track: %v", err)
+			}
+		}
+	}
+
+	err := s.stores.Files.Save(store.ScannedFile{
+		Path: file.path, Size: file.size, ModTime: file.modTime, Hash: file.hash, TrackID: trackID,
+	})
+	if err != nil {
+		return err
+	}
+	return s.stores.Files.Delete(old.Path)
+}
+
+// importFile reads a new or changed file and adds or updates its track.
+// trackID is the track of the file at the last scan, if any.
+func (s *Scanner) importFile(file localFile, trackID int, result *Result) error {
+	tags, err := ReadTags(s.abs(file.path))
+	if err != nil {
+		return err
+	}
+	fillFromPath(tags, file.path)
+	if tags.Duration <= 0 {
+		return errors.New("cannot determine the duration")
+	}
+	fileURL, err := fileURL(file.path)
+	if err != nil {
+		return err
+	}
+
+	artistName := tags.AlbumArtist
+	if artistName == "" {
+		artistName = tags.Artist
+	}
+	artistID, err := s.artistID(clip(artistName, 255), result)
+	if err != nil {
+		return err
+	}
+	album, err := s.album(artistID, tags, file, result)
+	if err != nil {
+		return err
+	}
+
+	track := store.NewTrack{
+		Title:       clip(tags.Title, 255),
+		ArtistID:    artistID,
+		AlbumID:     album.ID,
+		Duration:    tags.Duration,
+		Genre:       clip(tags.Genre, 100),
+		ReleaseDate: tags.ReleaseDate,
+		FileURL:     fileURL,
+		CoverURL:    album.CoverURL,
+	}
+	if track.ReleaseDate.IsZero() {
+		track.ReleaseDate = album.ReleaseDate
+	}
+
+	// A track already in the catalog, say from an import, takes the file
+	// unless another file has it
+	if trackID == 0 {
+		ids, err := s.stores.Tracks.IDsByTitle(album.ID, []string{track.Title})
+		if err != nil {
+			return fmt.Errorf("error looking up track: %v", err)
+		}
+		if id, ok := ids[strings.ToLower(track.Title)]; ok && !s.linked[id] {
+			trackID = id
+		}
+	}
+
+	if trackID != 0 {
+		err := s.stores.Tracks.Update(trackID, track)
+		if err == store.ErrNotFound {
+			trackID = 0
+		} else if err != nil {
+			return fmt.Errorf("error updating track: %v", err)
+		} else {
+			result.Updated++
+		}
+	}
+	if trackID == 0 {
+		id, status, err := s.stores.Tracks.Add(track)
+		if err != nil {
+			return fmt.Errorf("error adding track: %v", err)
+		}
+		if strings.HasPrefix(status, "ERROR") {
+			return errors.New(strings.TrimSpace(strings.TrimPrefix(status, "ERROR:")))
+		}
+		trackID = id
+		result.Added++
+	}
+	s.linked[trackID] = true
+
+	return s.stores.Files.Save(store.ScannedFile{
+		Path: file.path, Size: file.size, ModTime: file.modTime, Hash: file.hash, TrackID: trackID,
+	})
+}
+
+// fillFromPath takes what the tags lack from an Artist/Album/01 Title.mp3
+// layout
+func fillFromPath(tags *Tags, rel string) {
+	dirs := strings.Split(path.Dir(rel), "/")
+	if dirs[0] == "." {
+		dirs = nil
+	}
+
+	if tags.Title == "" {
+		base := strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+		tags.Title = strings.TrimSpace(trackNumberPrefix.ReplaceAllString(base, ""))
+		if tags.Title == "" {
+			tags.Title = base
+		}
+	}
+	if tags.Album == "" {
+		tags.Album = "Unknown Album"
+		if len(dirs) > 0 {
+			tags.Album = dirs[len(dirs)-1]
+		}
+	}
+	if tags.Artist == "" && tags.AlbumArtist == "" {
+		tags.Artist = "Unknown Artist"
+		if len(dirs) > 1 {
+			tags.Artist = dirs[len(dirs)-2]
+		}
+	}
+}
+
+func (s *Scanner) artistID(name string, result *Result) (int, error) {
+	key := strings.ToLower(name)
+	if id, ok := s.artists[key]; ok {
+		return id, nil
+	}
+
+	ids, err := s.stores.Artists.IDsByName([]string{name})
+	if err != nil {
+		return 0, fmt.Errorf("error looking up artist: %v", err)
+	}
+	id, ok := ids[key]
+	if !ok {
+		artist := models.Artist{Name: name}
+		if err := s.stores.Artists.Create(&artist); err != nil {
+			return 0, fmt.Errorf("error creating artist %q: %v", name, err)
+		}
+		id = artist.ID
+		result.ArtistsCreated++
+	}
+	s.artists[key] = id
+	return id, nil
+}
+
+// album finds or creates the album of a file and gives it cover art if it has
+// none. New albums are dated by the tags, or else the file.
+func (s *Scanner) album(artistID int, tags *Tags, file localFile, result *Result) (*models.Album, error) {
+	title := clip(tags.Album, 255)
+	key := albumKey{artistID, strings.ToLower(title)}
+	album, ok := s.albums[key]
+	if !ok {
+		ids, err := s.stores.Albums.IDsByTitle(artistID, []string{title})
+		if err != nil {
+			return nil, fmt.Errorf("error looking up album: %v", err)
+		}
+		if id, found := ids[key.title]; found {
+			if album, err = s.stores.Albums.Get(id); err != nil {
+				return nil, fmt.Errorf("error looking up album: %v", err)
+			}
+		} else {
+			album = &models.Album{Title: title, ArtistID: artistID, ReleaseDate: tags.ReleaseDate}
+			if album.ReleaseDate.IsZero() {
+				year, month, day := file.modTime.Date()
+				album.ReleaseDate = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+			}
+			if err := s.stores.Albums.Create(album); err != nil {
+				return nil, fmt.Errorf("error creating album %q: %v", title, err)
+			}
+			result.AlbumsCreated++
+		}
+		s.albums[key] = album
+	}
+
+	if album.CoverURL == "" {
+		picture := tags.Picture
+		if picture == nil {
+			picture = s.folderCover(path.Dir(file.path))
+		}
+		if picture != nil {
+			coverURL, err := s.SaveCover(album.ID, picture)
+			if err == nil {
+				err = s.stores.Albums.SetCover(album.ID, coverURL)
+			}
+			if err != nil {
+				result.Warnings = append(result.Warnings, FileError{file.path, fmt.Sprintf("cover art not saved: %v", err)})
+			} else {
+				album.CoverURL = coverURL
+			}
+		}
+	}
+	return album, nil
+}
+
+// folderCover returns the cover.jpg, folder.jpg or front.jpg (or .jpeg or
+// .png) image of a directory
+func (s *Scanner) folderCover(dir string) []byte {
+	if data, ok := s.folderArt[dir]; ok {
+		return data
+	}
+	s.folderArt[dir] = nil
+
+	entries, err := os.ReadDir(s.abs(dir))
+	if err != nil {
+		return nil
+	}
+	for _, name := range coverFiles {
+		for _, entry := range entries {
+			base := strings.ToLower(entry.Name())
+			ext := path.Ext(base)
+			if strings.TrimSuffix(base, ext) != name || (ext != ".jpg" && ext != ".jpeg" && ext != ".png") {
+				continue
+			}
+			if info, err := entry.Info(); err != nil || !info.Mode().IsRegular() || info.Size() > maxPictureBytes {
+				continue
+			}
+			if data, err := os.ReadFile(filepath.Join(s.abs(dir), entry.Name())); err == nil {
+				s.folderArt[dir] = data
+				return data
+			}
+		}
+	}
+	return nil
+}
+
+// saveCover re-encodes cover art as a square JPEG like uploaded playlist
+// covers, named after the album and the source image
+func saveCover(albumID int, data []byte) (string, error) {
+	img, err := media.DecodeImage(data)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := media.EncodeJPEG(media.Square(img, media.CoverSize))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	name := fmt.Sprintf("covers/album-%d-%s.jpg", albumID, hex.EncodeToString(sum[:6]))
+	if err := media.Save(name, encoded); err != nil {
+		return "", err
+	}
+	return media.URL(name), nil
+}
+
+// fileURL escapes each segment of a relative path under URLPrefix
+func fileURL(rel string) (string, error) {
+	segments := strings.Split(rel, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	fileURL := URLPrefix + "/" + strings.Join(segments, "/")
+	if len(fileURL) > 500 {
+		return "", errors.New("file_url would be longer than 500 characters")
+	}
+	return fileURL, nil
+}
+
+func hashFile(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// clip shortens a tag to the size of its column
+func clip(value string, max int) string {
+	if utf8.RuneCountInString(value) <= max {
+		return value
+	}
+	return string([]rune(value)[:max])
+}
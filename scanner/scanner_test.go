@@ -0,0 +1,327 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"spotify-clone/store"
+)
+
+// id3Frame builds an ID3v2.3 frame
+func id3Frame(id string, data []byte) []byte {
+	frame := append([]byte(id), 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(frame[4:], uint32(len(data)))
+	return append(frame, data...)
+}
+
+func latin1Text(value string) []byte {
+	return append([]byte{0}, value...)
+}
+
+func utf16Text(value string) []byte {
+	data := []byte{1, 0xff, 0xfe}
+	for _, unit := range utf16.Encode([]rune(value)) {
+		data = binary.LittleEndian.AppendUint16(data, unit)
+	}
+	return data
+}
+
+func apic(kind byte, image []byte) []byte {
+	data := append([]byte{0}, "image/png\x00"...)
+	data = append(data, kind)
+	data = append(data, "cover\x00"...)
+	return append(data, image...)
+}
+
+// id3Tag wraps frames in an ID3v2.3 header
+func id3Tag(frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	size := len(body)
+	header := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(header, body...)
+}
+
+// mpegFrames returns n MPEG-1 Layer III frames at 128 kbit/s and 44.1 kHz,
+// the first one carrying a Xing header with xingFrames when it is not 0
+func mpegFrames(n int, xingFrames uint32) []byte {
+	var data []byte
+	for i := 0; i < n; i++ {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+		if i == 0 && xingFrames != 0 {
+			copy(frame[36:], "Xing")
+			binary.BigEndian.PutUint32(frame[40:], 1)
+			binary.BigEndian.PutUint32(frame[44:], xingFrames)
+		}
+		data = append(data, frame...)
+	}
+	return data
+}
+
+// flacFile builds a FLAC file with STREAMINFO, VORBIS_COMMENT and, when
+// picture is set, PICTURE blocks, and no audio
+func flacFile(seconds int, comments []string, picture []byte) []byte {
+	block := func(kind byte, last bool, data []byte) []byte {
+		if last {
+			kind |= 0x80
+		}
+		n := len(data)
+		return append([]byte{kind, byte(n >> 16), byte(n >> 8), byte(n)}, data...)
+	}
+
+	const sampleRate = 44100
+	total := uint64(seconds) * sampleRate
+	info := make([]byte, 34)
+	info[10] = byte(sampleRate >> 12)
+	info[11] = byte(sampleRate >> 4 & 0xff)
+	info[12] = byte(sampleRate&0x0f)<<4 | 1<<1 // two channels
+	info[13] = 15<<4 | byte(total>>32)         // 16 bits per sample
+	binary.BigEndian.PutUint32(info[14:], uint32(total))
+
+	comment := binary.LittleEndian.AppendUint32(nil, 4)
+	comment = append(comment, "test"...)
+	comment = binary.LittleEndian.AppendUint32(comment, uint32(len(comments)))
+	for _, c := range comments {
+		comment = binary.LittleEndian.AppendUint32(comment, uint32(len(c)))
+		comment = append(comment, c...)
+	}
+
+	data := append([]byte("fLaC"), block(0, false, info)...)
+	data = append(data, block(4, picture == nil, comment)...)
+	if picture != nil {
+		pic := binary.BigEndian.AppendUint32(nil, 3)
+		pic = binary.BigEndian.AppendUint32(pic, 9)
+		pic = append(pic, "image/png"...)
+		pic = binary.BigEndian.AppendUint32(pic, 0)
+		pic = append(pic, make([]byte, 16)...)
+		pic = binary.BigEndian.AppendUint32(pic, uint32(len(picture)))
+		pic = append(pic, picture...)
+		data = append(data, block(6, true, pic)...)
+	}
+	return data
+}
+
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadMP3(t *testing.T) {
+	data := id3Tag(
+		id3Frame("TIT2", utf16Text("Roads")),
+		id3Frame("TPE1", latin1Text("Portishead")),
+		id3Frame("TALB", latin1Text("Dummy")),
+		id3Frame("TCON", latin1Text("(27)")),
+		id3Frame("TRCK", latin1Text("2/11")),
+		id3Frame("TYER", latin1Text("1994")),
+		id3Frame("TDAT", latin1Text("2208")),
+		id3Frame("APIC", apic(0, []byte("other"))),
+		id3Frame("APIC", apic(3, []byte("front"))),
+		id3Frame("APIC", apic(4, []byte("back"))),
+	)
+	data = append(data, make([]byte, 32)...) // padding
+	data = append(data, mpegFrames(3, 1000)...)
+
+	tags, err := readMP3(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags.Title != "Roads" || tags.Artist != "Portishead" || tags.Album != "Dummy" || tags.Genre != "Trip-Hop" || tags.TrackNumber != 2 {
+		t.Errorf("tags = %+v", tags)
+	}
+	if got := tags.ReleaseDate.Format("2006-01-02"); got != "1994-08-22" {
+		t.Errorf("release date = %s, want 1994-08-22", got)
+	}
+	// 1000 frames of 1152 samples at 44.1 kHz
+	if tags.Duration != 26 {
+		t.Errorf("duration = %d, want 26 from the Xing header", tags.Duration)
+	}
+	if string(tags.Picture) != "front" {
+		t.Errorf("picture = %q, want the front cover", tags.Picture)
+	}
+}
+
+func TestReadMP3WithoutID3v2(t *testing.T) {
+	// 120 frames of 417 bytes at 128 kbit/s, then an ID3v1.1 trailer
+	data := mpegFrames(120, 0)
+	trailer := make([]byte, 128)
+	copy(trailer, "TAG")
+	copy(trailer[3:], "Glory Box")
+	copy(trailer[93:], "1994")
+	trailer[126] = 11
+	trailer[127] = 26 // Ambient
+	data = append(data, trailer...)
+
+	tags, err := readMP3(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags.Title != "Glory Box" || tags.TrackNumber != 11 || tags.Genre != "Ambient" || tags.ReleaseDate.Year() != 1994 {
+		t.Errorf("tags = %+v", tags)
+	}
+	if tags.Duration != 3 {
+		t.Errorf("duration = %d, want 3 at a constant bitrate", tags.Duration)
+	}
+
+	if _, err := readMP3(bytes.NewReader(make([]byte, 1000)), 1000); err != errNoTags {
+		t.Errorf("readMP3(silence) error = %v, want errNoTags", err)
+	}
+}
+
+func TestReadFLAC(t *testing.T) {
+	data := flacFile(200, []string{"TITLE=Teardrop", "artist=Massive Attack", "ALBUM=Mezzanine", "DATE=1998-04-20", "GENRE=Trip-Hop", "TRACKNUMBER=3"}, []byte("art"))
+	tags, err := readFLAC(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags.Title != "Teardrop" || tags.Artist != "Massive Attack" || tags.Album != "Mezzanine" || tags.Genre != "Trip-Hop" || tags.TrackNumber != 3 {
+		t.Errorf("tags = %+v", tags)
+	}
+	if tags.Duration != 200 || tags.ReleaseDate.Format("2006-01-02") != "1998-04-20" || string(tags.Picture) != "art" {
+		t.Errorf("duration = %d, date = %s, picture = %q", tags.Duration, tags.ReleaseDate, tags.Picture)
+	}
+
+	if _, err := readFLAC(bytes.NewReader(data[:50]), 50); err == nil {
+		t.Error("readFLAC accepted a truncated file")
+	}
+}
+
+func TestID3Genre(t *testing.T) {
+	for value, want := range map[string]string{"17": "Rock", "(17)": "Rock", "(17)Indie Rock": "Indie Rock", "(RX)": "Remix", "Shoegaze": "Shoegaze", "(250)": ""} {
+		if got := id3Genre(value); got != want {
+			t.Errorf("id3Genre(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func writeFile(t *testing.T, root, rel string, data []byte) {
+	t.Helper()
+	name := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	memory := store.NewMemory()
+	if err := store.SeedDemo(memory); err != nil {
+		t.Fatal(err)
+	}
+	stores := memory.Stores()
+	root := t.TempDir()
+
+	// A file without tags, named Artist/Album/01 - Title.mp3, with folder art
+	writeFile(t, root, "Portishead/Dummy/01 - Sour Times.mp3", mpegFrames(120, 0))
+	writeFile(t, root, "Portishead/Dummy/Cover.png", pngImage(t))
+	// A track of the demo catalog, which the file is linked to
+	getLucky := []string{"TITLE=Get Lucky", "ARTIST=Daft Punk", "ALBUM=Random Access Memories"}
+	writeFile(t, root, "daft punk/get lucky.flac", flacFile(369, getLucky, nil))
+	writeFile(t, root, ".trash/old.mp3", mpegFrames(120, 0))
+	writeFile(t, root, "notes.txt", []byte("not audio"))
+
+	scan := func(deleteMissing bool) *Result {
+		t.Helper()
+		s := New(stores, root)
+		s.DeleteMissing = deleteMissing
+		s.SaveCover = func(albumID int, data []byte) (string, error) {
+			return "/media/covers/test.jpg", nil
+		}
+		result, err := s.Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Errors) > 0 {
+			t.Fatalf("errors = %v", result.Errors)
+		}
+		return result
+	}
+	findTrack := func(title string) int {
+		t.Helper()
+		tracks, err := stores.Tracks.Search(title, 10)
+		if err != nil || len(tracks) != 1 {
+			t.Fatalf("Search(%q) = %v, %v, want one track", title, tracks, err)
+		}
+		return tracks[0].ID
+	}
+
+	result := scan(false)
+	if result.Files != 2 || result.Added != 1 || result.Updated != 1 || result.ArtistsCreated != 1 || result.AlbumsCreated != 1 {
+		t.Fatalf("first scan = %+v, want 2 files, 1 added, 1 updated, 1 artist and 1 album", result)
+	}
+	sourTimes, err := stores.Tracks.Get(findTrack("Sour Times"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sourTimes.ArtistName != "Portishead" || sourTimes.AlbumName != "Dummy" || sourTimes.Duration != 3 ||
+		sourTimes.FileURL != "/music/Portishead/Dummy/01%20-%20Sour%20Times.mp3" || sourTimes.CoverURL != "/media/covers/test.jpg" {
+		t.Errorf("Sour Times = %+v", sourTimes)
+	}
+	getLuckyID := findTrack("Get Lucky")
+
+	// Unchanged files, including touched ones, are not imported again
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(root, "daft punk/get lucky.flac"), later, later)
+	if result := scan(false); result.Unchanged != 2 || result.Added+result.Updated != 0 {
+		t.Errorf("rescan = %+v, want 2 unchanged", result)
+	}
+
+	// A changed file updates its track, and album_stats follows the duration
+	album, _ := stores.Tracks.Get(getLuckyID)
+	before, _ := stores.Albums.Stats(album.AlbumID)
+	writeFile(t, root, "daft punk/get lucky.flac", flacFile(400, getLucky, nil))
+	if result := scan(false); result.Updated != 1 || result.Unchanged != 1 {
+		t.Errorf("scan after a change = %+v, want 1 updated", result)
+	}
+	after, _ := stores.Albums.Stats(album.AlbumID)
+	if after.TrackCount != before.TrackCount || after.TotalDuration != before.TotalDuration+400-369 {
+		t.Errorf("album stats = %+v, want %+v plus 31s", after, before)
+	}
+
+	// A moved file keeps its track
+	if err := os.Rename(filepath.Join(root, "Portishead/Dummy/01 - Sour Times.mp3"), filepath.Join(root, "Portishead/Dummy/sour times.mp3")); err != nil {
+		t.Fatal(err)
+	}
+	if result := scan(false); result.Moved != 1 || result.Added != 0 || len(result.Missing) != 0 {
+		t.Errorf("scan after a move = %+v, want 1 moved", result)
+	}
+	if moved, _ := stores.Tracks.Get(sourTimes.ID); moved == nil || moved.FileURL != "/music/Portishead/Dummy/sour%20times.mp3" {
+		t.Errorf("moved track = %+v", moved)
+	}
+
+	// A deleted file is reported until the scan is told to delete its track
+	os.Remove(filepath.Join(root, "daft punk/get lucky.flac"))
+	if result := scan(false); len(result.Missing) != 1 || result.Missing[0] != "daft punk/get lucky.flac" || result.Removed != 0 {
+		t.Errorf("scan after a delete = %+v, want the file missing", result)
+	}
+	if _, err := stores.Tracks.Get(getLuckyID); err != nil {
+		t.Errorf("track of a missing file was deleted: %v", err)
+	}
+	if result := scan(true); result.Removed != 1 {
+		t.Errorf("scan with DeleteMissing = %+v, want 1 removed", result)
+	}
+	if _, err := stores.Tracks.Get(getLuckyID); err != store.ErrNotFound {
+		t.Errorf("Get(deleted track) error = %v, want ErrNotFound", err)
+	}
+	if result := scan(true); len(result.Missing) != 0 {
+		t.Errorf("missing after deletion = %v", result.Missing)
+	}
+
+	if _, err := New(stores, filepath.Join(root, "nope")).Scan(); err == nil {
+		t.Error("Scan accepted a missing directory")
+	}
+}
@@ -0,0 +1,103 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPictureBytes bounds the embedded cover art read from a file
+const maxPictureBytes = 16 << 20
+
+// errNoTags is returned for a file without a tag block the readers know
+var errNoTags = errors.New("no tags found")
+
+// Tags is what the scanner reads from an audio file. Fields the file does not
+// set are zero.
+type Tags struct {
+	Title       string
+	Artist      string
+	Album       string
+	AlbumArtist string
+	Genre       string
+	TrackNumber int
+	// ReleaseDate is as precise as the tag: a bare year is January 1st
+	ReleaseDate time.Time
+	// Duration is in seconds
+	Duration int
+	// Picture is the embedded cover art, preferring the front cover
+	Picture []byte
+}
+
+// readers are the tag readers by lower-cased file extension
+var readers = map[string]func(io.ReaderAt, int64) (*Tags, error){
+	".mp3":  readMP3,
+	".flac": readFLAC,
+}
+
+// supported reports whether the scanner can read tags from the file at path
+func supported(path string) bool {
+	_, ok := readers[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// ReadTags reads the tags and duration of an MP3 or FLAC file
+func ReadTags(path string) (*Tags, error) {
+	read, ok := readers[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported file type %q", filepath.Ext(path))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return read(file, info.Size())
+}
+
+// readAt reads n bytes at offset, failing when the file is shorter
+func readAt(r io.ReaderAt, offset int64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, offset)
+	if read == n {
+		return buf, nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return nil, err
+}
+
+// parseDate reads "2006", "2006-01" or "2006-01-02", ignoring anything after
+// the day such as a time of day
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if len(value) >= len(layout) {
+			if date, err := time.Parse(layout, value[:len(layout)]); err == nil {
+				return date
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// parseTrackNumber reads "3" or "3/12"
+func parseTrackNumber(value string) int {
+	value, _, _ = strings.Cut(strings.TrimSpace(value), "/")
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
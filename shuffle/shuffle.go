@@ -0,0 +1,90 @@
+// Package shuffle orders tracks randomly while keeping tracks of the same
+// artist, and of the same album, apart. It knows nothing about the database;
+// callers pass the artist and album of every track.
+package shuffle
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// jitter is how far, as a fraction of the gap between them, tracks of one group
+// may move from evenly spaced positions. It stays below 0.5 so a group's own
+// tracks never swap places.
+const jitter = 0.4
+
+// Item is a track as far as shuffling is concerned
+type Item struct {
+	ID       int
+	ArtistID int
+	AlbumID  int
+}
+
+type placed struct {
+	item     Item
+	position float64
+}
+
+// Spread returns the items in shuffled order. Each artist's tracks are spaced
+// evenly over the whole order, each starting at a random offset and moved by a
+// little jitter, and within an artist the albums are spread the same way. The
+// same items in the same order and the same seed always give the same result.
+func Spread(items []Item, seed int64) []Item {
+	rng := rand.New(rand.NewSource(seed))
+
+	all := make([]placed, 0, len(items))
+	for _, artistItems := range group(items, func(item Item) int { return item.ArtistID }) {
+		albums := group(artistItems, func(item Item) int { return item.AlbumID })
+		for _, album := range albums {
+			rng.Shuffle(len(album), func(i, j int) { album[i], album[j] = album[j], album[i] })
+		}
+
+		// Interleave the artist's albums, then spread the result over the order
+		artistPlaced := []placed{}
+		for _, album := range albums {
+			artistPlaced = append(artistPlaced, place(album, rng)...)
+		}
+		all = append(all, place(sorted(artistPlaced), rng)...)
+	}
+	return sorted(all)
+}
+
+// place gives the n items of one group evenly spaced positions in [0, 1)
+func place(items []Item, rng *rand.Rand) []placed {
+	n := float64(len(items))
+	offset := rng.Float64() / n
+	result := make([]placed, len(items))
+	for i, item := range items {
+		shift := (rng.Float64()*2 - 1) * jitter / n
+		result[i] = placed{item: item, position: offset + float64(i)/n + shift}
+	}
+	return result
+}
+
+// sorted returns the items ordered by position
+func sorted(all []placed) []Item {
+	sort.SliceStable(all, func(i, j int) bool { return all[i].position < all[j].position })
+	items := make([]Item, len(all))
+	for i, p := range all {
+		items[i] = p.item
+	}
+	return items
+}
+
+// group splits items by key, keeping groups in order of first appearance so the
+// result does not depend on map iteration order
+func group(items []Item, key func(Item) int) [][]Item {
+	index := map[int]int{}
+	groups := [][]Item{}
+	for _, item := range items {
+		k := key(item)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], item)
+	}
+	return groups
+}
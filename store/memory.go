@@ -0,0 +1,437 @@
+package store
+
+import (
+	"errors"
+	"sort"
+	"spotify-clone/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errForeignKey is returned where MySQL would reject a row referencing a
+// missing parent
+var errForeignKey = errors.New("referenced row does not exist")
+
+// Memory holds the whole data layer in memory. Besides the rows it keeps what
+// the triggers and routines of migrations/sql/0003_routines.up.sql
+// maintain: album_stats counters, track_stats play counts and the add_track
+// validation rules, and it removes dependent rows the way the foreign keys
+// cascade, so the API behaves as it does on MySQL with no database. It is
+// safe for concurrent use.
+type Memory struct {
+	mu sync.RWMutex
+	// playerMu and radioMu stand in for the row locks PlayerStore.Update and
+	// RadioStore.NextBatch hold while their callbacks run. mu is not held then,
+	// so the callbacks can use the other stores.
+	playerMu sync.Mutex
+	radioMu  sync.Mutex
+
+	artists    map[int]models.Artist
+	albums     map[int]models.Album
+	tracks     map[int]models.Track
+	albumStats map[int]*albumCounters
+	trackStats map[int]*trackCounters
+	users      map[int]*models.User
+	playlists  map[int]*memPlaylist
+	invites    map[string]*memInvite
+	covers     map[int]PlaylistCover
+	plays      []memPlay
+	files      map[string]ScannedFile
+
+	savedTracks map[int][]memSaved
+	savedAlbums map[int][]memSaved
+	follows     []memFollow
+	activities  []memActivity
+	privacy     map[int]*models.PrivacySettings
+	playback    map[int]*models.PlaybackState
+	queues      map[int][]models.QueueItem
+	sessions    map[int]*memSession
+	stations    map[int]*memStation
+	skips       []memSkip
+	deletions   map[int]models.AccountDeletion
+
+	lastID map[string]int
+}
+
+// albumCounters is a row of album_stats
+type albumCounters struct {
+	trackCount    int
+	totalDuration int
+}
+
+// trackCounters is a row of track_stats
+type trackCounters struct {
+	playCount  int
+	lastPlayed *time.Time
+	// recommendable counts the plays of users who do not exclude their
+	// listening from recommendations
+	recommendable int
+}
+
+type memPlaylist struct {
+	models.Playlist
+	entries       []memPlaylistEntry
+	collaborators map[int]time.Time
+	followers     map[int]time.Time
+	// smart holds the rules of a smart playlist, nil for a regular one
+	smart *models.SmartPlaylistDefinition
+}
+
+// memPlaylistEntry is a row of playlist_tracks
+type memPlaylistEntry struct {
+	trackID  int
+	position int
+	addedBy  *int
+	addedAt  time.Time
+}
+
+// memInvite is a row of playlist_invites
+type memInvite struct {
+	models.PlaylistInvite
+	createdBy int
+}
+
+type memPlay struct {
+	// userID is 0 once the user's account was deleted
+	userID         int
+	trackID        int
+	playedAt       time.Time
+	durationPlayed int
+	completed      bool
+}
+
+// NewMemory returns an empty in-memory data layer. Seed its catalog with
+// AddArtist, AddAlbum and the TrackStore of Stores.
+func NewMemory() *Memory {
+	return &Memory{
+		artists:    map[int]models.Artist{},
+		albums:     map[int]models.Album{},
+		tracks:     map[int]models.Track{},
+		albumStats: map[int]*albumCounters{},
+		trackStats: map[int]*trackCounters{},
+		users:      map[int]*models.User{},
+		playlists:  map[int]*memPlaylist{},
+		invites:    map[string]*memInvite{},
+		covers:     map[int]PlaylistCover{},
+		files:      map[string]ScannedFile{},
+
+		savedTracks: map[int][]memSaved{},
+		savedAlbums: map[int][]memSaved{},
+		privacy:     map[int]*models.PrivacySettings{},
+		playback:    map[int]*models.PlaybackState{},
+		queues:      map[int][]models.QueueItem{},
+		sessions:    map[int]*memSession{},
+		stations:    map[int]*memStation{},
+		deletions:   map[int]models.AccountDeletion{},
+
+		lastID: map[string]int{},
+	}
+}
+
+// Stores returns the stores backed by m
+func (m *Memory) Stores() *Stores {
+	return &Stores{
+		Tracks:          &memTracks{m},
+		Artists:         &memArtists{m},
+		Albums:          &memAlbums{m},
+		Playlists:       &memPlaylists{m},
+		Invites:         &memInvites{m},
+		Covers:          &memCovers{m},
+		Users:           &memUsers{m},
+		Plays:           &memPlays{m},
+		Files:           &memFiles{m},
+		Library:         &memLibrary{m},
+		Social:          &memSocial{m},
+		Privacy:         &memPrivacy{m},
+		Player:          &memPlayer{m},
+		Sessions:        &memSessions{m},
+		Radio:           &memRadio{m},
+		Recommendations: &memRecommendations{m},
+		Accounts:        &memAccounts{m},
+	}
+}
+
+// AddArtist inserts an artist and returns its ID
+func (m *Memory) AddArtist(artist models.Artist) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	artist.ID = m.nextID("artists")
+	artist.CreatedAt = time.Now()
+	m.artists[artist.ID] = artist
+	return artist.ID
+}
+
+// AddAlbum inserts an album of an existing artist and returns its ID
+func (m *Memory) AddAlbum(album models.Album) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.artists[album.ArtistID]; !ok {
+		return 0, errForeignKey
+	}
+	album.ID = m.nextID("albums")
+	album.ArtistName = ""
+	album.CreatedAt = time.Now()
+	m.albums[album.ID] = album
+	return album.ID, nil
+}
+
+// DeleteTrack removes a track the way the after_track_delete trigger and the
+// ON DELETE CASCADE and SET NULL foreign keys do
+func (m *Memory) DeleteTrack(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	track, ok := m.tracks[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.tracks, id)
+
+	if counters, ok := m.albumStats[track.AlbumID]; ok {
+		counters.trackCount--
+		counters.totalDuration -= track.Duration
+	}
+	delete(m.trackStats, id)
+
+	m.plays = filter(m.plays, func(play memPlay) bool { return play.trackID != id })
+	for _, playlist := range m.playlists {
+		playlist.removeTrack(id)
+	}
+	for path, file := range m.files {
+		if file.TrackID == id {
+			file.TrackID = 0
+			m.files[path] = file
+		}
+	}
+
+	for userID, saved := range m.savedTracks {
+		m.savedTracks[userID] = removeSaved(saved, id)
+	}
+	m.activities = filter(m.activities, func(activity memActivity) bool { return activity.trackID != id })
+	for _, state := range m.playback {
+		if state.TrackID != nil && *state.TrackID == id {
+			state.TrackID = nil
+		}
+	}
+	for userID, queue := range m.queues {
+		m.queues[userID] = renumberQueue(filter(queue, func(item models.QueueItem) bool { return item.TrackID != id }))
+	}
+	for _, station := range m.stations {
+		station.tracks = filter(station.tracks, func(served memStationTrack) bool { return served.trackID != id })
+	}
+	m.skips = filter(m.skips, func(skip memSkip) bool { return skip.trackID != id })
+	return nil
+}
+
+// deletePlaylist removes a playlist with its invites, cover and the activity
+// about it. The caller holds the lock.
+func (m *Memory) deletePlaylist(id int) {
+	delete(m.playlists, id)
+	for token, invite := range m.invites {
+		if invite.PlaylistID == id {
+			delete(m.invites, token)
+		}
+	}
+	delete(m.covers, id)
+	m.activities = filter(m.activities, func(activity memActivity) bool { return activity.playlistID != id })
+}
+
+// deleteUser removes a user and everything that references them the way the
+// foreign keys do: owned rows go, plays and playlist entries the user added
+// lose their user. The caller holds the lock.
+func (m *Memory) deleteUser(id int) {
+	for playlistID, playlist := range m.playlists {
+		if playlist.UserID == id {
+			m.deletePlaylist(playlistID)
+			continue
+		}
+		delete(playlist.collaborators, id)
+		delete(playlist.followers, id)
+		for i := range playlist.entries {
+			if addedBy := playlist.entries[i].addedBy; addedBy != nil && *addedBy == id {
+				playlist.entries[i].addedBy = nil
+			}
+		}
+	}
+	for token, invite := range m.invites {
+		if invite.createdBy == id {
+			delete(m.invites, token)
+		}
+	}
+	for i := range m.plays {
+		if m.plays[i].userID == id {
+			m.plays[i].userID = 0
+		}
+	}
+
+	delete(m.savedTracks, id)
+	delete(m.savedAlbums, id)
+	m.follows = filter(m.follows, func(follow memFollow) bool {
+		return follow.followerID != id && follow.followeeID != id
+	})
+	m.activities = filter(m.activities, func(activity memActivity) bool { return activity.userID != id })
+	delete(m.privacy, id)
+	delete(m.playback, id)
+	delete(m.queues, id)
+	for sessionID, session := range m.sessions {
+		if session.hostID == id {
+			delete(m.sessions, sessionID)
+			continue
+		}
+		session.members = filter(session.members, func(member *memSessionMember) bool { return member.userID != id })
+		for vote := range session.votes {
+			if vote.userID == id {
+				delete(session.votes, vote)
+			}
+		}
+	}
+	for stationID, station := range m.stations {
+		if station.userID == id {
+			delete(m.stations, stationID)
+		}
+	}
+	m.skips = filter(m.skips, func(skip memSkip) bool { return skip.userID != id })
+	delete(m.deletions, id)
+	delete(m.users, id)
+}
+
+// excluded reports whether the user keeps their plays out of recommendations.
+// The caller holds the lock.
+func (m *Memory) excluded(userID int) bool {
+	settings, ok := m.privacy[userID]
+	return ok && settings.ExcludeFromRecommendations
+}
+
+// summary returns the public fields of a user. The caller holds the lock.
+func (m *Memory) summary(userID int) models.UserSummary {
+	user, ok := m.users[userID]
+	if !ok {
+		return models.UserSummary{ID: userID}
+	}
+	return models.UserSummary{
+		ID:                user.ID,
+		Username:          user.Username,
+		DisplayName:       user.DisplayName,
+		ProfilePictureURL: user.ProfilePictureURL,
+	}
+}
+
+// AddCollaborator invites a user to edit a playlist
+func (m *Memory) AddCollaborator(playlistID, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	playlist, ok := m.playlists[playlistID]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := m.users[userID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := playlist.collaborators[userID]; !ok {
+		playlist.collaborators[userID] = time.Now()
+	}
+	return nil
+}
+
+// PlayCount returns the track_stats play count of a track
+func (m *Memory) PlayCount(trackID int) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if counters, ok := m.trackStats[trackID]; ok {
+		return counters.playCount
+	}
+	return 0
+}
+
+// recommendable returns the recommendable play count of a track. The caller
+// holds the lock.
+func (m *Memory) recommendable(trackID int) int {
+	if counters, ok := m.trackStats[trackID]; ok {
+		return counters.recommendable
+	}
+	return 0
+}
+
+// nextID hands out AUTO_INCREMENT IDs per table. The caller holds the lock.
+func (m *Memory) nextID(table string) int {
+	m.lastID[table]++
+	return m.lastID[table]
+}
+
+// track returns a track with the artist and album names joined in. The caller
+// holds the lock.
+func (m *Memory) track(id int) (models.Track, bool) {
+	track, ok := m.tracks[id]
+	if !ok {
+		return track, false
+	}
+	track.ArtistName = m.artists[track.ArtistID].Name
+	track.AlbumName = m.albums[track.AlbumID].Title
+	return track, true
+}
+
+// trackList returns the tracks matching keep, joined as by track, newest
+// first. The caller holds the lock.
+func (m *Memory) trackList(keep func(models.Track) bool) []models.Track {
+	tracks := []models.Track{}
+	for id := range m.tracks {
+		track, _ := m.track(id)
+		if keep(track) {
+			tracks = append(tracks, track)
+		}
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		if !tracks[i].CreatedAt.Equal(tracks[j].CreatedAt) {
+			return tracks[i].CreatedAt.After(tracks[j].CreatedAt)
+		}
+		return tracks[i].ID > tracks[j].ID
+	})
+	return tracks
+}
+
+// album returns an album with the artist name joined in. The caller holds the
+// lock.
+func (m *Memory) album(id int) (models.Album, bool) {
+	album, ok := m.albums[id]
+	if !ok {
+		return album, false
+	}
+	album.ArtistName = m.artists[album.ArtistID].Name
+	return album, true
+}
+
+// filter keeps the items for which keep returns true, reusing the backing array
+func filter[T any](items []T, keep func(T) bool) []T {
+	kept := items[:0]
+	for _, item := range items {
+		if keep(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// like matches the way the default MySQL collation compares LIKE '%pattern%'
+func like(value, pattern string) bool {
+	return strings.Contains(strings.ToLower(value), strings.ToLower(pattern))
+}
+
+// page applies LIMIT and OFFSET
+func page[T any](items []T, limit, offset int) []T {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(items) {
+		return items[:0]
+	}
+	items = items[offset:]
+	if limit >= 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
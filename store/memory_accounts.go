@@ -0,0 +1,85 @@
+package store
+
+import (
+	"sort"
+	"spotify-clone/models"
+	"time"
+)
+
+type memAccounts struct {
+	m *Memory
+}
+
+func (s *memAccounts) ScheduleDeletion(userID int, grace time.Duration) (*models.AccountDeletion, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.users[userID]; !ok {
+		return nil, errForeignKey
+	}
+	deletion, ok := s.m.deletions[userID]
+	if !ok {
+		now := time.Now()
+		deletion = models.AccountDeletion{RequestedAt: now, DeleteAfter: now.Add(grace)}
+		s.m.deletions[userID] = deletion
+	}
+	return &deletion, nil
+}
+
+func (s *memAccounts) Deletion(userID int) (*models.AccountDeletion, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	deletion, ok := s.m.deletions[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &deletion, nil
+}
+
+func (s *memAccounts) CancelDeletion(userID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.deletions[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.m.deletions, userID)
+	return nil
+}
+
+func (s *memAccounts) DueDeletions() ([]int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	now := time.Now()
+	due := []int{}
+	for userID, deletion := range s.m.deletions {
+		if !deletion.DeleteAfter.After(now) {
+			due = append(due, userID)
+		}
+	}
+	sort.Ints(due)
+	return due, nil
+}
+
+func (s *memAccounts) Purge(userID int) ([]string, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	covers := []string{}
+	for playlistID, playlist := range s.m.playlists {
+		if playlist.UserID != userID {
+			continue
+		}
+		cover := s.m.covers[playlistID]
+		for _, name := range []string{cover.GeneratedFile, cover.UploadedFile} {
+			if name != "" {
+				covers = append(covers, name)
+			}
+		}
+	}
+	// deleteUser keeps the plays without the user, as the foreign key does
+	s.m.deleteUser(userID)
+	return covers, nil
+}
@@ -0,0 +1,545 @@
+package store
+
+import (
+	"math"
+	"sort"
+	"spotify-clone/models"
+	"strings"
+	"time"
+)
+
+type memTracks struct {
+	m *Memory
+}
+
+func (s *memTracks) List(filter TrackFilter) ([]models.Track, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	tracks := s.m.trackList(func(track models.Track) bool {
+		if filter.Genre != "" && !strings.EqualFold(track.Genre, filter.Genre) {
+			return false
+		}
+		return filter.Search == "" || like(track.Title, filter.Search) || like(track.ArtistName, filter.Search)
+	})
+	return page(tracks, filter.Limit, filter.Offset), nil
+}
+
+func (s *memTracks) Get(id int) (*models.Track, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	track, ok := s.m.track(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &track, nil
+}
+
+func (s *memTracks) GetMany(ids []int) ([]models.Track, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	tracks := make([]models.Track, 0, len(ids))
+	for _, id := range ids {
+		if track, ok := s.m.track(id); ok {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks, nil
+}
+
+func (s *memTracks) ByArtist(artistID int) ([]models.Track, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	tracks := s.m.trackList(func(track models.Track) bool {
+		return track.ArtistID == artistID
+	})
+	sort.SliceStable(tracks, func(i, j int) bool {
+		return tracks[i].ReleaseDate.After(tracks[j].ReleaseDate)
+	})
+	return tracks, nil
+}
+
+func (s *memTracks) Search(query string, limit int) ([]models.Track, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	tracks := s.m.trackList(func(track models.Track) bool {
+		return like(track.Title, query) || like(track.ArtistName, query)
+	})
+	sortTracksByID(tracks)
+	return page(tracks, limit, 0), nil
+}
+
+// Add applies the checks of the add_track procedure in the same order, then
+// bumps album_stats and creates track_stats as after_track_insert does
+func (s *memTracks) Add(track NewTrack) (int, string, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.artists[track.ArtistID]; !ok {
+		return 0, "ERROR: Artist does not exist", nil
+	}
+	album, ok := s.m.albums[track.AlbumID]
+	if !ok {
+		return 0, "ERROR: Album does not exist", nil
+	}
+	if album.ArtistID != track.ArtistID {
+		return 0, "ERROR: Album does not belong to the specified artist", nil
+	}
+
+	id := s.m.nextID("tracks")
+	s.m.tracks[id] = models.Track{
+		ID:          id,
+		Title:       track.Title,
+		ArtistID:    track.ArtistID,
+		AlbumID:     track.AlbumID,
+		Duration:    track.Duration,
+		Genre:       track.Genre,
+		ReleaseDate: track.ReleaseDate.Truncate(24 * time.Hour),
+		FileURL:     track.FileURL,
+		CoverURL:    track.CoverURL,
+		CreatedAt:   time.Now(),
+	}
+
+	counters, ok := s.m.albumStats[track.AlbumID]
+	if !ok {
+		counters = &albumCounters{}
+		s.m.albumStats[track.AlbumID] = counters
+	}
+	counters.trackCount++
+	counters.totalDuration += track.Duration
+	s.m.trackStats[id] = &trackCounters{}
+
+	return id, "SUCCESS: Track added successfully", nil
+}
+
+func (s *memTracks) IDsByTitle(albumID int, titles []string) (map[string]int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	ids := map[string]int{}
+	for id, track := range s.m.tracks {
+		if track.AlbumID == albumID {
+			matchKey(ids, titles, track.Title, id)
+		}
+	}
+	return ids, nil
+}
+
+// Update rejects a missing artist or album as the foreign keys do and moves
+// the album_stats counters as after_track_update does
+func (s *memTracks) Update(id int, track NewTrack) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	old, ok := s.m.tracks[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := s.m.artists[track.ArtistID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := s.m.albums[track.AlbumID]; !ok {
+		return errForeignKey
+	}
+
+	s.m.tracks[id] = models.Track{
+		ID:          id,
+		Title:       track.Title,
+		ArtistID:    track.ArtistID,
+		AlbumID:     track.AlbumID,
+		Duration:    track.Duration,
+		Genre:       track.Genre,
+		ReleaseDate: track.ReleaseDate.Truncate(24 * time.Hour),
+		FileURL:     track.FileURL,
+		CoverURL:    track.CoverURL,
+		CreatedAt:   old.CreatedAt,
+	}
+
+	if old.AlbumID != track.AlbumID || old.Duration != track.Duration {
+		if counters, ok := s.m.albumStats[old.AlbumID]; ok {
+			counters.trackCount--
+			counters.totalDuration -= old.Duration
+		}
+		counters, ok := s.m.albumStats[track.AlbumID]
+		if !ok {
+			counters = &albumCounters{}
+			s.m.albumStats[track.AlbumID] = counters
+		}
+		counters.trackCount++
+		counters.totalDuration += track.Duration
+	}
+	return nil
+}
+
+func (s *memTracks) Delete(id int) error {
+	return s.m.DeleteTrack(id)
+}
+
+func (s *memTracks) AlbumTrackIDs(albumID int) ([]int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	ids := []int{}
+	for id, track := range s.m.tracks {
+		if track.AlbumID == albumID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (s *memTracks) ArtistTrackIDs(artistID int) ([]int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	ids := []int{}
+	for id, track := range s.m.tracks {
+		if track.ArtistID == artistID {
+			ids = append(ids, id)
+		}
+	}
+	plays := func(id int) int {
+		if counters, ok := s.m.trackStats[id]; ok {
+			return counters.playCount
+		}
+		return 0
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if plays(ids[i]) != plays(ids[j]) {
+			return plays(ids[i]) > plays(ids[j])
+		}
+		return ids[i] < ids[j]
+	})
+	return ids, nil
+}
+
+func (s *memTracks) IDByFileURL(url string) (int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	ids := []int{}
+	for id, track := range s.m.tracks {
+		if track.FileURL == url {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, ErrNotFound
+	}
+	sort.Ints(ids)
+	return ids[0], nil
+}
+
+func (s *memTracks) MatchTitleOrArtist(titleWord, artist string, limit int) ([]models.Track, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	tracks := s.m.trackList(func(track models.Track) bool {
+		return like(track.Title, titleWord) || (artist != "" && like(track.ArtistName, artist))
+	})
+	sortTracksByID(tracks)
+	return page(tracks, limit, 0), nil
+}
+
+func sortTracksByID(tracks []models.Track) {
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].ID < tracks[j].ID })
+}
+
+type memArtists struct {
+	m *Memory
+}
+
+// list returns the artists matching keep by name. The caller holds the lock.
+func (s *memArtists) list(keep func(models.Artist) bool) []models.Artist {
+	artists := []models.Artist{}
+	for _, artist := range s.m.artists {
+		if keep(artist) {
+			artists = append(artists, artist)
+		}
+	}
+	sort.Slice(artists, func(i, j int) bool {
+		if !strings.EqualFold(artists[i].Name, artists[j].Name) {
+			return strings.ToLower(artists[i].Name) < strings.ToLower(artists[j].Name)
+		}
+		return artists[i].ID < artists[j].ID
+	})
+	return artists
+}
+
+func (s *memArtists) List(limit, offset int) ([]models.Artist, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	artists := s.list(func(models.Artist) bool { return true })
+	return page(artists, limit, offset), nil
+}
+
+func (s *memArtists) Get(id int) (*models.Artist, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	artist, ok := s.m.artists[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &artist, nil
+}
+
+func (s *memArtists) Search(query string, limit int) ([]models.Artist, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	artists := s.list(func(artist models.Artist) bool { return like(artist.Name, query) })
+	return page(artists, limit, 0), nil
+}
+
+func (s *memArtists) Existing(ids []int) ([]int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	existing := []int{}
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if _, ok := s.m.artists[id]; ok && !seen[id] {
+			seen[id] = true
+			existing = append(existing, id)
+		}
+	}
+	return existing, nil
+}
+
+// Stats computes what the get_artist_stats procedure selects
+func (s *memArtists) Stats(id int) (*models.ArtistStats, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	artist, ok := s.m.artists[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	stats := models.ArtistStats{ArtistID: artist.ID, ArtistName: artist.Name}
+
+	for _, album := range s.m.albums {
+		if album.ArtistID == id {
+			stats.TotalAlbums++
+		}
+	}
+
+	genres := map[string]bool{}
+	totalPlays := 0
+	for _, track := range s.m.tracks {
+		if track.ArtistID != id {
+			continue
+		}
+		stats.TotalTracks++
+		stats.TotalDurationSeconds += track.Duration
+		genres[track.Genre] = true
+		if stats.FirstRelease.IsZero() || track.ReleaseDate.Before(stats.FirstRelease) {
+			stats.FirstRelease = track.ReleaseDate
+		}
+		if track.ReleaseDate.After(stats.LatestRelease) {
+			stats.LatestRelease = track.ReleaseDate
+		}
+		if counters, ok := s.m.trackStats[track.ID]; ok {
+			totalPlays += counters.playCount
+		}
+	}
+
+	names := make([]string, 0, len(genres))
+	for genre := range genres {
+		names = append(names, genre)
+	}
+	sort.Strings(names)
+	stats.UniqueGenres = len(names)
+	stats.Genres = strings.Join(names, ", ")
+	stats.TotalDurationMinutes = math.Round(float64(stats.TotalDurationSeconds)/60*100) / 100
+	if stats.TotalTracks > 0 {
+		stats.AvgPlaysPerTrack = float64(totalPlays) / float64(stats.TotalTracks)
+	}
+	return &stats, nil
+}
+
+func (s *memArtists) IDsByName(names []string) (map[string]int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	ids := map[string]int{}
+	for id, artist := range s.m.artists {
+		matchKey(ids, names, artist.Name, id)
+	}
+	return ids, nil
+}
+
+func (s *memArtists) Create(artist *models.Artist) error {
+	artist.ID = s.m.AddArtist(*artist)
+	return nil
+}
+
+func (s *memArtists) Popular(genres []string, limit int) ([]models.Artist, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	plays := map[int]int{}
+	for _, track := range s.m.tracks {
+		if len(genres) > 0 && !containsFold(genres, track.Genre) {
+			continue
+		}
+		plays[track.ArtistID] += s.m.recommendable(track.ID)
+	}
+	artists := s.list(func(artist models.Artist) bool {
+		_, ok := plays[artist.ID]
+		return ok
+	})
+	sort.SliceStable(artists, func(i, j int) bool {
+		return plays[artists[i].ID] > plays[artists[j].ID]
+	})
+	return page(artists, limit, 0), nil
+}
+
+// containsFold reports whether values holds value, compared case-insensitively
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+type memAlbums struct {
+	m *Memory
+}
+
+// list returns the albums matching keep, latest release first. The caller
+// holds the lock.
+func (s *memAlbums) list(keep func(models.Album) bool) []models.Album {
+	albums := []models.Album{}
+	for id := range s.m.albums {
+		album, _ := s.m.album(id)
+		if keep(album) {
+			albums = append(albums, album)
+		}
+	}
+	sort.Slice(albums, func(i, j int) bool {
+		if !albums[i].ReleaseDate.Equal(albums[j].ReleaseDate) {
+			return albums[i].ReleaseDate.After(albums[j].ReleaseDate)
+		}
+		return albums[i].ID < albums[j].ID
+	})
+	return albums
+}
+
+func (s *memAlbums) List(limit, offset int) ([]models.Album, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	albums := s.list(func(models.Album) bool { return true })
+	return page(albums, limit, offset), nil
+}
+
+func (s *memAlbums) Get(id int) (*models.Album, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	album, ok := s.m.album(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &album, nil
+}
+
+func (s *memAlbums) Search(query string, limit int) ([]models.Album, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	albums := s.list(func(album models.Album) bool {
+		return like(album.Title, query) || like(album.ArtistName, query)
+	})
+	return page(albums, limit, 0), nil
+}
+
+func (s *memAlbums) Stats(id int) (*models.AlbumStats, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	album, ok := s.m.albums[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	stats := models.AlbumStats{AlbumID: album.ID, Title: album.Title}
+	if counters, ok := s.m.albumStats[id]; ok {
+		stats.TrackCount = counters.trackCount
+		stats.TotalDuration = counters.totalDuration
+	}
+	stats.DurationMinutes = math.Round(float64(stats.TotalDuration)/60*100) / 100
+	return &stats, nil
+}
+
+// Duration sums the tracks as the get_album_duration function does, which
+// yields 0 for an unknown album
+func (s *memAlbums) Duration(id int) (int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	total := 0
+	for _, track := range s.m.tracks {
+		if track.AlbumID == id {
+			total += track.Duration
+		}
+	}
+	return total, nil
+}
+
+func (s *memAlbums) IDsByTitle(artistID int, titles []string) (map[string]int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	ids := map[string]int{}
+	for id, album := range s.m.albums {
+		if album.ArtistID == artistID {
+			matchKey(ids, titles, album.Title, id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *memAlbums) Create(album *models.Album) error {
+	id, err := s.m.AddAlbum(*album)
+	if err != nil {
+		return err
+	}
+	album.ID = id
+	return nil
+}
+
+func (s *memAlbums) SetCover(id int, coverURL string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	album, ok := s.m.albums[id]
+	if !ok {
+		return ErrNotFound
+	}
+	album.CoverURL = coverURL
+	s.m.albums[id] = album
+	return nil
+}
+
+// matchKey records id under the lower-cased value when it is one of keys,
+// keeping the lowest ID as the ORDER BY id of the MySQL lookups does
+func matchKey(ids map[string]int, keys []string, value string, id int) {
+	for _, key := range keys {
+		if strings.EqualFold(key, value) {
+			lower := strings.ToLower(value)
+			if existing, ok := ids[lower]; !ok || id < existing {
+				ids[lower] = id
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"fmt"
+	"spotify-clone/models"
+	"strings"
+	"time"
+)
+
+type demoTrack struct {
+	title    string
+	duration int // in seconds
+	genre    string
+}
+
+// demoAlbum is an artist with one album, as in seed/seed_data.sql
+type demoAlbum struct {
+	artist   string
+	bio      string
+	title    string
+	released string
+	tracks   []demoTrack
+}
+
+var demoCatalog = []demoAlbum{
+	{"The Weeknd", "Canadian singer, songwriter, and record producer known for his distinctive voice and dark R&B style.",
+		"After Hours", "2020-03-20",
+		[]demoTrack{{"Blinding Lights", 200, "Pop"}, {"Save Your Tears", 215, "Pop"}, {"In Your Eyes", 237, "R&B"}}},
+	{"Taylor Swift", "American singer-songwriter known for narrative songwriting and genre versatility.",
+		"1989", "2014-10-27",
+		[]demoTrack{{"Shake It Off", 219, "Pop"}, {"Blank Space", 231, "Pop"}, {"Style", 231, "Pop"}}},
+	{"Drake", "Canadian rapper, singer, and songwriter, one of the best-selling music artists worldwide.",
+		"Certified Lover Boy", "2021-09-03",
+		[]demoTrack{{"Way 2 Sexy", 257, "Hip Hop"}, {"Girls Want Girls", 248, "Hip Hop"}, {"Champagne Poetry", 307, "Hip Hop"}}},
+	{"Daft Punk", "French electronic music duo known for their innovative approach to electronic music.",
+		"Random Access Memories", "2013-05-17",
+		[]demoTrack{{"Get Lucky", 369, "Electronic"}, {"Instant Crush", 337, "Electronic"}, {"Lose Yourself to Dance", 353, "Electronic"}}},
+}
+
+// SeedDemo fills m with a small catalog taken from seed/seed_data.sql
+func SeedDemo(m *Memory) error {
+	tracks := m.Stores().Tracks
+	for _, album := range demoCatalog {
+		released, err := time.Parse("2006-01-02", album.released)
+		if err != nil {
+			return err
+		}
+
+		artistID := m.AddArtist(models.Artist{Name: album.artist, Bio: album.bio})
+		albumID, err := m.AddAlbum(models.Album{Title: album.title, ArtistID: artistID, ReleaseDate: released})
+		if err != nil {
+			return err
+		}
+
+		for _, track := range album.tracks {
+			slug := strings.ReplaceAll(strings.ToLower(track.title), " ", "-")
+			_, status, err := tracks.Add(NewTrack{
+				Title:       track.title,
+				ArtistID:    artistID,
+				AlbumID:     albumID,
+				Duration:    track.duration,
+				Genre:       track.genre,
+				ReleaseDate: released,
+				FileURL:     "https://audio.example.com/" + slug + ".mp3",
+			})
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(status, "ERROR") {
+				return fmt.Errorf("error seeding %q: %s", track.title, status)
+			}
+		}
+	}
+	return nil
+}
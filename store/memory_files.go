@@ -0,0 +1,38 @@
+package store
+
+import "sort"
+
+type memFiles struct {
+	m *Memory
+}
+
+func (s *memFiles) List() ([]ScannedFile, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	files := make([]ScannedFile, 0, len(s.m.files))
+	for _, file := range s.m.files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+func (s *memFiles) Save(file ScannedFile) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.tracks[file.TrackID]; file.TrackID != 0 && !ok {
+		return errForeignKey
+	}
+	s.m.files[file.Path] = file
+	return nil
+}
+
+func (s *memFiles) Delete(path string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	delete(s.m.files, path)
+	return nil
+}
@@ -0,0 +1,258 @@
+package store
+
+import (
+	"sort"
+	"spotify-clone/models"
+	"time"
+)
+
+// memSaved is a row of user_saved_tracks or user_saved_albums. seq stands in
+// for the auto-increment id that breaks ties between items saved at once.
+type memSaved struct {
+	id      int
+	savedAt time.Time
+	seq     int
+}
+
+func removeSaved(saved []memSaved, id int) []memSaved {
+	return filter(saved, func(item memSaved) bool { return item.id != id })
+}
+
+// newestSaved orders saved items the way the library lists them by default
+func newestSaved(saved []memSaved) []memSaved {
+	sorted := append([]memSaved(nil), saved...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].savedAt.Equal(sorted[j].savedAt) {
+			return sorted[i].savedAt.After(sorted[j].savedAt)
+		}
+		return sorted[i].seq > sorted[j].seq
+	})
+	return sorted
+}
+
+type memLibrary struct {
+	m *Memory
+}
+
+// section returns the saved items of a section and whether the item exists in
+// the catalog. The caller holds the lock.
+func (s *memLibrary) section(section string, id int) (map[int][]memSaved, bool) {
+	switch section {
+	case LibraryTracks:
+		_, ok := s.m.tracks[id]
+		return s.m.savedTracks, ok
+	case LibraryAlbums:
+		_, ok := s.m.albums[id]
+		return s.m.savedAlbums, ok
+	}
+	return nil, false
+}
+
+func (s *memLibrary) Save(userID int, section string, id int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	user, ok := s.m.users[userID]
+	if !ok {
+		return errForeignKey
+	}
+	if section == LibraryArtists {
+		if _, ok := s.m.artists[id]; !ok {
+			return errForeignKey
+		}
+		if !containsInt(user.FavoriteArtists, id) {
+			user.FavoriteArtists = append(user.FavoriteArtists, id)
+		}
+		return nil
+	}
+
+	saved, ok := s.section(section, id)
+	if saved == nil {
+		return ErrNotFound
+	}
+	if !ok {
+		return errForeignKey
+	}
+	for _, item := range saved[userID] {
+		if item.id == id {
+			return nil
+		}
+	}
+	saved[userID] = append(saved[userID], memSaved{id: id, savedAt: time.Now(), seq: s.m.nextID("user_saved_" + section)})
+	return nil
+}
+
+func (s *memLibrary) Remove(userID int, section string, id int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if section == LibraryArtists {
+		user, ok := s.m.users[userID]
+		if !ok || !containsInt(user.FavoriteArtists, id) {
+			return ErrNotFound
+		}
+		user.FavoriteArtists = filter(user.FavoriteArtists, func(artistID int) bool { return artistID != id })
+		return nil
+	}
+
+	saved, _ := s.section(section, id)
+	if saved == nil {
+		return ErrNotFound
+	}
+	before := len(saved[userID])
+	saved[userID] = removeSaved(saved[userID], id)
+	if len(saved[userID]) == before {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *memLibrary) Contains(userID int, section string, ids []int) ([]int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	saved := map[int]bool{}
+	switch section {
+	case LibraryArtists:
+		if user, ok := s.m.users[userID]; ok {
+			for _, id := range user.FavoriteArtists {
+				saved[id] = true
+			}
+		}
+	case LibraryTracks, LibraryAlbums:
+		items, _ := s.section(section, 0)
+		for _, item := range items[userID] {
+			saved[item.id] = true
+		}
+	}
+
+	contained := []int{}
+	for _, id := range ids {
+		if saved[id] {
+			contained = append(contained, id)
+			saved[id] = false
+		}
+	}
+	return contained, nil
+}
+
+func (s *memLibrary) Tracks(userID int, filter LibraryFilter) ([]models.SavedTrack, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	tracks := []models.SavedTrack{}
+	for _, item := range newestSaved(s.m.savedTracks[userID]) {
+		track, ok := s.m.track(item.id)
+		if !ok {
+			continue
+		}
+		if filter.Genre != "" && track.Genre != filter.Genre {
+			continue
+		}
+		if filter.ArtistID != 0 && track.ArtistID != filter.ArtistID {
+			continue
+		}
+		if filter.Search != "" && !like(track.Title, filter.Search) &&
+			!like(track.ArtistName, filter.Search) && !like(track.AlbumName, filter.Search) {
+			continue
+		}
+		tracks = append(tracks, models.SavedTrack{Track: track, SavedAt: item.savedAt})
+	}
+
+	var less func(a, b models.SavedTrack) bool
+	switch filter.Sort {
+	case "title":
+		less = func(a, b models.SavedTrack) bool { return a.Title < b.Title }
+	case "artist":
+		less = func(a, b models.SavedTrack) bool {
+			if a.ArtistName != b.ArtistName {
+				return a.ArtistName < b.ArtistName
+			}
+			if a.AlbumName != b.AlbumName {
+				return a.AlbumName < b.AlbumName
+			}
+			return a.Title < b.Title
+		}
+	case "album":
+		less = func(a, b models.SavedTrack) bool {
+			if a.AlbumName != b.AlbumName {
+				return a.AlbumName < b.AlbumName
+			}
+			return a.ID < b.ID
+		}
+	case "duration":
+		less = func(a, b models.SavedTrack) bool { return a.Duration > b.Duration }
+	}
+	if less != nil {
+		sort.SliceStable(tracks, func(i, j int) bool { return less(tracks[i], tracks[j]) })
+	}
+	return page(tracks, filter.Limit, filter.Offset), nil
+}
+
+func (s *memLibrary) Albums(userID int, filter LibraryFilter) ([]models.SavedAlbum, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	albums := []models.SavedAlbum{}
+	for _, item := range newestSaved(s.m.savedAlbums[userID]) {
+		album, ok := s.m.album(item.id)
+		if !ok {
+			continue
+		}
+		if filter.Search != "" && !like(album.Title, filter.Search) && !like(album.ArtistName, filter.Search) {
+			continue
+		}
+		albums = append(albums, models.SavedAlbum{Album: album, SavedAt: item.savedAt})
+	}
+
+	var less func(a, b models.SavedAlbum) bool
+	switch filter.Sort {
+	case "title":
+		less = func(a, b models.SavedAlbum) bool { return a.Title < b.Title }
+	case "artist":
+		less = func(a, b models.SavedAlbum) bool {
+			if a.ArtistName != b.ArtistName {
+				return a.ArtistName < b.ArtistName
+			}
+			return a.ReleaseDate.Before(b.ReleaseDate)
+		}
+	case "release_date":
+		less = func(a, b models.SavedAlbum) bool { return a.ReleaseDate.After(b.ReleaseDate) }
+	}
+	if less != nil {
+		sort.SliceStable(albums, func(i, j int) bool { return less(albums[i], albums[j]) })
+	}
+	return page(albums, filter.Limit, filter.Offset), nil
+}
+
+// Artists lists favorites most recently added first, as the auto-increment
+// order of user_favorite_artists does
+func (s *memLibrary) Artists(userID int, filter LibraryFilter) ([]models.Artist, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	artists := []models.Artist{}
+	user, ok := s.m.users[userID]
+	if !ok {
+		return artists, nil
+	}
+	for i := len(user.FavoriteArtists) - 1; i >= 0; i-- {
+		artist, ok := s.m.artists[user.FavoriteArtists[i]]
+		if ok && (filter.Search == "" || like(artist.Name, filter.Search)) {
+			artists = append(artists, artist)
+		}
+	}
+	if filter.Sort == "name" {
+		sort.SliceStable(artists, func(i, j int) bool { return artists[i].Name < artists[j].Name })
+	}
+	return page(artists, filter.Limit, filter.Offset), nil
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"spotify-clone/models"
+	"time"
+)
+
+type memPlayer struct {
+	m *Memory
+}
+
+// state returns a copy of the user's playback state, or the idle state. The
+// caller holds the lock.
+func (s *memPlayer) state(userID int) *models.PlaybackState {
+	stored, ok := s.m.playback[userID]
+	if !ok {
+		return &models.PlaybackState{RepeatMode: "off"}
+	}
+	state := *stored
+	if stored.ContextID != nil {
+		state.ContextID = intPointer(*stored.ContextID)
+	}
+	if stored.TrackID != nil {
+		state.TrackID = intPointer(*stored.TrackID)
+	}
+	return &state
+}
+
+func (s *memPlayer) State(userID int) (*models.PlaybackState, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	return s.state(userID), nil
+}
+
+// memPlaybackTx is the queue of the user whose player Update holds
+type memPlaybackTx struct {
+	m      *Memory
+	userID int
+}
+
+func (q *memPlaybackTx) PopQueue() (int, bool, error) {
+	q.m.mu.Lock()
+	defer q.m.mu.Unlock()
+
+	queue := q.m.queues[q.userID]
+	if len(queue) == 0 {
+		return 0, false, nil
+	}
+	q.m.queues[q.userID] = renumberQueue(queue[1:])
+	return queue[0].TrackID, true, nil
+}
+
+// Update holds playerMu rather than a lock per user; the in-memory store
+// serves a single process where players rarely change at the same instant
+func (s *memPlayer) Update(userID int, change func(tx PlaybackTx, state *models.PlaybackState) error) error {
+	s.m.playerMu.Lock()
+	defer s.m.playerMu.Unlock()
+
+	s.m.mu.RLock()
+	_, exists := s.m.users[userID]
+	state := s.state(userID)
+	s.m.mu.RUnlock()
+	if !exists {
+		return errForeignKey
+	}
+
+	if err := change(&memPlaybackTx{m: s.m, userID: userID}, state); err != nil {
+		return err
+	}
+	state.UpdatedAt = time.Now()
+
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	if _, ok := s.m.users[userID]; !ok {
+		return errForeignKey
+	}
+	stored := *state
+	stored.Track = nil
+	stored.Queue = nil
+	stored.UpNext = nil
+	s.m.playback[userID] = &stored
+	return nil
+}
+
+func (s *memPlayer) Queue(userID int) ([]models.QueueItem, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	return append([]models.QueueItem{}, s.m.queues[userID]...), nil
+}
+
+func (s *memPlayer) Enqueue(userID, trackID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.users[userID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := s.m.tracks[trackID]; !ok {
+		return errForeignKey
+	}
+	queue := s.m.queues[userID]
+	s.m.queues[userID] = append(queue, models.QueueItem{
+		ID:       int64(s.m.nextID("playback_queue")),
+		TrackID:  trackID,
+		Position: len(queue),
+		AddedAt:  time.Now(),
+	})
+	return nil
+}
+
+func (s *memPlayer) RemoveFromQueue(userID int, itemID int64) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	queue := s.m.queues[userID]
+	for i, item := range queue {
+		if item.ID == itemID {
+			s.m.queues[userID] = renumberQueue(append(queue[:i], queue[i+1:]...))
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memPlayer) MoveInQueue(userID int, itemID int64, position int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	queue := s.m.queues[userID]
+	items := make(map[int64]models.QueueItem, len(queue))
+	order := make([]int64, len(queue))
+	for i, item := range queue {
+		items[item.ID] = item
+		order[i] = item.ID
+	}
+	order, err := moveInOrder(order, itemID, position)
+	if err != nil {
+		return err
+	}
+
+	moved := make([]models.QueueItem, len(order))
+	for i, id := range order {
+		moved[i] = items[id]
+	}
+	s.m.queues[userID] = renumberQueue(moved)
+	return nil
+}
+
+// renumberQueue sets dense positions on a queue in play order
+func renumberQueue(queue []models.QueueItem) []models.QueueItem {
+	for i := range queue {
+		queue[i].Position = i
+	}
+	return queue
+}
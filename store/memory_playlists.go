@@ -0,0 +1,717 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"spotify-clone/models"
+	"time"
+)
+
+type memPlaylists struct {
+	m *Memory
+}
+
+// view returns a copy of the playlist with the owner name and follower count
+// joined in; IsFollowing is seen from viewerID
+func (s *memPlaylists) view(playlist *memPlaylist, viewerID int) models.Playlist {
+	view := playlist.Playlist
+	view.OwnerName = s.m.users[view.UserID].DisplayName
+	view.TrackIDs = []int{}
+	view.IsSmart = playlist.smart != nil
+	view.FollowerCount = len(playlist.followers)
+	_, view.IsFollowing = playlist.followers[viewerID]
+	return view
+}
+
+func (s *memPlaylists) Create(playlist *models.Playlist) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	_, err := s.create(playlist)
+	return err
+}
+
+// create inserts a playlist and sets its ID. The caller holds the lock.
+func (s *memPlaylists) create(playlist *models.Playlist) (*memPlaylist, error) {
+	if _, ok := s.m.users[playlist.UserID]; !ok {
+		return nil, errForeignKey
+	}
+	now := time.Now()
+	playlist.ID = s.m.nextID("playlists")
+	created := &memPlaylist{
+		Playlist: models.Playlist{
+			ID:          playlist.ID,
+			UserID:      playlist.UserID,
+			Name:        playlist.Name,
+			Description: playlist.Description,
+			IsPublic:    playlist.IsPublic,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		collaborators: map[int]time.Time{},
+		followers:     map[int]time.Time{},
+	}
+	s.m.playlists[playlist.ID] = created
+	return created, nil
+}
+
+func (s *memPlaylists) Get(id, viewerID int) (*models.Playlist, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	playlist, ok := s.m.playlists[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	view := s.view(playlist, viewerID)
+	return &view, nil
+}
+
+func (s *memPlaylists) Update(id int, name, description string, isPublic bool) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	playlist, ok := s.m.playlists[id]
+	if !ok {
+		return nil
+	}
+	playlist.Name = name
+	playlist.Description = description
+	playlist.IsPublic = isPublic
+	playlist.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *memPlaylists) Delete(id int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.deletePlaylist(id)
+	return nil
+}
+
+func (s *memPlaylists) ListForUser(userID int) ([]models.Playlist, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	playlists := []models.Playlist{}
+	for _, playlist := range s.m.playlists {
+		_, collaborator := playlist.collaborators[userID]
+		_, follower := playlist.followers[userID]
+		if playlist.UserID != userID && !collaborator && !(playlist.IsPublic && follower) {
+			continue
+		}
+		view := s.view(playlist, userID)
+		if playlist.smart == nil {
+			view.TrackIDs = playlist.trackIDs()
+		}
+		playlists = append(playlists, view)
+	}
+	sort.Slice(playlists, func(i, j int) bool {
+		if !playlists[i].UpdatedAt.Equal(playlists[j].UpdatedAt) {
+			return playlists[i].UpdatedAt.After(playlists[j].UpdatedAt)
+		}
+		return playlists[i].ID > playlists[j].ID
+	})
+	return playlists, nil
+}
+
+func (s *memPlaylists) SearchPublic(query string, limit int) ([]models.Playlist, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	playlists := []models.Playlist{}
+	for _, playlist := range s.m.playlists {
+		if playlist.IsPublic && like(playlist.Name, query) {
+			playlists = append(playlists, s.view(playlist, 0))
+		}
+	}
+	sort.Slice(playlists, func(i, j int) bool {
+		if playlists[i].FollowerCount != playlists[j].FollowerCount {
+			return playlists[i].FollowerCount > playlists[j].FollowerCount
+		}
+		return playlists[i].ID < playlists[j].ID
+	})
+	return page(playlists, limit, 0), nil
+}
+
+func (s *memPlaylists) BrowsePublic(search, order string, limit, offset int) ([]models.Playlist, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	playlists := s.list(func(playlist *memPlaylist) bool {
+		return playlist.IsPublic && (search == "" || like(playlist.Name, search) || like(playlist.Description, search))
+	})
+	if order != "recent" {
+		sort.SliceStable(playlists, func(i, j int) bool {
+			return playlists[i].FollowerCount > playlists[j].FollowerCount
+		})
+	}
+	return page(playlists, limit, offset), nil
+}
+
+func (s *memPlaylists) PublicByOwner(ownerID int) ([]models.Playlist, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	return s.list(func(playlist *memPlaylist) bool {
+		return playlist.UserID == ownerID && playlist.IsPublic
+	}), nil
+}
+
+// list returns the playlists matching keep, seen by no one, most recently
+// updated first. The caller holds the lock.
+func (s *memPlaylists) list(keep func(*memPlaylist) bool) []models.Playlist {
+	playlists := []models.Playlist{}
+	for _, playlist := range s.m.playlists {
+		if keep(playlist) {
+			playlists = append(playlists, s.view(playlist, 0))
+		}
+	}
+	sort.Slice(playlists, func(i, j int) bool {
+		if !playlists[i].UpdatedAt.Equal(playlists[j].UpdatedAt) {
+			return playlists[i].UpdatedAt.After(playlists[j].UpdatedAt)
+		}
+		return playlists[i].ID > playlists[j].ID
+	})
+	return playlists
+}
+
+func (s *memPlaylists) Access(id, userID int) (*PlaylistAccess, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	playlist, ok := s.m.playlists[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	_, collaborator := playlist.collaborators[userID]
+	return &PlaylistAccess{
+		OwnerID:        playlist.UserID,
+		IsPublic:       playlist.IsPublic,
+		IsCollaborator: collaborator,
+		IsSmart:        playlist.smart != nil,
+	}, nil
+}
+
+func (s *memPlaylists) Collaborators(id int) ([]models.PlaylistCollaborator, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	collaborators := []models.PlaylistCollaborator{}
+	playlist, ok := s.m.playlists[id]
+	if !ok {
+		return collaborators, nil
+	}
+	for userID, addedAt := range playlist.collaborators {
+		user := s.m.users[userID]
+		collaborators = append(collaborators, models.PlaylistCollaborator{
+			UserID:      userID,
+			Username:    user.Username,
+			DisplayName: user.DisplayName,
+			AddedAt:     addedAt,
+		})
+	}
+	sort.Slice(collaborators, func(i, j int) bool {
+		if !collaborators[i].AddedAt.Equal(collaborators[j].AddedAt) {
+			return collaborators[i].AddedAt.Before(collaborators[j].AddedAt)
+		}
+		return collaborators[i].UserID < collaborators[j].UserID
+	})
+	return collaborators, nil
+}
+
+func (s *memPlaylists) Tracks(id int) ([]models.Track, error) {
+	tracks, _, err := s.Items(id)
+	return tracks, err
+}
+
+func (s *memPlaylists) Items(id int) ([]models.Track, []models.PlaylistItem, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	tracks := []models.Track{}
+	items := []models.PlaylistItem{}
+	playlist, ok := s.m.playlists[id]
+	if !ok {
+		return tracks, items, nil
+	}
+	for _, entry := range playlist.sorted() {
+		track, ok := s.m.track(entry.trackID)
+		if !ok {
+			continue
+		}
+		addedAt := entry.addedAt
+		item := models.PlaylistItem{TrackID: entry.trackID, Position: entry.position, AddedAt: &addedAt}
+		if entry.addedBy != nil {
+			addedBy := *entry.addedBy
+			item.AddedBy = &addedBy
+			if user, ok := s.m.users[addedBy]; ok {
+				item.AddedByName = user.DisplayName
+			}
+		}
+		tracks = append(tracks, track)
+		items = append(items, item)
+	}
+	return tracks, items, nil
+}
+
+func (s *memPlaylists) AddTrack(id, trackID, addedBy int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	playlist, ok := s.m.playlists[id]
+	if !ok {
+		return errForeignKey
+	}
+	if _, ok := s.m.tracks[trackID]; !ok {
+		return errForeignKey
+	}
+
+	position := -1
+	for _, entry := range playlist.entries {
+		if entry.position > position {
+			position = entry.position
+		}
+	}
+	position++
+
+	// Like ON DUPLICATE KEY UPDATE position, a track already in the playlist
+	// only moves to the end
+	for i := range playlist.entries {
+		if playlist.entries[i].trackID == trackID {
+			playlist.entries[i].position = position
+			playlist.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+
+	entry := memPlaylistEntry{trackID: trackID, position: position, addedAt: time.Now()}
+	if _, ok := s.m.users[addedBy]; ok {
+		entry.addedBy = &addedBy
+	}
+	playlist.entries = append(playlist.entries, entry)
+	playlist.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *memPlaylists) RemoveTrack(id, trackID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if playlist, ok := s.m.playlists[id]; ok {
+		playlist.removeTrack(trackID)
+		playlist.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (s *memPlaylists) MoveTrack(id, trackID, position int) ([]int, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	playlist, ok := s.m.playlists[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	order, err := moveInOrder(playlist.trackIDs(), trackID, position)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rewrite positions densely so gaps left by removals disappear
+	positions := make(map[int]int, len(order))
+	for i, trackID := range order {
+		positions[trackID] = i
+	}
+	for i := range playlist.entries {
+		playlist.entries[i].position = positions[playlist.entries[i].trackID]
+	}
+	playlist.UpdatedAt = time.Now()
+	return order, nil
+}
+
+func (s *memPlaylists) CreateWithTracks(playlist *models.Playlist, trackIDs []int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, trackID := range trackIDs {
+		if _, ok := s.m.tracks[trackID]; !ok {
+			return errForeignKey
+		}
+	}
+	created, err := s.create(playlist)
+	if err != nil {
+		return err
+	}
+	ownerID := playlist.UserID
+	for position, trackID := range trackIDs {
+		created.entries = append(created.entries, memPlaylistEntry{
+			trackID:  trackID,
+			position: position,
+			addedBy:  &ownerID,
+			addedAt:  created.CreatedAt,
+		})
+	}
+	return nil
+}
+
+func (s *memPlaylists) Follow(id, userID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	playlist, ok := s.m.playlists[id]
+	if !ok {
+		return errForeignKey
+	}
+	if _, ok := s.m.users[userID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := playlist.followers[userID]; !ok {
+		playlist.followers[userID] = time.Now()
+	}
+	return nil
+}
+
+func (s *memPlaylists) Unfollow(id, userID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	playlist, ok := s.m.playlists[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := playlist.followers[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(playlist.followers, userID)
+	return nil
+}
+
+func (s *memPlaylists) FollowerCount(id int) (int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	if playlist, ok := s.m.playlists[id]; ok {
+		return len(playlist.followers), nil
+	}
+	return 0, nil
+}
+
+func (s *memPlaylists) AddCollaborator(id, userID int) error {
+	if err := s.m.AddCollaborator(id, userID); err != ErrNotFound {
+		return err
+	}
+	return errForeignKey
+}
+
+func (s *memPlaylists) RemoveCollaborator(id, userID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	playlist, ok := s.m.playlists[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := playlist.collaborators[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(playlist.collaborators, userID)
+	return nil
+}
+
+func (s *memPlaylists) SetCoverURL(id int, coverURL string, edited bool) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if playlist, ok := s.m.playlists[id]; ok {
+		playlist.CoverURL = coverURL
+		if edited {
+			playlist.UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (s *memPlaylists) CreateSmart(playlist *models.Playlist, def models.SmartPlaylistDefinition) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	created, err := s.create(playlist)
+	if err != nil {
+		return err
+	}
+	created.smart = copyDefinition(def)
+	playlist.IsSmart = true
+	return nil
+}
+
+func (s *memPlaylists) SmartDefinition(id int) (*models.SmartPlaylistDefinition, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	playlist, ok := s.m.playlists[id]
+	if !ok || playlist.smart == nil {
+		return nil, ErrNotFound
+	}
+	return copyDefinition(*playlist.smart), nil
+}
+
+func (s *memPlaylists) SetSmartDefinition(id int, def models.SmartPlaylistDefinition) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if playlist, ok := s.m.playlists[id]; ok && playlist.smart != nil {
+		playlist.smart = copyDefinition(def)
+		playlist.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// copyDefinition copies def the way storing it as JSON does
+func copyDefinition(def models.SmartPlaylistDefinition) *models.SmartPlaylistDefinition {
+	rules := make([]models.SmartPlaylistRule, len(def.Rules))
+	for i, rule := range def.Rules {
+		rule.Values = append([]string(nil), rule.Values...)
+		rule.IDs = append([]int(nil), rule.IDs...)
+		rules[i] = rule
+	}
+	def.Rules = rules
+	return &def
+}
+
+func (s *memPlaylists) SmartTracks(def *models.SmartPlaylistDefinition, listenerID int) ([]models.Track, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	var ruleErr error
+	tracks := s.m.trackList(func(track models.Track) bool {
+		for _, rule := range def.Rules {
+			matched, err := s.matchesRule(rule, track, listenerID)
+			if err != nil {
+				ruleErr = err
+				return false
+			}
+			if def.Match == "any" && matched {
+				return true
+			}
+			if def.Match != "any" && !matched {
+				return false
+			}
+		}
+		return def.Match != "any"
+	})
+	if ruleErr != nil {
+		return nil, ruleErr
+	}
+
+	sortTracksByID(tracks)
+	switch def.OrderBy {
+	case "play_count":
+		sort.SliceStable(tracks, func(i, j int) bool {
+			return s.m.recommendable(tracks[i].ID) > s.m.recommendable(tracks[j].ID)
+		})
+	case "title":
+		sort.SliceStable(tracks, func(i, j int) bool { return tracks[i].Title < tracks[j].Title })
+	case "recently_added":
+		sort.SliceStable(tracks, func(i, j int) bool { return tracks[i].CreatedAt.After(tracks[j].CreatedAt) })
+	case "random":
+		return spreadTracks(tracks, def.Seed, def.Limit), nil
+	default:
+		sort.SliceStable(tracks, func(i, j int) bool { return tracks[i].ReleaseDate.After(tracks[j].ReleaseDate) })
+	}
+	return page(tracks, def.Limit, 0), nil
+}
+
+// matchesRule is smartRuleCondition for a single track. The caller holds the
+// lock.
+func (s *memPlaylists) matchesRule(rule models.SmartPlaylistRule, track models.Track, listenerID int) (bool, error) {
+	switch rule.Field {
+	case "genre":
+		// Like NOT IN, a track without a genre is in no list
+		in := track.Genre != "" && containsFold(rule.Values, track.Genre)
+		switch rule.Operator {
+		case "in":
+			return in, nil
+		case "not_in":
+			return !in, nil
+		}
+
+	case "artist":
+		in := false
+		for _, id := range rule.IDs {
+			in = in || id == track.ArtistID
+		}
+		switch rule.Operator {
+		case "in":
+			return in, nil
+		case "not_in":
+			return !in, nil
+		}
+
+	case "release_date":
+		date, err := time.Parse("2006-01-02", rule.Date)
+		if err != nil {
+			return false, err
+		}
+		switch rule.Operator {
+		case "after":
+			return track.ReleaseDate.After(date), nil
+		case "before":
+			return track.ReleaseDate.Before(date), nil
+		}
+
+	case "play_count":
+		plays := s.m.recommendable(track.ID)
+		switch rule.Operator {
+		case "gt":
+			return plays > rule.Number, nil
+		case "lt":
+			return plays < rule.Number, nil
+		}
+
+	case "played_by_me":
+		since := time.Now().AddDate(0, 0, -rule.Number)
+		played := false
+		for _, play := range s.m.plays {
+			if play.trackID == track.ID && play.userID == listenerID && !play.playedAt.Before(since) {
+				played = true
+				break
+			}
+		}
+		switch rule.Operator {
+		case "within_days":
+			return listenerID != 0 && played, nil
+		case "not_within_days":
+			return listenerID != 0 && !played, nil
+		}
+	}
+	return false, fmt.Errorf("unsupported smart playlist rule %s %s", rule.Field, rule.Operator)
+}
+
+// sorted returns the entries by position
+func (p *memPlaylist) sorted() []memPlaylistEntry {
+	entries := append([]memPlaylistEntry(nil), p.entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].position < entries[j].position })
+	return entries
+}
+
+func (p *memPlaylist) trackIDs() []int {
+	ids := []int{}
+	for _, entry := range p.sorted() {
+		ids = append(ids, entry.trackID)
+	}
+	return ids
+}
+
+func (p *memPlaylist) removeTrack(trackID int) {
+	entries := p.entries[:0]
+	for _, entry := range p.entries {
+		if entry.trackID != trackID {
+			entries = append(entries, entry)
+		}
+	}
+	p.entries = entries
+}
+
+type memInvites struct {
+	m *Memory
+}
+
+func (s *memInvites) Create(invite models.PlaylistInvite, createdBy int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.playlists[invite.PlaylistID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := s.m.users[createdBy]; !ok {
+		return errForeignKey
+	}
+	if _, ok := s.m.invites[invite.Token]; ok {
+		return ErrConflict
+	}
+	invite.CreatedAt = time.Now()
+	s.m.invites[invite.Token] = &memInvite{PlaylistInvite: invite, createdBy: createdBy}
+	return nil
+}
+
+func (s *memInvites) Get(token string) (*models.PlaylistInvite, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	invite, ok := s.m.invites[token]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := invite.PlaylistInvite
+	return &found, nil
+}
+
+func (s *memInvites) Active(playlistID int) ([]models.PlaylistInvite, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	now := time.Now()
+	invites := []models.PlaylistInvite{}
+	for _, invite := range s.m.invites {
+		if invite.PlaylistID == playlistID && invite.ExpiresAt.After(now) {
+			invites = append(invites, invite.PlaylistInvite)
+		}
+	}
+	sort.Slice(invites, func(i, j int) bool {
+		if !invites[i].CreatedAt.Equal(invites[j].CreatedAt) {
+			return invites[i].CreatedAt.After(invites[j].CreatedAt)
+		}
+		return invites[i].Token < invites[j].Token
+	})
+	return invites, nil
+}
+
+func (s *memInvites) Revoke(playlistID int, token string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	invite, ok := s.m.invites[token]
+	if !ok || invite.PlaylistID != playlistID {
+		return ErrNotFound
+	}
+	delete(s.m.invites, token)
+	return nil
+}
+
+type memCovers struct {
+	m *Memory
+}
+
+func (s *memCovers) Get(playlistID int) (PlaylistCover, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	return s.m.covers[playlistID], nil
+}
+
+func (s *memCovers) SetUploaded(playlistID int, file string) error {
+	return s.update(playlistID, func(cover *PlaylistCover) { cover.UploadedFile = file })
+}
+
+func (s *memCovers) SetGenerated(playlistID int, file, sourceHash string) error {
+	return s.update(playlistID, func(cover *PlaylistCover) {
+		cover.GeneratedFile = file
+		cover.SourceHash = sourceHash
+	})
+}
+
+// update changes the cover row of a playlist, creating it as the upserts do
+func (s *memCovers) update(playlistID int, change func(cover *PlaylistCover)) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.playlists[playlistID]; !ok {
+		return errForeignKey
+	}
+	cover := s.m.covers[playlistID]
+	change(&cover)
+	s.m.covers[playlistID] = cover
+	return nil
+}
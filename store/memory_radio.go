@@ -0,0 +1,244 @@
+package store
+
+import (
+	"sort"
+	"spotify-clone/models"
+	"time"
+)
+
+// memStation is a row of radio_stations with the tracks it served
+type memStation struct {
+	models.RadioStation
+	userID int
+	tracks []memStationTrack
+}
+
+// memStationTrack is a row of radio_station_tracks
+type memStationTrack struct {
+	trackID  int
+	batch    int
+	servedAt time.Time
+}
+
+// memSkip is a row of track_skips
+type memSkip struct {
+	userID    int
+	trackID   int
+	skippedAt time.Time
+}
+
+type memRadio struct {
+	m *Memory
+}
+
+// station returns the user's station. The caller holds the lock.
+func (s *memRadio) station(id, userID int) (*memStation, bool) {
+	station, ok := s.m.stations[id]
+	if !ok || station.userID != userID {
+		return nil, false
+	}
+	return station, true
+}
+
+func (s *memRadio) Create(userID int, station *models.RadioStation) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.users[userID]; !ok {
+		return errForeignKey
+	}
+	now := time.Now()
+	station.ID = s.m.nextID("radio_stations")
+	station.TracksServed = 0
+	station.CreatedAt = now
+	station.UpdatedAt = now
+	stored := &memStation{RadioStation: *station, userID: userID}
+	if station.SeedID != nil {
+		stored.SeedID = intPointer(*station.SeedID)
+	}
+	s.m.stations[station.ID] = stored
+	return nil
+}
+
+func (s *memRadio) Get(id, userID int) (*models.RadioStation, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	station, ok := s.station(id, userID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := station.RadioStation
+	return &found, nil
+}
+
+func (s *memRadio) Delete(id, userID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.station(id, userID); !ok {
+		return ErrNotFound
+	}
+	delete(s.m.stations, id)
+	return nil
+}
+
+func (s *memRadio) Served(id, userID, trackID int) (bool, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	station, ok := s.station(id, userID)
+	if !ok {
+		return false, nil
+	}
+	for _, served := range station.tracks {
+		if served.trackID == trackID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RecentTracks walks the served tracks backwards, as they are recorded in
+// batch order
+func (s *memRadio) RecentTracks(id, limit int) ([]int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	trackIDs := []int{}
+	station, ok := s.m.stations[id]
+	if !ok {
+		return trackIDs, nil
+	}
+	for i := len(station.tracks) - 1; i >= 0 && len(trackIDs) < limit; i-- {
+		trackIDs = append(trackIDs, station.tracks[i].trackID)
+	}
+	return trackIDs, nil
+}
+
+func (s *memRadio) NextBatch(id, userID int, pick func(station *models.RadioStation, batch int) ([]int, error)) (*models.RadioStation, error) {
+	s.m.radioMu.Lock()
+	defer s.m.radioMu.Unlock()
+
+	s.m.mu.RLock()
+	stored, ok := s.station(id, userID)
+	var station models.RadioStation
+	batch := 1
+	if ok {
+		station = stored.RadioStation
+		if len(stored.tracks) > 0 {
+			batch = stored.tracks[len(stored.tracks)-1].batch + 1
+		}
+	}
+	s.m.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	trackIDs, err := pick(&station, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	stored, ok = s.station(id, userID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	for _, trackID := range trackIDs {
+		if _, ok := s.m.tracks[trackID]; !ok {
+			return nil, errForeignKey
+		}
+	}
+	now := time.Now()
+	for _, trackID := range trackIDs {
+		stored.tracks = append(stored.tracks, memStationTrack{trackID: trackID, batch: batch, servedAt: now})
+	}
+	stored.TracksServed += len(trackIDs)
+	stored.UpdatedAt = now
+
+	updated := stored.RadioStation
+	return &updated, nil
+}
+
+func (s *memRadio) ForgetBatches(id, batch int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if station, ok := s.m.stations[id]; ok {
+		station.tracks = filter(station.tracks, func(served memStationTrack) bool { return served.batch >= batch })
+	}
+	return nil
+}
+
+func (s *memRadio) RecordSkip(userID, trackID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.users[userID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := s.m.tracks[trackID]; !ok {
+		return errForeignKey
+	}
+	s.m.skips = append(s.m.skips, memSkip{userID: userID, trackID: trackID, skippedAt: time.Now()})
+	return nil
+}
+
+func (s *memRadio) RecentSkipsByArtist(userID int, since time.Time) (map[int]int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	skips := map[int]int{}
+	for _, skip := range s.m.skips {
+		if track, ok := s.m.tracks[skip.trackID]; ok && skip.userID == userID && !skip.skippedAt.Before(since) {
+			skips[track.ArtistID]++
+		}
+	}
+	return skips, nil
+}
+
+func (s *memRadio) Stations(userID int) ([]models.ExportedRadioStation, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	stations := []models.ExportedRadioStation{}
+	for _, station := range s.m.stations {
+		if station.userID != userID {
+			continue
+		}
+		exported := models.ExportedRadioStation{RadioStation: station.RadioStation, Tracks: []models.ExportedRadioTrack{}}
+		for _, served := range station.tracks {
+			exported.Tracks = append(exported.Tracks, models.ExportedRadioTrack{
+				TrackID: served.trackID, Batch: served.batch, ServedAt: served.servedAt,
+			})
+		}
+		stations = append(stations, exported)
+	}
+	sort.Slice(stations, func(i, j int) bool { return stations[i].ID < stations[j].ID })
+	return stations, nil
+}
+
+func (s *memRadio) Skips(userID int) ([]models.ExportedSkip, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	skips := []models.ExportedSkip{}
+	for _, skip := range s.m.skips {
+		if skip.userID != userID {
+			continue
+		}
+		track, ok := s.m.track(skip.trackID)
+		if !ok {
+			continue
+		}
+		skips = append(skips, models.ExportedSkip{
+			SkippedAt: skip.skippedAt,
+			TrackID:   track.ID,
+			Title:     track.Title,
+			Artist:    track.ArtistName,
+		})
+	}
+	return skips, nil
+}
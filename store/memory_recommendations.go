@@ -0,0 +1,210 @@
+package store
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type memRecommendations struct {
+	m *Memory
+}
+
+// candidate reports whether filter lets a track through. The caller holds the
+// lock.
+func (s *memRecommendations) candidate(trackID int, filter CandidateFilter) bool {
+	if station, ok := s.m.stations[filter.StationID]; filter.StationID != 0 && ok {
+		for _, served := range station.tracks {
+			if served.trackID == trackID {
+				return false
+			}
+		}
+	}
+	if filter.SkippedBy != 0 {
+		for _, skip := range s.m.skips {
+			if skip.userID == filter.SkippedBy && skip.trackID == trackID && !skip.skippedAt.Before(filter.SkippedSince) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// counted reports whether a play counts towards recommendations. The caller
+// holds the lock.
+func (s *memRecommendations) counted(play memPlay) bool {
+	return play.userID != 0 && !s.m.excluded(play.userID)
+}
+
+// sortScored orders suggestions by score, then by less
+func sortScored(tracks []ScoredTrack, less func(a, b ScoredTrack) bool) {
+	sort.Slice(tracks, func(i, j int) bool {
+		if tracks[i].Score != tracks[j].Score {
+			return tracks[i].Score > tracks[j].Score
+		}
+		return less(tracks[i], tracks[j])
+	})
+}
+
+func (s *memRecommendations) Similar(seeds []int, filter CandidateFilter, limit int) ([]ScoredTrack, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	isSeed := map[int]bool{}
+	artists, genres := map[int]bool{}, map[string]bool{}
+	for _, id := range seeds {
+		isSeed[id] = true
+		if track, ok := s.m.tracks[id]; ok {
+			artists[track.ArtistID] = true
+			if track.Genre != "" {
+				genres[track.Genre] = true
+			}
+		}
+	}
+	// None of the seeds exist
+	if len(artists) == 0 {
+		return []ScoredTrack{}, nil
+	}
+
+	since := time.Now().AddDate(0, 0, -180)
+	seedListeners := map[int]bool{}
+	for _, play := range s.m.plays {
+		if isSeed[play.trackID] && play.userID != 0 && !play.playedAt.Before(since) {
+			seedListeners[play.userID] = true
+		}
+	}
+	coListeners := map[int]map[int]bool{}
+	for _, play := range s.m.plays {
+		if !seedListeners[play.userID] || play.playedAt.Before(since) || !s.counted(play) {
+			continue
+		}
+		if coListeners[play.trackID] == nil {
+			coListeners[play.trackID] = map[int]bool{}
+		}
+		coListeners[play.trackID][play.userID] = true
+	}
+
+	tracks := []ScoredTrack{}
+	for _, track := range s.m.tracks {
+		if isSeed[track.ID] || !s.candidate(track.ID, filter) {
+			continue
+		}
+		score := 0.0
+		if artists[track.ArtistID] {
+			score += 3
+		}
+		if genres[track.Genre] {
+			score += 2
+		}
+		if listeners := len(coListeners[track.ID]); listeners > 0 {
+			score += math.Log(1+float64(listeners)) * 2
+		}
+		if score > 0 {
+			tracks = append(tracks, ScoredTrack{ID: track.ID, ArtistID: track.ArtistID, Score: score})
+		}
+	}
+	sortScored(tracks, func(a, b ScoredTrack) bool { return a.ID < b.ID })
+	return page(tracks, limit, 0), nil
+}
+
+func (s *memRecommendations) Popular(filter CandidateFilter, limit int) ([]ScoredTrack, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	tracks := []ScoredTrack{}
+	for _, track := range s.m.tracks {
+		if s.candidate(track.ID, filter) {
+			tracks = append(tracks, ScoredTrack{ID: track.ID, ArtistID: track.ArtistID, Score: float64(s.m.recommendable(track.ID))})
+		}
+	}
+	sortScored(tracks, func(a, b ScoredTrack) bool {
+		createdA, createdB := s.m.tracks[a.ID].CreatedAt, s.m.tracks[b.ID].CreatedAt
+		if !createdA.Equal(createdB) {
+			return createdA.After(createdB)
+		}
+		return a.ID > b.ID
+	})
+	return page(tracks, limit, 0), nil
+}
+
+func (s *memRecommendations) GenreTop(genre string, limit int) ([]int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	tracks := []ScoredTrack{}
+	for _, track := range s.m.tracks {
+		if strings.EqualFold(track.Genre, genre) {
+			tracks = append(tracks, ScoredTrack{ID: track.ID, Score: float64(s.m.recommendable(track.ID))})
+		}
+	}
+	sortScored(tracks, func(a, b ScoredTrack) bool { return a.ID < b.ID })
+	return scoredIDs(page(tracks, limit, 0)), nil
+}
+
+// ForLibrary weighs favorite artists 3, saved tracks and albums 2, and each play
+// in the last 90 days 1
+func (s *memRecommendations) ForLibrary(userID, limit int) ([]int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	user, ok := s.m.users[userID]
+	if !ok {
+		return []int{}, nil
+	}
+	artistWeight, genreWeight := map[int]float64{}, map[string]float64{}
+	for _, artistID := range user.FavoriteArtists {
+		artistWeight[artistID] += 3
+	}
+	for _, genre := range user.FavoriteGenres {
+		genreWeight[genre] += 2
+	}
+	saved := map[int]bool{}
+	for _, item := range s.m.savedTracks[userID] {
+		saved[item.id] = true
+		if track, ok := s.m.tracks[item.id]; ok {
+			artistWeight[track.ArtistID] += 2
+			if track.Genre != "" {
+				genreWeight[track.Genre] += 2
+			}
+		}
+	}
+	for _, item := range s.m.savedAlbums[userID] {
+		if album, ok := s.m.albums[item.id]; ok {
+			artistWeight[album.ArtistID] += 2
+		}
+	}
+	since := time.Now().AddDate(0, 0, -90)
+	for _, play := range s.m.plays {
+		if play.userID != userID || play.playedAt.Before(since) || !s.counted(play) {
+			continue
+		}
+		if track, ok := s.m.tracks[play.trackID]; ok {
+			artistWeight[track.ArtistID]++
+			if track.Genre != "" {
+				genreWeight[track.Genre]++
+			}
+		}
+	}
+
+	tracks := []ScoredTrack{}
+	for _, track := range s.m.tracks {
+		artist, byArtist := artistWeight[track.ArtistID]
+		genre, byGenre := genreWeight[track.Genre]
+		if saved[track.ID] || (!byArtist && !byGenre) {
+			continue
+		}
+		score := artist + genre*0.5 + math.Log(1+float64(s.m.recommendable(track.ID)))*0.1
+		tracks = append(tracks, ScoredTrack{ID: track.ID, Score: score})
+	}
+	sortScored(tracks, func(a, b ScoredTrack) bool { return a.ID < b.ID })
+	return scoredIDs(page(tracks, limit, 0)), nil
+}
+
+func scoredIDs(tracks []ScoredTrack) []int {
+	ids := make([]int, len(tracks))
+	for i, track := range tracks {
+		ids[i] = track.ID
+	}
+	return ids
+}
@@ -0,0 +1,265 @@
+package store
+
+import (
+	"sort"
+	"spotify-clone/models"
+	"time"
+)
+
+// memSession is a row of listening_sessions with its members and skip votes
+type memSession struct {
+	id        int
+	hostID    int
+	code      string
+	createdAt time.Time
+	endedAt   *time.Time
+	skipRound int
+	members   []*memSessionMember
+	votes     map[memVote]bool
+}
+
+// memSessionMember is a row of listening_session_members
+type memSessionMember struct {
+	userID   int
+	joinedAt time.Time
+	leftAt   *time.Time
+}
+
+// present reports whether the member is still in the session
+func (member *memSessionMember) present() bool {
+	return member != nil && member.leftAt == nil
+}
+
+// memVote is a row of listening_session_skip_votes
+type memVote struct {
+	round  int
+	userID int
+}
+
+// member returns the session's row for the user, nil if they never joined
+func (session *memSession) member(userID int) *memSessionMember {
+	for _, member := range session.members {
+		if member.userID == userID {
+			return member
+		}
+	}
+	return nil
+}
+
+type memSessions struct {
+	m *Memory
+}
+
+func (s *memSessions) Create(hostID int, code string) (int, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.users[hostID]; !ok {
+		return 0, errForeignKey
+	}
+	// Codes stay unique after a session ends, as the unique key on code does
+	for _, session := range s.m.sessions {
+		if session.code == code {
+			return 0, ErrConflict
+		}
+	}
+	now := time.Now()
+	session := &memSession{
+		id:        s.m.nextID("listening_sessions"),
+		hostID:    hostID,
+		code:      code,
+		createdAt: now,
+		members:   []*memSessionMember{{userID: hostID, joinedAt: now}},
+		votes:     map[memVote]bool{},
+	}
+	s.m.sessions[session.id] = session
+	return session.id, nil
+}
+
+// view returns the session with its host. The caller holds the lock.
+func (s *memSessions) view(session *memSession) *models.ListeningSession {
+	return &models.ListeningSession{
+		ID:        session.id,
+		Code:      session.code,
+		Host:      s.m.summary(session.hostID),
+		CreatedAt: session.createdAt,
+	}
+}
+
+// find returns a view of the first session that matches
+func (s *memSessions) find(match func(session *memSession) bool) (*models.ListeningSession, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	ids := make([]int, 0, len(s.m.sessions))
+	for id := range s.m.sessions {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if session := s.m.sessions[id]; match(session) {
+			return s.view(session), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memSessions) Get(id int) (*models.ListeningSession, error) {
+	return s.find(func(session *memSession) bool { return session.id == id })
+}
+
+func (s *memSessions) Active(userID int) (*models.ListeningSession, error) {
+	return s.find(func(session *memSession) bool {
+		return session.endedAt == nil && session.member(userID).present()
+	})
+}
+
+func (s *memSessions) ByCode(code string) (*models.ListeningSession, error) {
+	return s.find(func(session *memSession) bool { return session.endedAt == nil && session.code == code })
+}
+
+func (s *memSessions) Hosted(hostID int) (*models.ListeningSession, error) {
+	return s.find(func(session *memSession) bool { return session.endedAt == nil && session.hostID == hostID })
+}
+
+func (s *memSessions) Join(sessionID, userID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	session, ok := s.m.sessions[sessionID]
+	if !ok {
+		return errForeignKey
+	}
+	if _, ok := s.m.users[userID]; !ok {
+		return errForeignKey
+	}
+	if member := session.member(userID); member != nil {
+		member.leftAt = nil
+		member.joinedAt = time.Now()
+		return nil
+	}
+	session.members = append(session.members, &memSessionMember{userID: userID, joinedAt: time.Now()})
+	return nil
+}
+
+func (s *memSessions) Leave(sessionID, userID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if session, ok := s.m.sessions[sessionID]; ok {
+		if member := session.member(userID); member != nil {
+			now := time.Now()
+			member.leftAt = &now
+		}
+	}
+	return nil
+}
+
+func (s *memSessions) End(sessionID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if session, ok := s.m.sessions[sessionID]; ok {
+		now := time.Now()
+		session.endedAt = &now
+		for _, member := range session.members {
+			if member.leftAt == nil {
+				member.leftAt = &now
+			}
+		}
+	}
+	return nil
+}
+
+func (s *memSessions) IsMember(sessionID, userID int) (bool, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	session, ok := s.m.sessions[sessionID]
+	if !ok {
+		return false, nil
+	}
+	return session.member(userID).present(), nil
+}
+
+func (s *memSessions) Members(sessionID int) ([]models.UserSummary, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	members := []models.UserSummary{}
+	session, ok := s.m.sessions[sessionID]
+	if !ok {
+		return members, nil
+	}
+	current := filter(session.members, func(member *memSessionMember) bool { return member.present() })
+	sort.SliceStable(current, func(i, j int) bool { return current[i].joinedAt.Before(current[j].joinedAt) })
+	for _, member := range current {
+		members = append(members, s.m.summary(member.userID))
+	}
+	return members, nil
+}
+
+func (s *memSessions) Vote(sessionID, userID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if session, ok := s.m.sessions[sessionID]; ok {
+		session.votes[memVote{round: session.skipRound, userID: userID}] = true
+	}
+	return nil
+}
+
+func (s *memSessions) SkipVotes(sessionID int) (int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	session, ok := s.m.sessions[sessionID]
+	if !ok {
+		return 0, nil
+	}
+	votes := 0
+	for vote := range session.votes {
+		if vote.round == session.skipRound && session.member(vote.userID).present() {
+			votes++
+		}
+	}
+	return votes, nil
+}
+
+func (s *memSessions) NextRound(sessionID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if session, ok := s.m.sessions[sessionID]; ok {
+		session.skipRound++
+	}
+	return nil
+}
+
+func (s *memSessions) Memberships(userID int) ([]models.ExportedSessionMembership, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	memberships := []models.ExportedSessionMembership{}
+	for _, session := range s.m.sessions {
+		member := session.member(userID)
+		if member == nil {
+			continue
+		}
+		memberships = append(memberships, models.ExportedSessionMembership{
+			SessionID: session.id,
+			Code:      session.code,
+			Host:      s.m.summary(session.hostID),
+			JoinedAt:  member.joinedAt,
+			LeftAt:    member.leftAt,
+			EndedAt:   session.endedAt,
+		})
+	}
+	sort.Slice(memberships, func(i, j int) bool {
+		if !memberships[i].JoinedAt.Equal(memberships[j].JoinedAt) {
+			return memberships[i].JoinedAt.Before(memberships[j].JoinedAt)
+		}
+		return memberships[i].SessionID < memberships[j].SessionID
+	})
+	return memberships, nil
+}
@@ -0,0 +1,310 @@
+package store
+
+import (
+	"sort"
+	"spotify-clone/models"
+	"time"
+)
+
+// memFollow is a row of user_follows
+type memFollow struct {
+	followerID int
+	followeeID int
+	createdAt  time.Time
+	seq        int
+}
+
+// memActivity is a row of user_activities; zero IDs are NULL
+type memActivity struct {
+	id         int64
+	userID     int
+	kind       string
+	playlistID int
+	trackID    int
+	artistID   int
+	createdAt  time.Time
+}
+
+type memSocial struct {
+	m *Memory
+}
+
+func (s *memSocial) Follow(followerID, followeeID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.users[followerID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := s.m.users[followeeID]; !ok {
+		return errForeignKey
+	}
+	for _, follow := range s.m.follows {
+		if follow.followerID == followerID && follow.followeeID == followeeID {
+			return nil
+		}
+	}
+	s.m.follows = append(s.m.follows, memFollow{
+		followerID: followerID,
+		followeeID: followeeID,
+		createdAt:  time.Now(),
+		seq:        s.m.nextID("user_follows"),
+	})
+	return nil
+}
+
+func (s *memSocial) Unfollow(followerID, followeeID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	before := len(s.m.follows)
+	s.m.follows = filter(s.m.follows, func(follow memFollow) bool {
+		return follow.followerID != followerID || follow.followeeID != followeeID
+	})
+	if len(s.m.follows) == before {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *memSocial) IsFollowing(followerID, followeeID int) (bool, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	for _, follow := range s.m.follows {
+		if follow.followerID == followerID && follow.followeeID == followeeID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memSocial) FollowCounts(userID int) (followers, following int, err error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	for _, follow := range s.m.follows {
+		if follow.followeeID == userID {
+			followers++
+		}
+		if follow.followerID == userID {
+			following++
+		}
+	}
+	return followers, following, nil
+}
+
+func (s *memSocial) Followers(userID, limit, offset int) ([]models.UserSummary, int, error) {
+	return s.follows(func(follow memFollow) (bool, int) { return follow.followeeID == userID, follow.followerID }, limit, offset)
+}
+
+func (s *memSocial) Following(userID, limit, offset int) ([]models.UserSummary, int, error) {
+	return s.follows(func(follow memFollow) (bool, int) { return follow.followerID == userID, follow.followeeID }, limit, offset)
+}
+
+// follows lists the other side of the follows that match, most recent first
+func (s *memSocial) follows(match func(memFollow) (bool, int), limit, offset int) ([]models.UserSummary, int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	matched := []memFollow{}
+	for _, follow := range s.m.follows {
+		if ok, _ := match(follow); ok {
+			matched = append(matched, follow)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].createdAt.Equal(matched[j].createdAt) {
+			return matched[i].createdAt.After(matched[j].createdAt)
+		}
+		return matched[i].seq > matched[j].seq
+	})
+
+	users := []models.UserSummary{}
+	for _, follow := range page(matched, limit, offset) {
+		_, userID := match(follow)
+		user := s.m.summary(userID)
+		followedAt := follow.createdAt
+		user.FollowedAt = &followedAt
+		users = append(users, user)
+	}
+	return users, len(matched), nil
+}
+
+func (s *memSocial) RecordActivity(activity NewActivity) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.users[activity.UserID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := s.m.playlists[activity.PlaylistID]; activity.PlaylistID != 0 && !ok {
+		return errForeignKey
+	}
+	if _, ok := s.m.tracks[activity.TrackID]; activity.TrackID != 0 && !ok {
+		return errForeignKey
+	}
+	if _, ok := s.m.artists[activity.ArtistID]; activity.ArtistID != 0 && !ok {
+		return errForeignKey
+	}
+	s.m.activities = append(s.m.activities, memActivity{
+		id:         int64(s.m.nextID("user_activities")),
+		userID:     activity.UserID,
+		kind:       activity.Type,
+		playlistID: activity.PlaylistID,
+		trackID:    activity.TrackID,
+		artistID:   activity.ArtistID,
+		createdAt:  time.Now(),
+	})
+	return nil
+}
+
+func (s *memSocial) Feed(userID int, before int64, since time.Time, limit int) ([]models.Activity, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	followees := map[int]bool{}
+	for _, follow := range s.m.follows {
+		if follow.followerID == userID {
+			followees[follow.followeeID] = true
+		}
+	}
+
+	// Activities are appended in ID order, so walking backwards is newest first
+	activities := []models.Activity{}
+	for i := len(s.m.activities) - 1; i >= 0 && len(activities) < limit; i-- {
+		entry := s.m.activities[i]
+		if !followees[entry.userID] || entry.id >= before || entry.createdAt.Before(since) {
+			continue
+		}
+		if entry.playlistID != 0 && !s.m.playlists[entry.playlistID].IsPublic {
+			continue
+		}
+		activities = append(activities, s.activity(entry))
+	}
+	return activities, nil
+}
+
+// Activities names private playlists too; the log is the user's own
+func (s *memSocial) Activities(userID int) ([]models.Activity, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	activities := []models.Activity{}
+	for _, entry := range s.m.activities {
+		if entry.userID == userID {
+			activities = append(activities, s.activity(entry))
+		}
+	}
+	return activities, nil
+}
+
+// activity joins the names into a log entry. The caller holds the lock.
+func (s *memSocial) activity(entry memActivity) models.Activity {
+	activity := models.Activity{
+		ID:        entry.id,
+		Type:      entry.kind,
+		User:      s.m.summary(entry.userID),
+		CreatedAt: entry.createdAt,
+	}
+	if entry.playlistID != 0 {
+		activity.PlaylistID = intPointer(entry.playlistID)
+		activity.PlaylistName = s.m.playlists[entry.playlistID].Name
+	}
+	if entry.trackID != 0 {
+		activity.TrackID = intPointer(entry.trackID)
+		activity.TrackTitle = s.m.tracks[entry.trackID].Title
+	}
+	if entry.artistID != 0 {
+		activity.ArtistID = intPointer(entry.artistID)
+		activity.ArtistName = s.m.artists[entry.artistID].Name
+	}
+	return activity
+}
+
+func intPointer(v int) *int {
+	return &v
+}
+
+type memPrivacy struct {
+	m *Memory
+}
+
+func (s *memPrivacy) Get(userID int) (models.PrivacySettings, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	stored, ok := s.m.privacy[userID]
+	if !ok {
+		return models.PrivacySettings{ShowPlaylists: true, ShowFollows: true, ShowTopArtists: true}, nil
+	}
+	settings := *stored
+	settings.PrivateSession = false
+	settings.PrivateSessionUntil = nil
+	if until := stored.PrivateSessionUntil; until != nil && until.After(time.Now()) {
+		settings.PrivateSession = true
+		settings.PrivateSessionUntil = until
+	}
+	return settings, nil
+}
+
+// row returns the user's stored settings, creating the defaults. The caller
+// holds the lock.
+func (s *memPrivacy) row(userID int) (*models.PrivacySettings, error) {
+	if _, ok := s.m.users[userID]; !ok {
+		return nil, errForeignKey
+	}
+	settings, ok := s.m.privacy[userID]
+	if !ok {
+		settings = &models.PrivacySettings{ShowPlaylists: true, ShowFollows: true, ShowTopArtists: true}
+		s.m.privacy[userID] = settings
+	}
+	return settings, nil
+}
+
+func (s *memPrivacy) Update(userID int, settings models.PrivacySettings) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	stored, err := s.row(userID)
+	if err != nil {
+		return err
+	}
+	if stored.ExcludeFromRecommendations != settings.ExcludeFromRecommendations {
+		// Move the user's past plays out of or back into the recommendable counts
+		sign := 1
+		if settings.ExcludeFromRecommendations {
+			sign = -1
+		}
+		for _, play := range s.m.plays {
+			if counters, ok := s.m.trackStats[play.trackID]; ok && play.userID == userID {
+				counters.recommendable = max(counters.recommendable+sign, 0)
+			}
+		}
+	}
+	stored.ShowPlaylists = settings.ShowPlaylists
+	stored.ShowFollows = settings.ShowFollows
+	stored.ShowTopArtists = settings.ShowTopArtists
+	stored.HideListeningActivity = settings.HideListeningActivity
+	stored.ExcludeFromRecommendations = settings.ExcludeFromRecommendations
+	return nil
+}
+
+func (s *memPrivacy) SetPrivateSession(userID int, until *time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if until == nil {
+		if stored, ok := s.m.privacy[userID]; ok {
+			stored.PrivateSessionUntil = nil
+		}
+		return nil
+	}
+	stored, err := s.row(userID)
+	if err != nil {
+		return err
+	}
+	end := *until
+	stored.PrivateSessionUntil = &end
+	return nil
+}
@@ -0,0 +1,286 @@
+package store
+
+import (
+	"sort"
+	"spotify-clone/models"
+	"strings"
+	"time"
+)
+
+type memUsers struct {
+	m *Memory
+}
+
+func (s *memUsers) Create(user *models.User) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, existing := range s.m.users {
+		if strings.EqualFold(existing.Email, user.Email) || strings.EqualFold(existing.Username, user.Username) {
+			return ErrConflict
+		}
+	}
+
+	now := time.Now()
+	stored := *user
+	stored.ID = s.m.nextID("users")
+	stored.FavoriteGenres = append([]string(nil), user.FavoriteGenres...)
+	stored.FavoriteArtists = s.existingArtists(user.FavoriteArtists)
+	stored.CreatedAt = now
+	stored.UpdatedAt = now
+	s.m.users[stored.ID] = &stored
+
+	user.ID = stored.ID
+	return nil
+}
+
+// existingArtists drops duplicates and unknown artists the way INSERT IGNORE
+// into user_favorite_artists does. The caller holds the lock.
+func (s *memUsers) existingArtists(ids []int) []int {
+	artists := []int{}
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if _, ok := s.m.artists[id]; ok && !seen[id] {
+			seen[id] = true
+			artists = append(artists, id)
+		}
+	}
+	return artists
+}
+
+// get returns a copy of a user. The caller holds the lock.
+func (s *memUsers) get(user *models.User) *models.User {
+	found := *user
+	found.FavoriteGenres = append([]string(nil), user.FavoriteGenres...)
+	found.FavoriteArtists = append([]int{}, user.FavoriteArtists...)
+	return &found
+}
+
+func (s *memUsers) Get(id int) (*models.User, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	user, ok := s.m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := s.get(user)
+	found.Password = ""
+	return found, nil
+}
+
+func (s *memUsers) GetByEmail(email string) (*models.User, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	for _, user := range s.m.users {
+		if strings.EqualFold(user.Email, email) {
+			return s.get(user), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memUsers) UpdatePreferences(id int, prefs models.UserPreferences) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	user, ok := s.m.users[id]
+	if !ok {
+		return nil
+	}
+	user.Theme = prefs.Theme
+	user.Language = prefs.Language
+	user.ExplicitContent = prefs.ExplicitContent
+	user.FavoriteGenres = append([]string(nil), prefs.PreferredGenres...)
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *memUsers) FavoriteArtistIDs(id int) ([]int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	if user, ok := s.m.users[id]; ok {
+		return append([]int{}, user.FavoriteArtists...), nil
+	}
+	return []int{}, nil
+}
+
+func (s *memUsers) FavoriteArtists(id int) ([]models.Artist, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	artists := []models.Artist{}
+	if user, ok := s.m.users[id]; ok {
+		for _, artistID := range user.FavoriteArtists {
+			artists = append(artists, s.m.artists[artistID])
+		}
+	}
+	return artists, nil
+}
+
+func (s *memUsers) SetFavoriteArtists(id int, artistIDs []int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if user, ok := s.m.users[id]; ok {
+		user.FavoriteArtists = s.existingArtists(artistIDs)
+	}
+	return nil
+}
+
+func (s *memUsers) AddFavoriteArtist(id, artistID, limit int) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	user, ok := s.m.users[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if _, ok := s.m.artists[artistID]; !ok {
+		return false, errForeignKey
+	}
+	for _, existing := range user.FavoriteArtists {
+		if existing == artistID {
+			return false, nil
+		}
+	}
+	if len(user.FavoriteArtists) >= limit {
+		return false, ErrLimit
+	}
+	user.FavoriteArtists = append(user.FavoriteArtists, artistID)
+	return true, nil
+}
+
+func (s *memUsers) RemoveFavoriteArtist(id, artistID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	user, ok := s.m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	for i, existing := range user.FavoriteArtists {
+		if existing == artistID {
+			user.FavoriteArtists = append(user.FavoriteArtists[:i], user.FavoriteArtists[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memUsers) SavedTrackCount(id int) (int, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	return len(s.m.savedTracks[id]), nil
+}
+
+func (s *memUsers) GetByUsername(username string) (*models.User, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	for _, user := range s.m.users {
+		if strings.EqualFold(user.Username, username) {
+			return &models.User{
+				ID:                user.ID,
+				Email:             user.Email,
+				Username:          user.Username,
+				DisplayName:       user.DisplayName,
+				ProfilePictureURL: user.ProfilePictureURL,
+				CreatedAt:         user.CreatedAt,
+			}, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+type memPlays struct {
+	m *Memory
+}
+
+// Record adds the play and bumps track_stats as the after_play_insert trigger
+// does
+func (s *memPlays) Record(userID, trackID, durationPlayed int, completed bool) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.tracks[trackID]; !ok {
+		return errForeignKey
+	}
+	if _, ok := s.m.users[userID]; !ok {
+		return errForeignKey
+	}
+
+	playedAt := time.Now()
+	s.m.plays = append(s.m.plays, memPlay{
+		userID:         userID,
+		trackID:        trackID,
+		playedAt:       playedAt,
+		durationPlayed: durationPlayed,
+		completed:      completed,
+	})
+	if counters, ok := s.m.trackStats[trackID]; ok {
+		counters.playCount++
+		if !s.m.excluded(userID) {
+			counters.recommendable++
+		}
+		counters.lastPlayed = &playedAt
+	}
+	return nil
+}
+
+// History reads the plays in the order they were recorded, which is the order
+// of played_at
+func (s *memPlays) History(userID int) ([]models.ExportedPlay, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	plays := []models.ExportedPlay{}
+	for _, play := range s.m.plays {
+		if play.userID != userID {
+			continue
+		}
+		track, ok := s.m.track(play.trackID)
+		if !ok {
+			continue
+		}
+		plays = append(plays, models.ExportedPlay{
+			PlayedAt:       play.playedAt,
+			TrackID:        track.ID,
+			Title:          track.Title,
+			Artist:         track.ArtistName,
+			Album:          track.AlbumName,
+			DurationPlayed: play.durationPlayed,
+			Completed:      play.completed,
+		})
+	}
+	return plays, nil
+}
+
+func (s *memPlays) TopArtists(userID int, since time.Time, limit int) ([]models.TopArtist, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	counts := map[int]int{}
+	for _, play := range s.m.plays {
+		if play.userID == userID && !play.playedAt.Before(since) {
+			counts[s.m.tracks[play.trackID].ArtistID]++
+		}
+	}
+
+	artists := []models.TopArtist{}
+	for artistID, count := range counts {
+		if artist, ok := s.m.artists[artistID]; ok {
+			artists = append(artists, models.TopArtist{Artist: artist, PlayCount: count})
+		}
+	}
+	sort.Slice(artists, func(i, j int) bool {
+		if artists[i].PlayCount != artists[j].PlayCount {
+			return artists[i].PlayCount > artists[j].PlayCount
+		}
+		return artists[i].Name < artists[j].Name
+	})
+	return page(artists, limit, 0), nil
+}
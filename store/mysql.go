@@ -0,0 +1,155 @@
+package store
+
+import (
+	"database/sql"
+	"spotify-clone/models"
+	"strings"
+)
+
+// trackColumns and trackJoins select everything a models.Track holds; scanTrack
+// reads a row of them
+const (
+	trackColumns = `t.id, t.title, t.artist_id, a.name, t.album_id, al.title, t.duration,
+		t.genre, t.release_date, t.file_url, t.cover_url, t.created_at`
+	trackJoins = `JOIN artists a ON t.artist_id = a.id
+		JOIN albums al ON t.album_id = al.id`
+)
+
+const (
+	artistColumns = "ar.id, ar.name, ar.bio, ar.image_url, ar.created_at"
+	albumColumns  = "al.id, al.title, al.artist_id, a.name, al.release_date, al.cover_url, al.created_at"
+)
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// NewMySQL returns stores backed by a MySQL database migrated by
+// database.InitMySQL, with the triggers and routines of
+// migrations/sql/0003_routines.up.sql
+func NewMySQL(db *sql.DB) *Stores {
+	return &Stores{
+		Tracks:          &mysqlTracks{db: db},
+		Artists:         &mysqlArtists{db: db},
+		Albums:          &mysqlAlbums{db: db},
+		Playlists:       &mysqlPlaylists{db: db},
+		Invites:         &mysqlInvites{db: db},
+		Covers:          &mysqlCovers{db: db},
+		Users:           &mysqlUsers{db: db},
+		Plays:           &mysqlPlays{db: db},
+		Files:           &mysqlFiles{db: db},
+		Library:         &mysqlLibrary{db: db},
+		Social:          &mysqlSocial{db: db},
+		Privacy:         &mysqlPrivacy{db: db},
+		Player:          &mysqlPlayer{db: db},
+		Sessions:        &mysqlSessions{db: db},
+		Radio:           &mysqlRadio{db: db},
+		Recommendations: &mysqlRecommendations{db: db},
+		Accounts:        &mysqlAccounts{db: db},
+	}
+}
+
+func scanTrack(row rowScanner, extra ...interface{}) (models.Track, error) {
+	var track models.Track
+	dest := []interface{}{
+		&track.ID, &track.Title, &track.ArtistID, &track.ArtistName,
+		&track.AlbumID, &track.AlbumName, &track.Duration,
+		&track.Genre, &track.ReleaseDate, &track.FileURL,
+		&track.CoverURL, &track.CreatedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	return track, err
+}
+
+func scanArtist(row rowScanner) (models.Artist, error) {
+	var artist models.Artist
+	err := row.Scan(&artist.ID, &artist.Name, &artist.Bio, &artist.ImageURL, &artist.CreatedAt)
+	return artist, err
+}
+
+func scanAlbum(row rowScanner) (models.Album, error) {
+	var album models.Album
+	err := row.Scan(
+		&album.ID, &album.Title, &album.ArtistID, &album.ArtistName,
+		&album.ReleaseDate, &album.CoverURL, &album.CreatedAt,
+	)
+	return album, err
+}
+
+// queryTracks runs a query selecting trackColumns. Rows that fail to scan are
+// skipped, as the handlers always did.
+func queryTracks(db *sql.DB, query string, args ...interface{}) ([]models.Track, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tracks := []models.Track{}
+	for rows.Next() {
+		if track, err := scanTrack(rows); err == nil {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks, rows.Err()
+}
+
+func queryIDs(db *sql.DB, query string, args ...interface{}) ([]int, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// queryIDsByKey runs a query selecting an ID and a name or title, ordered by
+// ID, and keys the first ID of each lower-cased name
+func queryIDsByKey(db *sql.DB, query string, args ...interface{}) (map[string]int, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[string]int{}
+	for rows.Next() {
+		var id int
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			return nil, err
+		}
+		if _, ok := ids[strings.ToLower(key)]; !ok {
+			ids[strings.ToLower(key)] = id
+		}
+	}
+	return ids, rows.Err()
+}
+
+// inPlaceholders returns "?,?,..." for values and the matching arguments
+func inPlaceholders[T any](values []T) (string, []interface{}) {
+	placeholders := make([]string, len(values))
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		placeholders[i] = "?"
+		args[i] = value
+	}
+	return strings.Join(placeholders, ","), args
+}
+
+// notFound maps sql.ErrNoRows to ErrNotFound
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
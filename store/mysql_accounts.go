@@ -0,0 +1,99 @@
+package store
+
+import (
+	"database/sql"
+	"spotify-clone/models"
+	"time"
+)
+
+type mysqlAccounts struct {
+	db *sql.DB
+}
+
+func (s *mysqlAccounts) ScheduleDeletion(userID int, grace time.Duration) (*models.AccountDeletion, error) {
+	_, err := s.db.Exec(`
+		INSERT INTO account_deletions (user_id, delete_after)
+		VALUES (?, DATE_ADD(NOW(), INTERVAL ? SECOND))
+		ON DUPLICATE KEY UPDATE user_id = user_id`,
+		userID, int64(grace/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	return s.Deletion(userID)
+}
+
+func (s *mysqlAccounts) Deletion(userID int) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	err := s.db.QueryRow(
+		"SELECT requested_at, delete_after FROM account_deletions WHERE user_id = ?", userID,
+	).Scan(&deletion.RequestedAt, &deletion.DeleteAfter)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &deletion, nil
+}
+
+func (s *mysqlAccounts) CancelDeletion(userID int) error {
+	result, err := s.db.Exec("DELETE FROM account_deletions WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mysqlAccounts) DueDeletions() ([]int, error) {
+	return queryIDs(s.db, "SELECT user_id FROM account_deletions WHERE delete_after <= NOW()")
+}
+
+// Purge leaves the rest to ON DELETE CASCADE: the user's playlists and
+// everything else they own go with the users row, and tracks they added to
+// other playlists lose their added_by.
+func (s *mysqlAccounts) Purge(userID int) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT pc.generated_file, pc.uploaded_file
+		FROM playlist_covers pc
+		JOIN playlists p ON pc.playlist_id = p.id
+		WHERE p.user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	covers := []string{}
+	for rows.Next() {
+		var generated, uploaded sql.NullString
+		if err := rows.Scan(&generated, &uploaded); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for _, name := range []sql.NullString{generated, uploaded} {
+			if name.Valid && name.String != "" {
+				covers = append(covers, name.String)
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The foreign key would do this too; anonymizing explicitly keeps the
+	// policy independent of the schema
+	if _, err := tx.Exec("UPDATE plays SET user_id = NULL WHERE user_id = ?", userID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", userID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return covers, nil
+}
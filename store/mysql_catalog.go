@@ -0,0 +1,412 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"spotify-clone/models"
+	"time"
+)
+
+// routineTimeout bounds calls of stored procedures and functions
+const routineTimeout = 5 * time.Second
+
+type mysqlTracks struct {
+	db *sql.DB
+}
+
+func (s *mysqlTracks) List(filter TrackFilter) ([]models.Track, error) {
+	query := "SELECT " + trackColumns + " FROM tracks t " + trackJoins + " WHERE 1=1"
+	args := []interface{}{}
+	if filter.Genre != "" {
+		query += " AND t.genre = ?"
+		args = append(args, filter.Genre)
+	}
+	if filter.Search != "" {
+		query += " AND (t.title LIKE ? OR a.name LIKE ?)"
+		searchParam := "%" + filter.Search + "%"
+		args = append(args, searchParam, searchParam)
+	}
+	query += " ORDER BY t.created_at DESC LIMIT ? OFFSET ?"
+	args = append(args, filter.Limit, filter.Offset)
+	return queryTracks(s.db, query, args...)
+}
+
+func (s *mysqlTracks) Get(id int) (*models.Track, error) {
+	track, err := scanTrack(s.db.QueryRow("SELECT "+trackColumns+" FROM tracks t "+trackJoins+" WHERE t.id = ?", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &track, nil
+}
+
+func (s *mysqlTracks) GetMany(ids []int) ([]models.Track, error) {
+	if len(ids) == 0 {
+		return []models.Track{}, nil
+	}
+	placeholders, args := inPlaceholders(ids)
+	found, err := queryTracks(s.db, "SELECT "+trackColumns+" FROM tracks t "+trackJoins+" WHERE t.id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]models.Track, len(found))
+	for _, track := range found {
+		byID[track.ID] = track
+	}
+	tracks := make([]models.Track, 0, len(ids))
+	for _, id := range ids {
+		if track, ok := byID[id]; ok {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks, nil
+}
+
+func (s *mysqlTracks) ByArtist(artistID int) ([]models.Track, error) {
+	return queryTracks(s.db, "SELECT "+trackColumns+" FROM tracks t "+trackJoins+`
+		WHERE t.artist_id = ?
+		ORDER BY t.release_date DESC`, artistID)
+}
+
+func (s *mysqlTracks) Search(query string, limit int) ([]models.Track, error) {
+	searchParam := "%" + query + "%"
+	return queryTracks(s.db, "SELECT "+trackColumns+" FROM tracks t "+trackJoins+`
+		WHERE t.title LIKE ? OR a.name LIKE ?
+		LIMIT ?`, searchParam, searchParam, limit)
+}
+
+func (s *mysqlTracks) Add(track NewTrack) (int, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), routineTimeout)
+	defer cancel()
+
+	// Session variables only live on one connection, so the call and the read of
+	// its output parameters must share it
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return 0, "", err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx,
+		"CALL add_track(?, ?, ?, ?, ?, ?, ?, ?, @track_id, @status)",
+		track.Title, track.ArtistID, track.AlbumID, track.Duration, track.Genre,
+		track.ReleaseDate.Format("2006-01-02"), track.FileURL, track.CoverURL,
+	)
+	if err != nil {
+		return 0, "", fmt.Errorf("error calling add_track procedure: %v", err)
+	}
+
+	var trackID sql.NullInt64
+	var status string
+	if err := conn.QueryRowContext(ctx, "SELECT @track_id, @status").Scan(&trackID, &status); err != nil {
+		return 0, "", fmt.Errorf("error getting output parameters: %v", err)
+	}
+	if !trackID.Valid {
+		return 0, status, nil
+	}
+	return int(trackID.Int64), status, nil
+}
+
+func (s *mysqlTracks) IDsByTitle(albumID int, titles []string) (map[string]int, error) {
+	if len(titles) == 0 {
+		return map[string]int{}, nil
+	}
+	placeholders, args := inPlaceholders(titles)
+	return queryIDsByKey(s.db, "SELECT id, title FROM tracks WHERE album_id = ? AND title IN ("+placeholders+") ORDER BY id",
+		append([]interface{}{albumID}, args...)...)
+}
+
+func (s *mysqlTracks) Update(id int, track NewTrack) error {
+	_, err := s.db.Exec(`
+		UPDATE tracks
+		SET title = ?, artist_id = ?, album_id = ?, duration = ?, genre = ?,
+			release_date = ?, file_url = ?, cover_url = ?
+		WHERE id = ?`,
+		track.Title, track.ArtistID, track.AlbumID, track.Duration, track.Genre,
+		track.ReleaseDate.Format("2006-01-02"), track.FileURL, track.CoverURL, id,
+	)
+	return err
+}
+
+func (s *mysqlTracks) Delete(id int) error {
+	// The after_track_delete trigger updates album_stats and CASCADE deletes
+	// the track's plays and playlist entries
+	_, err := s.db.Exec("DELETE FROM tracks WHERE id = ?", id)
+	return err
+}
+
+func (s *mysqlTracks) AlbumTrackIDs(albumID int) ([]int, error) {
+	return queryIDs(s.db, "SELECT id FROM tracks WHERE album_id = ? ORDER BY id", albumID)
+}
+
+func (s *mysqlTracks) ArtistTrackIDs(artistID int) ([]int, error) {
+	return queryIDs(s.db, `
+		SELECT t.id FROM tracks t
+		LEFT JOIN track_stats ts ON ts.track_id = t.id
+		WHERE t.artist_id = ?
+		ORDER BY COALESCE(ts.play_count, 0) DESC, t.id`, artistID)
+}
+
+func (s *mysqlTracks) IDByFileURL(url string) (int, error) {
+	var id int
+	err := s.db.QueryRow("SELECT id FROM tracks WHERE file_url = ? LIMIT 1", url).Scan(&id)
+	return id, notFound(err)
+}
+
+func (s *mysqlTracks) MatchTitleOrArtist(titleWord, artist string, limit int) ([]models.Track, error) {
+	query := "SELECT " + trackColumns + " FROM tracks t " + trackJoins + " WHERE t.title LIKE ?"
+	args := []interface{}{"%" + titleWord + "%"}
+	if artist != "" {
+		query += " OR a.name LIKE ?"
+		args = append(args, "%"+artist+"%")
+	}
+	query += " LIMIT ?"
+	return queryTracks(s.db, query, append(args, limit)...)
+}
+
+type mysqlArtists struct {
+	db *sql.DB
+}
+
+func (s *mysqlArtists) query(query string, args ...interface{}) ([]models.Artist, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artists := []models.Artist{}
+	for rows.Next() {
+		if artist, err := scanArtist(rows); err == nil {
+			artists = append(artists, artist)
+		}
+	}
+	return artists, rows.Err()
+}
+
+func (s *mysqlArtists) List(limit, offset int) ([]models.Artist, error) {
+	return s.query("SELECT "+artistColumns+" FROM artists ar ORDER BY ar.name LIMIT ? OFFSET ?", limit, offset)
+}
+
+func (s *mysqlArtists) Get(id int) (*models.Artist, error) {
+	artist, err := scanArtist(s.db.QueryRow("SELECT "+artistColumns+" FROM artists ar WHERE ar.id = ?", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &artist, nil
+}
+
+func (s *mysqlArtists) Search(query string, limit int) ([]models.Artist, error) {
+	return s.query("SELECT "+artistColumns+" FROM artists ar WHERE ar.name LIKE ? LIMIT ?", "%"+query+"%", limit)
+}
+
+func (s *mysqlArtists) Existing(ids []int) ([]int, error) {
+	if len(ids) == 0 {
+		return []int{}, nil
+	}
+	placeholders, args := inPlaceholders(ids)
+	return queryIDs(s.db, "SELECT id FROM artists WHERE id IN ("+placeholders+")", args...)
+}
+
+func (s *mysqlArtists) Stats(id int) (*models.ArtistStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), routineTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "CALL get_artist_stats(?)", id)
+	if err != nil {
+		return nil, fmt.Errorf("error calling get_artist_stats procedure: %v", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, ErrNotFound
+	}
+
+	var stats models.ArtistStats
+	var genres sql.NullString
+	var firstRelease, latestRelease sql.NullTime
+	err = rows.Scan(
+		&stats.ArtistID,
+		&stats.ArtistName,
+		&stats.TotalAlbums,
+		&stats.TotalTracks,
+		&stats.TotalDurationSeconds,
+		&stats.TotalDurationMinutes,
+		&stats.UniqueGenres,
+		&genres,
+		&firstRelease,
+		&latestRelease,
+		&stats.AvgPlaysPerTrack,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error scanning artist stats: %v", err)
+	}
+
+	stats.Genres = genres.String
+	if firstRelease.Valid {
+		stats.FirstRelease = firstRelease.Time
+	}
+	if latestRelease.Valid {
+		stats.LatestRelease = latestRelease.Time
+	}
+	return &stats, nil
+}
+
+func (s *mysqlArtists) IDsByName(names []string) (map[string]int, error) {
+	if len(names) == 0 {
+		return map[string]int{}, nil
+	}
+	placeholders, args := inPlaceholders(names)
+	return queryIDsByKey(s.db, "SELECT id, name FROM artists WHERE name IN ("+placeholders+") ORDER BY id", args...)
+}
+
+func (s *mysqlArtists) Create(artist *models.Artist) error {
+	result, err := s.db.Exec("INSERT INTO artists (name, bio, image_url) VALUES (?, ?, ?)",
+		artist.Name, artist.Bio, artist.ImageURL)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	artist.ID = int(id)
+	return nil
+}
+
+func (s *mysqlArtists) Popular(genres []string, limit int) ([]models.Artist, error) {
+	query := `
+		SELECT ` + artistColumns + `
+		FROM artists ar
+		JOIN tracks t ON t.artist_id = ar.id
+		LEFT JOIN track_stats ts ON ts.track_id = t.id`
+	args := []interface{}{}
+	if len(genres) > 0 {
+		placeholders, genreArgs := inPlaceholders(genres)
+		query += " WHERE t.genre IN (" + placeholders + ")"
+		args = append(args, genreArgs...)
+	}
+	query += `
+		GROUP BY ar.id, ar.name, ar.bio, ar.image_url, ar.created_at
+		ORDER BY COALESCE(SUM(ts.recommendable_play_count), 0) DESC, ar.name
+		LIMIT ?`
+	return s.query(query, append(args, limit)...)
+}
+
+type mysqlAlbums struct {
+	db *sql.DB
+}
+
+func (s *mysqlAlbums) query(query string, args ...interface{}) ([]models.Album, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	albums := []models.Album{}
+	for rows.Next() {
+		if album, err := scanAlbum(rows); err == nil {
+			albums = append(albums, album)
+		}
+	}
+	return albums, rows.Err()
+}
+
+func (s *mysqlAlbums) List(limit, offset int) ([]models.Album, error) {
+	return s.query(`
+		SELECT `+albumColumns+`
+		FROM albums al
+		JOIN artists a ON al.artist_id = a.id
+		ORDER BY al.release_date DESC
+		LIMIT ? OFFSET ?`, limit, offset)
+}
+
+func (s *mysqlAlbums) Get(id int) (*models.Album, error) {
+	album, err := scanAlbum(s.db.QueryRow(`
+		SELECT `+albumColumns+`
+		FROM albums al
+		JOIN artists a ON al.artist_id = a.id
+		WHERE al.id = ?`, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &album, nil
+}
+
+func (s *mysqlAlbums) Search(query string, limit int) ([]models.Album, error) {
+	searchParam := "%" + query + "%"
+	return s.query(`
+		SELECT `+albumColumns+`
+		FROM albums al
+		JOIN artists a ON al.artist_id = a.id
+		WHERE al.title LIKE ? OR a.name LIKE ?
+		LIMIT ?`, searchParam, searchParam, limit)
+}
+
+func (s *mysqlAlbums) Stats(id int) (*models.AlbumStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), routineTimeout)
+	defer cancel()
+
+	var stats models.AlbumStats
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			a.id,
+			a.title,
+			COALESCE(ast.track_count, 0),
+			COALESCE(ast.total_duration, 0),
+			ROUND(COALESCE(ast.total_duration, 0) / 60.0, 2)
+		FROM albums a
+		LEFT JOIN album_stats ast ON a.id = ast.album_id
+		WHERE a.id = ?`, id).Scan(
+		&stats.AlbumID,
+		&stats.Title,
+		&stats.TrackCount,
+		&stats.TotalDuration,
+		&stats.DurationMinutes,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &stats, nil
+}
+
+func (s *mysqlAlbums) Duration(id int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), routineTimeout)
+	defer cancel()
+
+	var duration int
+	if err := s.db.QueryRowContext(ctx, "SELECT get_album_duration(?)", id).Scan(&duration); err != nil {
+		return 0, fmt.Errorf("error getting album duration: %v", err)
+	}
+	return duration, nil
+}
+
+func (s *mysqlAlbums) IDsByTitle(artistID int, titles []string) (map[string]int, error) {
+	if len(titles) == 0 {
+		return map[string]int{}, nil
+	}
+	placeholders, args := inPlaceholders(titles)
+	return queryIDsByKey(s.db, "SELECT id, title FROM albums WHERE artist_id = ? AND title IN ("+placeholders+") ORDER BY id",
+		append([]interface{}{artistID}, args...)...)
+}
+
+func (s *mysqlAlbums) Create(album *models.Album) error {
+	result, err := s.db.Exec("INSERT INTO albums (title, artist_id, release_date, cover_url) VALUES (?, ?, ?, ?)",
+		album.Title, album.ArtistID, album.ReleaseDate.Format("2006-01-02"), album.CoverURL)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	album.ID = int(id)
+	return nil
+}
+
+func (s *mysqlAlbums) SetCover(id int, coverURL string) error {
+	_, err := s.db.Exec("UPDATE albums SET cover_url = ? WHERE id = ?", coverURL, id)
+	return err
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+type mysqlFiles struct {
+	db *sql.DB
+}
+
+func (s *mysqlFiles) List() ([]ScannedFile, error) {
+	rows, err := s.db.Query("SELECT path, size, mod_time, hash, track_id FROM scanned_files ORDER BY path")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []ScannedFile{}
+	for rows.Next() {
+		var file ScannedFile
+		var modTime int64
+		var trackID sql.NullInt64
+		if err := rows.Scan(&file.Path, &file.Size, &modTime, &file.Hash, &trackID); err != nil {
+			return nil, err
+		}
+		file.ModTime = time.Unix(0, modTime)
+		file.TrackID = int(trackID.Int64)
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+func (s *mysqlFiles) Save(file ScannedFile) error {
+	var trackID interface{}
+	if file.TrackID != 0 {
+		trackID = file.TrackID
+	}
+	// mod_time is in nanoseconds so an unchanged file compares equal
+	_, err := s.db.Exec(`
+		INSERT INTO scanned_files (path, size, mod_time, hash, track_id)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			size = VALUES(size),
+			mod_time = VALUES(mod_time),
+			hash = VALUES(hash),
+			track_id = VALUES(track_id)`,
+		file.Path, file.Size, file.ModTime.UnixNano(), file.Hash, trackID)
+	return err
+}
+
+func (s *mysqlFiles) Delete(path string) error {
+	_, err := s.db.Exec("DELETE FROM scanned_files WHERE path = ?", path)
+	return err
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"database/sql"
+	"spotify-clone/models"
+)
+
+// libraryTables maps a library section to the table that stores it and the
+// column holding the saved item. Saved artists live in user_favorite_artists
+// so the library and favorites stay one list.
+var libraryTables = map[string]struct{ table, column string }{
+	LibraryTracks:  {"user_saved_tracks", "track_id"},
+	LibraryAlbums:  {"user_saved_albums", "album_id"},
+	LibraryArtists: {"user_favorite_artists", "artist_id"},
+}
+
+type mysqlLibrary struct {
+	db *sql.DB
+}
+
+func (s *mysqlLibrary) Save(userID int, section string, id int) error {
+	kind, ok := libraryTables[section]
+	if !ok {
+		return ErrNotFound
+	}
+	_, err := s.db.Exec("INSERT IGNORE INTO "+kind.table+" (user_id, "+kind.column+") VALUES (?, ?)", userID, id)
+	return err
+}
+
+func (s *mysqlLibrary) Remove(userID int, section string, id int) error {
+	kind, ok := libraryTables[section]
+	if !ok {
+		return ErrNotFound
+	}
+	result, err := s.db.Exec("DELETE FROM "+kind.table+" WHERE user_id = ? AND "+kind.column+" = ?", userID, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mysqlLibrary) Contains(userID int, section string, ids []int) ([]int, error) {
+	kind, ok := libraryTables[section]
+	if !ok || len(ids) == 0 {
+		return []int{}, nil
+	}
+	placeholders, args := inPlaceholders(ids)
+	return queryIDs(s.db,
+		"SELECT "+kind.column+" FROM "+kind.table+" WHERE user_id = ? AND "+kind.column+" IN ("+placeholders+")",
+		append([]interface{}{userID}, args...)...)
+}
+
+// limitClause pages a library query; a negative limit returns everything
+func limitClause(filter LibraryFilter, args []interface{}) (string, []interface{}) {
+	if filter.Limit < 0 {
+		return "", args
+	}
+	return " LIMIT ? OFFSET ?", append(args, filter.Limit, filter.Offset)
+}
+
+func (s *mysqlLibrary) Tracks(userID int, filter LibraryFilter) ([]models.SavedTrack, error) {
+	orders := map[string]string{
+		"title":    "t.title ASC",
+		"artist":   "a.name ASC, al.title ASC, t.title ASC",
+		"album":    "al.title ASC, t.id ASC",
+		"duration": "t.duration DESC",
+	}
+	order, ok := orders[filter.Sort]
+	if !ok {
+		order = "st.saved_at DESC, st.id DESC"
+	}
+
+	query := "SELECT " + trackColumns + `, st.saved_at
+		FROM user_saved_tracks st
+		JOIN tracks t ON st.track_id = t.id
+		` + trackJoins + `
+		WHERE st.user_id = ?`
+	args := []interface{}{userID}
+	if filter.Genre != "" {
+		query += " AND t.genre = ?"
+		args = append(args, filter.Genre)
+	}
+	if filter.ArtistID != 0 {
+		query += " AND t.artist_id = ?"
+		args = append(args, filter.ArtistID)
+	}
+	if filter.Search != "" {
+		query += " AND (t.title LIKE ? OR a.name LIKE ? OR al.title LIKE ?)"
+		searchParam := "%" + filter.Search + "%"
+		args = append(args, searchParam, searchParam, searchParam)
+	}
+	limit, args := limitClause(filter, args)
+
+	rows, err := s.db.Query(query+" ORDER BY "+order+limit, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tracks := []models.SavedTrack{}
+	for rows.Next() {
+		var saved models.SavedTrack
+		track, err := scanTrack(rows, &saved.SavedAt)
+		if err != nil {
+			continue
+		}
+		saved.Track = track
+		tracks = append(tracks, saved)
+	}
+	return tracks, rows.Err()
+}
+
+func (s *mysqlLibrary) Albums(userID int, filter LibraryFilter) ([]models.SavedAlbum, error) {
+	orders := map[string]string{
+		"title":        "al.title ASC",
+		"artist":       "a.name ASC, al.release_date ASC",
+		"release_date": "al.release_date DESC",
+	}
+	order, ok := orders[filter.Sort]
+	if !ok {
+		order = "sa.saved_at DESC, sa.id DESC"
+	}
+
+	query := "SELECT " + albumColumns + `, sa.saved_at
+		FROM user_saved_albums sa
+		JOIN albums al ON sa.album_id = al.id
+		JOIN artists a ON al.artist_id = a.id
+		WHERE sa.user_id = ?`
+	args := []interface{}{userID}
+	if filter.Search != "" {
+		query += " AND (al.title LIKE ? OR a.name LIKE ?)"
+		searchParam := "%" + filter.Search + "%"
+		args = append(args, searchParam, searchParam)
+	}
+	limit, args := limitClause(filter, args)
+
+	rows, err := s.db.Query(query+" ORDER BY "+order+limit, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	albums := []models.SavedAlbum{}
+	for rows.Next() {
+		var saved models.SavedAlbum
+		err := rows.Scan(
+			&saved.ID, &saved.Title, &saved.ArtistID, &saved.ArtistName,
+			&saved.ReleaseDate, &saved.CoverURL, &saved.CreatedAt, &saved.SavedAt,
+		)
+		if err != nil {
+			continue
+		}
+		albums = append(albums, saved)
+	}
+	return albums, rows.Err()
+}
+
+func (s *mysqlLibrary) Artists(userID int, filter LibraryFilter) ([]models.Artist, error) {
+	// user_favorite_artists has no timestamp; its auto-increment id preserves save order
+	order := "fa.id DESC"
+	if filter.Sort == "name" {
+		order = "ar.name ASC"
+	}
+
+	query := "SELECT " + artistColumns + `
+		FROM user_favorite_artists fa
+		JOIN artists ar ON fa.artist_id = ar.id
+		WHERE fa.user_id = ?`
+	args := []interface{}{userID}
+	if filter.Search != "" {
+		query += " AND ar.name LIKE ?"
+		args = append(args, "%"+filter.Search+"%")
+	}
+	limit, args := limitClause(filter, args)
+	return (&mysqlArtists{db: s.db}).query(query+" ORDER BY "+order+limit, args...)
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"database/sql"
+	"spotify-clone/models"
+	"time"
+)
+
+const playbackColumns = `context_type, context_id, context_index, track_id, from_queue, position_ms,
+	is_playing, shuffle, shuffle_seed, repeat_mode, updated_at`
+
+type mysqlPlayer struct {
+	db *sql.DB
+}
+
+// scanPlaybackState reads a row of playbackColumns; no row gives an idle state
+func scanPlaybackState(row rowScanner) (*models.PlaybackState, error) {
+	state := &models.PlaybackState{RepeatMode: "off"}
+	var contextType sql.NullString
+	var contextID, trackID sql.NullInt64
+	err := row.Scan(&contextType, &contextID, &state.ContextIndex, &trackID,
+		&state.FromQueue, &state.PositionMs, &state.IsPlaying, &state.Shuffle, &state.ShuffleSeed,
+		&state.RepeatMode, &state.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state.ContextType = contextType.String
+	state.ContextID = nullableInt(contextID)
+	state.TrackID = nullableInt(trackID)
+	return state, nil
+}
+
+func (s *mysqlPlayer) State(userID int) (*models.PlaybackState, error) {
+	return scanPlaybackState(s.db.QueryRow("SELECT "+playbackColumns+" FROM playback_states WHERE user_id = ?", userID))
+}
+
+// mysqlPlaybackTx is the queue of the user whose playback_states row the
+// transaction holds locked
+type mysqlPlaybackTx struct {
+	tx     *sql.Tx
+	userID int
+}
+
+func (q *mysqlPlaybackTx) PopQueue() (int, bool, error) {
+	var itemID int64
+	var trackID, position int
+	err := q.tx.QueryRow(`
+		SELECT id, track_id, position FROM playback_queue
+		WHERE user_id = ? ORDER BY position LIMIT 1 FOR UPDATE`, q.userID).Scan(&itemID, &trackID, &position)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if _, err := q.tx.Exec("DELETE FROM playback_queue WHERE id = ?", itemID); err != nil {
+		return 0, false, err
+	}
+	if _, err := q.tx.Exec("UPDATE playback_queue SET position = position - 1 WHERE user_id = ? AND position > ?", q.userID, position); err != nil {
+		return 0, false, err
+	}
+	return trackID, true, nil
+}
+
+func (s *mysqlPlayer) Update(userID int, change func(tx PlaybackTx, state *models.PlaybackState) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("INSERT IGNORE INTO playback_states (user_id) VALUES (?)", userID); err != nil {
+		return err
+	}
+	state, err := scanPlaybackState(tx.QueryRow("SELECT "+playbackColumns+" FROM playback_states WHERE user_id = ? FOR UPDATE", userID))
+	if err != nil {
+		return err
+	}
+
+	if err := change(&mysqlPlaybackTx{tx: tx, userID: userID}, state); err != nil {
+		return err
+	}
+	state.UpdatedAt = time.Now()
+
+	var contextType interface{}
+	if state.ContextType != "" {
+		contextType = state.ContextType
+	}
+	_, err = tx.Exec(`
+		UPDATE playback_states
+		SET context_type = ?, context_id = ?, context_index = ?, track_id = ?, from_queue = ?,
+		    position_ms = ?, is_playing = ?, shuffle = ?, shuffle_seed = ?, repeat_mode = ?, updated_at = ?
+		WHERE user_id = ?`,
+		contextType, state.ContextID, state.ContextIndex, state.TrackID, state.FromQueue,
+		state.PositionMs, state.IsPlaying, state.Shuffle, state.ShuffleSeed, state.RepeatMode, state.UpdatedAt,
+		userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *mysqlPlayer) Queue(userID int) ([]models.QueueItem, error) {
+	rows, err := s.db.Query(`
+		SELECT id, track_id, position, added_at
+		FROM playback_queue WHERE user_id = ?
+		ORDER BY position`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queue := []models.QueueItem{}
+	for rows.Next() {
+		var item models.QueueItem
+		if err := rows.Scan(&item.ID, &item.TrackID, &item.Position, &item.AddedAt); err != nil {
+			continue
+		}
+		queue = append(queue, item)
+	}
+	return queue, rows.Err()
+}
+
+func (s *mysqlPlayer) Enqueue(userID, trackID int) error {
+	_, err := s.db.Exec(`
+		INSERT INTO playback_queue (user_id, track_id, position)
+		SELECT ?, ?, COALESCE(MAX(position), -1) + 1 FROM playback_queue WHERE user_id = ?`,
+		userID, trackID, userID)
+	return err
+}
+
+func (s *mysqlPlayer) RemoveFromQueue(userID int, itemID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var position int
+	err = tx.QueryRow("SELECT position FROM playback_queue WHERE id = ? AND user_id = ? FOR UPDATE", itemID, userID).Scan(&position)
+	if err != nil {
+		return notFound(err)
+	}
+	if _, err := tx.Exec("DELETE FROM playback_queue WHERE id = ?", itemID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE playback_queue SET position = position - 1 WHERE user_id = ? AND position > ?", userID, position); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MoveInQueue rewrites the positions of the whole queue densely
+func (s *mysqlPlayer) MoveInQueue(userID int, itemID int64, position int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id FROM playback_queue WHERE user_id = ? ORDER BY position FOR UPDATE", userID)
+	if err != nil {
+		return err
+	}
+	order := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		order = append(order, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	order, err = moveInOrder(order, itemID, position)
+	if err != nil {
+		return err
+	}
+	for i, id := range order {
+		if _, err := tx.Exec("UPDATE playback_queue SET position = ? WHERE id = ?", i, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,705 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"spotify-clone/models"
+	"spotify-clone/shuffle"
+	"strings"
+)
+
+// playlistColumns select a playlist with its follower count; the single
+// placeholder is the viewer for is_following
+const playlistColumns = `p.id, p.user_id, u.display_name, p.name, COALESCE(p.description, ''), p.is_public,
+	COALESCE(p.cover_url, ''), p.created_at, p.updated_at,
+	EXISTS(SELECT 1 FROM smart_playlists sp WHERE sp.playlist_id = p.id),
+	(SELECT COUNT(*) FROM playlist_followers pf WHERE pf.playlist_id = p.id),
+	EXISTS(SELECT 1 FROM playlist_followers pf WHERE pf.playlist_id = p.id AND pf.user_id = ?)`
+
+type mysqlPlaylists struct {
+	db *sql.DB
+}
+
+func scanPlaylist(row rowScanner) (models.Playlist, error) {
+	var playlist models.Playlist
+	err := row.Scan(&playlist.ID, &playlist.UserID, &playlist.OwnerName, &playlist.Name, &playlist.Description,
+		&playlist.IsPublic, &playlist.CoverURL, &playlist.CreatedAt, &playlist.UpdatedAt,
+		&playlist.IsSmart, &playlist.FollowerCount, &playlist.IsFollowing)
+	return playlist, err
+}
+
+func (s *mysqlPlaylists) Create(playlist *models.Playlist) error {
+	result, err := s.db.Exec(`
+		INSERT INTO playlists (user_id, name, description, is_public)
+		VALUES (?, ?, ?, ?)`,
+		playlist.UserID, playlist.Name, playlist.Description, playlist.IsPublic)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	playlist.ID = int(id)
+	return nil
+}
+
+func (s *mysqlPlaylists) Get(id, viewerID int) (*models.Playlist, error) {
+	playlist, err := scanPlaylist(s.db.QueryRow(`
+		SELECT `+playlistColumns+`
+		FROM playlists p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.id = ?`, viewerID, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	playlist.TrackIDs = []int{}
+	return &playlist, nil
+}
+
+func (s *mysqlPlaylists) Update(id int, name, description string, isPublic bool) error {
+	_, err := s.db.Exec(`
+		UPDATE playlists
+		SET name = ?, description = ?, is_public = ?, updated_at = NOW()
+		WHERE id = ?`,
+		name, description, isPublic, id)
+	return err
+}
+
+func (s *mysqlPlaylists) Delete(id int) error {
+	// CASCADE deletes the playlist's tracks, collaborators and followers
+	_, err := s.db.Exec("DELETE FROM playlists WHERE id = ?", id)
+	return err
+}
+
+func (s *mysqlPlaylists) ListForUser(userID int) ([]models.Playlist, error) {
+	rows, err := s.db.Query(`
+		SELECT `+playlistColumns+`
+		FROM playlists p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.user_id = ?
+		   OR p.id IN (SELECT playlist_id FROM playlist_collaborators WHERE user_id = ?)
+		   OR (p.is_public = TRUE AND p.id IN (SELECT playlist_id FROM playlist_followers WHERE user_id = ?))
+		ORDER BY p.updated_at DESC`, userID, userID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	playlists := []models.Playlist{}
+	for rows.Next() {
+		if playlist, err := scanPlaylist(rows); err == nil {
+			playlists = append(playlists, playlist)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range playlists {
+		playlists[i].TrackIDs = []int{}
+		if playlists[i].IsSmart {
+			continue
+		}
+		ids, err := queryIDs(s.db, "SELECT track_id FROM playlist_tracks WHERE playlist_id = ? ORDER BY position", playlists[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		playlists[i].TrackIDs = ids
+	}
+	return playlists, nil
+}
+
+func (s *mysqlPlaylists) SearchPublic(query string, limit int) ([]models.Playlist, error) {
+	rows, err := s.db.Query(`
+		SELECT p.id, p.user_id, u.display_name, p.name, COALESCE(p.description, ''),
+		       COALESCE(p.cover_url, ''), p.created_at, p.updated_at,
+		       (SELECT COUNT(*) FROM playlist_followers pf WHERE pf.playlist_id = p.id) AS follower_count
+		FROM playlists p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.is_public = TRUE AND p.name LIKE ?
+		ORDER BY follower_count DESC
+		LIMIT ?`, "%"+query+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := []models.Playlist{}
+	for rows.Next() {
+		playlist := models.Playlist{IsPublic: true, TrackIDs: []int{}}
+		err := rows.Scan(
+			&playlist.ID, &playlist.UserID, &playlist.OwnerName, &playlist.Name,
+			&playlist.Description, &playlist.CoverURL, &playlist.CreatedAt, &playlist.UpdatedAt,
+			&playlist.FollowerCount,
+		)
+		if err == nil {
+			playlists = append(playlists, playlist)
+		}
+	}
+	return playlists, rows.Err()
+}
+
+// query runs a query selecting playlistColumns
+func (s *mysqlPlaylists) query(query string, args ...interface{}) ([]models.Playlist, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := []models.Playlist{}
+	for rows.Next() {
+		if playlist, err := scanPlaylist(rows); err == nil {
+			playlist.TrackIDs = []int{}
+			playlists = append(playlists, playlist)
+		}
+	}
+	return playlists, rows.Err()
+}
+
+func (s *mysqlPlaylists) BrowsePublic(search, sort string, limit, offset int) ([]models.Playlist, error) {
+	query := `
+		SELECT ` + playlistColumns + `
+		FROM playlists p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.is_public = TRUE`
+	args := []interface{}{0}
+	if search != "" {
+		query += " AND (p.name LIKE ? OR p.description LIKE ?)"
+		searchParam := "%" + search + "%"
+		args = append(args, searchParam, searchParam)
+	}
+	if sort == "recent" {
+		query += " ORDER BY p.updated_at DESC"
+	} else {
+		query += " ORDER BY (SELECT COUNT(*) FROM playlist_followers pf WHERE pf.playlist_id = p.id) DESC, p.updated_at DESC"
+	}
+	query += " LIMIT ? OFFSET ?"
+	return s.query(query, append(args, limit, offset)...)
+}
+
+func (s *mysqlPlaylists) PublicByOwner(ownerID int) ([]models.Playlist, error) {
+	return s.query(`
+		SELECT `+playlistColumns+`
+		FROM playlists p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.user_id = ? AND p.is_public = TRUE
+		ORDER BY p.updated_at DESC`, 0, ownerID)
+}
+
+func (s *mysqlPlaylists) Access(id, userID int) (*PlaylistAccess, error) {
+	var access PlaylistAccess
+	err := s.db.QueryRow(`
+		SELECT p.user_id, p.is_public,
+		       EXISTS(SELECT 1 FROM playlist_collaborators pc WHERE pc.playlist_id = p.id AND pc.user_id = ?),
+		       EXISTS(SELECT 1 FROM smart_playlists sp WHERE sp.playlist_id = p.id)
+		FROM playlists p WHERE p.id = ?`, userID, id).Scan(
+		&access.OwnerID, &access.IsPublic, &access.IsCollaborator, &access.IsSmart)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &access, nil
+}
+
+func (s *mysqlPlaylists) Collaborators(id int) ([]models.PlaylistCollaborator, error) {
+	rows, err := s.db.Query(`
+		SELECT u.id, u.username, u.display_name, pc.added_at
+		FROM playlist_collaborators pc
+		JOIN users u ON pc.user_id = u.id
+		WHERE pc.playlist_id = ?
+		ORDER BY pc.added_at`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collaborators := []models.PlaylistCollaborator{}
+	for rows.Next() {
+		var collaborator models.PlaylistCollaborator
+		if err := rows.Scan(&collaborator.UserID, &collaborator.Username, &collaborator.DisplayName, &collaborator.AddedAt); err == nil {
+			collaborators = append(collaborators, collaborator)
+		}
+	}
+	return collaborators, rows.Err()
+}
+
+func (s *mysqlPlaylists) Tracks(id int) ([]models.Track, error) {
+	return queryTracks(s.db, "SELECT "+trackColumns+`
+		FROM playlist_tracks pt
+		JOIN tracks t ON pt.track_id = t.id
+		`+trackJoins+`
+		WHERE pt.playlist_id = ?
+		ORDER BY pt.position`, id)
+}
+
+func (s *mysqlPlaylists) Items(id int) ([]models.Track, []models.PlaylistItem, error) {
+	rows, err := s.db.Query("SELECT "+trackColumns+`,
+		       pt.position, pt.added_by, COALESCE(u.display_name, ''), pt.added_at
+		FROM playlist_tracks pt
+		JOIN tracks t ON pt.track_id = t.id
+		`+trackJoins+`
+		LEFT JOIN users u ON pt.added_by = u.id
+		WHERE pt.playlist_id = ?
+		ORDER BY pt.position`, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	tracks := []models.Track{}
+	items := []models.PlaylistItem{}
+	for rows.Next() {
+		var item models.PlaylistItem
+		var addedBy sql.NullInt64
+		track, err := scanTrack(rows, &item.Position, &addedBy, &item.AddedByName, &item.AddedAt)
+		if err != nil {
+			return nil, nil, err
+		}
+		item.TrackID = track.ID
+		if addedBy.Valid {
+			id := int(addedBy.Int64)
+			item.AddedBy = &id
+		}
+		tracks = append(tracks, track)
+		items = append(items, item)
+	}
+	return tracks, items, rows.Err()
+}
+
+func (s *mysqlPlaylists) AddTrack(id, trackID, addedBy int) error {
+	var maxPosition int
+	if err := s.db.QueryRow(
+		"SELECT COALESCE(MAX(position), -1) FROM playlist_tracks WHERE playlist_id = ?", id).Scan(&maxPosition); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO playlist_tracks (playlist_id, track_id, position, added_by)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE position = VALUES(position)`,
+		id, trackID, maxPosition+1, addedBy)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("UPDATE playlists SET updated_at = NOW() WHERE id = ?", id)
+	return err
+}
+
+func (s *mysqlPlaylists) RemoveTrack(id, trackID int) error {
+	if _, err := s.db.Exec("DELETE FROM playlist_tracks WHERE playlist_id = ? AND track_id = ?", id, trackID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("UPDATE playlists SET updated_at = NOW() WHERE id = ?", id)
+	return err
+}
+
+func (s *mysqlPlaylists) MoveTrack(id, trackID, position int) ([]int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Load the current order, locking the rows so concurrent edits queue up
+	rows, err := tx.Query("SELECT track_id FROM playlist_tracks WHERE playlist_id = ? ORDER BY position FOR UPDATE", id)
+	if err != nil {
+		return nil, err
+	}
+	order := []int{}
+	for rows.Next() {
+		var trackID int
+		rows.Scan(&trackID)
+		order = append(order, trackID)
+	}
+	rows.Close()
+
+	order, err = moveInOrder(order, trackID, position)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rewrite positions densely so gaps left by removals disappear
+	for i, trackID := range order {
+		if _, err := tx.Exec("UPDATE playlist_tracks SET position = ? WHERE playlist_id = ? AND track_id = ?", i, id, trackID); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec("UPDATE playlists SET updated_at = NOW() WHERE id = ?", id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (s *mysqlPlaylists) CreateWithTracks(playlist *models.Playlist, trackIDs []int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO playlists (user_id, name, description, is_public)
+		VALUES (?, ?, ?, ?)`,
+		playlist.UserID, playlist.Name, playlist.Description, playlist.IsPublic)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for position, trackID := range trackIDs {
+		_, err := tx.Exec(`
+			INSERT INTO playlist_tracks (playlist_id, track_id, position, added_by)
+			VALUES (?, ?, ?, ?)`,
+			id, trackID, position, playlist.UserID)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	playlist.ID = int(id)
+	return nil
+}
+
+func (s *mysqlPlaylists) Follow(id, userID int) error {
+	_, err := s.db.Exec("INSERT IGNORE INTO playlist_followers (playlist_id, user_id) VALUES (?, ?)", id, userID)
+	return err
+}
+
+func (s *mysqlPlaylists) Unfollow(id, userID int) error {
+	result, err := s.db.Exec("DELETE FROM playlist_followers WHERE playlist_id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mysqlPlaylists) FollowerCount(id int) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM playlist_followers WHERE playlist_id = ?", id).Scan(&count)
+	return count, err
+}
+
+func (s *mysqlPlaylists) AddCollaborator(id, userID int) error {
+	_, err := s.db.Exec("INSERT IGNORE INTO playlist_collaborators (playlist_id, user_id) VALUES (?, ?)", id, userID)
+	return err
+}
+
+func (s *mysqlPlaylists) RemoveCollaborator(id, userID int) error {
+	result, err := s.db.Exec("DELETE FROM playlist_collaborators WHERE playlist_id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mysqlPlaylists) SetCoverURL(id int, coverURL string, edited bool) error {
+	query := "UPDATE playlists SET cover_url = NULLIF(?, '') WHERE id = ?"
+	if !edited {
+		// ON UPDATE would bump updated_at; a regenerated cover is not a user edit
+		query = "UPDATE playlists SET cover_url = NULLIF(?, ''), updated_at = updated_at WHERE id = ?"
+	}
+	_, err := s.db.Exec(query, coverURL, id)
+	return err
+}
+
+func (s *mysqlPlaylists) CreateSmart(playlist *models.Playlist, def models.SmartPlaylistDefinition) error {
+	definition, err := json.Marshal(def)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO playlists (user_id, name, description, is_public)
+		VALUES (?, ?, ?, ?)`,
+		playlist.UserID, playlist.Name, playlist.Description, playlist.IsPublic)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO smart_playlists (playlist_id, definition) VALUES (?, ?)", id, string(definition)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	playlist.ID = int(id)
+	playlist.IsSmart = true
+	return nil
+}
+
+func (s *mysqlPlaylists) SmartDefinition(id int) (*models.SmartPlaylistDefinition, error) {
+	var raw []byte
+	if err := s.db.QueryRow("SELECT definition FROM smart_playlists WHERE playlist_id = ?", id).Scan(&raw); err != nil {
+		return nil, notFound(err)
+	}
+
+	var def models.SmartPlaylistDefinition
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+func (s *mysqlPlaylists) SetSmartDefinition(id int, def models.SmartPlaylistDefinition) error {
+	definition, err := json.Marshal(def)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec("UPDATE smart_playlists SET definition = ? WHERE playlist_id = ?", string(definition), id); err != nil {
+		return err
+	}
+	_, err = s.db.Exec("UPDATE playlists SET updated_at = NOW() WHERE id = ?", id)
+	return err
+}
+
+func (s *mysqlPlaylists) SmartTracks(def *models.SmartPlaylistDefinition, listenerID int) ([]models.Track, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	for _, rule := range def.Rules {
+		condition, ruleArgs, err := smartRuleCondition(rule, listenerID)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, ruleArgs...)
+	}
+
+	joiner := " AND "
+	if def.Match == "any" {
+		joiner = " OR "
+	}
+	query := "SELECT " + trackColumns + " FROM tracks t " + trackJoins + `
+		LEFT JOIN track_stats ts ON t.id = ts.track_id
+		WHERE (` + strings.Join(conditions, joiner) + ")"
+
+	if def.OrderBy == "random" {
+		// Shuffle every match in a fixed order with the stored seed, so the
+		// order holds until the rules change
+		tracks, err := queryTracks(s.db, query+" ORDER BY t.id", args...)
+		if err != nil {
+			return nil, err
+		}
+		return spreadTracks(tracks, def.Seed, def.Limit), nil
+	}
+
+	switch def.OrderBy {
+	case "play_count":
+		query += " ORDER BY COALESCE(ts.recommendable_play_count, 0) DESC, t.id"
+	case "title":
+		query += " ORDER BY t.title, t.id"
+	case "recently_added":
+		query += " ORDER BY t.created_at DESC, t.id"
+	default:
+		query += " ORDER BY t.release_date DESC, t.id"
+	}
+	query += " LIMIT ?"
+	return queryTracks(s.db, query, append(args, def.Limit)...)
+}
+
+// spreadTracks orders tracks sorted by ID with shuffle.Spread and keeps the
+// first limit. Both stores order random smart playlists this way.
+func spreadTracks(tracks []models.Track, seed int64, limit int) []models.Track {
+	byID := map[int]models.Track{}
+	items := make([]shuffle.Item, len(tracks))
+	for i, track := range tracks {
+		byID[track.ID] = track
+		items[i] = shuffle.Item{ID: track.ID, ArtistID: track.ArtistID, AlbumID: track.AlbumID}
+	}
+
+	spread := []models.Track{}
+	for _, item := range shuffle.Spread(items, seed) {
+		if len(spread) == limit {
+			break
+		}
+		spread = append(spread, byID[item.ID])
+	}
+	return spread
+}
+
+// smartRuleCondition translates a rule into a SQL condition over tracks t and
+// track_stats ts
+func smartRuleCondition(rule models.SmartPlaylistRule, listenerID int) (string, []interface{}, error) {
+	switch rule.Field {
+	case "genre":
+		placeholders, args := inPlaceholders(rule.Values)
+		switch rule.Operator {
+		case "in":
+			return "t.genre IN (" + placeholders + ")", args, nil
+		case "not_in":
+			return "(t.genre IS NULL OR t.genre NOT IN (" + placeholders + "))", args, nil
+		}
+
+	case "artist":
+		placeholders, args := inPlaceholders(rule.IDs)
+		switch rule.Operator {
+		case "in":
+			return "t.artist_id IN (" + placeholders + ")", args, nil
+		case "not_in":
+			return "t.artist_id NOT IN (" + placeholders + ")", args, nil
+		}
+
+	case "release_date":
+		switch rule.Operator {
+		case "after":
+			return "t.release_date > ?", []interface{}{rule.Date}, nil
+		case "before":
+			return "t.release_date < ?", []interface{}{rule.Date}, nil
+		}
+
+	case "play_count":
+		switch rule.Operator {
+		case "gt":
+			return "COALESCE(ts.recommendable_play_count, 0) > ?", []interface{}{rule.Number}, nil
+		case "lt":
+			return "COALESCE(ts.recommendable_play_count, 0) < ?", []interface{}{rule.Number}, nil
+		}
+
+	case "played_by_me":
+		recentPlay := "EXISTS(SELECT 1 FROM plays p WHERE p.track_id = t.id AND p.user_id = ? AND p.played_at >= NOW() - INTERVAL ? DAY)"
+		switch rule.Operator {
+		case "within_days", "not_within_days":
+			// The reader may not see the listener's plays, so neither side matches
+			if listenerID == 0 {
+				return "FALSE", nil, nil
+			}
+			if rule.Operator == "not_within_days" {
+				recentPlay = "NOT " + recentPlay
+			}
+			return recentPlay, []interface{}{listenerID, rule.Number}, nil
+		}
+	}
+	return "", nil, fmt.Errorf("unsupported smart playlist rule %s %s", rule.Field, rule.Operator)
+}
+
+// moveInOrder moves id to position within order, a list of tracks or queue
+// items. Returns ErrNotFound when id is not in order.
+func moveInOrder[T comparable](order []T, id T, position int) ([]T, error) {
+	from := -1
+	for i, v := range order {
+		if v == id {
+			from = i
+			break
+		}
+	}
+	if from == -1 {
+		return nil, ErrNotFound
+	}
+
+	if position >= len(order) {
+		position = len(order) - 1
+	}
+	order = append(order[:from], order[from+1:]...)
+	return append(order[:position], append([]T{id}, order[position:]...)...), nil
+}
+
+type mysqlInvites struct {
+	db *sql.DB
+}
+
+func (s *mysqlInvites) Create(invite models.PlaylistInvite, createdBy int) error {
+	_, err := s.db.Exec(`
+		INSERT INTO playlist_invites (token, playlist_id, created_by, expires_at)
+		VALUES (?, ?, ?, ?)`,
+		invite.Token, invite.PlaylistID, createdBy, invite.ExpiresAt)
+	return err
+}
+
+func (s *mysqlInvites) Get(token string) (*models.PlaylistInvite, error) {
+	var invite models.PlaylistInvite
+	err := s.db.QueryRow(
+		"SELECT token, playlist_id, expires_at, created_at FROM playlist_invites WHERE token = ?", token,
+	).Scan(&invite.Token, &invite.PlaylistID, &invite.ExpiresAt, &invite.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &invite, nil
+}
+
+func (s *mysqlInvites) Active(playlistID int) ([]models.PlaylistInvite, error) {
+	rows, err := s.db.Query(`
+		SELECT token, playlist_id, expires_at, created_at
+		FROM playlist_invites
+		WHERE playlist_id = ? AND expires_at > NOW()
+		ORDER BY created_at DESC`, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []models.PlaylistInvite{}
+	for rows.Next() {
+		var invite models.PlaylistInvite
+		if err := rows.Scan(&invite.Token, &invite.PlaylistID, &invite.ExpiresAt, &invite.CreatedAt); err == nil {
+			invites = append(invites, invite)
+		}
+	}
+	return invites, rows.Err()
+}
+
+func (s *mysqlInvites) Revoke(playlistID int, token string) error {
+	result, err := s.db.Exec("DELETE FROM playlist_invites WHERE playlist_id = ? AND token = ?", playlistID, token)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type mysqlCovers struct {
+	db *sql.DB
+}
+
+func (s *mysqlCovers) Get(playlistID int) (PlaylistCover, error) {
+	var generated, hash, uploaded sql.NullString
+	err := s.db.QueryRow(
+		"SELECT generated_file, source_hash, uploaded_file FROM playlist_covers WHERE playlist_id = ?", playlistID,
+	).Scan(&generated, &hash, &uploaded)
+	if err != nil && err != sql.ErrNoRows {
+		return PlaylistCover{}, err
+	}
+	return PlaylistCover{GeneratedFile: generated.String, SourceHash: hash.String, UploadedFile: uploaded.String}, nil
+}
+
+func (s *mysqlCovers) SetUploaded(playlistID int, file string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO playlist_covers (playlist_id, uploaded_file) VALUES (?, NULLIF(?, ''))
+		ON DUPLICATE KEY UPDATE uploaded_file = VALUES(uploaded_file)`,
+		playlistID, file)
+	return err
+}
+
+func (s *mysqlCovers) SetGenerated(playlistID int, file, sourceHash string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO playlist_covers (playlist_id, generated_file, source_hash) VALUES (?, NULLIF(?, ''), ?)
+		ON DUPLICATE KEY UPDATE generated_file = VALUES(generated_file), source_hash = VALUES(source_hash)`,
+		playlistID, file, sourceHash)
+	return err
+}
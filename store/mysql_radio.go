@@ -0,0 +1,223 @@
+package store
+
+import (
+	"database/sql"
+	"spotify-clone/models"
+	"time"
+)
+
+const radioColumns = `id, seed_type, seed_id, COALESCE(seed_genre, ''), name, tracks_served, created_at, updated_at`
+
+type mysqlRadio struct {
+	db *sql.DB
+}
+
+// scanRadioStation reads a row of radioColumns
+func scanRadioStation(row rowScanner) (*models.RadioStation, error) {
+	var station models.RadioStation
+	var seedID sql.NullInt64
+	err := row.Scan(&station.ID, &station.SeedType, &seedID, &station.SeedGenre, &station.Name,
+		&station.TracksServed, &station.CreatedAt, &station.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	station.SeedID = nullableInt(seedID)
+	return &station, nil
+}
+
+func (s *mysqlRadio) Create(userID int, station *models.RadioStation) error {
+	var seedID interface{}
+	if station.SeedID != nil {
+		seedID = *station.SeedID
+	}
+	result, err := s.db.Exec(
+		"INSERT INTO radio_stations (user_id, seed_type, seed_id, seed_genre, name) VALUES (?, ?, ?, NULLIF(?, ''), ?)",
+		userID, station.SeedType, seedID, station.SeedGenre, station.Name)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	station.ID = int(id)
+	return nil
+}
+
+func (s *mysqlRadio) Get(id, userID int) (*models.RadioStation, error) {
+	return scanRadioStation(s.db.QueryRow("SELECT "+radioColumns+" FROM radio_stations WHERE id = ? AND user_id = ?", id, userID))
+}
+
+func (s *mysqlRadio) Delete(id, userID int) error {
+	result, err := s.db.Exec("DELETE FROM radio_stations WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mysqlRadio) Served(id, userID, trackID int) (bool, error) {
+	var served bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM radio_station_tracks rt
+			JOIN radio_stations rs ON rs.id = rt.station_id
+			WHERE rt.station_id = ? AND rs.user_id = ? AND rt.track_id = ?)`,
+		id, userID, trackID).Scan(&served)
+	return served, err
+}
+
+func (s *mysqlRadio) RecentTracks(id, limit int) ([]int, error) {
+	return queryIDs(s.db, `
+		SELECT track_id FROM radio_station_tracks
+		WHERE station_id = ?
+		ORDER BY batch DESC, served_at DESC
+		LIMIT ?`, id, limit)
+}
+
+// NextBatch holds the station row locked while pick runs, which serializes
+// concurrent batches of the same station. pick reads candidates outside the
+// transaction, so ForgetBatches must not run inside it either.
+func (s *mysqlRadio) NextBatch(id, userID int, pick func(station *models.RadioStation, batch int) ([]int, error)) (*models.RadioStation, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	station, err := scanRadioStation(tx.QueryRow(
+		"SELECT "+radioColumns+" FROM radio_stations WHERE id = ? AND user_id = ? FOR UPDATE", id, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	var batch int
+	if err := tx.QueryRow(
+		"SELECT COALESCE(MAX(batch), 0) FROM radio_station_tracks WHERE station_id = ?", id).Scan(&batch); err != nil {
+		return nil, err
+	}
+	batch++
+
+	trackIDs, err := pick(station, batch)
+	if err != nil {
+		return nil, err
+	}
+	for _, trackID := range trackIDs {
+		if _, err := tx.Exec(
+			"INSERT INTO radio_station_tracks (station_id, track_id, batch) VALUES (?, ?, ?)",
+			id, trackID, batch); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(
+		"UPDATE radio_stations SET tracks_served = tracks_served + ? WHERE id = ?", len(trackIDs), id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	station.TracksServed += len(trackIDs)
+	return station, nil
+}
+
+func (s *mysqlRadio) ForgetBatches(id, batch int) error {
+	_, err := s.db.Exec("DELETE FROM radio_station_tracks WHERE station_id = ? AND batch < ?", id, batch)
+	return err
+}
+
+func (s *mysqlRadio) RecordSkip(userID, trackID int) error {
+	_, err := s.db.Exec("INSERT INTO track_skips (user_id, track_id) VALUES (?, ?)", userID, trackID)
+	return err
+}
+
+func (s *mysqlRadio) RecentSkipsByArtist(userID int, since time.Time) (map[int]int, error) {
+	rows, err := s.db.Query(`
+		SELECT t.artist_id, COUNT(*) FROM track_skips sk
+		JOIN tracks t ON t.id = sk.track_id
+		WHERE sk.user_id = ? AND sk.skipped_at >= ?
+		GROUP BY t.artist_id`, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skips := map[int]int{}
+	for rows.Next() {
+		var artistID, count int
+		if err := rows.Scan(&artistID, &count); err == nil {
+			skips[artistID] = count
+		}
+	}
+	return skips, rows.Err()
+}
+
+func (s *mysqlRadio) Stations(userID int) ([]models.ExportedRadioStation, error) {
+	rows, err := s.db.Query("SELECT "+radioColumns+" FROM radio_stations WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	stations := []models.ExportedRadioStation{}
+	for rows.Next() {
+		station, err := scanRadioStation(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stations = append(stations, models.ExportedRadioStation{RadioStation: *station, Tracks: []models.ExportedRadioTrack{}})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range stations {
+		rows, err := s.db.Query(`
+			SELECT track_id, batch, served_at FROM radio_station_tracks
+			WHERE station_id = ?
+			ORDER BY batch, served_at, track_id`, stations[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var track models.ExportedRadioTrack
+			if err := rows.Scan(&track.TrackID, &track.Batch, &track.ServedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			stations[i].Tracks = append(stations[i].Tracks, track)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return stations, nil
+}
+
+func (s *mysqlRadio) Skips(userID int) ([]models.ExportedSkip, error) {
+	rows, err := s.db.Query(`
+		SELECT sk.skipped_at, t.id, t.title, ar.name
+		FROM track_skips sk
+		JOIN tracks t ON t.id = sk.track_id
+		JOIN artists ar ON ar.id = t.artist_id
+		WHERE sk.user_id = ?
+		ORDER BY sk.skipped_at, sk.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skips := []models.ExportedSkip{}
+	for rows.Next() {
+		var skip models.ExportedSkip
+		if err := rows.Scan(&skip.SkippedAt, &skip.TrackID, &skip.Title, &skip.Artist); err != nil {
+			return nil, err
+		}
+		skips = append(skips, skip)
+	}
+	return skips, rows.Err()
+}
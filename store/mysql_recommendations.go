@@ -0,0 +1,171 @@
+package store
+
+import (
+	"database/sql"
+)
+
+type mysqlRecommendations struct {
+	db *sql.DB
+}
+
+// candidateCondition turns filter into an "AND ..." condition on a track aliased t
+func candidateCondition(filter CandidateFilter) (string, []interface{}) {
+	condition := ""
+	args := []interface{}{}
+	if filter.StationID != 0 {
+		condition += `
+		AND NOT EXISTS (SELECT 1 FROM radio_station_tracks rt WHERE rt.station_id = ? AND rt.track_id = t.id)`
+		args = append(args, filter.StationID)
+	}
+	if filter.SkippedBy != 0 {
+		condition += `
+		AND NOT EXISTS (
+			SELECT 1 FROM track_skips sk
+			WHERE sk.user_id = ? AND sk.track_id = t.id AND sk.skipped_at >= ?)`
+		args = append(args, filter.SkippedBy, filter.SkippedSince)
+	}
+	return condition, args
+}
+
+// queryScored reads rows of track id, artist id and score
+func (s *mysqlRecommendations) queryScored(query string, args ...interface{}) ([]ScoredTrack, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tracks := []ScoredTrack{}
+	for rows.Next() {
+		var track ScoredTrack
+		if err := rows.Scan(&track.ID, &track.ArtistID, &track.Score); err == nil {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks, rows.Err()
+}
+
+func (s *mysqlRecommendations) Similar(seeds []int, filter CandidateFilter, limit int) ([]ScoredTrack, error) {
+	if len(seeds) == 0 {
+		return []ScoredTrack{}, nil
+	}
+	seedPlaceholders, seedArgs := inPlaceholders(seeds)
+
+	// Resolve the seeds' artists and genres up front instead of once per candidate
+	rows, err := s.db.Query("SELECT DISTINCT artist_id, genre FROM tracks WHERE id IN ("+seedPlaceholders+")", seedArgs...)
+	if err != nil {
+		return nil, err
+	}
+	artistSet, genreSet := map[int]bool{}, map[string]bool{}
+	artistIDs, genres := []int{}, []string{}
+	for rows.Next() {
+		var artistID int
+		var genre sql.NullString
+		if err := rows.Scan(&artistID, &genre); err != nil {
+			continue
+		}
+		if !artistSet[artistID] {
+			artistSet[artistID] = true
+			artistIDs = append(artistIDs, artistID)
+		}
+		if genre.Valid && genre.String != "" && !genreSet[genre.String] {
+			genreSet[genre.String] = true
+			genres = append(genres, genre.String)
+		}
+	}
+	rows.Close()
+	// None of the seeds exist
+	if len(artistIDs) == 0 {
+		return []ScoredTrack{}, nil
+	}
+
+	artists, args := inPlaceholders(artistIDs)
+	artistScore := "CASE WHEN t.artist_id IN (" + artists + ") THEN 3 ELSE 0 END"
+	genreScore := "0"
+	if len(genres) > 0 {
+		placeholders, genreArgs := inPlaceholders(genres)
+		genreScore = "CASE WHEN t.genre IN (" + placeholders + ") THEN 2 ELSE 0 END"
+		args = append(args, genreArgs...)
+	}
+	condition, conditionArgs := candidateCondition(filter)
+
+	query := `
+		SELECT t.id, t.artist_id,
+		       (` + artistScore + `) + (` + genreScore + `) + COALESCE(LN(1 + co.listeners) * 2, 0) AS score
+		FROM tracks t
+		LEFT JOIN (
+			SELECT p.track_id, COUNT(DISTINCT p.user_id) AS listeners
+			FROM plays p
+			WHERE p.user_id IN (
+				SELECT s.user_id FROM plays s
+				WHERE s.track_id IN (` + seedPlaceholders + `) AND s.played_at >= NOW() - INTERVAL 180 DAY
+			)
+			  AND p.played_at >= NOW() - INTERVAL 180 DAY AND ` + recommendablePlays + `
+			GROUP BY p.track_id
+		) co ON co.track_id = t.id
+		WHERE t.id NOT IN (` + seedPlaceholders + `) ` + condition + `
+		HAVING score > 0
+		ORDER BY score DESC, t.id
+		LIMIT ?`
+	args = append(args, seedArgs...)
+	args = append(args, seedArgs...)
+	args = append(args, conditionArgs...)
+	args = append(args, limit)
+	return s.queryScored(query, args...)
+}
+
+func (s *mysqlRecommendations) Popular(filter CandidateFilter, limit int) ([]ScoredTrack, error) {
+	condition, args := candidateCondition(filter)
+	return s.queryScored(`
+		SELECT t.id, t.artist_id, COALESCE(ts.recommendable_play_count, 0) AS score
+		FROM tracks t
+		LEFT JOIN track_stats ts ON ts.track_id = t.id
+		WHERE TRUE `+condition+`
+		ORDER BY score DESC, t.created_at DESC
+		LIMIT ?`, append(args, limit)...)
+}
+
+func (s *mysqlRecommendations) GenreTop(genre string, limit int) ([]int, error) {
+	return queryIDs(s.db, `
+		SELECT t.id FROM tracks t
+		LEFT JOIN track_stats ts ON ts.track_id = t.id
+		WHERE t.genre = ?
+		ORDER BY COALESCE(ts.recommendable_play_count, 0) DESC, t.id
+		LIMIT ?`, genre, limit)
+}
+
+// ForLibrary weighs favorite artists 3, saved tracks and albums 2, and each play
+// in the last 90 days 1
+func (s *mysqlRecommendations) ForLibrary(userID, limit int) ([]int, error) {
+	return queryIDs(s.db, `
+		SELECT t.id
+		FROM tracks t
+		LEFT JOIN (
+			SELECT artist_id, SUM(w) AS weight FROM (
+				SELECT artist_id, 3 AS w FROM user_favorite_artists WHERE user_id = ?
+				UNION ALL
+				SELECT t.artist_id, 2 FROM user_saved_tracks st JOIN tracks t ON st.track_id = t.id WHERE st.user_id = ?
+				UNION ALL
+				SELECT al.artist_id, 2 FROM user_saved_albums sa JOIN albums al ON sa.album_id = al.id WHERE sa.user_id = ?
+				UNION ALL
+				SELECT t.artist_id, 1 FROM plays p JOIN tracks t ON p.track_id = t.id
+				WHERE p.user_id = ? AND p.played_at >= NOW() - INTERVAL 90 DAY AND `+recommendablePlays+`
+			) artist_signals GROUP BY artist_id
+		) aa ON aa.artist_id = t.artist_id
+		LEFT JOIN (
+			SELECT genre, SUM(w) AS weight FROM (
+				SELECT genre, 2 AS w FROM user_favorite_genres WHERE user_id = ?
+				UNION ALL
+				SELECT t.genre, 2 FROM user_saved_tracks st JOIN tracks t ON st.track_id = t.id WHERE st.user_id = ?
+				UNION ALL
+				SELECT t.genre, 1 FROM plays p JOIN tracks t ON p.track_id = t.id
+				WHERE p.user_id = ? AND p.played_at >= NOW() - INTERVAL 90 DAY AND `+recommendablePlays+`
+			) genre_signals WHERE genre IS NOT NULL GROUP BY genre
+		) ga ON ga.genre = t.genre
+		LEFT JOIN track_stats ts ON ts.track_id = t.id
+		WHERE t.id NOT IN (SELECT track_id FROM user_saved_tracks WHERE user_id = ?)
+		  AND (aa.weight IS NOT NULL OR ga.weight IS NOT NULL)
+		ORDER BY COALESCE(aa.weight, 0) + COALESCE(ga.weight, 0) * 0.5 + LN(1 + COALESCE(ts.recommendable_play_count, 0)) * 0.1 DESC, t.id
+		LIMIT ?`,
+		userID, userID, userID, userID, userID, userID, userID, userID, limit)
+}
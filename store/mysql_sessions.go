@@ -0,0 +1,200 @@
+package store
+
+import (
+	"database/sql"
+	"spotify-clone/models"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type mysqlSessions struct {
+	db *sql.DB
+}
+
+func (s *mysqlSessions) Create(hostID int, code string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO listening_sessions (host_id, code) VALUES (?, ?)", hostID, code)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return 0, ErrConflict
+		}
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("INSERT INTO listening_session_members (session_id, user_id) VALUES (?, ?)", id, hostID); err != nil {
+		return 0, err
+	}
+	return int(id), tx.Commit()
+}
+
+// session reads the session of the query, which selects s.id, s.code,
+// s.created_at and the host's summary
+func (s *mysqlSessions) session(query string, args ...interface{}) (*models.ListeningSession, error) {
+	session := &models.ListeningSession{}
+	err := s.db.QueryRow(`
+		SELECT s.id, s.code, s.created_at, u.id, u.username, u.display_name, COALESCE(u.profile_picture_url, '')
+		`+query, args...).Scan(&session.ID, &session.Code, &session.CreatedAt,
+		&session.Host.ID, &session.Host.Username, &session.Host.DisplayName, &session.Host.ProfilePictureURL)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return session, nil
+}
+
+func (s *mysqlSessions) Get(id int) (*models.ListeningSession, error) {
+	return s.session(`
+		FROM listening_sessions s
+		JOIN users u ON u.id = s.host_id
+		WHERE s.id = ?`, id)
+}
+
+func (s *mysqlSessions) Active(userID int) (*models.ListeningSession, error) {
+	return s.session(`
+		FROM listening_session_members m
+		JOIN listening_sessions s ON s.id = m.session_id
+		JOIN users u ON u.id = s.host_id
+		WHERE m.user_id = ? AND m.left_at IS NULL AND s.ended_at IS NULL
+		LIMIT 1`, userID)
+}
+
+func (s *mysqlSessions) ByCode(code string) (*models.ListeningSession, error) {
+	return s.session(`
+		FROM listening_sessions s
+		JOIN users u ON u.id = s.host_id
+		WHERE s.code = ? AND s.ended_at IS NULL`, code)
+}
+
+func (s *mysqlSessions) Hosted(hostID int) (*models.ListeningSession, error) {
+	return s.session(`
+		FROM listening_sessions s
+		JOIN users u ON u.id = s.host_id
+		WHERE s.host_id = ? AND s.ended_at IS NULL`, hostID)
+}
+
+func (s *mysqlSessions) Join(sessionID, userID int) error {
+	_, err := s.db.Exec(`
+		INSERT INTO listening_session_members (session_id, user_id) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE left_at = NULL, joined_at = NOW()`,
+		sessionID, userID)
+	return err
+}
+
+func (s *mysqlSessions) Leave(sessionID, userID int) error {
+	_, err := s.db.Exec(
+		"UPDATE listening_session_members SET left_at = NOW() WHERE session_id = ? AND user_id = ?",
+		sessionID, userID)
+	return err
+}
+
+func (s *mysqlSessions) End(sessionID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE listening_sessions SET ended_at = NOW() WHERE id = ?", sessionID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE listening_session_members SET left_at = NOW() WHERE session_id = ? AND left_at IS NULL", sessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *mysqlSessions) IsMember(sessionID, userID int) (bool, error) {
+	var member bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM listening_session_members
+		              WHERE session_id = ? AND user_id = ? AND left_at IS NULL)`,
+		sessionID, userID).Scan(&member)
+	return member, err
+}
+
+func (s *mysqlSessions) Members(sessionID int) ([]models.UserSummary, error) {
+	rows, err := s.db.Query(`
+		SELECT u.id, u.username, u.display_name, COALESCE(u.profile_picture_url, '')
+		FROM listening_session_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.session_id = ? AND m.left_at IS NULL
+		ORDER BY m.joined_at`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.UserSummary{}
+	for rows.Next() {
+		var member models.UserSummary
+		if err := rows.Scan(&member.ID, &member.Username, &member.DisplayName, &member.ProfilePictureURL); err == nil {
+			members = append(members, member)
+		}
+	}
+	return members, rows.Err()
+}
+
+func (s *mysqlSessions) Vote(sessionID, userID int) error {
+	_, err := s.db.Exec(`
+		INSERT IGNORE INTO listening_session_skip_votes (session_id, skip_round, user_id)
+		SELECT id, skip_round, ? FROM listening_sessions WHERE id = ?`,
+		userID, sessionID)
+	return err
+}
+
+func (s *mysqlSessions) SkipVotes(sessionID int) (int, error) {
+	var votes int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM listening_session_skip_votes v
+		JOIN listening_sessions s ON s.id = v.session_id AND s.skip_round = v.skip_round
+		JOIN listening_session_members m ON m.session_id = v.session_id AND m.user_id = v.user_id AND m.left_at IS NULL
+		WHERE v.session_id = ?`, sessionID).Scan(&votes)
+	return votes, err
+}
+
+func (s *mysqlSessions) NextRound(sessionID int) error {
+	_, err := s.db.Exec("UPDATE listening_sessions SET skip_round = skip_round + 1 WHERE id = ?", sessionID)
+	return err
+}
+
+func (s *mysqlSessions) Memberships(userID int) ([]models.ExportedSessionMembership, error) {
+	rows, err := s.db.Query(`
+		SELECT s.id, s.code, u.id, u.username, u.display_name, COALESCE(u.profile_picture_url, ''),
+		       m.joined_at, m.left_at, s.ended_at
+		FROM listening_session_members m
+		JOIN listening_sessions s ON s.id = m.session_id
+		JOIN users u ON u.id = s.host_id
+		WHERE m.user_id = ?
+		ORDER BY m.joined_at, s.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := []models.ExportedSessionMembership{}
+	for rows.Next() {
+		var membership models.ExportedSessionMembership
+		var leftAt, endedAt sql.NullTime
+		err := rows.Scan(&membership.SessionID, &membership.Code,
+			&membership.Host.ID, &membership.Host.Username, &membership.Host.DisplayName, &membership.Host.ProfilePictureURL,
+			&membership.JoinedAt, &leftAt, &endedAt)
+		if err != nil {
+			return nil, err
+		}
+		if leftAt.Valid {
+			membership.LeftAt = &leftAt.Time
+		}
+		if endedAt.Valid {
+			membership.EndedAt = &endedAt.Time
+		}
+		memberships = append(memberships, membership)
+	}
+	return memberships, rows.Err()
+}
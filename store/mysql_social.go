@@ -0,0 +1,306 @@
+package store
+
+import (
+	"database/sql"
+	"spotify-clone/models"
+	"time"
+)
+
+type mysqlSocial struct {
+	db *sql.DB
+}
+
+func (s *mysqlSocial) Follow(followerID, followeeID int) error {
+	_, err := s.db.Exec(
+		"INSERT IGNORE INTO user_follows (follower_id, followee_id) VALUES (?, ?)",
+		followerID, followeeID)
+	return err
+}
+
+func (s *mysqlSocial) Unfollow(followerID, followeeID int) error {
+	result, err := s.db.Exec(
+		"DELETE FROM user_follows WHERE follower_id = ? AND followee_id = ?",
+		followerID, followeeID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mysqlSocial) IsFollowing(followerID, followeeID int) (bool, error) {
+	var following bool
+	err := s.db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM user_follows WHERE follower_id = ? AND followee_id = ?)",
+		followerID, followeeID).Scan(&following)
+	return following, err
+}
+
+func (s *mysqlSocial) FollowCounts(userID int) (followers, following int, err error) {
+	err = s.db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM user_follows WHERE followee_id = ?),
+		       (SELECT COUNT(*) FROM user_follows WHERE follower_id = ?)`,
+		userID, userID).Scan(&followers, &following)
+	return followers, following, err
+}
+
+func (s *mysqlSocial) Followers(userID, limit, offset int) ([]models.UserSummary, int, error) {
+	// rows where the user is followed, listing who follows
+	return s.follows("f.followee_id", "f.follower_id", userID, limit, offset)
+}
+
+func (s *mysqlSocial) Following(userID, limit, offset int) ([]models.UserSummary, int, error) {
+	return s.follows("f.follower_id", "f.followee_id", userID, limit, offset)
+}
+
+// follows lists the users in userColumn of the follows whose matchColumn is userID
+func (s *mysqlSocial) follows(matchColumn, userColumn string, userID, limit, offset int) ([]models.UserSummary, int, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, COALESCE(u.profile_picture_url, ''), f.created_at
+		FROM user_follows f
+		JOIN users u ON u.id = ` + userColumn + `
+		WHERE ` + matchColumn + ` = ?
+		ORDER BY f.created_at DESC`
+	args := []interface{}{userID}
+	if limit >= 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, offset)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []models.UserSummary{}
+	for rows.Next() {
+		var user models.UserSummary
+		var followedAt time.Time
+		if err := rows.Scan(&user.ID, &user.Username, &user.DisplayName, &user.ProfilePictureURL, &followedAt); err != nil {
+			continue
+		}
+		user.FollowedAt = &followedAt
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM user_follows f WHERE "+matchColumn+" = ?", userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (s *mysqlSocial) RecordActivity(activity NewActivity) error {
+	_, err := s.db.Exec(`
+		INSERT INTO user_activities (user_id, type, playlist_id, track_id, artist_id)
+		VALUES (?, ?, ?, ?, ?)`,
+		activity.UserID, activity.Type,
+		nullIfZero(activity.PlaylistID), nullIfZero(activity.TrackID), nullIfZero(activity.ArtistID))
+	return err
+}
+
+func (s *mysqlSocial) Feed(userID int, before int64, since time.Time, limit int) ([]models.Activity, error) {
+	// The lateral subquery reads at most limit entries per followee backwards
+	// along idx_user_recent, so only followees × limit rows are merged and
+	// sorted, however much activity there is. Entries only grow older as the
+	// index is walked, so the window is applied after the per-followee limit.
+	// The README shows the expected EXPLAIN.
+	rows, err := s.db.Query(`
+		SELECT a.id, a.type, a.created_at,
+		       u.id, u.username, u.display_name, COALESCE(u.profile_picture_url, ''),
+		       a.playlist_id, COALESCE(a.playlist_name, ''),
+		       a.track_id, COALESCE(t.title, ''),
+		       a.artist_id, COALESCE(ar.name, '')
+		FROM user_follows f
+		JOIN LATERAL (
+			SELECT fa.id, fa.user_id, fa.type, fa.created_at,
+			       fa.playlist_id, p.name AS playlist_name, fa.track_id, fa.artist_id
+			FROM user_activities fa
+			LEFT JOIN playlists p ON p.id = fa.playlist_id
+			WHERE fa.user_id = f.followee_id
+			  AND fa.id < ?
+			  AND (fa.playlist_id IS NULL OR p.is_public = TRUE)
+			ORDER BY fa.id DESC
+			LIMIT ?
+		) a ON TRUE
+		JOIN users u ON u.id = a.user_id
+		LEFT JOIN tracks t ON t.id = a.track_id
+		LEFT JOIN artists ar ON ar.id = a.artist_id
+		WHERE f.follower_id = ?
+		  AND a.created_at >= ?
+		ORDER BY a.id DESC
+		LIMIT ?`,
+		before, limit, userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanActivities(rows)
+}
+
+// Activities names private playlists too; the log is the user's own
+func (s *mysqlSocial) Activities(userID int) ([]models.Activity, error) {
+	rows, err := s.db.Query(`
+		SELECT a.id, a.type, a.created_at,
+		       u.id, u.username, u.display_name, COALESCE(u.profile_picture_url, ''),
+		       a.playlist_id, COALESCE(p.name, ''),
+		       a.track_id, COALESCE(t.title, ''),
+		       a.artist_id, COALESCE(ar.name, '')
+		FROM user_activities a
+		JOIN users u ON u.id = a.user_id
+		LEFT JOIN playlists p ON p.id = a.playlist_id
+		LEFT JOIN tracks t ON t.id = a.track_id
+		LEFT JOIN artists ar ON ar.id = a.artist_id
+		WHERE a.user_id = ?
+		ORDER BY a.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanActivities(rows)
+}
+
+// scanActivities reads and closes rows of activity id, type and time, the
+// user's summary, and the playlist, track and artist IDs each with its name
+func scanActivities(rows *sql.Rows) ([]models.Activity, error) {
+	defer rows.Close()
+
+	activities := []models.Activity{}
+	for rows.Next() {
+		var activity models.Activity
+		var playlistID, trackID, artistID sql.NullInt64
+		err := rows.Scan(&activity.ID, &activity.Type, &activity.CreatedAt,
+			&activity.User.ID, &activity.User.Username, &activity.User.DisplayName, &activity.User.ProfilePictureURL,
+			&playlistID, &activity.PlaylistName,
+			&trackID, &activity.TrackTitle,
+			&artistID, &activity.ArtistName)
+		if err != nil {
+			continue
+		}
+		activity.PlaylistID = nullableInt(playlistID)
+		activity.TrackID = nullableInt(trackID)
+		activity.ArtistID = nullableInt(artistID)
+		activities = append(activities, activity)
+	}
+	return activities, rows.Err()
+}
+
+// recommendablePlays is a condition on a plays row aliased p that leaves out plays of
+// users who excluded their listening from recommendations. Every query that derives
+// recommendations from plays must include it; queries ranking by popularity use
+// track_stats.recommendable_play_count, which leaves out the same plays.
+const recommendablePlays = `NOT EXISTS (
+	SELECT 1 FROM user_privacy_settings ps
+	WHERE ps.user_id = p.user_id AND ps.exclude_from_recommendations = TRUE)`
+
+type mysqlPrivacy struct {
+	db *sql.DB
+}
+
+func (s *mysqlPrivacy) Get(userID int) (models.PrivacySettings, error) {
+	settings := models.PrivacySettings{ShowPlaylists: true, ShowFollows: true, ShowTopArtists: true}
+	var until sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT show_playlists, show_follows, show_top_artists,
+		       hide_listening_activity, exclude_from_recommendations, private_session_until
+		FROM user_privacy_settings WHERE user_id = ?`, userID).Scan(
+		&settings.ShowPlaylists, &settings.ShowFollows, &settings.ShowTopArtists,
+		&settings.HideListeningActivity, &settings.ExcludeFromRecommendations, &until)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+	if until.Valid && until.Time.After(time.Now()) {
+		settings.PrivateSession = true
+		settings.PrivateSessionUntil = &until.Time
+	}
+	return settings, nil
+}
+
+func (s *mysqlPrivacy) Update(userID int, settings models.PrivacySettings) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var wasExcluded bool
+	err = tx.QueryRow(
+		"SELECT exclude_from_recommendations FROM user_privacy_settings WHERE user_id = ? FOR UPDATE",
+		userID).Scan(&wasExcluded)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_privacy_settings (user_id, show_playlists, show_follows, show_top_artists,
+		                                   hide_listening_activity, exclude_from_recommendations)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE show_playlists = VALUES(show_playlists),
+		                        show_follows = VALUES(show_follows),
+		                        show_top_artists = VALUES(show_top_artists),
+		                        hide_listening_activity = VALUES(hide_listening_activity),
+		                        exclude_from_recommendations = VALUES(exclude_from_recommendations)`,
+		userID, settings.ShowPlaylists, settings.ShowFollows, settings.ShowTopArtists,
+		settings.HideListeningActivity, settings.ExcludeFromRecommendations)
+	if err != nil {
+		return err
+	}
+	if wasExcluded != settings.ExcludeFromRecommendations {
+		if err := recountRecommendablePlays(tx, userID, settings.ExcludeFromRecommendations); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// recountRecommendablePlays moves a user's past plays out of or back into
+// track_stats.recommendable_play_count when they change exclude_from_recommendations.
+// New plays are counted by the after_play_insert trigger.
+func recountRecommendablePlays(tx *sql.Tx, userID int, excluded bool) error {
+	sign := 1
+	if excluded {
+		sign = -1
+	}
+	_, err := tx.Exec(`
+		UPDATE track_stats ts
+		JOIN (SELECT track_id, COUNT(*) AS plays FROM plays WHERE user_id = ? GROUP BY track_id) up
+		  ON up.track_id = ts.track_id
+		SET ts.recommendable_play_count = GREATEST(ts.recommendable_play_count + ? * up.plays, 0)`,
+		userID, sign)
+	return err
+}
+
+func (s *mysqlPrivacy) SetPrivateSession(userID int, until *time.Time) error {
+	if until == nil {
+		_, err := s.db.Exec("UPDATE user_privacy_settings SET private_session_until = NULL WHERE user_id = ?", userID)
+		return err
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO user_privacy_settings (user_id, private_session_until)
+		VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE private_session_until = VALUES(private_session_until)`,
+		userID, *until)
+	return err
+}
+
+func nullIfZero(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func nullableInt(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
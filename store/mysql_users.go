@@ -0,0 +1,296 @@
+package store
+
+import (
+	"database/sql"
+	"spotify-clone/models"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const userColumns = `id, email, password, username, display_name, COALESCE(profile_picture_url, ''),
+	theme, language, explicit_content, created_at, updated_at`
+
+type mysqlUsers struct {
+	db *sql.DB
+}
+
+func (s *mysqlUsers) Create(user *models.User) error {
+	var existingID int
+	err := s.db.QueryRow("SELECT id FROM users WHERE email = ? OR username = ?", user.Email, user.Username).Scan(&existingID)
+	if err == nil {
+		return ErrConflict
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO users (email, password, username, display_name, theme, language, explicit_content)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		user.Email, user.Password, user.Username, user.DisplayName, user.Theme, user.Language, user.ExplicitContent)
+	if err != nil {
+		// A concurrent registration took the email or username first
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return ErrConflict
+		}
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for _, genre := range user.FavoriteGenres {
+		if _, err := tx.Exec("INSERT INTO user_favorite_genres (user_id, genre) VALUES (?, ?)", id, genre); err != nil {
+			return err
+		}
+	}
+	for _, artistID := range user.FavoriteArtists {
+		if _, err := tx.Exec("INSERT IGNORE INTO user_favorite_artists (user_id, artist_id) VALUES (?, ?)", id, artistID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	user.ID = int(id)
+	return nil
+}
+
+func (s *mysqlUsers) get(where string, arg interface{}) (*models.User, error) {
+	var user models.User
+	err := s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE "+where, arg).Scan(
+		&user.ID, &user.Email, &user.Password, &user.Username, &user.DisplayName,
+		&user.ProfilePictureURL, &user.Theme, &user.Language, &user.ExplicitContent,
+		&user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	rows, err := s.db.Query("SELECT genre FROM user_favorite_genres WHERE user_id = ?", user.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var genre string
+		if rows.Scan(&genre) == nil {
+			user.FavoriteGenres = append(user.FavoriteGenres, genre)
+		}
+	}
+
+	if user.FavoriteArtists, err = s.FavoriteArtistIDs(user.ID); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *mysqlUsers) Get(id int) (*models.User, error) {
+	user, err := s.get("id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	return user, nil
+}
+
+func (s *mysqlUsers) GetByEmail(email string) (*models.User, error) {
+	return s.get("email = ?", email)
+}
+
+func (s *mysqlUsers) UpdatePreferences(id int, prefs models.UserPreferences) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE users
+		SET theme = ?, language = ?, explicit_content = ?, updated_at = NOW()
+		WHERE id = ?`,
+		prefs.Theme, prefs.Language, prefs.ExplicitContent, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM user_favorite_genres WHERE user_id = ?", id); err != nil {
+		return err
+	}
+	for _, genre := range prefs.PreferredGenres {
+		if _, err := tx.Exec("INSERT INTO user_favorite_genres (user_id, genre) VALUES (?, ?)", id, genre); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *mysqlUsers) FavoriteArtistIDs(id int) ([]int, error) {
+	return queryIDs(s.db, "SELECT artist_id FROM user_favorite_artists WHERE user_id = ? ORDER BY id", id)
+}
+
+func (s *mysqlUsers) FavoriteArtists(id int) ([]models.Artist, error) {
+	return (&mysqlArtists{db: s.db}).query(`
+		SELECT `+artistColumns+`
+		FROM user_favorite_artists fa
+		JOIN artists ar ON fa.artist_id = ar.id
+		WHERE fa.user_id = ?
+		ORDER BY fa.id`, id)
+}
+
+func (s *mysqlUsers) SetFavoriteArtists(id int, artistIDs []int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM user_favorite_artists WHERE user_id = ?", id); err != nil {
+		return err
+	}
+	for _, artistID := range artistIDs {
+		if _, err := tx.Exec("INSERT IGNORE INTO user_favorite_artists (user_id, artist_id) VALUES (?, ?)", id, artistID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *mysqlUsers) AddFavoriteArtist(id, artistID, limit int) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Locking the user row serializes concurrent adds so the count stays valid
+	var locked int
+	if err := tx.QueryRow("SELECT id FROM users WHERE id = ? FOR UPDATE", id).Scan(&locked); err != nil {
+		if err == sql.ErrNoRows {
+			return false, ErrNotFound
+		}
+		return false, err
+	}
+	var count int
+	var exists bool
+	err = tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(artist_id = ?), 0) > 0
+		FROM user_favorite_artists WHERE user_id = ?`, artistID, id).Scan(&count, &exists)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+	if count >= limit {
+		return false, ErrLimit
+	}
+
+	if _, err := tx.Exec("INSERT INTO user_favorite_artists (user_id, artist_id) VALUES (?, ?)", id, artistID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *mysqlUsers) RemoveFavoriteArtist(id, artistID int) error {
+	result, err := s.db.Exec("DELETE FROM user_favorite_artists WHERE user_id = ? AND artist_id = ?", id, artistID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *mysqlUsers) SavedTrackCount(id int) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM user_saved_tracks WHERE user_id = ?", id).Scan(&count)
+	return count, err
+}
+
+func (s *mysqlUsers) GetByUsername(username string) (*models.User, error) {
+	var user models.User
+	err := s.db.QueryRow(`
+		SELECT id, email, username, display_name, COALESCE(profile_picture_url, ''), created_at
+		FROM users WHERE username = ?`, username).Scan(
+		&user.ID, &user.Email, &user.Username, &user.DisplayName, &user.ProfilePictureURL, &user.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+type mysqlPlays struct {
+	db *sql.DB
+}
+
+func (s *mysqlPlays) Record(userID, trackID, durationPlayed int, completed bool) error {
+	// The after_play_insert trigger bumps track_stats
+	_, err := s.db.Exec(
+		"INSERT INTO plays (user_id, track_id, played_at, duration_played, completed) VALUES (?, ?, NOW(), ?, ?)",
+		userID, trackID, durationPlayed, completed)
+	return err
+}
+
+func (s *mysqlPlays) History(userID int) ([]models.ExportedPlay, error) {
+	rows, err := s.db.Query(`
+		SELECT p.played_at, t.id, t.title, ar.name, al.title,
+		       COALESCE(p.duration_played, 0), COALESCE(p.completed, FALSE)
+		FROM plays p
+		JOIN tracks t ON p.track_id = t.id
+		JOIN artists ar ON t.artist_id = ar.id
+		JOIN albums al ON t.album_id = al.id
+		WHERE p.user_id = ?
+		ORDER BY p.played_at, p.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plays := []models.ExportedPlay{}
+	for rows.Next() {
+		var play models.ExportedPlay
+		if err := rows.Scan(&play.PlayedAt, &play.TrackID, &play.Title, &play.Artist, &play.Album,
+			&play.DurationPlayed, &play.Completed); err != nil {
+			return nil, err
+		}
+		plays = append(plays, play)
+	}
+	return plays, rows.Err()
+}
+
+func (s *mysqlPlays) TopArtists(userID int, since time.Time, limit int) ([]models.TopArtist, error) {
+	rows, err := s.db.Query(`
+		SELECT a.id, a.name, COALESCE(a.bio, ''), COALESCE(a.image_url, ''), a.created_at,
+		       COUNT(*) AS play_count
+		FROM plays p
+		JOIN tracks t ON p.track_id = t.id
+		JOIN artists a ON t.artist_id = a.id
+		WHERE p.user_id = ? AND p.played_at >= ?
+		GROUP BY a.id, a.name, a.bio, a.image_url, a.created_at
+		ORDER BY play_count DESC, a.name
+		LIMIT ?`, userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artists := []models.TopArtist{}
+	for rows.Next() {
+		var artist models.TopArtist
+		if err := rows.Scan(&artist.ID, &artist.Name, &artist.Bio, &artist.ImageURL, &artist.CreatedAt, &artist.PlayCount); err != nil {
+			continue
+		}
+		artists = append(artists, artist)
+	}
+	return artists, rows.Err()
+}
@@ -0,0 +1,490 @@
+// Package store is the data layer behind the API. Handlers talk to the
+// interfaces below; NewMySQL provides the production implementation.
+package store
+
+import (
+	"errors"
+	"spotify-clone/models"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a row with the same unique key already exists
+	ErrConflict = errors.New("already exists")
+	// ErrLimit is returned when adding a row would exceed a per-user limit
+	ErrLimit = errors.New("limit reached")
+)
+
+// Stores bundles the stores handed to the handlers
+type Stores struct {
+	Tracks          TrackStore
+	Artists         ArtistStore
+	Albums          AlbumStore
+	Playlists       PlaylistStore
+	Invites         InviteStore
+	Covers          CoverStore
+	Users           UserStore
+	Plays           PlayStore
+	Files           FileStore
+	Library         LibraryStore
+	Social          SocialStore
+	Privacy         PrivacyStore
+	Player          PlayerStore
+	Sessions        SessionStore
+	Radio           RadioStore
+	Recommendations RecommendationStore
+	Accounts        AccountStore
+}
+
+// TrackFilter narrows TrackStore.List. Zero values do not filter.
+type TrackFilter struct {
+	Genre  string
+	Search string // matches the title or the artist name
+	Limit  int
+	Offset int
+}
+
+// NewTrack is a track to add to the catalog
+type NewTrack struct {
+	Title       string
+	ArtistID    int
+	AlbumID     int
+	Duration    int
+	Genre       string
+	ReleaseDate time.Time
+	FileURL     string
+	CoverURL    string
+}
+
+type TrackStore interface {
+	// List returns tracks, newest first
+	List(filter TrackFilter) ([]models.Track, error)
+	Get(id int) (*models.Track, error)
+	// GetMany returns the tracks in the order of ids, skipping unknown IDs
+	GetMany(ids []int) ([]models.Track, error)
+	// ByArtist returns an artist's tracks, latest release first
+	ByArtist(artistID int) ([]models.Track, error)
+	Search(query string, limit int) ([]models.Track, error)
+	// Add validates and inserts a track the way the add_track procedure does. A
+	// rejected track returns ID 0 and a status starting with "ERROR:".
+	Add(track NewTrack) (int, string, error)
+	// IDsByTitle finds an album's tracks by title, compared case-insensitively.
+	// The result is keyed by lower-cased title, the lowest ID winning.
+	IDsByTitle(albumID int, titles []string) (map[string]int, error)
+	// Update replaces the fields of a track. album_stats follows a change of
+	// album or duration, as the after_track_update trigger does.
+	Update(id int, track NewTrack) error
+	// Delete removes a track with its plays and playlist entries
+	Delete(id int) error
+	// AlbumTrackIDs returns an album's tracks in track order
+	AlbumTrackIDs(albumID int) ([]int, error)
+	// ArtistTrackIDs returns an artist's tracks, most played first
+	ArtistTrackIDs(artistID int) ([]int, error)
+	// IDByFileURL returns a track playing the file at url
+	IDByFileURL(url string) (int, error)
+	// MatchTitleOrArtist returns tracks whose title contains titleWord or, when
+	// artist is set, whose artist name contains artist
+	MatchTitleOrArtist(titleWord, artist string, limit int) ([]models.Track, error)
+}
+
+type ArtistStore interface {
+	// List returns artists by name
+	List(limit, offset int) ([]models.Artist, error)
+	Get(id int) (*models.Artist, error)
+	Search(query string, limit int) ([]models.Artist, error)
+	// Existing returns which of ids belong to an artist
+	Existing(ids []int) ([]int, error)
+	Stats(id int) (*models.ArtistStats, error)
+	// IDsByName finds artists by name, compared case-insensitively. The result
+	// is keyed by lower-cased name, the lowest ID winning.
+	IDsByName(names []string) (map[string]int, error)
+	// Create inserts the artist and sets its ID
+	Create(artist *models.Artist) error
+	// Popular returns the artists with the most recommendable plays, counting
+	// only tracks of genres when any are given
+	Popular(genres []string, limit int) ([]models.Artist, error)
+}
+
+type AlbumStore interface {
+	// List returns albums, latest release first
+	List(limit, offset int) ([]models.Album, error)
+	Get(id int) (*models.Album, error)
+	Search(query string, limit int) ([]models.Album, error)
+	// Stats reads the album_stats counters kept up to date as tracks come and go
+	Stats(id int) (*models.AlbumStats, error)
+	// Duration sums the durations of the album's tracks
+	Duration(id int) (int, error)
+	// IDsByTitle finds an artist's albums by title as TrackStore.IDsByTitle
+	// finds tracks
+	IDsByTitle(artistID int, titles []string) (map[string]int, error)
+	// Create inserts the album of an existing artist and sets its ID
+	Create(album *models.Album) error
+	SetCover(id int, coverURL string) error
+}
+
+// PlaylistAccess describes how a user relates to a playlist
+type PlaylistAccess struct {
+	OwnerID        int
+	IsPublic       bool
+	IsCollaborator bool
+	IsSmart        bool
+}
+
+func (a *PlaylistAccess) IsOwner(userID int) bool {
+	return a.OwnerID == userID
+}
+
+// CanEdit reports whether the user may add, remove or reorder tracks
+func (a *PlaylistAccess) CanEdit(userID int) bool {
+	return a.IsOwner(userID) || a.IsCollaborator
+}
+
+func (a *PlaylistAccess) CanRead(userID int) bool {
+	return a.IsPublic || a.CanEdit(userID)
+}
+
+type PlaylistStore interface {
+	// Create inserts the playlist and sets its ID
+	Create(playlist *models.Playlist) error
+	// Get returns a playlist without its tracks; IsFollowing is seen from viewerID
+	Get(id, viewerID int) (*models.Playlist, error)
+	Update(id int, name, description string, isPublic bool) error
+	Delete(id int) error
+	// ListForUser returns the playlists a user owns, collaborates on or follows,
+	// most recently updated first, with the track IDs of regular playlists
+	ListForUser(userID int) ([]models.Playlist, error)
+	// SearchPublic returns public playlists by name, most followed first
+	SearchPublic(query string, limit int) ([]models.Playlist, error)
+	// BrowsePublic returns public playlists whose name or description matches
+	// search, sorted "popular" (most followed first) or "recent"
+	BrowsePublic(search, sort string, limit, offset int) ([]models.Playlist, error)
+	// PublicByOwner returns a user's public playlists, most recently updated first
+	PublicByOwner(ownerID int) ([]models.Playlist, error)
+	Access(id, userID int) (*PlaylistAccess, error)
+	Collaborators(id int) ([]models.PlaylistCollaborator, error)
+	// Tracks returns the stored tracks of a regular playlist in order
+	Tracks(id int) ([]models.Track, error)
+	// Items is Tracks with who added each track and when
+	Items(id int) ([]models.Track, []models.PlaylistItem, error)
+	// AddTrack appends a track; a track already in the playlist moves to the end
+	AddTrack(id, trackID, addedBy int) error
+	RemoveTrack(id, trackID int) error
+	// MoveTrack moves a track to position and returns the new order. Positions
+	// past the end move the track to the end.
+	MoveTrack(id, trackID, position int) ([]int, error)
+	// CreateWithTracks creates a playlist holding trackIDs, added by its owner
+	CreateWithTracks(playlist *models.Playlist, trackIDs []int) error
+	// Follow adds the playlist to the user's followed playlists; following it
+	// again does nothing
+	Follow(id, userID int) error
+	// Unfollow returns ErrNotFound if the user does not follow the playlist
+	Unfollow(id, userID int) error
+	FollowerCount(id int) (int, error)
+	// AddCollaborator lets a user edit the playlist; adding them again does nothing
+	AddCollaborator(id, userID int) error
+	// RemoveCollaborator returns ErrNotFound if the user is no collaborator
+	RemoveCollaborator(id, userID int) error
+	// SetCoverURL points the playlist at its cover; an empty URL clears it.
+	// edited marks a change by the user, which bumps updated_at.
+	SetCoverURL(id int, coverURL string, edited bool) error
+	// CreateSmart creates a playlist whose tracks are computed from def
+	CreateSmart(playlist *models.Playlist, def models.SmartPlaylistDefinition) error
+	SmartDefinition(id int) (*models.SmartPlaylistDefinition, error)
+	// SetSmartDefinition replaces the rules and bumps updated_at
+	SetSmartDefinition(id int, def models.SmartPlaylistDefinition) error
+	// SmartTracks evaluates validated rules against the catalog. listenerID is
+	// the user that played_by_me rules refer to; 0 makes those rules match
+	// nothing, for readers who may not see the owner's listening.
+	SmartTracks(def *models.SmartPlaylistDefinition, listenerID int) ([]models.Track, error)
+}
+
+type InviteStore interface {
+	// Create stores an invite link to collaborate on a playlist
+	Create(invite models.PlaylistInvite, createdBy int) error
+	// Get returns an invite by token, expired or not
+	Get(token string) (*models.PlaylistInvite, error)
+	// Active returns the invites of a playlist that have not expired, newest first
+	Active(playlistID int) ([]models.PlaylistInvite, error)
+	// Revoke returns ErrNotFound if the playlist has no such invite
+	Revoke(playlistID int, token string) error
+}
+
+// PlaylistCover is a row of playlist_covers. Files are named relative to the
+// media directory; empty means none.
+type PlaylistCover struct {
+	GeneratedFile string
+	// SourceHash identifies the album covers GeneratedFile was built from
+	SourceHash   string
+	UploadedFile string
+}
+
+type CoverStore interface {
+	// Get returns the zero PlaylistCover for a playlist that has none
+	Get(playlistID int) (PlaylistCover, error)
+	// SetUploaded stores the user's cover; an empty file removes it
+	SetUploaded(playlistID int, file string) error
+	SetGenerated(playlistID int, file, sourceHash string) error
+}
+
+type UserStore interface {
+	// Create inserts the user, whose Password holds the bcrypt hash, with their
+	// favorite genres and artists and sets its ID. ErrConflict means the email
+	// or username is taken.
+	Create(user *models.User) error
+	// Get and GetByEmail return the user with favorite genres and artists. Only
+	// GetByEmail fills in the password hash.
+	Get(id int) (*models.User, error)
+	GetByEmail(email string) (*models.User, error)
+	UpdatePreferences(id int, prefs models.UserPreferences) error
+	// FavoriteArtistIDs returns favorites in the order they were added
+	FavoriteArtistIDs(id int) ([]int, error)
+	// FavoriteArtists is FavoriteArtistIDs with the artists' details
+	FavoriteArtists(id int) ([]models.Artist, error)
+	SetFavoriteArtists(id int, artistIDs []int) error
+	// AddFavoriteArtist appends an artist to the favorites, which are also the
+	// saved artists of the library, and reports whether it was new. ErrLimit
+	// means the user already has limit favorites.
+	AddFavoriteArtist(id, artistID, limit int) (bool, error)
+	// RemoveFavoriteArtist returns ErrNotFound if the artist is not a favorite
+	RemoveFavoriteArtist(id, artistID int) error
+	SavedTrackCount(id int) (int, error)
+	// GetByUsername returns the user without favorites or password hash
+	GetByUsername(username string) (*models.User, error)
+}
+
+type PlayStore interface {
+	// Record adds a play to the user's history and the track's play count
+	Record(userID, trackID, durationPlayed int, completed bool) error
+	// History returns every play of a user, oldest first
+	History(userID int) ([]models.ExportedPlay, error)
+	// TopArtists returns the artists the user played most since the given time
+	TopArtists(userID int, since time.Time, limit int) ([]models.TopArtist, error)
+}
+
+// ScannedFile is an audio file the library scanner has imported
+type ScannedFile struct {
+	// Path is relative to the library root, with forward slashes
+	Path    string
+	Size    int64
+	ModTime time.Time
+	// Hash is the hex SHA-256 of the file
+	Hash string
+	// TrackID is 0 once the track has been deleted
+	TrackID int
+}
+
+type FileStore interface {
+	// List returns every scanned file by path
+	List() ([]ScannedFile, error)
+	// Save inserts the file or replaces the one with the same path
+	Save(file ScannedFile) error
+	Delete(path string) error
+}
+
+// Library sections
+const (
+	LibraryTracks = "tracks"
+	LibraryAlbums = "albums"
+	// LibraryArtists are the user's favorite artists
+	LibraryArtists = "artists"
+)
+
+// LibraryFilter narrows and orders a library section. Zero values do not
+// filter; a negative Limit returns everything.
+type LibraryFilter struct {
+	// Sort is recent (the default) or one of title, artist, album and duration
+	// for tracks, title, artist and release_date for albums, name for artists
+	Sort     string
+	Genre    string
+	ArtistID int
+	// Search matches titles and artist names
+	Search string
+	Limit  int
+	Offset int
+}
+
+type LibraryStore interface {
+	// Save adds a track or an album; saving it again does nothing. Artists are
+	// saved with UserStore.AddFavoriteArtist, which enforces the limit.
+	Save(userID int, section string, id int) error
+	// Remove returns ErrNotFound if the item is not saved
+	Remove(userID int, section string, id int) error
+	// Contains returns which of ids are saved
+	Contains(userID int, section string, ids []int) ([]int, error)
+	Tracks(userID int, filter LibraryFilter) ([]models.SavedTrack, error)
+	Albums(userID int, filter LibraryFilter) ([]models.SavedAlbum, error)
+	Artists(userID int, filter LibraryFilter) ([]models.Artist, error)
+}
+
+// NewActivity is an entry for a user's activity log. Zero IDs are left out.
+type NewActivity struct {
+	UserID     int
+	Type       string
+	PlaylistID int
+	TrackID    int
+	ArtistID   int
+}
+
+type SocialStore interface {
+	// Follow makes followerID follow followeeID; following again does nothing
+	Follow(followerID, followeeID int) error
+	// Unfollow returns ErrNotFound if followerID does not follow followeeID
+	Unfollow(followerID, followeeID int) error
+	IsFollowing(followerID, followeeID int) (bool, error)
+	FollowCounts(userID int) (followers, following int, err error)
+	// Followers and Following return a page of users, most recently followed
+	// first, and how many there are in total. A negative limit returns all.
+	Followers(userID, limit, offset int) ([]models.UserSummary, int, error)
+	Following(userID, limit, offset int) ([]models.UserSummary, int, error)
+	RecordActivity(activity NewActivity) error
+	// Feed returns the activity of the users userID follows with an ID below
+	// before and created since the given time, newest first. Activity about
+	// private playlists is left out.
+	Feed(userID int, before int64, since time.Time, limit int) ([]models.Activity, error)
+	// Activities returns everything the user's activity log holds, oldest first
+	Activities(userID int) ([]models.Activity, error)
+}
+
+type PrivacyStore interface {
+	// Get returns the defaults, which show everything and record every play,
+	// for users who never changed them. PrivateSession is set while a private
+	// session runs.
+	Get(userID int) (models.PrivacySettings, error)
+	// Update saves the settings other than the private session. A change of
+	// ExcludeFromRecommendations moves the user's past plays out of or back
+	// into the recommendable play counts.
+	Update(userID int, settings models.PrivacySettings) error
+	// SetPrivateSession starts a private session lasting until the given time,
+	// or ends it when until is nil
+	SetPrivateSession(userID int, until *time.Time) error
+}
+
+// PlaybackTx is the queue as seen from inside PlayerStore.Update
+type PlaybackTx interface {
+	// PopQueue removes the first queued track and returns it; ok is false when
+	// the queue is empty
+	PopQueue() (trackID int, ok bool, err error)
+}
+
+type PlayerStore interface {
+	// State returns the stored playback state, or an idle one with repeat off
+	// for users who never played. ProgressMs is left for the caller to work out.
+	State(userID int) (*models.PlaybackState, error)
+	// Update loads the state, lets change modify it and saves it, holding the
+	// user's player locked throughout. An error from change discards the update.
+	Update(userID int, change func(tx PlaybackTx, state *models.PlaybackState) error) error
+	// Queue returns the queue in play order, without track details
+	Queue(userID int) ([]models.QueueItem, error)
+	// Enqueue appends a track to the end of the queue
+	Enqueue(userID, trackID int) error
+	// RemoveFromQueue and MoveInQueue return ErrNotFound for an item that is
+	// not in the user's queue. Positions past the end move the item to the end.
+	RemoveFromQueue(userID int, itemID int64) error
+	MoveInQueue(userID int, itemID int64, position int) error
+}
+
+type SessionStore interface {
+	// Create starts a session with the host as its first member and returns its
+	// ID. ErrConflict means the code is taken.
+	Create(hostID int, code string) (int, error)
+	// Get returns a session with its host but without members
+	Get(id int) (*models.ListeningSession, error)
+	// Active returns the running session the user is a member of
+	Active(userID int) (*models.ListeningSession, error)
+	// ByCode and Hosted return running sessions only
+	ByCode(code string) (*models.ListeningSession, error)
+	Hosted(hostID int) (*models.ListeningSession, error)
+	// Join adds a member, or brings back one who left
+	Join(sessionID, userID int) error
+	Leave(sessionID, userID int) error
+	// End closes the session for every member
+	End(sessionID int) error
+	IsMember(sessionID, userID int) (bool, error)
+	// Members returns the current members in the order they joined
+	Members(sessionID int) ([]models.UserSummary, error)
+	// Vote counts a member's vote to skip the current track once
+	Vote(sessionID, userID int) error
+	// SkipVotes counts the votes of current members for the current track
+	SkipVotes(sessionID int) (int, error)
+	// NextRound discards the skip votes as the session moves to another track
+	NextRound(sessionID int) error
+	// Memberships returns the sessions the user is or was a member of, in the
+	// order they joined
+	Memberships(userID int) ([]models.ExportedSessionMembership, error)
+}
+
+type RadioStore interface {
+	// Create inserts a station of the user and sets its ID
+	Create(userID int, station *models.RadioStation) error
+	// Get and Delete only find stations of the given user
+	Get(id, userID int) (*models.RadioStation, error)
+	Delete(id, userID int) error
+	// Served reports whether the user's station played the track
+	Served(id, userID, trackID int) (bool, error)
+	// RecentTracks returns the tracks the station played last
+	RecentTracks(id, limit int) ([]int, error)
+	// NextBatch locks the user's station and calls pick with it and the number
+	// of the batch about to be served, then records the picked tracks as that
+	// batch and returns the updated station
+	NextBatch(id, userID int, pick func(station *models.RadioStation, batch int) ([]int, error)) (*models.RadioStation, error)
+	// ForgetBatches lets the station play the tracks of batches before batch again
+	ForgetBatches(id, batch int) error
+	RecordSkip(userID, trackID int) error
+	// RecentSkipsByArtist counts the user's skips since the given time per artist
+	RecentSkipsByArtist(userID int, since time.Time) (map[int]int, error)
+	// Stations returns the user's stations with the tracks they served
+	Stations(userID int) ([]models.ExportedRadioStation, error)
+	// Skips returns every skip of the user, oldest first
+	Skips(userID int) ([]models.ExportedSkip, error)
+}
+
+// CandidateFilter narrows the tracks a RecommendationStore suggests. Zero
+// values do not filter.
+type CandidateFilter struct {
+	// StationID leaves out tracks the radio station already played
+	StationID int
+	// SkippedBy leaves out tracks the user skipped since SkippedSince
+	SkippedBy    int
+	SkippedSince time.Time
+}
+
+// ScoredTrack is a suggested track with its score, higher is better
+type ScoredTrack struct {
+	ID       int
+	ArtistID int
+	Score    float64
+}
+
+// RecommendationStore derives suggestions from plays, libraries and the
+// catalog. Plays of users who exclude their listening from recommendations
+// never count.
+type RecommendationStore interface {
+	// Similar scores tracks 3 for sharing an artist with a seed, 2 for sharing
+	// a genre, and up to about 2*ln(1+n) when n listeners of a seed played them
+	// in the last 180 days. Seeds themselves are never returned.
+	Similar(seeds []int, filter CandidateFilter, limit int) ([]ScoredTrack, error)
+	// Popular returns the most played tracks, newest first among equals
+	Popular(filter CandidateFilter, limit int) ([]ScoredTrack, error)
+	// GenreTop returns the most played tracks of a genre
+	GenreTop(genre string, limit int) ([]int, error)
+	// ForLibrary scores the tracks a user has not saved by how strongly their
+	// library and recent plays point at the track's artist and genre
+	ForLibrary(userID, limit int) ([]int, error)
+}
+
+type AccountStore interface {
+	// ScheduleDeletion schedules the user's account for deletion after grace.
+	// An account already scheduled keeps its original schedule.
+	ScheduleDeletion(userID int, grace time.Duration) (*models.AccountDeletion, error)
+	Deletion(userID int) (*models.AccountDeletion, error)
+	// CancelDeletion returns ErrNotFound if no deletion is pending
+	CancelDeletion(userID int) error
+	// DueDeletions returns the users whose grace period has ended
+	DueDeletions() ([]int, error)
+	// Purge deletes a user for good and returns the cover files of their
+	// playlists for the caller to remove. Plays are kept without the user so
+	// play counts and trending stay right; everything else the user owns goes.
+	Purge(userID int) ([]string, error)
+}